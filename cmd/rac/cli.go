@@ -1,18 +1,26 @@
 package main
 
 import (
+	"bufio"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
 	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/dashboard"
 	"github.com/innosat-mats/rac-extract-payload/internal/exports"
 	"github.com/innosat-mats/rac-extract-payload/internal/extractors"
+	"github.com/innosat-mats/rac-extract-payload/internal/ledger"
+	"github.com/innosat-mats/rac-extract-payload/internal/limits"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
 )
 
 // Version is the version of the source code
@@ -29,7 +37,26 @@ var skipTimeseries *bool
 var project *string
 var stdout *bool
 var parquet *bool
+var arrowFiles *bool
+var sqlitePath *string
+var sqliteImageFiles *bool
+var ndjsonPath *string
+var ndjsonImages *bool
+var pngDir *string
+var csvAppend *bool
+var csvConflict *string
+var csvSplit *string
+var imageWorkers *int
+var partition *string
+var partitionKey *string
+var streams = sinkStreams{}
+var parquetTuning = parquetOptions{}
 var dregsDir *string
+var calibrationFile *string
+var limitsFile *string
+var processingTime *string
+var ledgerPath *string
+var force *bool
 var version *bool
 
 // myUsage replaces default usage since it doesn't include information on non-flags
@@ -41,26 +68,25 @@ func myUsage() {
 		switch helpSection := strings.ToUpper(os.Args[2]); helpSection {
 		case "OUTPUT":
 			infoGeneral()
-		case "CCD":
-			infoCCD()
-		case "CPRU":
-			infoCPRU()
-		case "HTR":
-			infoHTR()
-		case "PWR":
-			infoPWR()
-		case "STAT":
-			infoSTAT()
-		case "TCV":
-			infoTCV()
-		case "PM":
-			infoPM()
 		case "PARQUET":
 			infoParquet()
+		case "ARROW", "FEATHER":
+			infoArrow()
+		case "SQLITE":
+			infoSQLite()
+		case "CALIBRATION":
+			infoCalibration()
+		case "LIMITS", "ALARMS":
+			infoLimits()
 		case "MATS", "SPACE", "M.A.T.S.", "SATELLITE":
 			infoSpace()
 		default:
-			fmt.Printf("\nUnrecognized help section %s\n", helpSection)
+			stream := timeseries.OutStreamFromName(helpSection)
+			if stream == timeseries.Unknown || stream == timeseries.ALARMS {
+				fmt.Printf("\nUnrecognized help section %s\n", helpSection)
+			} else {
+				infoStream(stream)
+			}
 		}
 		return
 	}
@@ -80,68 +106,503 @@ Tip for finding parsing errors:
 
 or if you want the Buffer contents which can be rather large if you are unlucky:
 	rac -stdout my.rac | grep -E -e".*Error:[^<]+" -o
+
+For machine readable output use -ndjson, where "-" is stdout, e.g.:
+	rac -ndjson - my.rac | jq 'select(.SID == "HTR") | .HTR1A'
+
+Parquet outputs can be merged into one file per partition with:
+	rac compact my-project
+
+With a ledger rac-files already processed are skipped and the ledger lists
+what was processed when, e.g.:
+	rac -parquet -project lake -ledger ledger.db racs/*.rac
+	rac ledger -since 2023-01-05 ledger.db
+
+New rac-files in a directory can be processed as they arrive with e.g.:
+	rac watch -in incoming -parquet -project lake -ledger ledger.db
+
+Rac-files can be decoded over HTTP, see rac serve -help, with:
+	rac serve -addr :8080
+
+RAMSES frames streamed by an EGSE can be processed live, writing outputs
+every ten minutes, see rac listen -help, with:
+	rac listen -parquet -project campaign -roll 10m tcp://:5000
+
+Add -dashboard :8081 to rac listen or rac watch to follow the housekeeping
+and CCD images in a browser.
+
+Parquet and csv outputs can be read back and filtered, see rac query -help,
+with e.g.:
+	rac query -stream HTR -from 2023-01-05T14:00:00Z -where HTR1A>20 lake
+
+Outputs of earlier runs can be converted between csv and parquet without the
+rac-files, see rac convert -help, with e.g.:
+	rac convert -to parquet -project lake old-csvs
+
+The columns of the outputs can be written as JSON Schema, markdown or parquet
+schema, see rac schema -help, with e.g.:
+	rac schema -format markdown > outputs.md
+
+Outputs can be combined into one pass over the rac-files and each can be
+limited to some timeseries, e.g.:
+	rac -parquet -project lake -png previews -ndjson alarms.ndjson -streams ndjson=ALARMS my.rac
 	`)
 }
 
-func getCallback(
-	toStdout bool,
-	toParquet bool,
-	project string,
-	skipImages bool,
-	skipTimeseries bool,
-	wg *sync.WaitGroup,
-) (common.Callback, common.CallbackTeardown, error) {
-	if project == "" && !toStdout {
+// outputs holds the requested outputs of a run, each becomes a sink of the
+// records
+type outputs struct {
+	stdout           bool
+	parquet          bool
+	arrow            bool
+	sqlite           string
+	sqliteImageFiles bool
+	ndjson           string
+	ndjsonImages     bool
+	png              string
+	project          string
+	skipImages       bool
+	skipTimeseries   bool
+	streams          sinkStreams
+	imageWorkers     int
+	csv              exports.CSVOptions
+	dashboard        *dashboard.Dashboard // Shows the latest records of rac watch and rac listen
+}
+
+// sinkStreams holds the streams each named sink is limited to
+type sinkStreams map[string][]timeseries.OutStream
+
+// sinkNames are the names used to limit the streams of sinks
+var sinkNames = []string{"stdout", "csv", "parquet", "arrow", "sqlite", "ndjson", "png"}
+
+func (streams sinkStreams) String() string {
+	var parts []string
+	for _, sink := range sinkNames {
+		if len(streams[sink]) == 0 {
+			continue
+		}
+		var names []string
+		for _, stream := range streams[sink] {
+			names = append(names, stream.String())
+		}
+		parts = append(parts, fmt.Sprintf("%v=%v", sink, strings.Join(names, ",")))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Set parses a value such as "ndjson=HTR,ALARMS"
+func (streams sinkStreams) Set(value string) error {
+	sink, names, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("expected sink=STREAM,STREAM but got '%v'", value)
+	}
+	known := false
+	for _, name := range sinkNames {
+		known = known || name == sink
+	}
+	if !known {
+		return fmt.Errorf("unknown sink '%v', expected one of %v", sink, strings.Join(sinkNames, ", "))
+	}
+	for _, name := range strings.Split(names, ",") {
+		stream := timeseries.OutStreamFromName(strings.TrimSpace(name))
+		if stream == timeseries.Unknown {
+			return fmt.Errorf("unknown stream '%v'", name)
+		}
+		streams[sink] = append(streams[sink], stream)
+	}
+	return nil
+}
+
+func (streams sinkStreams) filter(sink string) exports.Filter {
+	if len(streams[sink]) == 0 {
+		return nil
+	}
+	return exports.StreamFilter(streams[sink]...)
+}
+
+// parquetOptions holds the values of the parquet tuning flags by setting and
+// stream, values for all streams have an empty stream name
+type parquetOptions map[string]map[string]string
+
+// parquetOption is the flag of one parquet setting
+type parquetOption struct {
+	options parquetOptions
+	setting string
+}
+
+func (option parquetOption) String() string {
+	var parts []string
+	if value, ok := option.options[option.setting][""]; ok {
+		parts = append(parts, value)
+	}
+	for _, stream := range timeseries.Streams {
+		if value, ok := option.options[option.setting][stream.String()]; ok {
+			parts = append(parts, fmt.Sprintf("%v=%v", stream, value))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Set parses a value for all streams, such as "zstd", or for one stream,
+// such as "CCD=none"
+func (option parquetOption) Set(value string) error {
+	streamName := ""
+	if name, streamValue, ok := strings.Cut(value, "="); ok {
+		stream := timeseries.OutStreamFromName(strings.TrimSpace(name))
+		if stream == timeseries.Unknown {
+			return fmt.Errorf("unknown stream '%v'", name)
+		}
+		streamName = stream.String()
+		value = streamValue
+	}
+	var settings timeseries.ParquetSettings
+	if err := settings.Set(option.setting, value); err != nil {
+		return err
+	}
+	if option.options[option.setting] == nil {
+		option.options[option.setting] = make(map[string]string)
+	}
+	option.options[option.setting][streamName] = value
+	return nil
+}
+
+func setParquetTuning(options parquetOptions) error {
+	tuning := timeseries.ParquetTuning{
+		Default: timeseries.DefaultParquetSettings,
+		Streams: make(map[timeseries.OutStream]timeseries.ParquetSettings),
+	}
+	apply := func(settings *timeseries.ParquetSettings, streamName string) error {
+		for _, setting := range timeseries.ParquetSettingNames {
+			if value, ok := options[setting][streamName]; ok {
+				if err := settings.Set(setting, value); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := apply(&tuning.Default, ""); err != nil {
+		return err
+	}
+	for _, stream := range timeseries.Streams {
+		settings := tuning.Default
+		tuned := false
+		for _, setting := range timeseries.ParquetSettingNames {
+			_, ok := options[setting][stream.String()]
+			tuned = tuned || ok
+		}
+		if !tuned {
+			continue
+		}
+		if err := apply(&settings, stream.String()); err != nil {
+			return err
+		}
+		tuning.Streams[stream] = settings
+	}
+	timeseries.SetParquetTuning(tuning)
+	return nil
+}
+
+// validate checks that the outputs have what they need
+func (out outputs) validate() error {
+	if out.project == "" && (out.parquet || out.arrow) ||
+		out.project == "" && !out.stdout && out.sqlite == "" && out.ndjson == "" && out.png == "" &&
+			out.dashboard == nil {
 		flag.Usage()
 		fmt.Println("\nExpected a project")
-		return nil, nil, errors.New("invalid arguments")
+		return errors.New("invalid arguments")
+	}
+	return nil
+}
+
+func getCallback(out outputs) (common.Callback, common.CallbackTeardown, error) {
+	toDisk := out.project != "" && !out.parquet && !out.arrow
+	if err := out.validate(); err != nil {
+		return nil, nil, err
 	}
-	if skipTimeseries && (skipImages || toStdout) {
+	if out.skipTimeseries && (out.skipImages || out.stdout) {
 		fmt.Println("Nothing will be extracted, only validating integrity of rac-file(s)")
 	}
 
-	if toStdout {
-		callback, teardown := exports.StdoutCallbackFactory(os.Stdout, !skipTimeseries)
-		return callback, teardown, nil
-	} else if toParquet {
+	pool := exports.NewImagePool(out.imageWorkers)
+	var sinks []exports.Sink
+	fail := func(err error) (common.Callback, common.CallbackTeardown, error) {
+		for _, sink := range sinks {
+			sink.Teardown()
+		}
+		return nil, nil, err
+	}
+	addSink := func(name string, callback common.Callback, teardown common.CallbackTeardown) {
+		sinks = append(sinks, exports.Sink{
+			Callback: callback,
+			Teardown: teardown,
+			Filter:   out.streams.filter(name),
+		})
+	}
+	if out.stdout {
+		callback, teardown := exports.StdoutCallbackFactory(os.Stdout, !out.skipTimeseries)
+		addSink("stdout", callback, teardown)
+	}
+	if toDisk {
+		callback, teardown := exports.DiskCallbackFactory(
+			out.project,
+			!out.skipImages,
+			!out.skipTimeseries,
+			pool,
+			out.csv,
+		)
+		addSink("csv", callback, teardown)
+	}
+	if out.parquet {
 		callback, teardown := exports.ParquetCallbackFactory(
-			project,
-			wg,
+			out.project,
+			pool,
 		)
-		return callback, teardown, nil
+		addSink("parquet", callback, teardown)
 	}
-	callback, teardown := exports.DiskCallbackFactory(
-		project,
-		!skipImages,
-		!skipTimeseries,
-		wg,
-	)
+	if out.arrow {
+		callback, teardown := exports.ArrowCallbackFactory(
+			out.project,
+			pool,
+		)
+		addSink("arrow", callback, teardown)
+	}
+	if out.sqlite != "" {
+		callback, teardown, err := exports.SQLiteCallbackFactory(
+			out.sqlite,
+			!out.skipImages,
+			out.sqliteImageFiles,
+			pool,
+		)
+		if err != nil {
+			return fail(err)
+		}
+		addSink("sqlite", callback, teardown)
+	}
+	if out.ndjson == "-" {
+		callback, teardown := exports.NDJSONCallbackFactory(
+			os.Stdout,
+			out.ndjsonImages && !out.skipImages,
+			!out.skipTimeseries,
+			pool,
+		)
+		addSink("ndjson", callback, teardown)
+	} else if out.ndjson != "" {
+		callback, teardown, err := getNDJSONFileCallback(
+			out.ndjson,
+			out.ndjsonImages && !out.skipImages,
+			!out.skipTimeseries,
+			pool,
+		)
+		if err != nil {
+			return fail(err)
+		}
+		addSink("ndjson", callback, teardown)
+	}
+	if out.png != "" && !out.skipImages {
+		callback, teardown := exports.DiskCallbackFactory(
+			out.png,
+			true,
+			false,
+			pool,
+			exports.CSVOptions{},
+		)
+		addSink("png", callback, teardown)
+	}
+	if out.dashboard != nil {
+		callback, teardown := out.dashboard.CallbackFactory(pool)
+		addSink("dashboard", callback, teardown)
+	}
+	callback, teardown := exports.FanOutCallbackFactory(sinks)
 	return callback, teardown, nil
 }
 
+// describeRun returns the description of the run with the flags and
+// calibrations given
+func describeRun(flags *flag.FlagSet, calibrations aez.Calibrations) *common.RunDescription {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	var parameters []string
+	flags.Visit(func(f *flag.Flag) {
+		parameters = append(parameters, fmt.Sprintf("-%v=%v", f.Name, f.Value))
+	})
+	return &common.RunDescription{
+		Version:      common.FullVersion(),
+		Host:         host,
+		Parameters:   parameters,
+		Calibrations: calibrations.IDs(),
+	}
+}
+
+// skipProcessed returns a skip function for processFiles leaving out the
+// inputs done according to the ledger and repeated inputs, or nil if all
+// inputs should be processed
+func skipProcessed(processedLedger *ledger.Ledger, force bool) func(input common.InputDescription) bool {
+	if processedLedger == nil || force {
+		return nil
+	}
+	seen := make(map[string]bool)
+	return func(input common.InputDescription) bool {
+		if seen[input.SHA256] {
+			log.Printf("Skipping %v, it has the same content as an earlier input", input.Name)
+			return true
+		}
+		seen[input.SHA256] = true
+		done, err := processedLedger.Done(input.SHA256)
+		if err != nil {
+			log.Printf("Could not look up %v in the ledger: %v", input.Name, err)
+			return false
+		}
+		if done {
+			log.Printf("Skipping %v, it was already processed, use -force to process it again", input.Name)
+		}
+		return done
+	}
+}
+
+// getProcessingTime returns the processing time to record, now unless given
+func getProcessingTime(text string) (time.Time, error) {
+	if text == "" {
+		return time.Now(), nil
+	}
+	processed, err := time.Parse(time.RFC3339Nano, text)
+	if err != nil {
+		return processed, fmt.Errorf("invalid processing time '%v', use e.g. 2023-01-05T14:00:00Z", text)
+	}
+	return processed, nil
+}
+
+// processFiles extracts the files as processed by the run at the time,
+// leaving out those skip returns true for if skip is given
+//
+// The files are hashed before any is extracted so that the run lists all
+// inputs in its provenance.
 func processFiles(
 	extractor extractors.ExtractFunction,
 	inputFiles []string,
 	dregs extractors.Dregs,
+	calibrations aez.Calibrations,
 	callback common.Callback,
+	run *common.RunDescription,
+	processed time.Time,
+	skip func(input common.InputDescription) bool,
 ) error {
-	batch := make([]extractors.StreamBatch, len(inputFiles))
-	for n, filename := range inputFiles {
+	var batch []extractors.StreamBatch
+	run.Inputs = nil
+	for _, filename := range inputFiles {
 		f, err := os.Open(filename)
 		if err != nil {
 			return err
 		}
 		defer f.Close()
-		batch[n] = extractors.StreamBatch{
+		hash, err := common.HashFile(f)
+		if err == nil {
+			_, err = f.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			return fmt.Errorf("could not read %v: %v", filename, err)
+		}
+		input := common.InputDescription{Name: filename, SHA256: hash}
+		if skip != nil && skip(input) {
+			continue
+		}
+		run.Inputs = append(run.Inputs, input)
+		batch = append(batch, extractors.StreamBatch{
 			Buf: f,
 			Origin: &common.OriginDescription{
 				Name:           filename,
-				ProcessingDate: time.Now(),
+				ProcessingDate: processed,
+				SHA256:         hash,
+				Run:            run,
 			},
+		})
+	}
+	extractor(callback, dregs, calibrations, batch...)
+	return nil
+}
+
+// loadCalibrations reads the calibrations of the json file at the path
+func loadCalibrations(path string) (aez.Calibrations, error) {
+	if ext := filepath.Ext(path); !strings.EqualFold(ext, ".json") {
+		return nil, fmt.Errorf("calibration file %v is not json, only json calibrations are supported", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return aez.LoadCalibrations(f)
+}
+
+func loadLimits(path string) (*limits.Limits, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return limits.LoadLimits(f)
+}
+
+func getNDJSONFileCallback(
+	path string,
+	writeImages bool,
+	writeTimeseries bool,
+	pool *exports.ImagePool,
+) (common.Callback, common.CallbackTeardown, error) {
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create output directory '%v'", filepath.Dir(path))
+	}
+	file, err := common.CreateAtomic(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	out := bufio.NewWriter(file)
+	callback, teardown := exports.NDJSONCallbackFactory(out, writeImages, writeTimeseries, pool)
+	withOrigin := func(pkg common.DataRecord) {
+		file.AddOrigin(pkg.OriginName())
+		callback(pkg)
+	}
+	return withOrigin, func() error {
+		err := teardown()
+		if err == nil {
+			err = out.Flush()
+		}
+		if err != nil {
+			file.Abort()
+			return fmt.Errorf("could not write %v: %v", path, err)
 		}
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("could not write %v: %v", path, err)
+		}
+		return nil
+	}, nil
+}
 
+func getCSVOptions(appendFiles bool, conflictName string, splitName string) (exports.CSVOptions, error) {
+	conflict, err := timeseries.CSVConflictFromName(conflictName)
+	if err != nil {
+		return exports.CSVOptions{}, err
 	}
-	extractor(callback, dregs, batch...)
+	split, err := exports.CSVSplitFromName(splitName)
+	if err != nil {
+		return exports.CSVOptions{}, err
+	}
+	return exports.CSVOptions{Append: appendFiles, Conflict: conflict, Split: split}, nil
+}
+
+func setPartitioning(template string, keyName string) error {
+	key, err := timeseries.PartitionKeyFromName(keyName)
+	if err != nil {
+		return err
+	}
+	partitioning, err := timeseries.NewPartitioning(template, key)
+	if err != nil {
+		return err
+	}
+	timeseries.SetPartitioning(partitioning)
 	return nil
 }
 
@@ -159,23 +620,125 @@ func init() {
 	project = flag.String(
 		"project",
 		"",
-		"Name for experiments, when outputting to disk a directory will be created with this name.",
+		"Name for experiments, when outputting to disk a directory will be created with this name.\n"+
+			"A run that completes without failing to write an output adds _MANIFEST.json and _SUCCESS to it.",
 	)
 	stdout = flag.Bool(
 		"stdout",
 		false,
-		"Output to standard out instead of to disk (only timeseries)\n(Default: false)",
+		"Output to standard out (only timeseries)\n(Default: false)",
 	)
 	parquet = flag.Bool(
 		"parquet",
 		false,
 		"Output to disk as parquet",
 	)
+	arrowFiles = flag.Bool(
+		"arrow",
+		false,
+		"Output to disk as Arrow IPC (Feather v2) files",
+	)
+	sqlitePath = flag.String(
+		"sqlite",
+		"",
+		"Path to SQLite database to write to. Each timeseries is a table and re-processed rac-files replace earlier rows.",
+	)
+	sqliteImageFiles = flag.Bool(
+		"sqlite-image-files",
+		false,
+		"Write images as PNG-files next to the SQLite database instead of storing them in it.\n(Default: false)",
+	)
+	ndjsonPath = flag.String(
+		"ndjson",
+		"",
+		"Path to write newline-delimited json to, one object per record named as the CSV headers.\nUse - for stdout.",
+	)
+	ndjsonImages = flag.Bool(
+		"ndjson-images",
+		false,
+		"Include images as base64 encoded PNG in the ImageData field of -ndjson output.\n(Default: false)",
+	)
+	pngDir = flag.String(
+		"png",
+		"",
+		"Path to directory where to write images as PNG-files with json descriptions.",
+	)
+	csvAppend = flag.Bool(
+		"csv-append",
+		false,
+		"Append to existing CSVs of the project instead of replacing them.\n(Default: false)",
+	)
+	csvConflict = flag.String(
+		"csv-conflict",
+		"refuse",
+		"When appending to a CSV with other specifications or columns, refuse to write to it or\nmigrate it to the new specifications and the columns of both.",
+	)
+	csvSplit = flag.String(
+		"csv-split",
+		"none",
+		"Split CSVs into one per day, or per day and rac-file as the parquet files.\nEither none, day or origin.",
+	)
+	flag.Var(
+		streams,
+		"streams",
+		"Limit an output to some timeseries, e.g. ndjson=STAT,ALARMS. May be repeated.\nOutputs are "+strings.Join(sinkNames, ", ")+".",
+	)
+	imageWorkers = flag.Int(
+		"image-workers",
+		0,
+		"Number of images decoded and encoded at the same time, shared by all outputs.\nIf less than one, the number of CPUs is used.",
+	)
+	partition = flag.String(
+		"partition",
+		"legacy",
+		"Directories of parquet and arrow files, legacy, hive or a template such as\n{stream}/{year}/{month}/{day}. See -help PARQUET.",
+	)
+	partitionKey = flag.String(
+		"partition-key",
+		"tm",
+		"Time to partition parquet and arrow files on, tm, exposure or ramses.",
+	)
+	for setting, usage := range map[string]string{
+		"codec":      "Compression of parquet files, snappy (default), gzip, zstd or none.\nMay be given per timeseries, e.g. CCD=zstd.",
+		"row-group":  "Target size of parquet row groups. If zero each file is one row group.\nMay be given per timeseries, e.g. CCD=64M.",
+		"max-rows":   "Rows of a parquet file before continuing in a new part file. If zero there is no limit.\nMay be given per timeseries, e.g. HTR=100000.",
+		"max-bytes":  "Approximate size of a parquet file before continuing in a new part file. If zero there\nis no limit. May be given per timeseries, e.g. CCD=1G.",
+		"dictionary": "Dictionary encode the parquet columns, on (default) or off.\nMay be given per timeseries, e.g. CCD=off.",
+	} {
+		flag.Var(parquetOption{options: parquetTuning, setting: setting}, "parquet-"+setting, usage)
+	}
 	dregsDir = flag.String(
 		"dregs",
 		"",
 		"Path to directory where to find and write dregs files for multi packet continuation. Directory will be created if non-existent. If empty dregs will be skipped.",
 	)
+	calibrationFile = flag.String(
+		"calibration",
+		"",
+		"Path to json file with housekeeping calibrations. If empty the builtin calibration is used.",
+	)
+	limitsFile = flag.String(
+		"limits",
+		"",
+		"Path to json file with housekeeping limits. Values outside limits are written to the ALARMS output and the run statistics.",
+	)
+	processingTime = flag.String(
+		"processing-time",
+		"",
+		"Time recorded as processing time, e.g. 2023-01-05T14:00:00Z, for outputs identical to those of an earlier run.\n"+
+			"If empty the current time is used.",
+	)
+	ledgerPath = flag.String(
+		"ledger",
+		"",
+		"Path to SQLite ledger of processed rac-files, created if missing. Rac-files with the same content as one\n"+
+			"processed successfully are skipped, see rac ledger -help. If empty all rac-files are processed.",
+	)
+	force = flag.Bool(
+		"force",
+		false,
+		"Process rac-files even if the ledger lists them as processed.\n(Default: false)",
+	)
 	version = flag.Bool(
 		"version",
 		false,
@@ -185,8 +748,28 @@ func init() {
 	flag.Usage = myUsage
 }
 
+// commands are the sub-commands of rac, run as e.g. rac compact
+var commands = map[string]func(args []string) error{
+	"compact": compactCommand,
+	"convert": convertCommand,
+	"ledger":  ledgerCommand,
+	"listen":  listenCommand,
+	"query":   queryCommand,
+	"replay":  replayCommand,
+	"schema":  schemaCommand,
+	"serve":   serveCommand,
+	"watch":   watchCommand,
+}
+
 func main() {
-	var wg sync.WaitGroup
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
 	flag.Parse()
 	if *version {
 		fmt.Println("Version", Version, "Commit", Head, "@", Buildtime)
@@ -198,24 +781,227 @@ func main() {
 		flag.Usage()
 		log.Fatal("No rac-files supplied")
 	}
-	callback, teardown, err := getCallback(
-		*stdout,
-		*parquet,
-		*project,
-		*skipImages,
-		*skipTimeseries,
-		&wg,
-	)
+	setup, err := prepareRun(flag.CommandLine, nil)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer setup.Close()
 	dregs := extractors.Dregs{
 		Path:    *dregsDir,
 		MaxDiff: extractors.MaxDeviationNanos,
 	}
-	err = processFiles(extractors.ExtractData, inputFiles, dregs, callback)
+	_, err = setup.runFiles(inputFiles, dregs)
 	if err != nil {
 		log.Fatal(err)
 	}
-	teardown()
+}
+
+// runSetup holds what the runs of rac share, runs of rac watch share it
+// between runs
+type runSetup struct {
+	flags        *flag.FlagSet
+	out          outputs
+	calibrations aez.Calibrations
+	limits       *limits.Limits
+	ledger       *ledger.Ledger
+	force        bool
+	processed    string
+}
+
+// prepareRun applies the flags shared by the runs, the records are also
+// shown on the dashboard if given
+func prepareRun(flags *flag.FlagSet, board *dashboard.Dashboard) (*runSetup, error) {
+	err := setPartitioning(*partition, *partitionKey)
+	if err != nil {
+		return nil, err
+	}
+	err = setParquetTuning(parquetTuning)
+	if err != nil {
+		return nil, err
+	}
+	csvOptions, err := getCSVOptions(*csvAppend, *csvConflict, *csvSplit)
+	if err != nil {
+		return nil, err
+	}
+	var calibrations aez.Calibrations
+	if *calibrationFile != "" {
+		calibrations, err = loadCalibrations(*calibrationFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if _, err := getProcessingTime(*processingTime); err != nil {
+		return nil, err
+	}
+	setup := runSetup{
+		flags: flags,
+		out: outputs{
+			stdout:           *stdout,
+			parquet:          *parquet,
+			arrow:            *arrowFiles,
+			sqlite:           *sqlitePath,
+			sqliteImageFiles: *sqliteImageFiles,
+			ndjson:           *ndjsonPath,
+			ndjsonImages:     *ndjsonImages,
+			png:              *pngDir,
+			project:          *project,
+			skipImages:       *skipImages,
+			skipTimeseries:   *skipTimeseries,
+			streams:          streams,
+			imageWorkers:     *imageWorkers,
+			csv:              csvOptions,
+			dashboard:        board,
+		},
+		calibrations: calibrations,
+		force:        *force,
+		processed:    *processingTime,
+	}
+	if err := setup.out.validate(); err != nil {
+		return nil, err
+	}
+	if *limitsFile != "" {
+		setup.limits, err = loadLimits(*limitsFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if *ledgerPath != "" {
+		setup.ledger, err = ledger.Open(*ledgerPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &setup, nil
+}
+
+// Close releases what the runs shared
+func (setup *runSetup) Close() {
+	if setup.ledger != nil {
+		setup.ledger.Close()
+	}
+}
+
+// runFiles processes the files as one run, writing the manifest of the
+// project and recording the run in the ledger, it returns the errors of the
+// files whose packets had errors by name
+func (setup *runSetup) runFiles(inputFiles []string, dregs extractors.Dregs) (map[string]error, error) {
+	processed, err := getProcessingTime(setup.processed)
+	if err != nil {
+		return nil, err
+	}
+	callback, finish, err := setup.startRun()
+	if err != nil {
+		return nil, err
+	}
+	run := describeRun(setup.flags, setup.calibrations)
+	err = processFiles(
+		extractors.ExtractData,
+		inputFiles,
+		dregs,
+		setup.calibrations,
+		callback,
+		run,
+		processed,
+		skipProcessed(setup.ledger, setup.force),
+	)
+	return finish(run, err)
+}
+
+// inputErrors counts the records with errors, such as packets that could not
+// be decoded, of each input of a run
+type inputErrors struct {
+	lock   sync.Mutex
+	counts map[string]int
+	first  map[string]error
+}
+
+func newInputErrors() *inputErrors {
+	return &inputErrors{counts: make(map[string]int), first: make(map[string]error)}
+}
+
+// track returns a callback counting the errors of the records before
+// passing them on
+func (errs *inputErrors) track(callback common.Callback) common.Callback {
+	return func(pkg common.DataRecord) {
+		if pkg.Error != nil {
+			errs.lock.Lock()
+			name := pkg.OriginName()
+			if errs.counts[name] == 0 {
+				errs.first[name] = pkg.Error
+			}
+			errs.counts[name]++
+			errs.lock.Unlock()
+		}
+		callback(pkg)
+	}
+}
+
+// of returns the error of the input, nil if none of its records had errors
+func (errs *inputErrors) of(input string) error {
+	errs.lock.Lock()
+	defer errs.lock.Unlock()
+	if errs.counts[input] == 0 {
+		return nil
+	}
+	return fmt.Errorf("%v packets had errors, the first: %v", errs.counts[input], errs.first[input])
+}
+
+// startRun opens the outputs of a run, the returned finish completes them
+// after the run ended with the error, writing the manifest of the project
+// and recording the run in the ledger
+//
+// Finish returns the errors of the inputs whose packets had errors by name
+// besides the error of the run.
+func (setup *runSetup) startRun() (
+	common.Callback,
+	func(run *common.RunDescription, err error) (map[string]error, error),
+	error,
+) {
+	common.ResetOutputs()
+	if setup.out.project != "" {
+		err := exports.StartRun(setup.out.project)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	callback, teardown, err := getCallback(setup.out)
+	if err != nil {
+		return nil, nil, err
+	}
+	if setup.limits != nil {
+		callback = limits.CheckingCallback(callback, setup.limits)
+	}
+	decodeErrors := newInputErrors()
+	callback = decodeErrors.track(callback)
+	return callback, func(run *common.RunDescription, err error) (map[string]error, error) {
+		// A failed output leaves the project without manifest and success
+		// marker
+		if teardownErr := teardown(); err == nil {
+			err = teardownErr
+		}
+		inputErrs := make(map[string]error)
+		for _, input := range run.Inputs {
+			if inputErr := decodeErrors.of(input.Name); inputErr != nil {
+				inputErrs[input.Name] = inputErr
+			}
+		}
+		if err == nil && setup.out.project != "" {
+			err = exports.WriteManifest(setup.out.project)
+		}
+		if setup.ledger != nil {
+			results := make(map[string]ledger.Result, len(run.Inputs))
+			for _, input := range run.Inputs {
+				inputErr := err
+				if inputErr == nil {
+					inputErr = inputErrs[input.Name]
+				}
+				results[input.Name] = ledger.Result{Err: inputErr, Outputs: common.OutputsOf(input.Name)}
+			}
+			ledgerErr := setup.ledger.Record(run, results, time.Now())
+			if ledgerErr != nil {
+				log.Println(ledgerErr)
+			}
+		}
+		return inputErrs, err
+	}, nil
 }
@@ -1,46 +1,47 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"os"
 	"path/filepath"
 	"reflect"
-	"sync"
+	"strings"
 	"testing"
 	"time"
 
+	parquetformat "github.com/fraugster/parquet-go/parquet"
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
 	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/dashboard"
+	"github.com/innosat-mats/rac-extract-payload/internal/exports"
 	"github.com/innosat-mats/rac-extract-payload/internal/extractors"
+	"github.com/innosat-mats/rac-extract-payload/internal/innosat"
+	"github.com/innosat-mats/rac-extract-payload/internal/ledger"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
 )
 
 func Test_getCallback(t *testing.T) {
-	type args struct {
-		toStdout       bool
-		toParquet      bool
-		project        string
-		skipImages     bool
-		skipTimeseries bool
-		wg             *sync.WaitGroup
-	}
 	tests := []struct {
 		name    string
-		args    args
+		out     outputs
 		wantErr bool
 	}{
-		{"Returns stdout callback", args{toStdout: true}, false},
-		{"Returns disk callback", args{project: "somewhere"}, false},
-		{"Returns error if no output directory", args{}, true},
+		{"Returns stdout callback", outputs{stdout: true}, false},
+		{"Returns disk callback", outputs{project: "somewhere"}, false},
+		{"Returns parquet callback", outputs{parquet: true, project: "somewhere"}, false},
+		{"Returns arrow callback", outputs{arrow: true, project: "somewhere"}, false},
+		{"Returns sqlite callback", outputs{sqlite: filepath.Join(t.TempDir(), "rac.db")}, false},
+		{"Returns ndjson stdout callback", outputs{ndjson: "-"}, false},
+		{"Returns ndjson file callback", outputs{ndjson: filepath.Join(t.TempDir(), "rac.ndjson")}, false},
+		{"Returns png callback", outputs{png: t.TempDir()}, false},
+		{"Returns dashboard callback", outputs{dashboard: dashboard.New()}, false},
+		{"Returns error if no output directory", outputs{}, true},
+		{"Returns error if parquet without project", outputs{parquet: true, stdout: true}, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, _, err := getCallback(
-				tt.args.toStdout,
-				tt.args.toParquet,
-				tt.args.project,
-				tt.args.skipImages,
-				tt.args.skipTimeseries,
-				tt.args.wg,
-			)
+			_, _, err := getCallback(tt.out)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("getCallback() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -49,6 +50,356 @@ func Test_getCallback(t *testing.T) {
 	}
 }
 
+func Test_getCallback_fansOut(t *testing.T) {
+	dir := t.TempDir()
+	only := sinkStreams{}
+	if err := only.Set("ndjson=HTR"); err != nil {
+		t.Fatal(err)
+	}
+	callback, teardown, err := getCallback(
+		outputs{
+			parquet: true,
+			project: filepath.Join(dir, "lake"),
+			ndjson:  filepath.Join(dir, "report.ndjson"),
+			png:     filepath.Join(dir, "previews"),
+			streams: only,
+		},
+	)
+	if err != nil {
+		t.Fatalf("getCallback() error = %v", err)
+	}
+	for _, data := range []common.Exporter{&aez.STAT{}, &aez.HTR{}} {
+		callback(common.DataRecord{
+			Origin:       &common.OriginDescription{Name: "File1.rac"},
+			SourceHeader: &innosat.SourcePacketHeader{},
+			TMHeader:     &innosat.TMHeader{},
+			Data:         data,
+		})
+	}
+	teardown()
+	for _, stream := range []string{"STAT", "HTR"} {
+		path := filepath.Join(dir, "lake", stream, "1980", "1", "5", "File1.parquet")
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("getCallback() expected to produce file '%v': %v", path, err)
+		}
+	}
+	report, err := os.ReadFile(filepath.Join(dir, "report.ndjson"))
+	if err != nil {
+		t.Fatalf("getCallback() didn't write ndjson: %v", err)
+	}
+	if lines := strings.Count(string(report), "\n"); lines != 1 || !strings.Contains(string(report), `"HTR1A":`) {
+		t.Errorf("getCallback() wrote ndjson %v, want only the HTR record", string(report))
+	}
+}
+
+func Test_runSetup_startRun_failedSink(t *testing.T) {
+	lake := filepath.Join(t.TempDir(), "lake")
+	if err := os.MkdirAll(lake, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// A file where the HTR directory belongs makes the parquet sink fail
+	if err := os.WriteFile(filepath.Join(lake, "HTR"), []byte{}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	setup := runSetup{out: outputs{parquet: true, project: lake}}
+	callback, finish, err := setup.startRun()
+	if err != nil {
+		t.Fatalf("runSetup.startRun() error = %v", err)
+	}
+	callback(common.DataRecord{
+		Origin:       &common.OriginDescription{Name: "File1.rac"},
+		SourceHeader: &innosat.SourcePacketHeader{},
+		TMHeader:     &innosat.TMHeader{},
+		Data:         &aez.HTR{},
+	})
+	if _, err := finish(&common.RunDescription{}, nil); err == nil {
+		t.Error("runSetup.startRun() finish gave no error for a failed sink")
+	}
+	for _, name := range []string{exports.SuccessName, exports.ManifestName} {
+		if _, err := os.Stat(filepath.Join(lake, name)); err == nil {
+			t.Errorf("runSetup.startRun() finish wrote %v for a failed sink", name)
+		}
+	}
+}
+
+func Test_runSetup_startRun_ledgerPerInput(t *testing.T) {
+	dir := t.TempDir()
+	lake := filepath.Join(dir, "lake")
+	processedLedger, err := ledger.Open(filepath.Join(dir, "ledger.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer processedLedger.Close()
+	setup := runSetup{out: outputs{parquet: true, project: lake}, ledger: processedLedger}
+	callback, finish, err := setup.startRun()
+	if err != nil {
+		t.Fatalf("runSetup.startRun() error = %v", err)
+	}
+	run := &common.RunDescription{Inputs: []common.InputDescription{
+		{Name: "good.rac", SHA256: "good"},
+		{Name: "corrupt.rac", SHA256: "corrupt"},
+	}}
+	for _, input := range run.Inputs {
+		pkg := common.DataRecord{
+			Origin:       &common.OriginDescription{Name: input.Name},
+			SourceHeader: &innosat.SourcePacketHeader{},
+			TMHeader:     &innosat.TMHeader{},
+			Data:         &aez.HTR{},
+		}
+		if input.Name == "corrupt.rac" {
+			pkg.Error = errors.New("could not parse ramses header")
+		}
+		callback(pkg)
+	}
+	inputErrs, err := finish(run, nil)
+	if err != nil {
+		t.Fatalf("runSetup.startRun() finish error = %v", err)
+	}
+	if _, ok := inputErrs["corrupt.rac"]; !ok || len(inputErrs) != 1 {
+		t.Errorf("runSetup.startRun() finish gave input errors %v, want only corrupt.rac", inputErrs)
+	}
+	entries, err := processedLedger.Entries(ledger.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]ledger.Entry)
+	for _, entry := range entries {
+		got[entry.Name] = entry
+	}
+	prefix := filepath.Join(lake, "HTR", "1980", "1", "5")
+	if entry := got["good.rac"]; entry.Outcome != ledger.Done ||
+		!reflect.DeepEqual(entry.Outputs, []string{filepath.Join(prefix, "good.parquet")}) {
+		t.Errorf("runSetup.startRun() recorded %+v, want done with good.parquet", entry)
+	}
+	if entry := got["corrupt.rac"]; entry.Outcome != ledger.Failed ||
+		!strings.Contains(entry.Error, "could not parse ramses header") ||
+		!reflect.DeepEqual(entry.Outputs, []string{filepath.Join(prefix, "corrupt.parquet")}) {
+		t.Errorf("runSetup.startRun() recorded %+v, want failed with corrupt.parquet", entry)
+	}
+}
+
+func Test_sinkStreams_Set(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    sinkStreams
+		wantErr bool
+	}{
+		{
+			"Collects streams per sink",
+			[]string{"ndjson=HTR,alarms", "csv=CCD", "ndjson=STAT"},
+			sinkStreams{
+				"ndjson": {timeseries.HTR, timeseries.ALARMS, timeseries.STAT},
+				"csv":    {timeseries.CCD},
+			},
+			false,
+		},
+		{"Requires a sink", []string{"HTR"}, sinkStreams{}, true},
+		{"Requires a known sink", []string{"disk=HTR"}, sinkStreams{}, true},
+		{"Requires known streams", []string{"csv=HTR,XYZ"}, sinkStreams{"csv": {timeseries.HTR}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streams := sinkStreams{}
+			var err error
+			for _, value := range tt.values {
+				if err = streams.Set(value); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("sinkStreams.Set() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(streams, tt.want) {
+				t.Errorf("sinkStreams.Set() = %v, want %v", streams, tt.want)
+			}
+		})
+	}
+}
+
+func Test_setPartitioning(t *testing.T) {
+	defer timeseries.SetPartitioning(timeseries.ActivePartitioning())
+	tests := []struct {
+		name     string
+		template string
+		key      string
+		want     timeseries.Partitioning
+		wantErr  bool
+	}{
+		{"Sets legacy", "legacy", "tm", timeseries.LegacyPartitioning, false},
+		{
+			"Sets hive on exposure",
+			"hive",
+			"exposure",
+			timeseries.Partitioning{
+				Template:      timeseries.HivePartitioning.Template,
+				ImageTemplate: timeseries.HivePartitioning.ImageTemplate,
+				Key:           timeseries.ExposureTime,
+			},
+			false,
+		},
+		{"Rejects unknown key", "hive", "orbit", timeseries.LegacyPartitioning, true},
+		{"Rejects bad template", "{orbit}", "tm", timeseries.LegacyPartitioning, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeseries.SetPartitioning(timeseries.LegacyPartitioning)
+			err := setPartitioning(tt.template, tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("setPartitioning() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := timeseries.ActivePartitioning(); got != tt.want {
+				t.Errorf("setPartitioning() set %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getCSVOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		append   bool
+		conflict string
+		split    string
+		want     exports.CSVOptions
+		wantErr  bool
+	}{
+		{"Defaults", false, "refuse", "none", exports.CSVOptions{}, false},
+		{
+			"Appends and migrates per day",
+			true,
+			"migrate",
+			"day",
+			exports.CSVOptions{Append: true, Conflict: timeseries.MigrateConflict, Split: exports.SplitDay},
+			false,
+		},
+		{"Rejects unknown conflict", true, "overwrite", "none", exports.CSVOptions{}, true},
+		{"Rejects unknown split", true, "refuse", "hour", exports.CSVOptions{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getCSVOptions(tt.append, tt.conflict, tt.split)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getCSVOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getCSVOptions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_setParquetTuning(t *testing.T) {
+	defer timeseries.SetParquetTuning(timeseries.ActiveParquetTuning())
+	tests := []struct {
+		name    string
+		flags   map[string][]string
+		want    timeseries.ParquetTuning
+		wantErr bool
+	}{
+		{
+			"Keeps defaults",
+			map[string][]string{},
+			timeseries.ParquetTuning{
+				Default: timeseries.DefaultParquetSettings,
+				Streams: map[timeseries.OutStream]timeseries.ParquetSettings{},
+			},
+			false,
+		},
+		{
+			"Tunes a stream on top of all",
+			map[string][]string{
+				"codec":      {"ccd=none", "gzip"},
+				"dictionary": {"CCD=off"},
+				"max-rows":   {"1000"},
+			},
+			timeseries.ParquetTuning{
+				Default: timeseries.ParquetSettings{
+					Codec:      parquetformat.CompressionCodec_GZIP,
+					MaxRows:    1000,
+					Dictionary: true,
+				},
+				Streams: map[timeseries.OutStream]timeseries.ParquetSettings{
+					timeseries.CCD: {Codec: parquetformat.CompressionCodec_UNCOMPRESSED, MaxRows: 1000},
+				},
+			},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := parquetOptions{}
+			for setting, values := range tt.flags {
+				for _, value := range values {
+					if err := (parquetOption{options, setting}).Set(value); err != nil {
+						t.Fatalf("parquetOption.Set() error = %v", err)
+					}
+				}
+			}
+			err := setParquetTuning(options)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("setParquetTuning() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := timeseries.ActiveParquetTuning(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("setParquetTuning() set %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parquetOption_Set(t *testing.T) {
+	tests := []struct {
+		name    string
+		setting string
+		value   string
+		wantErr bool
+	}{
+		{"Accepts value", "codec", "zstd", false},
+		{"Accepts stream value", "row-group", "CCD=64M", false},
+		{"Rejects unknown stream", "codec", "XYZ=zstd", true},
+		{"Rejects bad value", "max-bytes", "CCD=lots", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			option := parquetOption{parquetOptions{}, tt.setting}
+			if err := option.Set(tt.value); (err != nil) != tt.wantErr {
+				t.Errorf("parquetOption.Set() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_compactCommand(t *testing.T) {
+	defer timeseries.SetParquetTuning(timeseries.ActiveParquetTuning())
+	empty := t.TempDir()
+	broken := t.TempDir()
+	for _, name := range []string{"a.parquet", "b.parquet"} {
+		err := os.WriteFile(filepath.Join(broken, name), []byte("not parquet"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{"Requires a directory", []string{}, true},
+		{"Rejects unknown flags", []string{"-nope", empty}, true},
+		{"Compacts nothing", []string{empty}, false},
+		{"Compacts with parquet settings", []string{"-parquet-codec", "HTR=zstd", empty}, false},
+		{"Rejects unknown codec", []string{"-parquet-codec", "lz5", empty}, true},
+		{"Reports failed partitions", []string{empty, broken}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := compactCommand(tt.args); (err != nil) != tt.wantErr {
+				t.Errorf("compactCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func Test_processFiles(t *testing.T) {
 	type args struct {
 		inputFiles []string
@@ -100,11 +451,18 @@ func Test_processFiles(t *testing.T) {
 				file.Close()
 			}
 			updatedFilenames := mapFilenamesToDirectory(dir, tt.args.inputFiles)
+			run := &common.RunDescription{}
+			processed := time.Date(2023, 1, 5, 14, 0, 0, 0, time.UTC)
+			calibrations := aez.Calibrations{aez.BuiltinCalibration}
 			extractor := func(
 				callback common.Callback,
 				dregs extractors.Dregs,
+				gotCalibrations aez.Calibrations,
 				streamBatch ...extractors.StreamBatch,
 			) {
+				if !reflect.DeepEqual(gotCalibrations, calibrations) {
+					t.Errorf("Expected calibrations to be passed on to extractor, got %v", gotCalibrations)
+				}
 				ptCallback := reflect.ValueOf(callback).Pointer()
 				ptArgsCallback := reflect.ValueOf(tt.args.callback).Pointer()
 				if ptCallback != ptArgsCallback {
@@ -126,18 +484,29 @@ func Test_processFiles(t *testing.T) {
 							stream.Origin.Name,
 						)
 					}
-					elapsed := time.Since(stream.Origin.ProcessingDate)
-					if elapsed < 0 || elapsed > time.Second {
-						t.Errorf("Processing time %v seems not to be now", stream.Origin.ProcessingDate)
+					if !stream.Origin.ProcessingDate.Equal(processed) {
+						t.Errorf("Expected processing time %v but got %v", processed, stream.Origin.ProcessingDate)
+					}
+					// sha256 of the empty fixtures
+					hash := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+					if stream.Origin.SHA256 != hash || stream.Origin.Run != run {
+						t.Errorf("Expected stream %v to have sha256 %v of the run but got %+v", idx, hash, stream.Origin)
 					}
 				}
+				if len(run.Inputs) != len(tt.args.inputFiles) {
+					t.Errorf("Expected run to list %v inputs but got %v", len(tt.args.inputFiles), run.Inputs)
+				}
 			}
 
 			err = processFiles(
 				extractor,
 				updatedFilenames,
 				extractors.Dregs{},
+				calibrations,
 				tt.args.callback,
+				run,
+				processed,
+				nil,
 			)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("getCallback() error = %v, wantErr %v", err, tt.wantErr)
@@ -146,3 +515,96 @@ func Test_processFiles(t *testing.T) {
 		})
 	}
 }
+
+func Test_getProcessingTime(t *testing.T) {
+	got, err := getProcessingTime("2023-01-05T14:00:00.5Z")
+	if want := time.Date(2023, 1, 5, 14, 0, 0, 5e8, time.UTC); err != nil || !got.Equal(want) {
+		t.Errorf("getProcessingTime() = %v, %v, want %v", got, err, want)
+	}
+	if _, err := getProcessingTime("yesterday"); err == nil {
+		t.Error("getProcessingTime() gave no error for yesterday")
+	}
+	got, err = getProcessingTime("")
+	if elapsed := time.Since(got); err != nil || elapsed < 0 || elapsed > time.Second {
+		t.Errorf("getProcessingTime() = %v, %v, want now", got, err)
+	}
+}
+
+func Test_loadCalibrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		missing bool
+		wantID  string
+		wantErr bool
+	}{
+		{"Loads calibrations", "calibration.json", `[{"id": "test", "validTo": "2000-01-01T00:00:00Z"}]`, false, "test", false},
+		{"Fails on invalid calibration", "calibration.json", `[{"validTo": "2000-01-01T00:00:00Z"}]`, false, "builtin", true},
+		{"Fails on missing file", "calibration.json", "", true, "builtin", true},
+		{"Rejects yaml", "calibration.yaml", "- id: test\n  validTo: 2000-01-01T00:00:00Z\n", false, "builtin", true},
+		{"Rejects csv", "calibration.csv", "id,validTo\ntest,2000-01-01T00:00:00Z\n", false, "builtin", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := os.MkdirTemp("", "mats-testing")
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, tt.file)
+			if !tt.missing {
+				err = os.WriteFile(path, []byte(tt.content), 0644)
+				if err != nil {
+					log.Fatal(err)
+				}
+			}
+			calibrations, err := loadCalibrations(path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadCalibrations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got := calibrations.Select(time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC))
+			if got.ID != tt.wantID {
+				t.Errorf("loadCalibrations() calibration = %v, want %v", got.ID, tt.wantID)
+			}
+		})
+	}
+}
+
+func Test_loadLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		missing    bool
+		wantFields int
+		wantErr    bool
+	}{
+		{"Loads limits", `{"id": "test", "limits": {"HTR1A": {"hardLow": -40}}}`, false, 1, false},
+		{"Fails on invalid limits", `{"limits": {}}`, false, 0, true},
+		{"Fails on missing file", "", true, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := os.MkdirTemp("", "mats-testing")
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "limits.json")
+			if !tt.missing {
+				err = os.WriteFile(path, []byte(tt.content), 0644)
+				if err != nil {
+					log.Fatal(err)
+				}
+			}
+			got, err := loadLimits(path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadLimits() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && len(got.Fields) != tt.wantFields {
+				t.Errorf("loadLimits() fields = %v, want %v", len(got.Fields), tt.wantFields)
+			}
+		})
+	}
+}
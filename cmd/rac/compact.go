@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/exports"
+)
+
+func compactCommand(args []string) error {
+	flags := flag.NewFlagSet("compact", flag.ContinueOnError)
+	options := parquetOptions{}
+	for setting, usage := range map[string]string{
+		"codec":      "Compression of the compacted files, snappy (default), gzip, zstd or none.\nMay be given per timeseries, e.g. CCD=zstd.",
+		"row-group":  "Target size of parquet row groups. If zero each file is one row group.\nMay be given per timeseries, e.g. CCD=64M.",
+		"dictionary": "Dictionary encode the parquet columns, on (default) or off.\nMay be given per timeseries, e.g. CCD=off.",
+	} {
+		flags.Var(parquetOption{options: options, setting: setting}, "parquet-"+setting, usage)
+	}
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), `Usage: rac compact DIR [DIR ...]
+
+Merges the parquet files of each partition below DIR into one file named
+%v, sorted by TMHeaderNanoseconds. Rows of packets already written
+from another rac-file are dropped, comparing all columns except those of the
+origin and Ramses headers. The meta-data of the files is kept, where the files
+disagree the values are joined by '|'. The merged files are removed and the
+_MANIFEST.json of the project holding DIR, if any, lists the compacted files
+instead. The parquet flags tune the compacted files as when extracting, but
+each partition is always compacted into one file.
+`, exports.CompactedName)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("no directories supplied")
+	}
+	if err := setParquetTuning(options); err != nil {
+		return err
+	}
+	var failed error
+	for _, dir := range flags.Args() {
+		stats, err := exports.CompactParquetTree(dir)
+		log.Printf(
+			"Compacted %v files into %v rows below %v, dropping %v duplicates",
+			stats.Files,
+			stats.Rows,
+			dir,
+			stats.Duplicates,
+		)
+		if err != nil {
+			log.Println(err)
+			failed = err
+		}
+	}
+	return failed
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/convert"
+)
+
+func convertCommand(args []string) error {
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	to := flags.String("to", "", "Format to convert to, parquet or csv")
+	project := flags.String("project", "", "Directory to write the converted outputs to")
+	partition := flags.String(
+		"partition",
+		"legacy",
+		"Directories of parquet files, legacy, hive or a template such as\n{stream}/{year}/{month}/{day}. See rac -help PARQUET.",
+	)
+	partitionKey := flags.String("partition-key", "tm", "Time to partition parquet files on, tm, exposure or ramses.")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), `Usage: rac convert -to FORMAT -project DIR PATH ...
+
+Converts the outputs of earlier runs found below PATH without the rac-files:
+
+  rac convert -to parquet -project lake old-csvs
+  rac convert -to csv -project csvs lake
+
+With -to parquet the csvs are written as parquet files named and partitioned
+as by rac -parquet. The images of CCD records are read from the PNGs named by
+ImageName, images lacking a record in CCD.csv are added from their JSON.
+Csvs of earlier versions of rac are converted too, with the columns they lack
+left empty.
+
+With -to csv the parquet files are written as one csv per timeseries and the
+images of CCD records as PNGs with JSON, as by rac without -parquet.
+
+The specifications row of the csvs becomes the meta-data of the parquet files
+and the other way around. Inputs of unknown timeseries are skipped and make
+the conversion fail once the rest is converted.
+
+`)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("expected outputs to convert")
+	}
+	if *project == "" {
+		return errors.New("expected a -project to write to")
+	}
+	var stats convert.Stats
+	var err error
+	switch *to {
+	case "parquet":
+		if err := setPartitioning(*partition, *partitionKey); err != nil {
+			return err
+		}
+		stats, err = convert.ToParquet(flags.Args(), *project)
+	case "csv":
+		stats, err = convert.ToDisk(flags.Args(), *project)
+	default:
+		return fmt.Errorf("unknown format '%v' to convert to, use parquet or csv", *to)
+	}
+	log.Printf(
+		"Converted %v records into %v files and %v images in %v",
+		stats.Records,
+		stats.Files,
+		stats.Images,
+		*project,
+	)
+	if err == nil && stats.Skipped > 0 {
+		return fmt.Errorf("skipped %v inputs since their timeseries is unknown", stats.Skipped)
+	}
+	return err
+}
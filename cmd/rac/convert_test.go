@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// unknownOutputs returns a directory with a csv of an unknown timeseries
+func unknownOutputs(t *testing.T) string {
+	dir := t.TempDir()
+	content := []byte("CODE,Test\nOriginFile,Something\nmy.rac,42\n")
+	if err := os.WriteFile(filepath.Join(dir, "Other.csv"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func Test_convertCommand_rejectsArguments(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"No outputs", []string{"-to", "parquet", "-project", "lake"}},
+		{"No project", []string{"-to", "parquet", "."}},
+		{"Unknown format", []string{"-to", "arrow", "-project", "lake", "."}},
+		{"Bad partition key", []string{"-to", "parquet", "-partition-key", "moon", "-project", "lake", "."}},
+		{"Missing path", []string{"-to", "csv", "-project", t.TempDir(), filepath.Join(t.TempDir(), "missing")}},
+		{"Skipped input", []string{"-to", "parquet", "-project", t.TempDir(), unknownOutputs(t)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := convertCommand(tt.args); err == nil {
+				t.Errorf("convertCommand(%v) expected error", tt.args)
+			}
+		})
+	}
+}
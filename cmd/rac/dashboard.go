@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/dashboard"
+)
+
+// addDashboardFlag adds the -dashboard flag of rac watch and rac listen
+func addDashboardFlag(flags *flag.FlagSet) *string {
+	return flags.String(
+		"dashboard",
+		"",
+		"Address to serve a live dashboard of the latest housekeeping values and CCD images on, e.g. :8081\n"+
+			"(Default: none)",
+	)
+}
+
+// startDashboard serves a dashboard on the address until the context is
+// done, no dashboard is returned if the address is empty
+func startDashboard(ctx context.Context, addr string) (*dashboard.Dashboard, error) {
+	if addr == "" {
+		return nil, nil
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	board := dashboard.New()
+	server := &http.Server{
+		Handler:           board,
+		ReadHeaderTimeout: 10 * time.Second,
+		// Ends the event streams of the dashboard when done
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Dashboard stopped: %v", err)
+		}
+	}()
+	log.Printf("Serving dashboard on http://%v", listener.Addr())
+	return board, nil
+}
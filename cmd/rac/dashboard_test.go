@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_startDashboard(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	board, err := startDashboard(ctx, "")
+	if board != nil || err != nil {
+		t.Errorf("startDashboard() without address = %v, %v, want no dashboard", board, err)
+	}
+	board, err = startDashboard(ctx, "127.0.0.1:0")
+	if board == nil || err != nil {
+		t.Errorf("startDashboard() = %v, %v, want a dashboard", board, err)
+	}
+	if _, err := startDashboard(ctx, "not an address"); err == nil {
+		t.Error("startDashboard() with invalid address gave no error")
+	}
+}
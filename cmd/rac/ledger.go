@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/ledger"
+)
+
+// parseLedgerTime parses a time or a date of the ledger command, empty gives
+// the zero time
+func parseLedgerTime(text string) (time.Time, error) {
+	if text == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+		parsed, err := time.Parse(layout, text)
+		if err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time '%v', use e.g. 2023-01-05 or 2023-01-05T14:00:00Z", text)
+}
+
+// writeLedgerEntries writes one line per entry, optionally followed by one
+// line per output
+func writeLedgerEntries(out io.Writer, entries []ledger.Entry, withOutputs bool) error {
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "PROCESSED\tOUTCOME\tSHA256\tNAME\tOUTPUTS\tERROR")
+	for _, entry := range entries {
+		fmt.Fprintf(
+			writer,
+			"%v\t%v\t%v\t%v\t%v\t%v\n",
+			entry.Processed.Format(time.RFC3339),
+			entry.Outcome,
+			entry.SHA256,
+			entry.Name,
+			len(entry.Outputs),
+			entry.Error,
+		)
+		if withOutputs {
+			for _, output := range entry.Outputs {
+				fmt.Fprintf(writer, "\t\t\t  %v\t\t\n", output)
+			}
+		}
+	}
+	return writer.Flush()
+}
+
+func ledgerCommand(args []string) error {
+	flags := flag.NewFlagSet("ledger", flag.ContinueOnError)
+	name := flags.String("name", "", "Only list rac-files with base names matching the pattern, e.g. '*_2023*.rac'")
+	sha256 := flags.String("sha256", "", "Only list the rac-file with the sha256")
+	outcome := flags.String("outcome", "", "Only list rac-files with the outcome done or failed")
+	since := flags.String("since", "", "Only list rac-files processed at or after the time or date, e.g. 2023-01-05")
+	until := flags.String("until", "", "Only list rac-files processed before the time or date")
+	withOutputs := flags.Bool("outputs", false, "List the outputs of each rac-file\n(Default: false)")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), `Usage: rac ledger [OPTIONS] LEDGER
+
+Lists the rac-files in the ledger of rac -ledger LEDGER, when they were last
+processed, the outcome and the number of outputs written with their records.
+
+A rac-file failed if its run failed or if any of its packets had errors, such
+as those that could not be decoded.
+`)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected one ledger")
+	}
+	filter := ledger.Filter{
+		Name:    *name,
+		SHA256:  strings.ToLower(*sha256),
+		Outcome: ledger.Outcome(strings.ToLower(*outcome)),
+	}
+	if filter.Outcome != "" && filter.Outcome != ledger.Done && filter.Outcome != ledger.Failed {
+		return fmt.Errorf("unknown outcome '%v', use done or failed", *outcome)
+	}
+	var err error
+	if filter.Since, err = parseLedgerTime(*since); err != nil {
+		return err
+	}
+	if filter.Until, err = parseLedgerTime(*until); err != nil {
+		return err
+	}
+	if _, err := os.Stat(flags.Arg(0)); err != nil {
+		return fmt.Errorf("could not open ledger: %v", err)
+	}
+	processedLedger, err := ledger.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer processedLedger.Close()
+	entries, err := processedLedger.Entries(filter)
+	if err != nil {
+		return err
+	}
+	return writeLedgerEntries(os.Stdout, entries, *withOutputs)
+}
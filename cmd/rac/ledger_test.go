@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/ledger"
+)
+
+func writeTestLedger(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "ledger.db")
+	processedLedger, err := ledger.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer processedLedger.Close()
+	run := &common.RunDescription{Inputs: []common.InputDescription{{Name: "a.rac", SHA256: "aaa"}}}
+	results := map[string]ledger.Result{"a.rac": {Outputs: []string{"lake/a.parquet"}}}
+	err = processedLedger.Record(run, results, time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_ledgerCommand(t *testing.T) {
+	path := writeTestLedger(t)
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{"Requires a ledger", []string{}, true},
+		{"Rejects missing ledger", []string{filepath.Join(t.TempDir(), "missing.db")}, true},
+		{"Rejects unknown outcome", []string{"-outcome", "maybe", path}, true},
+		{"Rejects bad time", []string{"-since", "yesterday", path}, true},
+		{"Lists ledger", []string{"-since", "2023-01-05", "-outputs", path}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ledgerCommand(tt.args); (err != nil) != tt.wantErr {
+				t.Errorf("ledgerCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_writeLedgerEntries(t *testing.T) {
+	entries := []ledger.Entry{{
+		SHA256:    "aaa",
+		Name:      "a.rac",
+		Processed: time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC),
+		Outcome:   ledger.Done,
+		Outputs:   []string{"lake/a.parquet"},
+	}}
+	var buf bytes.Buffer
+	if err := writeLedgerEntries(&buf, entries, true); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("writeLedgerEntries() wrote %q, want header, entry and output", buf.String())
+	}
+	for _, want := range []string{"2023-01-05T00:00:00Z", "done", "aaa", "a.rac"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("writeLedgerEntries() wrote %q, missing %v", lines[1], want)
+		}
+	}
+	if !strings.Contains(lines[2], "lake/a.parquet") {
+		t.Errorf("writeLedgerEntries() wrote %q, want output", lines[2])
+	}
+}
+
+func Test_skipProcessed(t *testing.T) {
+	processedLedger, err := ledger.Open(writeTestLedger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer processedLedger.Close()
+	if skipProcessed(nil, false) != nil || skipProcessed(processedLedger, true) != nil {
+		t.Error("skipProcessed() gave a skip function without ledger or with force")
+	}
+	skip := skipProcessed(processedLedger, false)
+	tests := []struct {
+		input common.InputDescription
+		want  bool
+	}{
+		{common.InputDescription{Name: "a.rac", SHA256: "aaa"}, true},
+		{common.InputDescription{Name: "b.rac", SHA256: "bbb"}, false},
+		{common.InputDescription{Name: "copy-of-b.rac", SHA256: "bbb"}, true},
+	}
+	for _, tt := range tests {
+		if got := skip(tt.input); got != tt.want {
+			t.Errorf("skip(%v) = %v, want %v", tt.input.Name, got, tt.want)
+		}
+	}
+}
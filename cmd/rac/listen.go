@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/extractors"
+	"github.com/innosat-mats/rac-extract-payload/internal/live"
+)
+
+// listen processes the streams received by the listener until the context
+// is done, completing the outputs each roll period
+func listen(
+	ctx context.Context,
+	listener *live.Listener,
+	setup *runSetup,
+	dregs extractors.Dregs,
+	name string,
+	roll time.Duration,
+) error {
+	var processed time.Time
+	if setup.processed != "" {
+		var err error
+		processed, err = getProcessingTime(setup.processed)
+		if err != nil {
+			return err
+		}
+	}
+	run := describeRun(setup.flags, setup.calibrations)
+	roller := live.NewRoller(
+		name,
+		roll,
+		run,
+		processed,
+		func(origin *common.OriginDescription) (common.Callback, func() error, error) {
+			callback, finish, err := setup.startRun()
+			if err != nil {
+				return nil, nil, err
+			}
+			log.Printf("Writing %v", origin.Name)
+			return callback, func() error {
+				_, err := finish(origin.Run, nil)
+				return err
+			}, nil
+		},
+	)
+	tick := time.Second
+	if roll < tick {
+		tick = roll
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if err := roller.Roll(now); err != nil {
+					log.Println(err)
+				}
+			}
+		}
+	}()
+
+	log.Printf("Listening on %v", listener.Addr())
+	err := listener.Serve(ctx, func(sender string, stream io.Reader) {
+		log.Printf("Receiving from %v", sender)
+		extractors.ExtractData(
+			roller.Callback,
+			dregs,
+			setup.calibrations,
+			extractors.StreamBatch{Buf: stream, Origin: &common.OriginDescription{Name: sender}},
+		)
+		log.Printf("Stream from %v ended", sender)
+	})
+	if closeErr := roller.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func listenCommand(args []string) error {
+	flags := flag.NewFlagSet("listen", flag.ContinueOnError)
+	roll := flags.Duration("roll", 10*time.Minute, "How often to complete the outputs and start new ones")
+	name := flags.String("name", "live", "Name of the outputs, followed by the start of their roll period")
+	dashboardAddr := addDashboardFlag(flags)
+	flag.VisitAll(func(f *flag.Flag) {
+		if f.Name != "version" {
+			flags.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), `Usage: rac listen [OPTIONS] ADDRESS
+
+Listens on ADDRESS, e.g. tcp://:5000 or udp://:5000, for RAMSES frames streamed
+by an EGSE and processes them as they arrive with the outputs and options of
+rac. Each TCP connection is a stream of frames, over UDP each datagram holds
+whole frames.
+
+The outputs are completed and new ones started every roll period, aligned to
+the clock, with the records of each period named e.g. live_20230105T140000Z
+in place of a rac-file. CSVs are appended to rather than replaced. With
+-processing-time the outputs of every period record that time as processed.
+
+Test with a rac-file replayed over loopback, see rac replay -help.
+
+With -dashboard the latest housekeeping values and CCD images are shown on a
+web page updated as they are decoded.
+
+Stop with Ctrl-C or SIGTERM, the outputs are completed first.
+
+`)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected one address to listen on")
+	}
+	address, err := live.ParseAddress(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *roll <= 0 {
+		return errors.New("the roll period must be positive")
+	}
+	if *ndjsonPath != "" && *ndjsonPath != "-" {
+		return errors.New("each roll would replace the -ndjson file, use -ndjson - or -sqlite with rac listen")
+	}
+	if *ledgerPath != "" {
+		return errors.New("streams have no rac-files to record in a ledger")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	board, err := startDashboard(ctx, *dashboardAddr)
+	if err != nil {
+		return err
+	}
+	setup, err := prepareRun(flags, board)
+	if err != nil {
+		return err
+	}
+	defer setup.Close()
+	setup.out.csv.Append = true
+
+	listener, err := live.Listen(address)
+	if err != nil {
+		return err
+	}
+	dregs := extractors.Dregs{
+		Path:    *dregsDir,
+		MaxDiff: extractors.MaxDeviationNanos,
+		Memory:  extractors.NewDregsMemory(),
+	}
+	return listen(ctx, listener, setup, dregs, *name, *roll)
+}
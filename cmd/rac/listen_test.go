@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/exports"
+	"github.com/innosat-mats/rac-extract-payload/internal/extractors"
+	"github.com/innosat-mats/rac-extract-payload/internal/live"
+)
+
+// statFrame is a RAMSES frame of one STAT packet
+var statFrame = []byte{
+	// Ramses header
+	0x90, 0xeb, 0x48, 0x00, 0x79, 0xd8, 0x00, 0x00,
+	0xfb, 0xad, 0xc8, 0x04, 0xda, 0x1c, 0x00, 0x00,
+	// Ramses TM header
+	0x00, 0x00, 0x2e, 0x02, 0x00, 0x00, 0x64, 0x00,
+	0x00, 0x00, 0x00, 0xcc, 0xcc, 0xcc, 0xcc, 0x00,
+	// Ramses TM Payload
+	0x08, 0x64, 0xc8, 0x98, 0x00, 0x31, 0x10, 0x03,
+	0x19, 0x00, 0x00, 0x12, 0x19, 0xe3, 0x39, 0x00,
+	0x01, 0x7f, 0x04, 0x02, 0x82, 0x04, 0x02, 0x02,
+	0x06, 0x01, 0x19, 0x12, 0x00, 0x00, 0x0c, 0xe3,
+	0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x01, 0x00, 0x00, 0x00, 0x41, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x30, 0xfb,
+}
+
+func Test_listen(t *testing.T) {
+	lake := filepath.Join(t.TempDir(), "lake")
+	racFile := filepath.Join(t.TempDir(), "my.rac")
+	if err := os.WriteFile(racFile, statFrame, 0644); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := live.Listen(live.Address{Network: "tcp", Host: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	setup := &runSetup{
+		flags:     flag.NewFlagSet("listen", flag.ContinueOnError),
+		out:       outputs{project: lake, imageWorkers: 1, csv: exports.CSVOptions{Append: true}},
+		processed: "2023-01-05T14:00:00Z",
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	listened := make(chan error)
+	go func() {
+		listened <- listen(ctx, listener, setup, extractors.Dregs{}, "egse", 50*time.Millisecond)
+	}()
+
+	if err := replayFiles(listener.Addr(), []string{racFile}, 0); err != nil {
+		t.Fatalf("replayFiles() error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(filepath.Join(lake, exports.SuccessName)); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("listen() didn't complete the outputs of the roll period")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-listened; err != nil {
+		t.Errorf("listen() error = %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(lake, "STAT.csv"))
+	if err != nil {
+		t.Fatalf("listen() didn't write the STAT csv: %v", err)
+	}
+	if len(content) == 0 {
+		t.Error("listen() wrote an empty STAT csv")
+	}
+	if !strings.Contains(string(content), "PROCESSED,2023-01-05T14:00:00Z") {
+		t.Errorf("listen() didn't record the processing time given in the STAT csv:\n%s", content)
+	}
+}
+
+func Test_listenCommand_rejectsArguments(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"Requires an address", []string{}},
+		{"Rejects unknown networks", []string{"http://:5000"}},
+		{"Rejects rac-files", []string{"tcp://:5000", "my.rac"}},
+		{"Requires a positive roll", []string{"-roll", "0s", "tcp://:5000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := listenCommand(tt.args); err == nil {
+				t.Error("listenCommand() gave no error")
+			}
+		})
+	}
+}
+
+func Test_replayCommand_rejectsArguments(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"Requires rac-files", []string{"tcp://localhost:5000"}},
+		{"Rejects unknown networks", []string{"http://localhost:5000", "my.rac"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := replayCommand(tt.args); err == nil {
+				t.Error("replayCommand() gave no error")
+			}
+		})
+	}
+}
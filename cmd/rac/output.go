@@ -1,272 +1,266 @@
 package main
 
+import (
+	"fmt"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/innosat"
+	"github.com/innosat-mats/rac-extract-payload/internal/ramses"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
 func infoGeneral() {
-	println(`
+	println(fmt.Sprintf(`
 ### All CSVs ###
 
 The first row:
 - "CODE": Means the following column says what version of the code produced
   the output.
-- "v0.2.2": The code version
-- "RAMES": Means the following column says what RAMSES specification was used.
-- "SPU045-S2:6F": The RAMSES version
+- "%v": The code version
+- "RAMSES": Means the following column says what RAMSES specification was
+  used.
+- "%v": The RAMSES version
 - "INNOSAT": Means the following column says what INNOSAT specification was
   used.
-- "IS-OSE-ICD-0005:1": The INNOSAT version
+- "%v": The INNOSAT version
 - "AEZ": Means the following column says what AEZ specification was used.
-- "AEZICD002:H": The AEZ version
+- "%v": The AEZ version
+- "CALIBRATION": Means the following column says what housekeeping
+  calibration was used for the first row (only HTR, PWR, CPRU and PM), the
+  Calibration column says what calibration was used for each row.
+- "builtin": The calibration id
+- "PROCESSED", "PARAMETERS", "CALIBRATIONS" and "INPUTS": When the rac-files
+  were processed, the flags given, the calibration files and the names and
+  sha256 of the rac-files.
 
 The header row starts with a couple of columns common to all output and then
-follows columns specific to each file
-
-
-- OriginFile
-  Name of the rac-file from which the record originated
-- ProcessingDate
-  The local time when the file was processed
-- RamsesTime (Ramses Header)
-  The time when the ramses file was created (UTC)
-
-- QualityIndicator (Ramses TM Header)
-  Indicates whether the transported data is complete or partial
-  (0 = Complete, 1 = partial).
-- LossFlag (Ramses TM Header)
-  Used to indicate that a sequence discontinuity has been detected
-- VCFrameCounter (Ramses TM Header)
-  Counter of the transfer frame the payload packet arrived in.
-  Wraps at (2^16)-1
-
-
-- SPSequenceCount (Innosat Source Header)
-  A counter that increases with each packet, may never short cycle and should
-  wrap around to zero after 2^14-1
-
-
-- TMHeaderTime (Innosat TM Header)
-  The time of the TM packet creation (UTC)
-- TMHeaderNanoseconds
-  The time of the TM packet creation (nanoseconds since epoch)
-- SID
-  The name of the SID or empty if the packet has no SID
-- RID
-  The name of the RID or empty if the packet has no RID
-
+follows columns specific to each file. Times are in UTC.
+
+Each run replaces the CSVs of the project unless -csv-append is given. Then
+rows are added to the end of existing CSVs if their first and header rows are
+those of the new rows, apart from the provenance. The first row of such a CSV
+stays that of the run creating it, so each appending run adds its first row
+to e.g. HTR.csv.provenance. Otherwise, e.g. after upgrading rac, the CSV is
+left as is, or with -csv-conflict migrate it is rewritten with the new first
+row and the columns of both, leaving cells of missing columns empty.
+
+The -csv-split flag writes a CSV per day, e.g. HTR/2023/1/5/HTR.csv, or per
+day and rac-file as the parquet files, e.g. HTR/2023/1/5/my.csv, using the
+-partition and -partition-key flags.
+
+"rac schema" writes the columns of all outputs as JSON Schema, markdown or
+parquet schema, e.g. for validating the outputs.
+
+`,
+		common.FullVersion(),
+		ramses.Specification,
+		innosat.Specification,
+		aez.Specification,
+	))
+	columns := (&common.DataRecord{}).Columns()
+	last := len(columns) - 1
+	println(schema.Describe(columns[:last]))
+	println(`
 Note that each line should have a SID or a RID depending on packet type
 
 All files also have a final common column
-
-- Error
-  If an error occurred it will be written here.
-  If empty, then no error occurred extracting the data.
-
+`)
+	println(schema.Describe(columns[last:]))
+	println(`
 For information about fields specific to a certain csv use any of these:
 
 -help CCD, -help CPRU, -help HTR, -help PWR, -help STAT, -help TCV,
 -help PM
 
-For info about parquet format use:
+For info about parquet, arrow and SQLite formats use:
 
--help PARQUET
-	`)
-}
+-help PARQUET, -help ARROW, -help SQLITE
 
-func infoCCD() {
-	println(`
-### CCD.csv ###
-
-The following columns directly export the data in the rac:
-CCDSEL, WDWOV, JPEGQ, FRAME, NROW, NRBIN, NRSKIP, NCOL, NCSKIP, NFLUSH
-TEXPMS, TEMP, FBINOV, LBLNK, TBLNK, ZERO, TIMING1, TIMING2, VERSION
-TIMING3, NBC, BC
-
-The following columns parse the values further:
-- EXPNanoseconds
-  Time of exposure (nanoseconds since epoch)
-- EXPDate
-  Time of exposure (UTC)
-- WDWMode
-  "Manual" (value in rac 0b0)
-  "Automatic" (value in rac 0b1)
-- WDWInputDataWindow
-  Written as the from - to bits used in the original image
-  "11..0" (value in rac 0x0)
-  "12..1" (value in rac 0x1)
-  "13..2" (value in rac 0x2)
-  "14..3" (value in rac 0x3)
-  "15..4" (value in rac 0x4)
-  "15..0" which is the full image (value in rac 0x7)
-- NCBINFPGAColumns
-  The actual number of FPGA Columns (value in rac is the exponent in 2^N)
-- NCBINCCDColumns
-  The number of CCD Columns
-- GAINMode
-  "High" (value in rac 0b0)
-  "Low" (value in rac 0b1)
-- GAINTiming
-  "Faster" used for binned and discarded (value in rac 0b0)
-  "Full" used even for pixels that are not read out (value in rac 0b1)
-- GAINTrunctation
-  The value of the truncation bits
-- ImageName
-  The name of the image file associated with these measurements
-- ImageFile
-  The image data encoded as a 16 bit grey scale PNG
+For info about calibration files use:
+
+-help CALIBRATION
+
+For info about limits and the ALARMS output use:
+
+-help LIMITS
 	`)
 }
 
-func infoCPRU() {
+func infoLimits() {
 	println(`
-### CPRU.csv ###
-All voltages are the calculated float values of their respective type
-according to the specification and not the raw encoded integer of the rac.
-
-- VGATE0:       voltage
-- VSUBS0:       voltage
-- VRD0:         voltage
-- VOD0:         voltage
-- Overvoltage0: If over voltage fault registered (bool)
-- Power0:       If power is enabled (bool)
-- VGATE1:       voltage
-- VSUBS1:       voltage
-- VRD1:         voltage
-- VOD1:         voltage
-- Overvoltage1: If over voltage fault registered (bool)
-- Power1:       If power is enabled (bool)
-- VGATE2:       voltage
-- VSUBS2:       voltage
-- VRD2:         voltage
-- VOD2:         voltage
-- Overvoltage2: If over voltage fault registered (bool)
-- Power2:       If power is enabled (bool)
-- VGATE3:       voltage
-- VSUBS3:       voltage
-- VRD3:         voltage
-- VOD3:         voltage
-- Overvoltage3: If over voltage fault registered (bool)
-- Power3:       If power is enabled (bool)
-  `)
+### Limits ###
+With the -limits flag each record is checked against limits on its fields and
+every value outside its limits produces a record in the ALARMS output. The run
+statistics at the end also summarize the alarms.
+
+The limits file is json with an id and limits per field name. Field names are
+the column names of the HTR, PWR, CPRU, STAT and PM outputs. All limits are
+optional, boolean fields (e.g. Overvoltage0) count as 0 or 1. Hard limits take
+precedence over soft limits and must lie outside them.
+
+{
+  "id": "2022-11-limits",            (required, written to the csv specs)
+  "limits": {
+    "HTR1A": {"softLow": -10, "softHigh": 40, "hardLow": -20, "hardHigh": 50},
+    "PWRP32V": {"softLow": 31, "softHigh": 33},
+    "VGATE0": {"hardLow": 0, "hardHigh": 30},
+    "Overvoltage0": {"hardHigh": 0},
+    "ANOMALY": {"hardHigh": 0},
+    "EDACE": {"softHigh": 100},
+    "EDACERate": {"softHigh": 0.1}
+  }
+}`)
+	infoStream(timeseries.ALARMS)
+	println(`The specifications row includes "LIMITS" followed by the limits id.`)
 }
 
-func infoHTR() {
+func infoCalibration() {
 	println(`
-### HTR.csv ###
-All voltages are the calculated float values of their respective type
-according to the specification and not the raw encoded integer of the rac.
-
-All temperatures are calculated from the specification and given in degrees
-Celcius.
-
-- HTR1A:    temperature,
-- HTR1B:    temperature,
-- HTR1OD:   voltage
-- HTR2A:    temperature,
-- HTR2B:    temperature,
-- HTR2OD:   voltage
-- HTR7A:    temperature,
-- HTR7B:    temperature,
-- HTR7OD:   voltage,
-- HTR8A:    temperature,
-- HTR8B:    temperature,
-- HTR8OD:   voltage,
-- Warnings: A summary of warnings from the temperature calculations.
-  The warnings come from the interpolator and probably indicate the measured
-  resistance is out of range.
-  Each warning is separated by a '|' character.
-  `)
+### Calibration ###
+Housekeeping values (HTR, PWR, CPRU and PM) are converted into useful units
+using a calibration. Unless the -calibration flag is given, the calibration
+built into the program (id "builtin") is used.
+
+The calibration file is a json list of calibrations. Each calibration starts
+out as a copy of the builtin calibration, so only parameters that differ need
+to be given. Each packet uses the calibration valid at its TM header time, if
+several are valid the one with the latest "validFrom" is used and if none is
+valid the builtin is used. The Calibration column of each row holds the id of
+the calibration used.
+
+Thermistor temperatures are by default interpolated linearly in the table and
+values outside the table are clamped to its ends. The "cubic" model uses a
+monotone cubic spline through the table and "steinhart-hart" uses the
+Steinhart-Hart equation 1/T = A + B ln(R) + C ln(R)^3. Outside the table the
+"linear" extrapolation continues the model while "nan" gives no value.
+Each temperature has an uncertainty column. It is NaN for clamped values,
+which also set the Clamped column.
+
+The builtin calibration has no photometer parameters, so the PM voltages,
+temperatures and uncertainties are null unless a calibration gives "PM".
+Means of inputs whose counter is zero are null as well.
+
+[
+  {
+    "id": "2022-11-recalibration",   (required, written to the Calibration column)
+    "validFrom": "2022-11-01T00:00:00Z",  (optional, inclusive)
+    "validTo": "2023-01-01T00:00:00Z",    (optional, exclusive)
+    "voltageConstant": 0.0006105,    (12 bit ADC value to volts)
+    "HTR": {
+      "dividerVoltage": 3.3,
+      "dividerResistance": 3900,
+      "temperatures": [-55, ...],    (⁰C)
+      "resistances": [ 963849, ...], (Ohm, decreasing)
+      "model": "linear",             (or "cubic" or "steinhart-hart")
+      "extrapolation": "clamp",      (or "linear" or "nan")
+      "steinhartHart": [A, B, C]     (optional, else fitted to the table)
+    },
+    "PWR": { ... same as HTR ... },
+    "PWRScales": {
+      "P32V": 21, "P32C": 0.101, "P16V": 11, "P16C": 2.02,
+      "M16V": -10, "M16C": 0.101, "P3V3": 4, "P3C3": 0.505
+    },
+    "CPRUScales": {"gate": 10, "subs": 7.33, "rd": 11.33, "od": 21.33},
+    "PM": {
+      "voltageConstant": 3.815e-05,  (16 bit ADC value to volts)
+      "thermistor": { ... same as HTR ... }
+    }
+  }
+]
+	`)
 }
 
-func infoPWR() {
-	println(`
-### PWR.csv ###
-All voltages are the calculated float values of their respective type
-according to the specification and not the raw encoded integer of the rac.
-
-All currents are calulated from the specification.
-
-All temperatures are calculated from the specification and given in degrees
-Celcius.
-
-- PWRT:     temperature,
-- PWRP32V:  voltage,
-- PWRP32C:  current,
-- PWRP16V:  voltage,
-- PWRP16C:  current,
-- PWRM16V:  voltage,
-- PWRM16C:  current,
-- PWRP3V3:  voltage,
-- PWRP3C3:  current,
-- Warnings: A summary of warnings from the temperature calculations.
-  The warnings come from the interpolator and probably indicate the measured
-  resistance is out of range.
-  Each warning is separated by a '|' character.
-  `)
+// infoStream describes the csv of the stream and its columns
+func infoStream(stream timeseries.OutStream) {
+	println(fmt.Sprintf("\n### %v.csv ###\n%v\n", stream, stream.Description()))
+	println(schema.Describe(stream.DataColumns()))
 }
 
-func infoSTAT() {
+func infoParquet() {
 	println(`
-### STAT.csv ###
+### Parquet files ###
 
-The following fields are read out exactly as they are encoded in the rac:
-SPID, SPREV, FPID, FPREV, SVNA, SVNB, SVNC, MODE, EDACE, EDACCE, EDACN,
-SPWEOP, SPWEEP, ANOMALY
+The parquet files have the same columns as the CSVs, but the specifications
+row is stored as meta-data instead. Warnings and Errors are lists rather than
+'|' separated texts. The CSV columns BC, PMTIME, PMNANO, STATTIME, STATNANO,
+ProcessingDate and Error are named BadColumns, PMTime, PMNanoseconds,
+STATTime, STATNanoseconds, ProcessingTime and Errors. Rather than one file per parameter and batch, one file per
+parameter and input file is produced.
 
-The fields TS and TSS are replaced by:
+In addition, the parquet files are written using a partitioning scheme so that
+data for each day is written to a file in a directory for that day. This means
+that files with the same name may occur in directories for subsequent days, if
+the original RAC-file covers two days. Partitioning is performed based on the
+CUC time of the source packet. CCD files are further partitioned by hour, e.g.
+CCD/2023/1/5/14/my.parquet.
 
-- STATTIME: The time of the packet (UTC)
-- STATNANO: The time of the packet (nanoseconds since epoch)
-  `)
-}
+The -partition flag changes the directories, either to "hive", e.g.
+stream=CCD/year=2023/month=01/day=05/hour=14, as expected by Athena, Spark and
+DuckDB, or to a template used for all timeseries. Templates are separated by
+"/" and may use {stream}, {year}, {month}, {day} and {hour}, zero padded, as
+well as {m}, {d} and {h} that aren't padded, e.g.:
 
-func infoTCV() {
-	println(`
-### TCV.csv ###
-
-This contains all the four telecommand verification types
-
-- TCV
-  "Accept" for both accept success and fail
-  "Exec" for both execute success and fail
-- TCPID
-  A copy of the Packet ID header field of the TC header
-- PSC
-  A copy of the Sequence Control Header field of the TC header
-- ErrorCode
-  Empty if success else the fail code
+	-partition "{stream}/{year}{month}{day}"
+
+The -partition-key flag selects the time partitioned on, "tm" for the CUC time
+of the source packet, "exposure" for the exposure time of CCD and PM, and the
+measurement time of STAT, or "ramses" for when the Ramses packet was created.
+
+When writing to parquet the PNG-files are stored in the parquet files
+themselves, rather than as separate files, in the ImageData column.
+
+The -parquet-codec, -parquet-row-group, -parquet-max-rows, -parquet-max-bytes
+and -parquet-dictionary flags tune how the files are written, either for all
+timeseries or for one when prefixed by its name. When a file reaches the
+maximum number of rows or bytes it is closed and writing continues in a part
+file next to it, e.g. my.part1.parquet. As the CCD files hold the images it
+may be good to split them into row groups and files and to leave their
+columns without dictionary, e.g.:
+
+	-parquet-row-group CCD=64M -parquet-max-bytes CCD=1G -parquet-dictionary CCD=off
   `)
 }
 
-func infoPM() {
+func infoArrow() {
 	println(`
-### PM.csv ###
+### Arrow files ###
 
-The following fields are read out exactly as they are encoded in the rac:
-PM1A, PM1ACNTR, PM1B, PM1BCNTR, PM1S, PM1SCNTR, PM2A, PM2ACNTR, PM2B,
-PM2BCNTR, PM2S, PM2SCNTR
+The -arrow flag writes Arrow IPC files (also known as Feather v2) that can be
+memory mapped directly e.g. by pyarrow and Arrow.jl. The files have the same
+columns, meta-data and partitioning as the parquet files, but end with .arrow
+instead of .parquet. As in parquet the images are stored in the ImageData
+column of the CCD files, as PNG-encoded binary data.
 
-The fields EXPTS and EXPTSS are replaced by:
-- PMTIME: The exposure time (UTC)
-- PMNANO: The exposure time (nanoseconds since epoch)
+Rows are stored in record batches of 4096 rows, or 16 rows for CCD files.
   `)
 }
 
-func infoParquet() {
+func infoSQLite() {
 	println(`
-### Parquet files ###
+### SQLite databases ###
 
-The parquet files follow the same naming conventions used in the CSVs, but the
-header row is stored as meta-data instead. Rather than one file per parameter
-and batch, one file per parameter and input file is produced.
+The -sqlite flag writes to a SQLite database instead of a project directory.
+Each timeseries (HTR, PWR, CPRU, STAT, PM, CCD, TCV and ALARMS) is a table
+with the same columns as the parquet files. Times are stored as fixed width UTC texts,
+e.g. 2022-11-01T12:00:00.000000000Z, so that they compare and sort as text,
+and Warnings and Errors are json arrays.
 
-In addition, the parquet files are written using a partitioning scheme so that
-data for each day is written to a file in a directory for that day. This means
-that files with the same name may occur in directories for subsequent days, if
-the original RAC-file covers two days. Partitioning is performed based on the
-CUC time of the source packet.
+A record is identified by its OriginFile, TMHeaderNanoseconds and
+SPSequenceCount (and AlarmField for ALARMS), so re-processing a rac-file
+replaces its earlier rows rather than duplicating them. The table "origins"
+lists the processed rac-files and the table "runs" each processing run.
 
-When writing to parquet the PNG-files are stored in the parquet files
-themselves, rather than as separate files. This introduces two new columns:
-- ImageName: The name of the PNG-image, if it had been written to disk
-- ImageData: The parsed PNG data
+Images are stored as PNG in the ImageData column of the CCD table. With
+-sqlite-image-files they are instead written as PNG-files next to the
+database, named as in the ImageName column.
+
+Example, the heater temperatures of a day:
+
+	SELECT TMHeaderTime, HTR1A, HTR1B FROM HTR
+	WHERE TMHeaderTime >= '2022-11-01' AND TMHeaderTime < '2022-11-02'
+	ORDER BY TMHeaderTime;
   `)
 }
 
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/query"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+// errQueryLimit stops reading when the limit of records is reached
+var errQueryLimit = errors.New("limit reached")
+
+// runQuery writes the records of the files the filter selects, at most
+// limit if positive, saving their images to imagesDir if given, and returns
+// the number of records and images written
+func runQuery(
+	files []string,
+	filter query.Filter,
+	writer *query.Writer,
+	imagesDir string,
+	limit int,
+) (int, int, error) {
+	records := 0
+	images := 0
+	for _, file := range files {
+		err := query.ReadFile(file, filter.SkipStream, func(record *query.Record) error {
+			if !filter.Match(record) {
+				return nil
+			}
+			if limit > 0 && records >= limit {
+				return errQueryLimit
+			}
+			records++
+			if imagesDir != "" {
+				path, err := query.SaveImage(record, imagesDir)
+				if err != nil {
+					return err
+				}
+				if path != "" {
+					images++
+				}
+			}
+			return writer.Write(record)
+		})
+		if errors.Is(err, errQueryLimit) {
+			break
+		} else if err != nil {
+			return records, images, err
+		}
+	}
+	return records, images, writer.Close()
+}
+
+// parseQueryStreams parses comma separated stream names
+func parseQueryStreams(value string) ([]timeseries.OutStream, error) {
+	var streams []timeseries.OutStream
+	for _, name := range strings.Split(value, ",") {
+		stream := timeseries.OutStreamFromName(strings.TrimSpace(name))
+		if stream == timeseries.Unknown {
+			return nil, fmt.Errorf("unknown stream '%v'", name)
+		}
+		streams = append(streams, stream)
+	}
+	return streams, nil
+}
+
+// parseQueryCCDs parses comma separated CCD numbers such as 1,3
+func parseQueryCCDs(value string) ([]int, error) {
+	var ccds []int
+	for _, text := range strings.Split(value, ",") {
+		ccd, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(text)), "CCD"))
+		if err != nil || ccd < 1 || ccd > 7 {
+			return nil, fmt.Errorf("invalid CCD '%v', use 1 to 7", text)
+		}
+		ccds = append(ccds, ccd)
+	}
+	return ccds, nil
+}
+
+func queryCommand(args []string) error {
+	flags := flag.NewFlagSet("query", flag.ContinueOnError)
+	filter := query.Filter{}
+	flags.Func("stream", "Only records of the timeseries, e.g. HTR or HTR,PWR. May be repeated.", func(value string) error {
+		streams, err := parseQueryStreams(value)
+		filter.Streams = append(filter.Streams, streams...)
+		return err
+	})
+	from := flags.String("from", "", "Only records at or after the time, e.g. 2023-01-05 or 2023-01-05T14:00:00Z")
+	to := flags.String("to", "", "Only records before the time")
+	timeColumn := flags.String(
+		"time",
+		query.DefaultTimeColumn,
+		"Column compared with -from and -to, e.g. EXPDate for the exposure time of CCD images",
+	)
+	flags.Func("ccd", "Only images of the CCDs, e.g. 1,3 for CCD1 and CCD3", func(value string) error {
+		ccds, err := parseQueryCCDs(value)
+		filter.CCDs = append(filter.CCDs, ccds...)
+		return err
+	})
+	flags.Func(
+		"where",
+		"Only records where the column compares to the value, e.g. HTR1A>20 or RID=CCD3.\n"+
+			"Operators are "+strings.Join(query.Operators, " ")+", where ~ is contains. May be repeated.",
+		func(value string) error {
+			predicate, err := query.ParsePredicate(value)
+			filter.Predicates = append(filter.Predicates, predicate)
+			return err
+		},
+	)
+	columns := flags.String("columns", "", "Columns to print, e.g. TMHeaderTime,HTR1A\n(Default: all but ImageData)")
+	formatName := flags.String("format", "table", "Print as table, csv or json")
+	imagesDir := flags.String("images", "", "Directory to write the ImageData of CCD records in parquet files to as PNG")
+	limit := flags.Int("limit", 0, "Print at most this many records\n(Default: all)")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), `Usage: rac query [OPTIONS] PATH ...
+
+Reads back the parquet and csv outputs of rac, searching directories such as
+a project for them, and prints the records selected, e.g.:
+
+  rac query -stream HTR -from 2023-01-05T14:00:00Z -columns TMHeaderTime,HTR1A lake
+  rac query -ccd 3 -where TEXPMS>1000 -images previews -format json lake
+
+The timeseries of a file is told by its columns. Numbers and times are
+compared by value, other values as they are written in csvs.
+
+`)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("expected outputs to query")
+	}
+	var err error
+	if filter.From, err = parseQueryTime(*from); err != nil {
+		return err
+	}
+	if filter.To, err = parseQueryTime(*to); err != nil {
+		return err
+	}
+	filter.TimeColumn = *timeColumn
+	format, err := query.FormatFromName(*formatName)
+	if err != nil {
+		return err
+	}
+	var selected []string
+	if *columns != "" {
+		for _, name := range strings.Split(*columns, ",") {
+			selected = append(selected, strings.TrimSpace(name))
+		}
+	}
+	files, err := query.Files(flags.Args()...)
+	if err != nil {
+		return err
+	}
+	_, images, err := runQuery(files, filter, query.NewWriter(os.Stdout, format, selected), *imagesDir, *limit)
+	if *imagesDir != "" {
+		log.Printf("Wrote %v images to %v", images, *imagesDir)
+	}
+	return err
+}
+
+// parseQueryTime parses a time of the query command, empty gives the zero
+// time
+func parseQueryTime(text string) (time.Time, error) {
+	if text == "" {
+		return time.Time{}, nil
+	}
+	return query.ParseTime(text)
+}
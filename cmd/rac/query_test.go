@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/exports"
+	"github.com/innosat-mats/rac-extract-payload/internal/innosat"
+	"github.com/innosat-mats/rac-extract-payload/internal/query"
+	"github.com/innosat-mats/rac-extract-payload/internal/ramses"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+func Test_parseQueryCCDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []int
+		wantErr bool
+	}{
+		{"Numbers", "1,3", []int{1, 3}, false},
+		{"Names", "CCD2, ccd7", []int{2, 7}, false},
+		{"Out of range", "8", nil, true},
+		{"Not a number", "one", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseQueryCCDs(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseQueryCCDs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseQueryCCDs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseQueryStreams(t *testing.T) {
+	got, err := parseQueryStreams("HTR,PWR")
+	if err != nil {
+		t.Fatalf("parseQueryStreams() error = %v", err)
+	}
+	want := []timeseries.OutStream{timeseries.HTR, timeseries.PWR}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseQueryStreams() = %v, want %v", got, want)
+	}
+	if _, err := parseQueryStreams("HTR,NOPE"); err == nil {
+		t.Error("parseQueryStreams() expected error for unknown stream")
+	}
+}
+
+func Test_runQuery(t *testing.T) {
+	dir := t.TempDir()
+	callback, teardown := exports.DiskCallbackFactory(dir, false, true, exports.NewImagePool(1), exports.CSVOptions{})
+	for seconds := uint32(10); seconds < 13; seconds++ {
+		callback(common.DataRecord{
+			Origin:         &common.OriginDescription{Name: "my.rac"},
+			RamsesHeader:   &ramses.Ramses{},
+			RamsesTMHeader: &ramses.TMHeader{},
+			SourceHeader:   &innosat.SourcePacketHeader{},
+			TMHeader:       &innosat.TMHeader{CUCTimeSeconds: seconds},
+			SID:            aez.SIDHTR,
+			Data:           &aez.HTR{},
+		})
+	}
+	teardown()
+	files, err := query.Files(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		filter query.Filter
+		limit  int
+		want   int
+	}{
+		{"All", query.Filter{}, 0, 3},
+		{"Limited", query.Filter{}, 2, 2},
+		{"Other stream", query.Filter{Streams: []timeseries.OutStream{timeseries.PWR}}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			records, images, err := runQuery(
+				files,
+				tt.filter,
+				query.NewWriter(&out, query.CSV, []string{"TMHeaderTime"}),
+				"",
+				tt.limit,
+			)
+			if err != nil {
+				t.Fatalf("runQuery() error = %v", err)
+			}
+			if records != tt.want || images != 0 {
+				t.Errorf("runQuery() = %v records %v images, want %v records 0 images", records, images, tt.want)
+			}
+			lines := strings.Count(out.String(), "\n")
+			if tt.want > 0 && lines != tt.want+1 {
+				t.Errorf("runQuery() wrote %v lines, want header and %v records:\n%s", lines, tt.want, out.String())
+			}
+		})
+	}
+}
+
+func Test_queryCommand_rejectsArguments(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"No outputs", []string{}},
+		{"Unknown stream", []string{"-stream", "NOPE", "."}},
+		{"Bad CCD", []string{"-ccd", "9", "."}},
+		{"Bad predicate", []string{"-where", "HTR1A", "."}},
+		{"Bad time", []string{"-from", "yesterday", "."}},
+		{"Bad format", []string{"-format", "xml", "."}},
+		{"Missing path", []string{filepath.Join(t.TempDir(), "missing")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := queryCommand(tt.args); err == nil {
+				t.Errorf("queryCommand(%v) expected error", tt.args)
+			}
+		})
+	}
+}
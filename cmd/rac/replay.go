@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/live"
+)
+
+// replayFiles sends the RAMSES frames of the rac-files to the address
+func replayFiles(address live.Address, inputFiles []string, interval time.Duration) error {
+	conn, err := live.Dial(address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for _, filename := range inputFiles {
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		frames, err := live.Replay(conn, f, interval)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("could not replay %v: %v", filename, err)
+		}
+		log.Printf("Replayed %v frames of %v to %v", frames, filename, address)
+	}
+	return nil
+}
+
+func replayCommand(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	interval := flags.Duration(
+		"interval",
+		0,
+		"Time to wait between frames, e.g. 1ms to not overrun a UDP receiver\n(Default: none)",
+	)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), `Usage: rac replay [OPTIONS] ADDRESS rac-file ...
+
+Sends the RAMSES frames of the rac-files to ADDRESS, e.g. tcp://localhost:5000,
+as an EGSE would, for testing rac listen. Over UDP each frame is a datagram.
+
+e.g.: rac replay -interval 1ms udp://localhost:5000 my.rac
+
+`)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return errors.New("expected an address and rac-files to replay")
+	}
+	address, err := live.ParseAddress(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	return replayFiles(address, flags.Args()[1:], *interval)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+// schemaFormats are the formats of rac schema
+var schemaFormats = []string{"jsonschema", "markdown", "parquet"}
+
+// recordsSchema is the JSON Schema of the records of several streams
+type recordsSchema struct {
+	Dialect     string                         `json:"$schema"`
+	Title       string                         `json:"title"`
+	Description string                         `json:"description"`
+	OneOf       []map[string]string            `json:"oneOf"`
+	Defs        map[string]schema.ObjectSchema `json:"$defs"`
+}
+
+// unwrap joins the lines of each paragraph of the text
+func unwrap(text string) string {
+	paragraphs := strings.Split(text, "\n\n")
+	for idx, paragraph := range paragraphs {
+		paragraphs[idx] = strings.Join(strings.Fields(paragraph), " ")
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// streamSchema returns the JSON Schema of the records of the stream
+func streamSchema(stream timeseries.OutStream) schema.ObjectSchema {
+	return schema.ObjectSchema{
+		Title:       stream.String(),
+		Description: unwrap(stream.Description()),
+		Columns:     schema.CSVNamed(stream.Columns()),
+	}
+}
+
+// writeSchema writes the columns of the streams in the format
+func writeSchema(out io.Writer, format string, streams []timeseries.OutStream) error {
+	switch format {
+	case "jsonschema":
+		var document interface{}
+		if len(streams) == 1 {
+			object := streamSchema(streams[0])
+			object.Dialect = schema.JSONSchemaDialect
+			document = object
+		} else {
+			records := recordsSchema{
+				Dialect:     schema.JSONSchemaDialect,
+				Title:       "rac records",
+				Description: "A record of any of the timeseries, as written by rac -ndjson",
+				Defs:        make(map[string]schema.ObjectSchema),
+			}
+			for _, stream := range streams {
+				records.OneOf = append(records.OneOf, map[string]string{"$ref": "#/$defs/" + stream.String()})
+				records.Defs[stream.String()] = streamSchema(stream)
+			}
+			document = records
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(document)
+	case "markdown":
+		sections := []string{"# rac outputs"}
+		for _, stream := range streams {
+			sections = append(
+				sections,
+				fmt.Sprintf("## %v", stream),
+				unwrap(stream.Description()),
+				schema.Markdown(stream.Columns()),
+			)
+		}
+		_, err := fmt.Fprintln(out, strings.Join(sections, "\n\n"))
+		return err
+	case "parquet":
+		messages := make([]string, len(streams))
+		for idx, stream := range streams {
+			messages[idx] = schema.ParquetMessage(stream.String(), stream.Columns())
+		}
+		_, err := fmt.Fprintln(out, strings.Join(messages, "\n\n"))
+		return err
+	default:
+		return fmt.Errorf("unknown format '%v', use %v", format, strings.Join(schemaFormats, ", "))
+	}
+}
+
+func schemaCommand(args []string) error {
+	flags := flag.NewFlagSet("schema", flag.ContinueOnError)
+	format := flags.String("format", "jsonschema", "Format of the schema, "+strings.Join(schemaFormats, ", "))
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), `Usage: rac schema [OPTIONS] [TIMESERIES ...]
+
+Writes the columns of the timeseries, by default all, with their types, units
+and descriptions, e.g.:
+
+  rac schema HTR > htr.schema.json
+  rac schema -format markdown > outputs.md
+
+The formats are:
+
+  jsonschema  JSON Schema of the records of -ndjson and rac serve, units are
+              given as x-unit and ImageData is only present with images
+  markdown    A table of the columns of each timeseries
+  parquet     The parquet schema of each timeseries, the messages are named
+              by the timeseries
+
+The csvs hold the same columns, except ImageData, as text.
+
+`)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	streams := timeseries.Streams
+	if flags.NArg() > 0 {
+		streams = nil
+		for _, name := range flags.Args() {
+			stream := timeseries.OutStreamFromName(name)
+			if stream == timeseries.Unknown {
+				return fmt.Errorf("unknown timeseries '%v'", name)
+			}
+			streams = append(streams, stream)
+		}
+	}
+	return writeSchema(os.Stdout, *format, streams)
+}
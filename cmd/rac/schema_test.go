@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+func Test_writeSchema_jsonschema(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeSchema(&buf, "jsonschema", timeseries.Streams); err != nil {
+		t.Fatalf("writeSchema() error = %v", err)
+	}
+	var document struct {
+		Dialect string                     `json:"$schema"`
+		OneOf   []map[string]string        `json:"oneOf"`
+		Defs    map[string]json.RawMessage `json:"$defs"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &document); err != nil {
+		t.Fatalf("writeSchema() wrote invalid json: %v", err)
+	}
+	if document.Dialect != schema.JSONSchemaDialect {
+		t.Errorf("writeSchema() $schema = %v, want %v", document.Dialect, schema.JSONSchemaDialect)
+	}
+	if len(document.OneOf) != len(timeseries.Streams) {
+		t.Errorf("writeSchema() oneOf = %v, want one per stream", document.OneOf)
+	}
+	for _, stream := range timeseries.Streams {
+		if _, ok := document.Defs[stream.String()]; !ok {
+			t.Errorf("writeSchema() $defs lacks %v", stream)
+		}
+	}
+}
+
+func Test_writeSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{"jsonschema of one stream", "jsonschema", `"$schema": "` + schema.JSONSchemaDialect + `"`},
+		{"jsonschema titled by stream", "jsonschema", `"title": "HTR"`},
+		{"markdown", "markdown", "## HTR\n"},
+		{"markdown table", "markdown", "| HTR1A |"},
+		{"parquet", "parquet", "message HTR {"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeSchema(&buf, tt.format, []timeseries.OutStream{timeseries.HTR}); err != nil {
+				t.Fatalf("writeSchema() error = %v", err)
+			}
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("writeSchema() = %v, want it to contain %v", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_schemaCommand_rejectsArguments(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"Unknown format", []string{"-format", "yaml"}},
+		{"Unknown stream", []string{"HTR", "MOON"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := schemaCommand(tt.args); err == nil {
+				t.Errorf("schemaCommand(%v) expected error", tt.args)
+			}
+		})
+	}
+}
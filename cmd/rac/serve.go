@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/service"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+// serveUsage describes the endpoints of rac serve
+const serveUsage = `Usage: rac serve [OPTIONS]
+
+Serves an HTTP API decoding rac-files:
+
+  POST /extract  Decodes the rac-file of the body and answers with the records.
+                 Query parameters:
+                   format  ndjson (default), csv or zip of parquet files and images
+                   stream  Timeseries to include, may be repeated or comma
+                           separated, csv needs exactly one
+                   from    Only records at or after the time, e.g. 2023-01-05T14:00:00Z
+                   to      Only records before the time
+                   key     Time of the records compared, tm (default), exposure or ramses
+                   images  Include images, true or false, by default only in zip
+                   name    Name of the rac-file recorded as origin
+                 If writing ndjson or csv fails the error is given in the
+                 Rac-Error trailer.
+  GET /healthz   Answers ok while serving
+  GET /metrics   Counters of requests and records in the Prometheus text format
+
+e.g.: curl --data-binary @my.rac 'localhost:8080/extract?stream=HTR&format=csv'
+
+`
+
+// listenAndServe serves the handler on the address until interrupted, then
+// lets the requests in progress finish
+func listenAndServe(addr string, handler http.Handler) error {
+	server := &http.Server{Addr: addr, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+	log.Printf("Serving on %v", addr)
+	err := server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func serveCommand(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", ":8080", "Address to listen on")
+	maxSize := flags.String("max-size", "512M", "Largest rac-file accepted, e.g. 64M or 1G")
+	workers := flags.Int("image-workers", 0, "Images decoded and encoded at the same time by all requests\n(Default: one per CPU)")
+	calibration := flags.String("calibration", "", "Path to json file with housekeeping calibrations")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), serveUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return errors.New("rac serve takes no rac-files")
+	}
+	size, err := timeseries.ParseByteSize(*maxSize)
+	if err != nil {
+		return err
+	}
+	var calibrations aez.Calibrations
+	if *calibration != "" {
+		calibrations, err = loadCalibrations(*calibration)
+		if err != nil {
+			return err
+		}
+	}
+	return listenAndServe(*addr, service.New(service.Options{
+		MaxSize:      size,
+		ImageWorkers: *workers,
+		Calibrations: calibrations,
+		Run:          describeRun(flags, calibrations),
+	}))
+}
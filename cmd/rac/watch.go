@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/extractors"
+)
+
+// watchedFile is the last seen state of a rac-file in the watched directory
+type watchedFile struct {
+	size     int64
+	modified time.Time
+	since    time.Time // When the file was first seen in this state
+}
+
+// watcher polls a directory for rac-files that stopped changing
+type watcher struct {
+	dir    string
+	settle time.Duration
+	files  map[string]watchedFile
+}
+
+func newWatcher(dir string, settle time.Duration) *watcher {
+	return &watcher{dir: dir, settle: settle, files: make(map[string]watchedFile)}
+}
+
+// poll returns the sorted rac-files that kept size and modification time for
+// the settle duration and the number of rac-files still changing
+func (w *watcher) poll(now time.Time) ([]string, int, error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	var stable []string
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || strings.HasPrefix(name, ".") ||
+			!strings.EqualFold(filepath.Ext(name), ".rac") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// Moved away since listed
+			continue
+		}
+		path := filepath.Join(w.dir, name)
+		seen[path] = true
+		last, ok := w.files[path]
+		if !ok || last.size != info.Size() || !last.modified.Equal(info.ModTime()) {
+			w.files[path] = watchedFile{size: info.Size(), modified: info.ModTime(), since: now}
+			continue
+		}
+		if now.Sub(last.since) >= w.settle {
+			stable = append(stable, path)
+		}
+	}
+	for path := range w.files {
+		if !seen[path] {
+			delete(w.files, path)
+		}
+	}
+	for _, path := range stable {
+		delete(w.files, path)
+	}
+	sort.Strings(stable)
+	return stable, len(w.files), nil
+}
+
+// moveFiles moves the files into the directory, creating it if needed
+func moveFiles(files []string, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := os.Rename(file, filepath.Join(dir, filepath.Base(file))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveProcessed processes the files as one run and moves them to done, or
+// to failed if the run failed or their packets had errors
+func moveProcessed(setup *runSetup, files []string, dregs extractors.Dregs, doneDir string, failedDir string) error {
+	inputErrs, err := setup.runFiles(files, dregs)
+	if err != nil {
+		log.Printf("Processing failed, moving the rac-files to %v: %v", failedDir, err)
+		return moveFiles(files, failedDir)
+	}
+	var done, failed []string
+	for _, file := range files {
+		if inputErr, ok := inputErrs[file]; ok {
+			log.Printf("Moving %v to %v: %v", file, failedDir, inputErr)
+			failed = append(failed, file)
+		} else {
+			done = append(done, file)
+		}
+	}
+	if err := moveFiles(done, doneDir); err != nil {
+		return err
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return moveFiles(failed, failedDir)
+}
+
+func watchCommand(args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	in := flags.String("in", "", "Directory to watch for rac-files")
+	doneDir := flags.String("done", "", "Directory to move processed rac-files to\n(Default: done in the watched directory)")
+	failedDir := flags.String(
+		"failed",
+		"",
+		"Directory to move rac-files of failed runs or with packet errors to\n(Default: failed in the watched directory)",
+	)
+	pollInterval := flags.Duration("poll", 5*time.Second, "How often to look for new rac-files")
+	settle := flags.Duration("settle", 10*time.Second, "How long a rac-file must stay unchanged before it is processed")
+	once := flags.Bool("once", false, "Exit once the watched directory has no rac-files left\n(Default: false)")
+	dashboardAddr := addDashboardFlag(flags)
+	flag.VisitAll(func(f *flag.Flag) {
+		if f.Name != "version" {
+			flags.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), `Usage: rac watch -in DIR [OPTIONS]
+
+Watches DIR for new rac-files and processes each that stopped changing with
+the outputs and options of rac, then moves it to the done or failed directory.
+Rac-files with packets that could not be decoded are moved to failed too.
+The rac-files that become ready together are processed as one run, keeping the
+outputs open across them, and each run completes the outputs, the manifest and
+the ledger entries. CSVs are appended to rather than replaced. Dregs are kept
+in memory between runs, and in the -dregs directory if given.
+
+With -dashboard the latest housekeeping values and CCD images are shown on a
+web page updated as they are decoded.
+
+Stop with Ctrl-C or SIGTERM, a run in progress is completed first.
+
+`)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *in == "" || flags.NArg() > 0 {
+		flags.Usage()
+		return errors.New("expected a directory to watch and no rac-files")
+	}
+	if *ndjsonPath != "" && *ndjsonPath != "-" {
+		return errors.New("each run would replace the -ndjson file, use -ndjson - or -sqlite with rac watch")
+	}
+	if *doneDir == "" {
+		*doneDir = filepath.Join(*in, "done")
+	}
+	if *failedDir == "" {
+		*failedDir = filepath.Join(*in, "failed")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	board, err := startDashboard(ctx, *dashboardAddr)
+	if err != nil {
+		return err
+	}
+	setup, err := prepareRun(flags, board)
+	if err != nil {
+		return err
+	}
+	defer setup.Close()
+	setup.out.csv.Append = true
+
+	dregs := extractors.Dregs{
+		Path:    *dregsDir,
+		MaxDiff: extractors.MaxDeviationNanos,
+		Memory:  extractors.NewDregsMemory(),
+	}
+	watched := newWatcher(*in, *settle)
+	for {
+		files, changing, err := watched.poll(time.Now())
+		if err != nil {
+			return err
+		}
+		if len(files) > 0 {
+			log.Printf("Processing %v rac-files from %v", len(files), *in)
+			if err := moveProcessed(setup, files, dregs, *doneDir, *failedDir); err != nil {
+				return err
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+			continue
+		}
+		if *once && changing == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*pollInterval):
+		}
+	}
+}
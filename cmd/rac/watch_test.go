@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/exports"
+)
+
+func Test_watcher_poll(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.rac", "a.RAC", ".partial.rac", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	watched := newWatcher(dir, time.Minute)
+	start := time.Now()
+	steps := []struct {
+		name         string
+		at           time.Duration
+		change       string
+		wantFiles    []string
+		wantChanging int
+	}{
+		{"Sees new files", 0, "", nil, 2},
+		{"Waits for files to settle", 30 * time.Second, "", nil, 2},
+		{"Waits again for changed files", time.Minute, "b.rac", []string{filepath.Join(dir, "a.RAC")}, 1},
+		{"Returns settled files", 2 * time.Minute, "", []string{filepath.Join(dir, "b.rac")}, 0},
+		{"Returns files once", 3 * time.Minute, "", nil, 0},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if step.change != "" {
+				if err := os.WriteFile(filepath.Join(dir, step.change), []byte("more data"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			files, changing, err := watched.poll(start.Add(step.at))
+			if err != nil {
+				t.Fatalf("watcher.poll() error = %v", err)
+			}
+			if !reflect.DeepEqual(files, step.wantFiles) || changing != step.wantChanging {
+				t.Errorf("watcher.poll() = %v, %v, want %v, %v", files, changing, step.wantFiles, step.wantChanging)
+			}
+			if err := moveFiles(files, filepath.Join(dir, "done")); err != nil {
+				t.Fatalf("moveFiles() error = %v", err)
+			}
+		})
+	}
+}
+
+func Test_watchCommand(t *testing.T) {
+	defer func(projectName string, toParquet bool) {
+		*project = projectName
+		*parquet = toParquet
+	}(*project, *parquet)
+	in := t.TempDir()
+	lake := filepath.Join(t.TempDir(), "lake")
+	if err := os.WriteFile(filepath.Join(in, "empty.rac"), []byte{}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	corrupt := bytes.Repeat([]byte{0xff}, 64)
+	if err := os.WriteFile(filepath.Join(in, "corrupt.rac"), corrupt, 0644); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"-in", in, "-project", lake, "-parquet", "-poll", "1ms", "-settle", "1ms", "-once"}
+	if err := watchCommand(args); err != nil {
+		t.Fatalf("watchCommand() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(in, "done", "empty.rac")); err != nil {
+		t.Errorf("watchCommand() did not move the rac-file to done: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(in, "failed", "corrupt.rac")); err != nil {
+		t.Errorf("watchCommand() did not move the corrupt rac-file to failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(lake, exports.SuccessName)); err != nil {
+		t.Errorf("watchCommand() did not complete the run: %v", err)
+	}
+}
+
+func Test_watchCommand_rejectsArguments(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"Requires a directory", []string{}},
+		{"Rejects rac-files", []string{"-in", t.TempDir(), "my.rac"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := watchCommand(tt.args); err == nil {
+				t.Error("watchCommand() gave no error")
+			}
+		})
+	}
+}
@@ -6,6 +6,8 @@ require (
 	github.com/fraugster/parquet-go v0.12.0
 	github.com/howeyc/crc16 v0.0.0-20171223171357-2b2a61e366a6
 	github.com/jbuchbinder/gopnm v0.0.0-20220507095634-e31f54490ce0
+	github.com/klauspost/compress v1.17.4
+	github.com/mattn/go-sqlite3 v1.14.22
 )
 
 require (
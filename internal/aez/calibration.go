@@ -0,0 +1,273 @@
+package aez
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// PWRScales holds the factors converting PWR ADC voltages into useful units
+type PWRScales struct {
+	P32V float64 `json:"P32V"` // +32V voltage sense
+	P32C float64 `json:"P32C"` // +32V current sense
+	P16V float64 `json:"P16V"` // +16V voltage sense
+	P16C float64 `json:"P16C"` // +16V current sense
+	M16V float64 `json:"M16V"` // -16V voltage sense
+	M16C float64 `json:"M16C"` // -16V current sense
+	P3V3 float64 `json:"P3V3"` // +3V3 voltage sense
+	P3C3 float64 `json:"P3C3"` // +3V3 current sense
+}
+
+// CPRUScales holds the factors converting CPRU ADC voltages into voltages
+type CPRUScales struct {
+	Gate float64 `json:"gate"` // Gate Voltage
+	Subs float64 `json:"subs"` // Substrate Voltage
+	RD   float64 `json:"rd"`   // Reset transistor Drain Voltage
+	OD   float64 `json:"od"`   // Output Drain Voltage
+}
+
+// PMCalibration holds the photometer conversion parameters
+//
+// Without a voltage constant the photometer voltages and temperatures are
+// not derived, without a thermistor table the temperatures are not.
+type PMCalibration struct {
+	VoltageConstant float64         `json:"voltageConstant"` // Converts mean ADC value to volts
+	Thermistor      ThermistorTable `json:"thermistor"`
+}
+
+func (pm *PMCalibration) hasVoltage() bool {
+	return pm.VoltageConstant > 0
+}
+
+func (pm *PMCalibration) hasThermistor() bool {
+	return len(pm.Thermistor.Resistances) > 0
+}
+
+// Calibration holds all parameters used to convert housekeeping data into
+// useful units during a period of validity
+type Calibration struct {
+	ID              string          `json:"id"`
+	ValidFrom       time.Time       `json:"validFrom"`       // Zero means valid since forever
+	ValidTo         time.Time       `json:"validTo"`         // Zero means valid until further notice
+	VoltageConstant float64         `json:"voltageConstant"` // Converts 12 bit ADC values to volts
+	HTR             ThermistorTable `json:"HTR"`
+	PWR             ThermistorTable `json:"PWR"`
+	PWRScales       PWRScales       `json:"PWRScales"`
+	CPRUScales      CPRUScales      `json:"CPRUScales"`
+	PM              PMCalibration   `json:"PM"`
+}
+
+// clone returns a copy that doesn't share tables with the original, so that
+// decoding into it leaves the original untouched
+func (calibration Calibration) clone() Calibration {
+	calibration.HTR = calibration.HTR.clone()
+	calibration.PWR = calibration.PWR.clone()
+	calibration.PM.Thermistor = calibration.PM.Thermistor.clone()
+	return calibration
+}
+
+// fit prepares the models of the thermistor tables
+func (calibration *Calibration) fit() {
+	calibration.HTR.fit()
+	calibration.PWR.fit()
+	calibration.PM.Thermistor.fit()
+}
+
+// Covers returns if the calibration is valid at the time
+func (calibration *Calibration) Covers(t time.Time) bool {
+	if !calibration.ValidFrom.IsZero() && t.Before(calibration.ValidFrom) {
+		return false
+	}
+	if !calibration.ValidTo.IsZero() && !t.Before(calibration.ValidTo) {
+		return false
+	}
+	return true
+}
+
+// Validate returns an error if the calibration can't be used
+func (calibration *Calibration) Validate() error {
+	if calibration.ID == "" {
+		return errors.New("calibration lacks id")
+	}
+	if !calibration.ValidTo.IsZero() && !calibration.ValidTo.After(calibration.ValidFrom) {
+		return fmt.Errorf("calibration %v validTo must be after validFrom", calibration.ID)
+	}
+	tables := map[string]*ThermistorTable{
+		"HTR": &calibration.HTR,
+		"PWR": &calibration.PWR,
+	}
+	if calibration.PM.hasThermistor() {
+		tables["PM"] = &calibration.PM.Thermistor
+	}
+	for name, table := range tables {
+		if err := table.validate(name); err != nil {
+			return fmt.Errorf("calibration %v: %v", calibration.ID, err)
+		}
+	}
+	if calibration.PM.VoltageConstant < 0 {
+		return fmt.Errorf("calibration %v: PM voltageConstant must not be negative", calibration.ID)
+	}
+	return nil
+}
+
+// BuiltinCalibration is the calibration used when no other calibration applies
+var BuiltinCalibration = Calibration{
+	ID:              "builtin",
+	VoltageConstant: 2.5 / (math.Pow(2, 12) - 1),
+	HTR: ThermistorTable{
+		DividerVoltage:    3.3,
+		DividerResistance: 3900,
+		Temperatures:      htrTemperatures[:],
+		Resistances:       htrResistances[:],
+	},
+	PWR: ThermistorTable{
+		DividerVoltage:    3.3,
+		DividerResistance: 1000,
+		Temperatures:      pwrTemperatures[:],
+		Resistances:       pwrResistances[:],
+	},
+	PWRScales: PWRScales{
+		P32V: 21,
+		P32C: 10.1 / 100,
+		P16V: 11,
+		P16C: 10.1 / 5,
+		M16V: -10,
+		M16C: 10.1 / 100,
+		P3V3: 4,
+		P3C3: 10.1 / 20,
+	},
+	CPRUScales: CPRUScales{
+		Gate: 10,
+		Subs: 11 / 1.5,
+		RD:   17 / 1.5,
+		OD:   32 / 1.5,
+	},
+	// No documentation of the photometer ADC and thermistors is at hand, so
+	// the photometers are left uncalibrated until a calibration gives them
+	PM: PMCalibration{},
+}
+
+func init() {
+	BuiltinCalibration.fit()
+}
+
+// Calibrations is a set of calibrations with different validity
+type Calibrations []Calibration
+
+// LoadCalibrations reads a json list of calibrations
+//
+// Each calibration starts out as a copy of the BuiltinCalibration so only
+// the parameters that differ need to be specified.
+func LoadCalibrations(buf io.Reader) (Calibrations, error) {
+	var raw []json.RawMessage
+	err := json.NewDecoder(buf).Decode(&raw)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse calibrations: %v", err)
+	}
+	calibrations := make(Calibrations, len(raw))
+	for idx, data := range raw {
+		calibration := BuiltinCalibration.clone()
+		calibration.ID = ""
+		err = json.Unmarshal(data, &calibration)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse calibration %d: %v", idx, err)
+		}
+		err = calibration.Validate()
+		if err != nil {
+			return nil, err
+		}
+		calibration.fit()
+		calibrations[idx] = calibration
+	}
+	return calibrations, nil
+}
+
+// Select returns the calibration valid at the time
+//
+// If several calibrations are valid the one with the latest start of
+// validity is used. If none is valid the BuiltinCalibration is returned.
+func (calibrations Calibrations) Select(t time.Time) *Calibration {
+	var selected *Calibration
+	for idx := range calibrations {
+		calibration := &calibrations[idx]
+		if !calibration.Covers(t) {
+			continue
+		}
+		if selected == nil || calibration.ValidFrom.After(selected.ValidFrom) {
+			selected = calibration
+		}
+	}
+	if selected == nil {
+		return &BuiltinCalibration
+	}
+	return selected
+}
+
+// IDs returns the sorted ids of the calibrations
+func (calibrations Calibrations) IDs() []string {
+	ids := make([]string, len(calibrations))
+	for idx, calibration := range calibrations {
+		ids[idx] = calibration.ID
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Calibrated is implemented by data that needs a calibration to be
+// converted into useful units
+type Calibrated interface {
+	SetCalibration(calibration *Calibration)
+}
+
+func calibrationOrBuiltin(calibration *Calibration) *Calibration {
+	if calibration == nil {
+		return &BuiltinCalibration
+	}
+	return calibration
+}
+
+func calibrationSpecifications(calibration *Calibration) []string {
+	return []string{
+		"AEZ", Specification,
+		"CALIBRATION", calibrationOrBuiltin(calibration).ID,
+	}
+}
+
+// readPacketFields reads the exported fields of obj from buf
+//
+// Unexported fields hold processing state rather than packet data and are
+// thus skipped. As with binary.Read, obj is left untouched unless all fields
+// could be read.
+func readPacketFields(buf io.Reader, obj interface{}) error {
+	val := reflect.Indirect(reflect.ValueOf(obj))
+	t := val.Type()
+	size := 0
+	for i := 0; i < val.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			size += binary.Size(val.Field(i).Interface())
+		}
+	}
+	data := make([]byte, size)
+	_, err := io.ReadFull(buf, data)
+	if err != nil {
+		return err
+	}
+	reader := bytes.NewReader(data)
+	for i := 0; i < val.NumField(); i++ {
+		if !t.Field(i).IsExported() {
+			continue
+		}
+		err = binary.Read(reader, binary.LittleEndian, val.Field(i).Addr().Interface())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package aez
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadCalibrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantIDs []string
+		wantErr bool
+	}{
+		{"Loads empty list", "[]", []string{}, false},
+		{
+			"Loads partial calibrations",
+			`[{"id": "a", "voltageConstant": 0.001}, {"id": "b", "validFrom": "2022-11-01T00:00:00Z"}]`,
+			[]string{"a", "b"},
+			false,
+		},
+		{
+			"Loads interpolation models",
+			`[{"id": "a", "HTR": {"model": "steinhart-hart"}, "PWR": {"model": "cubic", "extrapolation": "nan"}}]`,
+			[]string{"a"},
+			false,
+		},
+		{"Fails on unknown model", `[{"id": "a", "HTR": {"model": "spline"}}]`, nil, true},
+		{"Fails on bad json", "[{", nil, true},
+		{"Fails on missing id", `[{"voltageConstant": 0.001}]`, nil, true},
+		{
+			"Fails on bad validity",
+			`[{"id": "a", "validFrom": "2022-11-01T00:00:00Z", "validTo": "2022-10-01T00:00:00Z"}]`,
+			nil,
+			true,
+		},
+		{
+			"Fails on table length mismatch",
+			`[{"id": "a", "HTR": {"dividerVoltage": 3.3, "dividerResistance": 3900, "temperatures": [1, 2], "resistances": [3]}}]`,
+			nil,
+			true,
+		},
+		{
+			"Fails on increasing resistances",
+			`[{"id": "a", "PWR": {"dividerVoltage": 3.3, "dividerResistance": 1000, "temperatures": [1, 2], "resistances": [3, 4]}}]`,
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadCalibrations(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadCalibrations() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && !reflect.DeepEqual(got.IDs(), tt.wantIDs) {
+				t.Errorf("LoadCalibrations() ids = %v, want %v", got.IDs(), tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestLoadCalibrations_defaultsToBuiltin(t *testing.T) {
+	calibrations, err := LoadCalibrations(strings.NewReader(`[{"id": "a", "voltageConstant": 0.001}]`))
+	if err != nil {
+		t.Errorf("LoadCalibrations() unexpected error %v", err)
+		return
+	}
+	got := calibrations[0]
+	if got.VoltageConstant != 0.001 {
+		t.Errorf("LoadCalibrations() voltageConstant = %v, want 0.001", got.VoltageConstant)
+	}
+	if !reflect.DeepEqual(got.HTR, BuiltinCalibration.HTR) {
+		t.Errorf("LoadCalibrations() HTR = %v, want %v", got.HTR, BuiltinCalibration.HTR)
+	}
+	if got.PWRScales != BuiltinCalibration.PWRScales {
+		t.Errorf("LoadCalibrations() PWRScales = %v, want %v", got.PWRScales, BuiltinCalibration.PWRScales)
+	}
+}
+
+func TestLoadCalibrations_fitsSteinhartHart(t *testing.T) {
+	calibrations, err := LoadCalibrations(strings.NewReader(`[{"id": "a", "HTR": {"model": "steinhart-hart"}}]`))
+	if err != nil {
+		t.Errorf("LoadCalibrations() unexpected error %v", err)
+		return
+	}
+	if got := len(calibrations[0].HTR.SteinhartHart); got != 3 {
+		t.Errorf("LoadCalibrations() HTR has %v Steinhart-Hart coefficients, want 3", got)
+	}
+	if BuiltinCalibration.HTR.SteinhartHart != nil {
+		t.Errorf("LoadCalibrations() modified builtin calibration")
+	}
+}
+
+func TestCalibrations_Select(t *testing.T) {
+	early := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	mid := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	late := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	calibrations := Calibrations{
+		{ID: "open", ValidFrom: early},
+		{ID: "mid", ValidFrom: mid, ValidTo: late},
+		{ID: "old", ValidTo: early},
+	}
+	tests := []struct {
+		name string
+		t    time.Time
+		want string
+	}{
+		{"Selects closed range", mid.Add(time.Hour), "mid"},
+		{"Selects open ended after closed range", late, "open"},
+		{"Selects open start", early.Add(-time.Hour), "old"},
+		{"Start is inclusive", early, "open"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calibrations.Select(tt.t); got.ID != tt.want {
+				t.Errorf("Calibrations.Select() = %v, want %v", got.ID, tt.want)
+			}
+		})
+	}
+	t.Run("Latest start wins where windows overlap", func(t *testing.T) {
+		overlapping := Calibrations{
+			{ID: "late", ValidFrom: mid, ValidTo: late.AddDate(1, 0, 0)},
+			{ID: "early", ValidFrom: early, ValidTo: late.AddDate(2, 0, 0)},
+		}
+		for _, tt := range []struct {
+			t    time.Time
+			want string
+		}{
+			{early.Add(time.Hour), "early"},
+			{mid.Add(time.Hour), "late"},
+			{late.AddDate(1, 6, 0), "early"},
+		} {
+			if got := overlapping.Select(tt.t); got.ID != tt.want {
+				t.Errorf("Calibrations.Select(%v) = %v, want %v", tt.t, got.ID, tt.want)
+			}
+		}
+	})
+	t.Run("Falls back to builtin", func(t *testing.T) {
+		if got := (Calibrations{{ID: "mid", ValidFrom: mid, ValidTo: late}}).Select(early); got != &BuiltinCalibration {
+			t.Errorf("Calibrations.Select() = %v, want builtin", got.ID)
+		}
+	})
+}
+
+func TestHTR_SetCalibration(t *testing.T) {
+	calibration := BuiltinCalibration
+	calibration.ID = "double"
+	calibration.VoltageConstant *= 2
+	htr := HTR{HTR1OD: 10}
+	htr.SetCalibration(&calibration)
+	want := 2 * BuiltinCalibration.VoltageConstant * 10
+	if got := htr.Report().HTR1OD; got != want {
+		t.Errorf("HTR.Report().HTR1OD = %v, want %v", got, want)
+	}
+	wantSpecs := []string{"AEZ", Specification, "CALIBRATION", "double"}
+	if got := htr.CSVSpecifications(); !reflect.DeepEqual(got, wantSpecs) {
+		t.Errorf("HTR.CSVSpecifications() = %v, want %v", got, wantSpecs)
+	}
+}
+
+func Test_readPacketFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   []byte
+		want    HTR
+		wantErr error
+	}{
+		{"Returns EOF on empty buffer", []byte{}, HTR{}, io.EOF},
+		{"Returns unexpected EOF on partial buffer", []byte{1, 0, 2}, HTR{}, io.ErrUnexpectedEOF},
+		{
+			"Reads all fields",
+			[]byte{1, 0, 2, 0, 3, 0, 4, 0, 5, 0, 6, 0, 7, 0, 8, 0, 9, 0, 10, 0, 11, 0, 12, 0},
+			HTR{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, nil},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := HTR{}
+			err := readPacketFields(bytes.NewReader(tt.input), &got)
+			if err != tt.wantErr {
+				t.Errorf("readPacketFields() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readPacketFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,11 +4,10 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
-	"strconv"
 	"time"
 
 	"github.com/innosat-mats/rac-extract-payload/internal/ccsds"
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 // WDWMode describes the CCD WDW parameter
@@ -210,117 +209,81 @@ func (ccd *CCDImagePackData) Nanoseconds() int64 {
 	return ccsds.UnsegmentedTimeNanoseconds(ccd.EXPTS, ccd.EXPTSS)
 }
 
-// CSVHeaders returns the exportable field names
-func (ccd *CCDImagePackData) CSVHeaders() []string {
-	return []string{
-		"CCDSEL",
-		"EXPNanoseconds",
-		"EXPDate",
-		"WDWMode",
-		"WDWInputDataWindow",
-		"WDWOV",
-		"JPEGQ",
-		"FRAME",
-		"NROW",
-		"NRBIN",
-		"NRSKIP",
-		"NCOL",
-		"NCBINFPGAColumns",
-		"NCBINCCDColumns",
-		"NCSKIP",
-		"NFLUSH",
-		"TEXPMS",
-		"GAINMode",
-		"GAINTiming",
-		"GAINTruncation",
-
-		"TEMP",
-		"FBINOV",
-		"LBLNK",
-		"TBLNK",
-		"ZERO",
-		"TIMING1",
-		"TIMING2",
-		"VERSION",
-		"TIMING3",
-		"NBC",
-	}
+// ccdPackDataReport holds the exported CCDImagePackData
+type ccdPackDataReport struct {
+	CCDSEL             uint8     `description:"CCD sensor number"`
+	EXPNanoseconds     int64     `unit:"ns" description:"Time of exposure since epoch"`
+	EXPDate            time.Time `description:"Time of exposure"`
+	WDWMode            string    `description:"Manual (value in rac 0b0) or Automatic (value in rac 0b1)"`
+	WDWInputDataWindow string    `description:"The from..to bits used in the original image, 11..0 (value in rac 0x0), 12..1 (0x1), 13..2 (0x2), 14..3 (0x3), 15..4 (0x4) or the full image 15..0 (0x7)"`
+	WDWOV              uint16    `description:"Bit window overflow counter (should be zero when WDW is automatic)"`
+	JPEGQ              uint8     `description:"JPEG compression quality setting (0..100)"`
+	FRAME              uint16    `description:"Frame count since boot"`
+	NROW               uint16    `description:"Number of rows in image"`
+	NRBIN              uint16    `description:"Number of rows to bin together"`
+	NRSKIP             uint16    `description:"Number of rows to skip before start of readout"`
+	NCOL               uint16    `description:"Number of columns in image (starts at 0)"`
+	NCBINFPGAColumns   int       `description:"The actual number of FPGA columns binned (value in rac is the exponent in 2^N)"`
+	NCBINCCDColumns    int       `description:"The number of CCD columns binned"`
+	NCSKIP             uint16    `description:"Number of columns to skip before start of readout"`
+	NFLUSH             uint16    `description:"Number of pre-exposure flushes"`
+	TEXPMS             uint32    `unit:"ms" description:"Exposure time"`
+	GAINMode           string    `description:"High (value in rac 0b0) or Low (value in rac 0b1)"`
+	GAINTiming         string    `description:"Faster used for binned and discarded (value in rac 0b0) or Full used even for pixels that are not read out (value in rac 0b1)"`
+	GAINTruncation     uint8     `description:"The value of the truncation bits"`
+	TEMP               uint16    `description:"Temperature of the ADC"`
+	FBINOV             uint16    `description:"Number of overflows detected while binning"`
+	LBLNK              uint16    `description:"Value of leading blanks"`
+	TBLNK              uint16    `description:"Value of trailing blanks"`
+	ZERO               uint16    `description:"Value of zero input reading"`
+	TIMING1            uint16    `description:"Clock timing parameters, Bit[15..0]"`
+	TIMING2            uint16    `description:"Clock timing parameters, Bit[31..16]"`
+	VERSION            uint16    `description:"Readout of firmware version"`
+	TIMING3            uint16    `description:"Clock timing parameters, Bit[47..32]"`
+	NBC                uint16    `description:"Number of bad columns"`
 }
 
-// CSVRow returns the exportable field values
-func (ccd *CCDImagePackData) CSVRow() []string {
-	wdwhigh, wdwlow, _ := ccd.WDW.InputDataWindow()
-	wdwMode := ccd.WDW.Mode()
-	gainMode := ccd.GAIN.Mode()
-	gainTiming := ccd.GAIN.Timing()
-	return []string{
-		strconv.Itoa(int(ccd.CCDSEL)),
-		strconv.FormatInt(ccd.Nanoseconds(), 10),
-		ccd.Time(GpsTime).Format(time.RFC3339Nano),
-		(&wdwMode).String(),
-		fmt.Sprintf("%v..%v", wdwhigh, wdwlow),
-		strconv.Itoa(int(ccd.WDWOV)),
-		strconv.Itoa(int(ccd.JPEGQ)),
-		strconv.Itoa(int(ccd.FRAME)),
-		strconv.Itoa(int(ccd.NROW)),
-		strconv.Itoa(int(ccd.NRBIN)),
-		strconv.Itoa(int(ccd.NRSKIP)),
-		strconv.Itoa(int(ccd.NCOL)),
-		strconv.Itoa(int(ccd.NCBIN.FPGAColumns())),
-		strconv.Itoa(int(ccd.NCBIN.CCDColumns())),
-		strconv.Itoa(int(ccd.NCSKIP)),
-		strconv.Itoa(int(ccd.NFLUSH)),
-		strconv.Itoa(int(ccd.TEXPMS)),
-		(&gainMode).String(),
-		(&gainTiming).String(),
-		strconv.Itoa(int(ccd.GAIN.Truncation())),
-		strconv.Itoa(int(ccd.TEMP)),
-		strconv.Itoa(int(ccd.FBINOV)),
-		strconv.Itoa(int(ccd.LBLNK)),
-		strconv.Itoa(int(ccd.TBLNK)),
-		strconv.Itoa(int(ccd.ZERO)),
-		strconv.Itoa(int(ccd.TIMING1)),
-		strconv.Itoa(int(ccd.TIMING2)),
-		strconv.Itoa(int(ccd.VERSION)),
-		strconv.Itoa(int(ccd.TIMING3)),
-		strconv.Itoa(int(ccd.NBC)),
-	}
+// Columns returns the exported columns
+func (ccd *CCDImagePackData) Columns() []schema.Column {
+	return schema.Columns(ccdPackDataReport{})
 }
 
-// SetParquet sets the parquet representation of the CCDImagePackData
-func (ccd *CCDImagePackData) SetParquet(row *parquetrow.ParquetRow) {
+// Values returns the exported values
+func (ccd *CCDImagePackData) Values() []interface{} {
 	wdwhigh, wdwlow, _ := ccd.WDW.InputDataWindow()
 	wdwMode := ccd.WDW.Mode()
 	gainMode := ccd.GAIN.Mode()
 	gainTiming := ccd.GAIN.Timing()
-	row.CCDSEL = ccd.CCDSEL
-	row.EXPNanoseconds = ccd.Nanoseconds()
-	row.EXPDate = ccd.Time(GpsTime)
-	row.WDWMode = (&wdwMode).String()
-	row.WDWInputDataWindow = fmt.Sprintf("%v..%v", wdwhigh, wdwlow)
-	row.WDWOV = ccd.WDWOV
-	row.JPEGQ = ccd.JPEGQ
-	row.FRAME = ccd.FRAME
-	row.NROW = ccd.NROW
-	row.NRBIN = ccd.NRBIN
-	row.NRSKIP = ccd.NRSKIP
-	row.NCOL = ccd.NCOL
-	row.NCBINFPGAColumns = ccd.NCBIN.FPGAColumns()
-	row.NCBINCCDColumns = ccd.NCBIN.CCDColumns()
-	row.NCSKIP = ccd.NCSKIP
-	row.NFLUSH = ccd.NFLUSH
-	row.TEXPMS = ccd.TEXPMS
-	row.GAINMode = (&gainMode).String()
-	row.GAINTiming = (&gainTiming).String()
-	row.GAINTruncation = ccd.GAIN.Truncation()
-	row.TEMP = ccd.TEMP
-	row.FBINOV = ccd.FBINOV
-	row.LBLNK = ccd.LBLNK
-	row.TBLNK = ccd.TBLNK
-	row.ZERO = ccd.ZERO
-	row.TIMING1 = ccd.TIMING1
-	row.TIMING2 = ccd.TIMING2
-	row.VERSION = ccd.VERSION
-	row.TIMING3 = ccd.TIMING3
-	row.NBC = ccd.NBC
+	return schema.Values(ccdPackDataReport{
+		CCDSEL:             ccd.CCDSEL,
+		EXPNanoseconds:     ccd.Nanoseconds(),
+		EXPDate:            ccd.Time(GpsTime),
+		WDWMode:            (&wdwMode).String(),
+		WDWInputDataWindow: fmt.Sprintf("%v..%v", wdwhigh, wdwlow),
+		WDWOV:              ccd.WDWOV,
+		JPEGQ:              ccd.JPEGQ,
+		FRAME:              ccd.FRAME,
+		NROW:               ccd.NROW,
+		NRBIN:              ccd.NRBIN,
+		NRSKIP:             ccd.NRSKIP,
+		NCOL:               ccd.NCOL,
+		NCBINFPGAColumns:   ccd.NCBIN.FPGAColumns(),
+		NCBINCCDColumns:    ccd.NCBIN.CCDColumns(),
+		NCSKIP:             ccd.NCSKIP,
+		NFLUSH:             ccd.NFLUSH,
+		TEXPMS:             ccd.TEXPMS,
+		GAINMode:           (&gainMode).String(),
+		GAINTiming:         (&gainTiming).String(),
+		GAINTruncation:     ccd.GAIN.Truncation(),
+		TEMP:               ccd.TEMP,
+		FBINOV:             ccd.FBINOV,
+		LBLNK:              ccd.LBLNK,
+		TBLNK:              ccd.TBLNK,
+		ZERO:               ccd.ZERO,
+		TIMING1:            ccd.TIMING1,
+		TIMING2:            ccd.TIMING2,
+		VERSION:            ccd.VERSION,
+		TIMING3:            ccd.TIMING3,
+		NBC:                ccd.NBC,
+	})
 }
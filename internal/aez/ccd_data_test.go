@@ -6,7 +6,7 @@ import (
 	"time"
 
 	"github.com/innosat-mats/rac-extract-payload/internal/ccsds"
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 func TestWdw_Mode(t *testing.T) {
@@ -218,11 +218,11 @@ func TestCCDImagePackData_Nanoseconds(t *testing.T) {
 
 func TestCCDImagePackData_CSVHeaders_EqualLengthAs_CSVRow(t *testing.T) {
 	ccd := CCDImagePackData{}
-	headers := ccd.CSVHeaders()
-	row := ccd.CSVRow()
+	headers := schema.CSVHeaders(ccd.Columns())
+	row := schema.CSVRow(ccd.Columns(), ccd.Values())
 	if len(headers) != len(row) {
 		t.Errorf(
-			"CCDImagePackData.CSVHeaders() length %v != CCDImagePackData.CSVRow() length %v",
+			"schema.CSVHeaders() length %v != schema.CSVRow() length %v",
 			len(headers),
 			len(row),
 		)
@@ -328,12 +328,12 @@ func TestCCDImagePackData_CSVRow(t *testing.T) {
 		"30",
 		"31",
 	}
-	if got := ccd.CSVRow(); !reflect.DeepEqual(got, want) {
-		t.Errorf("CCDImagePackData.CSVRow() = %v, want %v", got, want)
+	if got := schema.CSVRow(ccd.Columns(), ccd.Values()); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema.CSVRow() = %v, want %v", got, want)
 	}
 }
 
-func TestCCDImagePackData_SetParquet(t *testing.T) {
+func TestCCDImagePackData_Values(t *testing.T) {
 	ccd := CCDImagePackData{
 		CCDSEL:  5,
 		EXPTS:   10,
@@ -363,39 +363,50 @@ func TestCCDImagePackData_SetParquet(t *testing.T) {
 		NBC:     31,
 	}
 
-	want := parquetrow.ParquetRow{
-		CCDSEL:             5,
-		EXPNanoseconds:     10750000000,
-		EXPDate:            ccd.Time(GpsTime),
-		WDWMode:            "Automatic",
-		WDWInputDataWindow: "14..3",
-		WDWOV:              13,
-		JPEGQ:              101,
-		FRAME:              14,
-		NROW:               15,
-		NRBIN:              16,
-		NRSKIP:             17,
-		NCOL:               18,
-		NCBINFPGAColumns:   64,
-		NCBINCCDColumns:    72,
-		NCSKIP:             19,
-		NFLUSH:             20,
-		TEXPMS:             21,
-		GAINMode:           "Low",
-		GAINTiming:         "Full",
-		TEMP:               22,
-		FBINOV:             23,
-		LBLNK:              24,
-		TBLNK:              25,
-		ZERO:               26,
-		TIMING1:            27,
-		TIMING2:            28,
-		VERSION:            29,
-		TIMING3:            30,
-		NBC:                31,
-	}
-	row := parquetrow.ParquetRow{}
-	if ccd.SetParquet(&row); !reflect.DeepEqual(row, want) {
-		t.Errorf("CCDImagePackData.SetParquet() = %v, want %v", row, want)
+	want := map[string]interface{}{
+		"CCDSEL":             int64(5),
+		"EXPNanoseconds":     int64(10750000000),
+		"EXPDate":            ccd.Time(GpsTime),
+		"WDWMode":            "Automatic",
+		"WDWInputDataWindow": "14..3",
+		"WDWOV":              int64(13),
+		"JPEGQ":              int64(101),
+		"FRAME":              int64(14),
+		"NROW":               int64(15),
+		"NRBIN":              int64(16),
+		"NRSKIP":             int64(17),
+		"NCOL":               int64(18),
+		"NCBINFPGAColumns":   int64(64),
+		"NCBINCCDColumns":    int64(72),
+		"NCSKIP":             int64(19),
+		"NFLUSH":             int64(20),
+		"TEXPMS":             int64(21),
+		"GAINMode":           "Low",
+		"GAINTiming":         "Full",
+		"TEMP":               int64(22),
+		"FBINOV":             int64(23),
+		"LBLNK":              int64(24),
+		"TBLNK":              int64(25),
+		"ZERO":               int64(26),
+		"TIMING1":            int64(27),
+		"TIMING2":            int64(28),
+		"VERSION":            int64(29),
+		"TIMING3":            int64(30),
+		"NBC":                int64(31),
+	}
+	columns := ccd.Columns()
+	values := ccd.Values()
+	if len(values) != len(columns) {
+		t.Fatalf("CCDImagePackData.Values() has %v values, want %v", len(values), len(columns))
+	}
+	for name, value := range want {
+		idx := schema.Index(columns, name)
+		if idx < 0 {
+			t.Errorf("CCDImagePackData.Columns() lacks %v", name)
+			continue
+		}
+		if !reflect.DeepEqual(values[idx], value) {
+			t.Errorf("CCDImagePackData.Values() %v = %v, want %v", name, values[idx], value)
+		}
 	}
 }
@@ -3,16 +3,14 @@ package aez
 import (
 	"bytes"
 	"encoding/binary"
-	"encoding/json"
-	"fmt"
 	"image"
 	"image/png"
 	"io"
 	"log"
 	"path/filepath"
-	"time"
+	"sync"
 
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 // CCDImage is a container for the invariant CCDImagePackData header and the variable BadColumns that follow
@@ -20,6 +18,23 @@ type CCDImage struct {
 	PackData      *CCDImagePackData
 	BadColumns    []uint16
 	ImageFileName string
+
+	decoded decodedImage
+}
+
+// decodedImage holds the latest decoded image so that it can be shared by
+// all outputs of the record, the image is dropped once encoded as PNG
+type decodedImage struct {
+	lock   sync.Mutex
+	buffer []byte
+	image  *image.Gray16
+	png    []byte
+}
+
+func (decoded *decodedImage) holds(buffer []byte) bool {
+	return (decoded.image != nil || decoded.png != nil) &&
+		len(buffer) == len(decoded.buffer) &&
+		(len(buffer) == 0 || &buffer[0] == &decoded.buffer[0])
 }
 
 // NewCCDImage reads buf into a complete CCDImage
@@ -38,26 +53,43 @@ func NewCCDImage(
 		return nil, err
 	}
 	imgFileName := getGrayscaleImageName(originName, packData, rid)
-	return &CCDImage{packData, badColumns, imgFileName}, nil
+	return &CCDImage{PackData: packData, BadColumns: badColumns, ImageFileName: imgFileName}, nil
 }
 
-// Image returns the 16bit gray image and the name of the file/bucket object
+// Image returns the 16bit gray image
+//
+// The image is decoded once per buffer, further calls return the same image.
 func (ccd *CCDImage) Image(
 	buf []byte,
 ) *image.Gray16 {
+	ccd.decoded.lock.Lock()
+	defer ccd.decoded.lock.Unlock()
+	return ccd.image(buf)
+}
+
+func (ccd *CCDImage) image(buf []byte) *image.Gray16 {
+	if ccd.decoded.holds(buf) && ccd.decoded.image != nil {
+		return ccd.decoded.image
+	}
 	imgData := getImageData(
 		buf,
 		ccd.PackData,
 		ccd.ImageFileName,
 	)
 	_, shift, _ := ccd.PackData.WDW.InputDataWindow()
-	return getGrayscaleImage(
+	img := getGrayscaleImage(
 		imgData,
 		int(ccd.PackData.NCOL+NCOLStartOffset),
 		int(ccd.PackData.NROW),
 		shift,
 		ccd.ImageFileName,
 	)
+	if !ccd.decoded.holds(buf) {
+		ccd.decoded.buffer = buf
+		ccd.decoded.png = nil
+	}
+	ccd.decoded.image = img
+	return img
 }
 
 // CSVSpecifications returns the specs used in creating the struct
@@ -65,90 +97,52 @@ func (ccd *CCDImage) CSVSpecifications() []string {
 	return []string{"AEZ", Specification}
 }
 
-// CSVHeaders returns the exportable field names
-func (ccd *CCDImage) CSVHeaders() []string {
-	return append(ccd.PackData.CSVHeaders(), "BC", "ImageName")
+// ccdImageReport holds the exported CCDImage fields that follow the pack data
+type ccdImageReport struct {
+	BadColumns []uint16 `csv:"BC" description:"The bad columns"`
+	ImageName  string   `description:"The name of the image file associated with these measurements"`
+	ImageData  []byte   `optional:"true" description:"The image data encoded as a 16 bit grey scale PNG (only in parquet)"`
 }
 
-// CSVRow returns the exportable field values
-func (ccd *CCDImage) CSVRow() []string {
-	row := ccd.PackData.CSVRow()
-	return append(row, fmt.Sprintf("%v", ccd.BadColumns), ccd.ImageFileName)
+// Columns returns the exported columns
+func (ccd *CCDImage) Columns() []schema.Column {
+	return append((&CCDImagePackData{}).Columns(), schema.Columns(ccdImageReport{}, noWarnings{})...)
+}
+
+// Values returns the exported values, the image data is left out since it is
+// held by the data record
+func (ccd *CCDImage) Values() []interface{} {
+	return append(
+		ccd.PackData.Values(),
+		schema.Values(
+			ccdImageReport{BadColumns: ccd.BadColumns, ImageName: ccd.ImageFileName},
+			noWarnings{},
+		)...,
+	)
 }
 
 // MarshalJSON jsonifies content
 func (ccd *CCDImage) MarshalJSON() ([]byte, error) {
-	wdwhigh, wdwlow, _ := ccd.PackData.WDW.InputDataWindow()
-	wdwMode := ccd.PackData.WDW.Mode()
-	gainMode := ccd.PackData.GAIN.Mode()
-	gainTiming := ccd.PackData.GAIN.Timing()
-	return json.Marshal(&struct {
-		Specification      string `json:"specification"`
-		CCDSEL             uint8
-		EXPNanoseconds     int64
-		EXPDate            string
-		WDWMode            string
-		WDWInputDataWindow string
-		WDWOV              uint16
-		JPEGQ              uint8
-		FRAME              uint16
-		NROW               uint16
-		NRBIN              uint16
-		NRSKIP             uint16
-		NCOL               uint16
-		NCBINFPGAColumns   int
-		NCBINCCDColumns    int
-		NCSKIP             uint16
-		NFLUSH             uint16
-		TEXPMS             uint32
-		GAINMode           string
-		GAINTiming         string
-		GAINTruncation     uint8
-		TEMP               uint16
-		FBINOV             uint16
-		LBLNK              uint16
-		TBLNK              uint16
-		ZERO               uint16
-		TIMING1            uint16
-		TIMING2            uint16
-		VERSION            uint16
-		TIMING3            uint16
-		NBC                uint16
-		BC                 []uint16
-	}{
-		Specification,
-		ccd.PackData.CCDSEL,
-		ccd.PackData.Nanoseconds(),
-		ccd.PackData.Time(GpsTime).Format(time.RFC3339Nano),
-		(&wdwMode).String(),
-		fmt.Sprintf("%v..%v", wdwhigh, wdwlow),
-		ccd.PackData.WDWOV,
-		ccd.PackData.JPEGQ,
-		ccd.PackData.FRAME,
-		ccd.PackData.NROW,
-		ccd.PackData.NRBIN,
-		ccd.PackData.NRSKIP,
-		ccd.PackData.NCOL,
-		ccd.PackData.NCBIN.FPGAColumns(),
-		ccd.PackData.NCBIN.CCDColumns(),
-		ccd.PackData.NCSKIP,
-		ccd.PackData.NFLUSH,
-		ccd.PackData.TEXPMS,
-		(&gainMode).String(),
-		(&gainTiming).String(),
-		ccd.PackData.GAIN.Truncation(),
-		ccd.PackData.TEMP,
-		ccd.PackData.FBINOV,
-		ccd.PackData.LBLNK,
-		ccd.PackData.TBLNK,
-		ccd.PackData.ZERO,
-		ccd.PackData.TIMING1,
-		ccd.PackData.TIMING2,
-		ccd.PackData.VERSION,
-		ccd.PackData.TIMING3,
-		ccd.PackData.NBC,
-		ccd.BadColumns,
-	})
+	return CCDImageJSON(ccd.Values())
+}
+
+// CCDImageJSON returns the json of the values of the CCDImage columns as
+// written next to each image, named as in the csv and without the image
+func CCDImageJSON(values []interface{}) ([]byte, error) {
+	columns := []schema.Column{{Name: "specification", Type: schema.String}}
+	sidecarValues := []interface{}{Specification}
+	for idx, column := range schema.CSVNamed((&CCDImage{}).Columns()) {
+		if column.Name == "ImageName" || column.Type == schema.Bytes {
+			continue
+		}
+		columns = append(columns, column)
+		var value interface{}
+		if idx < len(values) {
+			value = values[idx]
+		}
+		sidecarValues = append(sidecarValues, value)
+	}
+	return schema.MarshalJSON(columns, sidecarValues)
 }
 
 // FullImageName returns the full image filename for a given prefix
@@ -159,7 +153,12 @@ func (ccd *CCDImage) FullImageName(prefix string) string {
 	return filepath.Join(prefix, ccd.ImageFileName)
 }
 
-func (ccd *CCDImage) getPNG(buffer []byte) []byte {
+// PNG returns the image encoded as PNG or nil if it couldn't be processed
+//
+// As with Image, the encoding is shared by further calls with the buffer.
+func (ccd *CCDImage) PNG(buffer []byte) []byte {
+	ccd.decoded.lock.Lock()
+	defer ccd.decoded.lock.Unlock()
 	recoverWrite := func() {
 		if r := recover(); r != nil {
 			log.Printf(
@@ -169,21 +168,17 @@ func (ccd *CCDImage) getPNG(buffer []byte) []byte {
 		}
 	}
 	defer recoverWrite()
-	img := ccd.Image(buffer)
+	if ccd.decoded.holds(buffer) && ccd.decoded.png != nil {
+		return ccd.decoded.png
+	}
+	img := ccd.image(buffer)
 	pngImg := bytes.NewBuffer([]byte{})
 	err := png.Encode(pngImg, img)
 	if err != nil {
 		log.Panicf("failed encoding %s: %s", ccd.ImageFileName, err)
 	}
-
-	return pngImg.Bytes()
-}
-
-// SetParquet sets the parquet representation of the CCDImage
-func (ccd *CCDImage) SetParquet(row *parquetrow.ParquetRow, buffer []byte) {
-	ccd.PackData.SetParquet(row)
-	pngImg := ccd.getPNG(buffer)
-	row.BC = ccd.BadColumns
-	row.ImageName = ccd.ImageFileName
-	row.ImageData = pngImg
+	ccd.decoded.png = pngImg.Bytes()
+	// The outputs only need the encoding from now on
+	ccd.decoded.image = nil
+	return ccd.decoded.png
 }
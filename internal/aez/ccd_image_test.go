@@ -8,7 +8,7 @@ import (
 	"reflect"
 	"testing"
 
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 func TestCCDImage_CSVSpecifications(t *testing.T) {
@@ -22,8 +22,8 @@ func TestCCDImage_CSVSpecifications(t *testing.T) {
 func TestCCDImage_CSVHeaders_AddsOwn(t *testing.T) {
 	ccdI := CCDImage{}
 	ccdIPD := CCDImagePackData{}
-	headersI := ccdI.CSVHeaders()
-	want := append(ccdIPD.CSVHeaders(), "BC", "ImageName")
+	headersI := schema.CSVHeaders(ccdI.Columns())
+	want := append(schema.CSVHeaders(ccdIPD.Columns()), "BC", "ImageName")
 
 	for i, header := range headersI {
 		if i < len(want) {
@@ -47,8 +47,8 @@ func TestCCDImage_CSVHeaders_AddsOwn(t *testing.T) {
 func TestCCDImage_CSVRow_AddsOwn(t *testing.T) {
 	ccdIPD := CCDImagePackData{}
 	ccdI := CCDImage{PackData: &ccdIPD, BadColumns: []uint16{42, 6, 7}, ImageFileName: "my_🖼️.png"}
-	rowI := ccdI.CSVRow()
-	want := append(ccdIPD.CSVRow(), "[42 6 7]", "my_🖼️.png")
+	rowI := schema.CSVRow(ccdI.Columns(), ccdI.Values())
+	want := append(schema.CSVRow(ccdIPD.Columns(), ccdIPD.Values()), "[42 6 7]", "my_🖼️.png")
 	for i, value := range rowI {
 		if i < len(want) {
 			if value != want[i] {
@@ -79,6 +79,16 @@ func TestCCDImage_MarshalJSON(t *testing.T) {
 	if json.Unmarshal(got, &js) != nil {
 		t.Errorf("DataRecord.MarshalJSON() = %v, not a valid json", string(got))
 	}
+	for _, key := range []string{"specification", "EXPDate", "NBC", "BC"} {
+		if _, ok := js[key]; !ok {
+			t.Errorf("CCDImage.MarshalJSON() = %v, lacks %v", string(got), key)
+		}
+	}
+	for _, key := range []string{"BadColumns", "ImageName", "ImageData"} {
+		if _, ok := js[key]; ok {
+			t.Errorf("CCDImage.MarshalJSON() = %v, has %v", string(got), key)
+		}
+	}
 }
 
 func TestNewCCDImage(t *testing.T) {
@@ -161,28 +171,75 @@ func TestCCDImage_FullImageName(t *testing.T) {
 	}
 }
 
-func TestCCDImage_SetParquet(t *testing.T) {
+func TestCCDImage_Values(t *testing.T) {
 	packData := CCDImagePackData{NBC: 2, JPEGQ: 95}
-	buf := getTestImage()
 	ccd := CCDImage{
 		PackData:      &packData,
 		BadColumns:    []uint16{1, 2},
 		ImageFileName: "my_rac_0_1.png",
 	}
-	want := parquetrow.ParquetRow{
-		EXPDate:            packData.Time(GpsTime),
-		WDWMode:            "Manual",
-		WDWInputDataWindow: "11..0",
-		NCBINFPGAColumns:   1,
-		GAINMode:           "High",
-		GAINTiming:         "Faster",
-		JPEGQ:              95,
-		NBC:                2,
-		BC:                 []uint16{1, 2},
-		ImageName:          "my_rac_0_1.png",
-	}
-	row := parquetrow.ParquetRow{}
-	if ccd.SetParquet(&row, buf); !reflect.DeepEqual(row, want) {
-		t.Errorf("CCDImage.SetParquet() = %v, want %v", row, want)
+	columns := ccd.Columns()
+	values := ccd.Values()
+	if len(values) != len(columns) {
+		t.Fatalf("CCDImage.Values() has %v values, want %v", len(values), len(columns))
+	}
+	want := map[string]interface{}{
+		"EXPDate":    packData.Time(GpsTime),
+		"WDWMode":    "Manual",
+		"GAINMode":   "High",
+		"JPEGQ":      int64(95),
+		"NBC":        int64(2),
+		"BadColumns": []int64{1, 2},
+		"ImageName":  "my_rac_0_1.png",
+		"ImageData":  nil,
+	}
+	for name, value := range want {
+		idx := schema.Index(columns, name)
+		if idx < 0 {
+			t.Errorf("CCDImage.Columns() lacks %v", name)
+			continue
+		}
+		if !reflect.DeepEqual(values[idx], value) {
+			t.Errorf("CCDImage.Values() %v = %v, want %v", name, values[idx], value)
+		}
+	}
+}
+
+func TestCCDImage_PNG(t *testing.T) {
+	packData := CCDImagePackData{NCOL: 501 - NCOLStartOffset, NROW: 250, JPEGQ: 95}
+	ccd := CCDImage{PackData: &packData, ImageFileName: "my_rac_0_1.png"}
+	got := ccd.PNG(getTestImage())
+	if !bytes.HasPrefix(got, []byte("\x89PNG")) {
+		t.Errorf("CCDImage.PNG() = %v, want a png", got)
+	}
+}
+
+func TestCCDImage_Image_Shared(t *testing.T) {
+	packData := CCDImagePackData{NCOL: 2 - NCOLStartOffset, NROW: 1, JPEGQ: JPEGQUncompressed16bit}
+	ccd := CCDImage{PackData: &packData, ImageFileName: "my_rac_0_1.png"}
+	buffer := []byte{1, 0, 2, 0}
+	first := ccd.Image(buffer)
+	if second := ccd.Image(buffer); second != first {
+		t.Error("CCDImage.Image() decoded the same buffer twice")
+	}
+	png := ccd.PNG(buffer)
+	if ccd.decoded.image != nil {
+		t.Error("CCDImage.PNG() kept the decoded image after encoding it")
+	}
+	if again := ccd.PNG(buffer); &again[0] != &png[0] {
+		t.Error("CCDImage.PNG() encoded the same buffer twice")
+	}
+	if got := ccd.Image(buffer); !reflect.DeepEqual(got, first) {
+		t.Error("CCDImage.Image() after CCDImage.PNG() gave another image")
+	}
+	if again := ccd.PNG(buffer); &again[0] != &png[0] {
+		t.Error("CCDImage.PNG() encoded the same buffer again after CCDImage.Image()")
+	}
+	other := []byte{3, 0, 4, 0}
+	if got := ccd.Image(other); got == first || got.Gray16At(0, 0).Y == first.Gray16At(0, 0).Y {
+		t.Error("CCDImage.Image() reused the image of another buffer")
+	}
+	if got := ccd.PNG(other); bytes.Equal(got, png) {
+		t.Error("CCDImage.PNG() reused the png of another buffer")
 	}
 }
@@ -1,40 +1,34 @@
 package aez
 
 import (
-	"encoding/binary"
-	"fmt"
 	"io"
 	"log"
-	"math"
-	"reflect"
 
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 type gate uint16
 
-var voltageConstant float64 = 2.5 / (math.Pow(2, 12) - 1)
-
-func (data *gate) voltage() float64 {
-	return voltageConstant * float64(*data) * 10
+func (data *gate) voltage(calibration *Calibration) float64 {
+	return calibration.VoltageConstant * float64(*data) * calibration.CPRUScales.Gate
 }
 
 type subs uint16
 
-func (data *subs) voltage() float64 {
-	return voltageConstant * float64(*data) * 11 / 1.5
+func (data *subs) voltage(calibration *Calibration) float64 {
+	return calibration.VoltageConstant * float64(*data) * calibration.CPRUScales.Subs
 }
 
 type rd uint16
 
-func (data *rd) voltage() float64 {
-	return voltageConstant * float64(*data) * 17 / 1.5
+func (data *rd) voltage(calibration *Calibration) float64 {
+	return calibration.VoltageConstant * float64(*data) * calibration.CPRUScales.RD
 }
 
 type od uint16
 
-func (data *od) voltage() float64 {
-	return voltageConstant * float64(*data) * 32 / 1.5
+func (data *od) voltage(calibration *Calibration) float64 {
+	return calibration.VoltageConstant * float64(*data) * calibration.CPRUScales.OD
 }
 
 type cpruStat uint8
@@ -76,124 +70,94 @@ type CPRU struct {
 	VSUBS3 subs // CCD3 Substrate Voltage 0..4095
 	VRD3   rd   // CCD3 Reset transistor Drain Voltage 0..4095
 	VOD3   od   // CCD3 Output Drain Voltage 0..4095
+
+	calibration *Calibration
 }
 
 // CPRUReport structure
 type CPRUReport struct {
-	VGATE0       float64 // CCD0 Gate Voltage
-	VSUBS0       float64 // CCD0 Substrate Voltage
-	VRD0         float64 // CCD0 Reset transistor Drain Voltage
-	VOD0         float64 // CCD0 Output Drain Voltage
-	Overvoltage0 bool    // CCD0 overvoltage fault
-	Power0       bool    // CCD0 overvoltage fault
-	VGATE1       float64 // CCD1 Gate Voltage
-	VSUBS1       float64 // CCD1 Substrate Voltage
-	VRD1         float64 // CCD1 Reset transistor Drain Voltage
-	VOD1         float64 // CCD1 Output Drain Voltage
-	Overvoltage1 bool    // CCD1 overvoltage fault
-	Power1       bool    // CCD1 overvoltage fault
-	VGATE2       float64 // CCD2 Gate Voltage
-	VSUBS2       float64 // CCD2 Substrate Voltage
-	VRD2         float64 // CCD2 Reset transistor Drain Voltage
-	VOD2         float64 // CCD2 Output Drain Voltage
-	Overvoltage2 bool    // CCD2 overvoltage fault
-	Power2       bool    // CCD2 overvoltage fault
-	VGATE3       float64 // CCD3 Gate Voltage
-	VSUBS3       float64 // CCD3 Substrate Voltage
-	VRD3         float64 // CCD3 Reset transistor Drain Voltage
-	VOD3         float64 // CCD3 Output Drain Voltage
-	Overvoltage3 bool    // CCD3 overvoltage fault
-	Power3       bool    // CCD3 overvoltage fault
+	VGATE0       float64 `unit:"V" description:"CCD0 gate voltage"`
+	VSUBS0       float64 `unit:"V" description:"CCD0 substrate voltage"`
+	VRD0         float64 `unit:"V" description:"CCD0 reset transistor drain voltage"`
+	VOD0         float64 `unit:"V" description:"CCD0 output drain voltage"`
+	Overvoltage0 bool    `description:"If CCD0 over voltage fault registered"`
+	Power0       bool    `description:"If CCD0 power is enabled"`
+	VGATE1       float64 `unit:"V" description:"CCD1 gate voltage"`
+	VSUBS1       float64 `unit:"V" description:"CCD1 substrate voltage"`
+	VRD1         float64 `unit:"V" description:"CCD1 reset transistor drain voltage"`
+	VOD1         float64 `unit:"V" description:"CCD1 output drain voltage"`
+	Overvoltage1 bool    `description:"If CCD1 over voltage fault registered"`
+	Power1       bool    `description:"If CCD1 power is enabled"`
+	VGATE2       float64 `unit:"V" description:"CCD2 gate voltage"`
+	VSUBS2       float64 `unit:"V" description:"CCD2 substrate voltage"`
+	VRD2         float64 `unit:"V" description:"CCD2 reset transistor drain voltage"`
+	VOD2         float64 `unit:"V" description:"CCD2 output drain voltage"`
+	Overvoltage2 bool    `description:"If CCD2 over voltage fault registered"`
+	Power2       bool    `description:"If CCD2 power is enabled"`
+	VGATE3       float64 `unit:"V" description:"CCD3 gate voltage"`
+	VSUBS3       float64 `unit:"V" description:"CCD3 substrate voltage"`
+	VRD3         float64 `unit:"V" description:"CCD3 reset transistor drain voltage"`
+	VOD3         float64 `unit:"V" description:"CCD3 output drain voltage"`
+	Overvoltage3 bool    `description:"If CCD3 over voltage fault registered"`
+	Power3       bool    `description:"If CCD3 power is enabled"`
+	Calibration  string  `description:"ID of the calibration used"`
 }
 
 // NewCPRU reads buffer into a new CPRU
 func NewCPRU(buf io.Reader) (*CPRU, error) {
 	cpru := CPRU{}
-	err := binary.Read(buf, binary.LittleEndian, &cpru)
+	err := readPacketFields(buf, &cpru)
 	return &cpru, err
 }
 
+// SetCalibration sets the calibration used to convert into useful units
+func (cpru *CPRU) SetCalibration(calibration *Calibration) {
+	cpru.calibration = calibration
+}
+
 // Report transforms CPRU data to useful units
 func (cpru *CPRU) Report() CPRUReport {
+	calibration := calibrationOrBuiltin(cpru.calibration)
 	return CPRUReport{
-		VGATE0:       cpru.VGATE0.voltage(),
-		VSUBS0:       cpru.VSUBS0.voltage(),
-		VRD0:         cpru.VRD0.voltage(),
-		VOD0:         cpru.VOD0.voltage(),
+		VGATE0:       cpru.VGATE0.voltage(calibration),
+		VSUBS0:       cpru.VSUBS0.voltage(calibration),
+		VRD0:         cpru.VRD0.voltage(calibration),
+		VOD0:         cpru.VOD0.voltage(calibration),
 		Overvoltage0: cpru.STAT.overvoltageFault(0),
 		Power0:       cpru.STAT.powerEnabled(0),
-		VGATE1:       cpru.VGATE1.voltage(),
-		VSUBS1:       cpru.VSUBS1.voltage(),
-		VRD1:         cpru.VRD1.voltage(),
-		VOD1:         cpru.VOD1.voltage(),
+		VGATE1:       cpru.VGATE1.voltage(calibration),
+		VSUBS1:       cpru.VSUBS1.voltage(calibration),
+		VRD1:         cpru.VRD1.voltage(calibration),
+		VOD1:         cpru.VOD1.voltage(calibration),
 		Overvoltage1: cpru.STAT.overvoltageFault(1),
 		Power1:       cpru.STAT.powerEnabled(1),
-		VGATE2:       cpru.VGATE2.voltage(),
-		VSUBS2:       cpru.VSUBS2.voltage(),
-		VRD2:         cpru.VRD2.voltage(),
-		VOD2:         cpru.VOD2.voltage(),
+		VGATE2:       cpru.VGATE2.voltage(calibration),
+		VSUBS2:       cpru.VSUBS2.voltage(calibration),
+		VRD2:         cpru.VRD2.voltage(calibration),
+		VOD2:         cpru.VOD2.voltage(calibration),
 		Overvoltage2: cpru.STAT.overvoltageFault(2),
 		Power2:       cpru.STAT.powerEnabled(2),
-		VGATE3:       cpru.VGATE3.voltage(),
-		VSUBS3:       cpru.VSUBS3.voltage(),
-		VRD3:         cpru.VRD3.voltage(),
-		VOD3:         cpru.VOD3.voltage(),
+		VGATE3:       cpru.VGATE3.voltage(calibration),
+		VSUBS3:       cpru.VSUBS3.voltage(calibration),
+		VRD3:         cpru.VRD3.voltage(calibration),
+		VOD3:         cpru.VOD3.voltage(calibration),
 		Overvoltage3: cpru.STAT.overvoltageFault(3),
 		Power3:       cpru.STAT.powerEnabled(3),
+		Calibration:  calibration.ID,
 	}
 }
 
 // CSVSpecifications returns the specs used in creating the struct
 func (cpru *CPRU) CSVSpecifications() []string {
-	return []string{"AEZ", Specification}
+	return calibrationSpecifications(cpru.calibration)
 }
 
-// CSVHeaders returns the field names
-func (cpru *CPRU) CSVHeaders() []string {
-	return csvHeader(cpru.Report())
-}
-
-// CSVRow returns the field values
-func (cpru *CPRU) CSVRow() []string {
-	val := reflect.Indirect(reflect.ValueOf(cpru.Report()))
-	values := make([]string, val.NumField())
-	for i := range values {
-		valueField := val.Field(i)
-		switch valueField.Interface().(type) {
-		case bool:
-			values[i] = fmt.Sprintf("%v", valueField.Bool())
-		default:
-			values[i] = fmt.Sprintf("%v", valueField.Float())
-		}
-	}
-	return values
+// Columns returns the exported columns
+func (cpru *CPRU) Columns() []schema.Column {
+	return schema.Columns(CPRUReport{}, noWarnings{})
 }
 
-// SetParquet sets the parquet representation of the CPRU
-func (cpru *CPRU) SetParquet(row *parquetrow.ParquetRow) {
-	report := cpru.Report()
-	row.VGATE0 = report.VGATE0
-	row.VSUBS0 = report.VSUBS0
-	row.VRD0 = report.VRD0
-	row.VOD0 = report.VOD0
-	row.Overvoltage0 = report.Overvoltage0
-	row.Power0 = report.Power0
-	row.VGATE1 = report.VGATE1
-	row.VSUBS1 = report.VSUBS1
-	row.VRD1 = report.VRD1
-	row.VOD1 = report.VOD1
-	row.Overvoltage1 = report.Overvoltage1
-	row.Power1 = report.Power1
-	row.VGATE2 = report.VGATE2
-	row.VSUBS2 = report.VSUBS2
-	row.VRD2 = report.VRD2
-	row.VOD2 = report.VOD2
-	row.Overvoltage2 = report.Overvoltage2
-	row.Power2 = report.Power2
-	row.VGATE3 = report.VGATE3
-	row.VSUBS3 = report.VSUBS3
-	row.VRD3 = report.VRD3
-	row.VOD3 = report.VOD3
-	row.Overvoltage3 = report.Overvoltage3
-	row.Power3 = report.Power3
+// Values returns the exported values
+func (cpru *CPRU) Values() []interface{} {
+	return schema.Values(cpru.Report(), noWarnings{})
 }
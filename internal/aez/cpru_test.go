@@ -5,7 +5,7 @@ import (
 	"reflect"
 	"testing"
 
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 func TestCPRU_Report(t *testing.T) {
@@ -36,22 +36,22 @@ func TestCPRU_Report(t *testing.T) {
 		fields fields
 		want   CPRUReport
 	}{
-		{"Transforms VGATE0", fields{VGATE0: 10}, CPRUReport{VGATE0: gate10.voltage()}},
-		{"Transforms VSUBS0", fields{VSUBS0: 10}, CPRUReport{VSUBS0: subs10.voltage()}},
-		{"Transforms VRD0", fields{VRD0: 10}, CPRUReport{VRD0: rd10.voltage()}},
-		{"Transforms VOD0", fields{VOD0: 10}, CPRUReport{VOD0: od10.voltage()}},
-		{"Transforms VGATE1", fields{VGATE1: 10}, CPRUReport{VGATE1: gate10.voltage()}},
-		{"Transforms VSUBS1", fields{VSUBS1: 10}, CPRUReport{VSUBS1: subs10.voltage()}},
-		{"Transforms VRD1", fields{VRD1: 10}, CPRUReport{VRD1: rd10.voltage()}},
-		{"Transforms VOD1", fields{VOD1: 10}, CPRUReport{VOD1: od10.voltage()}},
-		{"Transforms VGATE2", fields{VGATE2: 10}, CPRUReport{VGATE2: gate10.voltage()}},
-		{"Transforms VSUBS2", fields{VSUBS2: 10}, CPRUReport{VSUBS2: subs10.voltage()}},
-		{"Transforms VRD2", fields{VRD2: 10}, CPRUReport{VRD2: rd10.voltage()}},
-		{"Transforms VOD2", fields{VOD2: 10}, CPRUReport{VOD2: od10.voltage()}},
-		{"Transforms VGATE3", fields{VGATE3: 10}, CPRUReport{VGATE3: gate10.voltage()}},
-		{"Transforms VSUBS3", fields{VSUBS3: 10}, CPRUReport{VSUBS3: subs10.voltage()}},
-		{"Transforms VRD3", fields{VRD3: 10}, CPRUReport{VRD3: rd10.voltage()}},
-		{"Transforms VOD3", fields{VOD3: 10}, CPRUReport{VOD3: od10.voltage()}},
+		{"Transforms VGATE0", fields{VGATE0: 10}, CPRUReport{VGATE0: gate10.voltage(&BuiltinCalibration), Calibration: "builtin"}},
+		{"Transforms VSUBS0", fields{VSUBS0: 10}, CPRUReport{VSUBS0: subs10.voltage(&BuiltinCalibration), Calibration: "builtin"}},
+		{"Transforms VRD0", fields{VRD0: 10}, CPRUReport{VRD0: rd10.voltage(&BuiltinCalibration), Calibration: "builtin"}},
+		{"Transforms VOD0", fields{VOD0: 10}, CPRUReport{VOD0: od10.voltage(&BuiltinCalibration), Calibration: "builtin"}},
+		{"Transforms VGATE1", fields{VGATE1: 10}, CPRUReport{VGATE1: gate10.voltage(&BuiltinCalibration), Calibration: "builtin"}},
+		{"Transforms VSUBS1", fields{VSUBS1: 10}, CPRUReport{VSUBS1: subs10.voltage(&BuiltinCalibration), Calibration: "builtin"}},
+		{"Transforms VRD1", fields{VRD1: 10}, CPRUReport{VRD1: rd10.voltage(&BuiltinCalibration), Calibration: "builtin"}},
+		{"Transforms VOD1", fields{VOD1: 10}, CPRUReport{VOD1: od10.voltage(&BuiltinCalibration), Calibration: "builtin"}},
+		{"Transforms VGATE2", fields{VGATE2: 10}, CPRUReport{VGATE2: gate10.voltage(&BuiltinCalibration), Calibration: "builtin"}},
+		{"Transforms VSUBS2", fields{VSUBS2: 10}, CPRUReport{VSUBS2: subs10.voltage(&BuiltinCalibration), Calibration: "builtin"}},
+		{"Transforms VRD2", fields{VRD2: 10}, CPRUReport{VRD2: rd10.voltage(&BuiltinCalibration), Calibration: "builtin"}},
+		{"Transforms VOD2", fields{VOD2: 10}, CPRUReport{VOD2: od10.voltage(&BuiltinCalibration), Calibration: "builtin"}},
+		{"Transforms VGATE3", fields{VGATE3: 10}, CPRUReport{VGATE3: gate10.voltage(&BuiltinCalibration), Calibration: "builtin"}},
+		{"Transforms VSUBS3", fields{VSUBS3: 10}, CPRUReport{VSUBS3: subs10.voltage(&BuiltinCalibration), Calibration: "builtin"}},
+		{"Transforms VRD3", fields{VRD3: 10}, CPRUReport{VRD3: rd10.voltage(&BuiltinCalibration), Calibration: "builtin"}},
+		{"Transforms VOD3", fields{VOD3: 10}, CPRUReport{VOD3: od10.voltage(&BuiltinCalibration), Calibration: "builtin"}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -92,14 +92,15 @@ func TestCPRU_CSVHeaders(t *testing.T) {
 				"VGATE1", "VSUBS1", "VRD1", "VOD1", "Overvoltage1", "Power1",
 				"VGATE2", "VSUBS2", "VRD2", "VOD2", "Overvoltage2", "Power2",
 				"VGATE3", "VSUBS3", "VRD3", "VOD3", "Overvoltage3", "Power3",
+				"Calibration",
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			cpru := CPRU{}
-			if got := cpru.CSVHeaders(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("CPRU.CSVHeaders() = %v, want %v", got, tt.want)
+			if got := schema.CSVHeaders(cpru.Columns()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schema.CSVHeaders() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -157,30 +158,31 @@ func TestCPRU_CSVRow(t *testing.T) {
 				VGATE3: 14, VSUBS3: 15, VRD3: 16, VOD3: 17,
 			},
 			[]string{
-				fmt.Sprintf("%v", gate2.voltage()),
-				fmt.Sprintf("%v", subs3.voltage()),
-				fmt.Sprintf("%v", rd4.voltage()),
-				fmt.Sprintf("%v", od5.voltage()),
+				fmt.Sprintf("%v", gate2.voltage(&BuiltinCalibration)),
+				fmt.Sprintf("%v", subs3.voltage(&BuiltinCalibration)),
+				fmt.Sprintf("%v", rd4.voltage(&BuiltinCalibration)),
+				fmt.Sprintf("%v", od5.voltage(&BuiltinCalibration)),
 				fmt.Sprintf("%v", cpruStat1.overvoltageFault(0)),
 				fmt.Sprintf("%v", cpruStat1.powerEnabled(0)),
-				fmt.Sprintf("%v", gate6.voltage()),
-				fmt.Sprintf("%v", subs7.voltage()),
-				fmt.Sprintf("%v", rd8.voltage()),
-				fmt.Sprintf("%v", od9.voltage()),
+				fmt.Sprintf("%v", gate6.voltage(&BuiltinCalibration)),
+				fmt.Sprintf("%v", subs7.voltage(&BuiltinCalibration)),
+				fmt.Sprintf("%v", rd8.voltage(&BuiltinCalibration)),
+				fmt.Sprintf("%v", od9.voltage(&BuiltinCalibration)),
 				fmt.Sprintf("%v", cpruStat1.overvoltageFault(1)),
 				fmt.Sprintf("%v", cpruStat1.powerEnabled(1)),
-				fmt.Sprintf("%v", gate10.voltage()),
-				fmt.Sprintf("%v", subs11.voltage()),
-				fmt.Sprintf("%v", rd12.voltage()),
-				fmt.Sprintf("%v", od13.voltage()),
+				fmt.Sprintf("%v", gate10.voltage(&BuiltinCalibration)),
+				fmt.Sprintf("%v", subs11.voltage(&BuiltinCalibration)),
+				fmt.Sprintf("%v", rd12.voltage(&BuiltinCalibration)),
+				fmt.Sprintf("%v", od13.voltage(&BuiltinCalibration)),
 				fmt.Sprintf("%v", cpruStat1.overvoltageFault(2)),
 				fmt.Sprintf("%v", cpruStat1.powerEnabled(2)),
-				fmt.Sprintf("%v", gate14.voltage()),
-				fmt.Sprintf("%v", subs15.voltage()),
-				fmt.Sprintf("%v", rd16.voltage()),
-				fmt.Sprintf("%v", od17.voltage()),
+				fmt.Sprintf("%v", gate14.voltage(&BuiltinCalibration)),
+				fmt.Sprintf("%v", subs15.voltage(&BuiltinCalibration)),
+				fmt.Sprintf("%v", rd16.voltage(&BuiltinCalibration)),
+				fmt.Sprintf("%v", od17.voltage(&BuiltinCalibration)),
 				fmt.Sprintf("%v", cpruStat1.overvoltageFault(3)),
 				fmt.Sprintf("%v", cpruStat1.powerEnabled(3)),
+				"builtin",
 			},
 		},
 	}
@@ -205,8 +207,8 @@ func TestCPRU_CSVRow(t *testing.T) {
 				VRD3:   tt.fields.VRD3,
 				VOD3:   tt.fields.VOD3,
 			}
-			if got := cpru.CSVRow(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("CPRU.CSVRow() = %v, want %v", got, tt.want)
+			if got := schema.CSVRow(cpru.Columns(), cpru.Values()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schema.CSVRow() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -237,7 +239,7 @@ func TestCPRU_CSVSpecifications(t *testing.T) {
 		fields fields
 		want   []string
 	}{
-		{"Genereates spec", fields{}, []string{"AEZ", Specification}},
+		{"Genereates spec", fields{}, []string{"AEZ", Specification, "CALIBRATION", "builtin"}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -323,7 +325,7 @@ func Test_cpruStat_powerEnabled(t *testing.T) {
 	}
 }
 
-func TestCPRU_SetParquet(t *testing.T) {
+func TestCPRU_Values(t *testing.T) {
 	cpru := CPRU{
 		STAT:   1,
 		VGATE0: 2, VSUBS0: 3, VRD0: 4, VOD0: 5,
@@ -331,34 +333,45 @@ func TestCPRU_SetParquet(t *testing.T) {
 		VGATE2: 10, VSUBS2: 11, VRD2: 12, VOD2: 13,
 		VGATE3: 14, VSUBS3: 15, VRD3: 16, VOD3: 17,
 	}
-	want := parquetrow.ParquetRow{
-		VGATE0:       0.01221001221001221,
-		VSUBS0:       0.013431013431013432,
-		VRD0:         0.027676027676027674,
-		VOD0:         0.06512006512006512,
-		Overvoltage0: false,
-		Power0:       false,
-		VGATE1:       0.03663003663003663,
-		VSUBS1:       0.03133903133903134,
-		VRD1:         0.05535205535205535,
-		VOD1:         0.1172161172161172,
-		Overvoltage1: false,
-		Power1:       false,
-		VGATE2:       0.06105006105006105,
-		VSUBS2:       0.04924704924704925,
-		VRD2:         0.08302808302808302,
-		VOD2:         0.1693121693121693,
-		Overvoltage2: false,
-		Power2:       false,
-		VGATE3:       0.08547008547008547,
-		VSUBS3:       0.06715506715506715,
-		VRD3:         0.1107041107041107,
-		VOD3:         0.2214082214082214,
-		Overvoltage3: false,
-		Power3:       true,
+	want := map[string]interface{}{
+		"VGATE0":       0.01221001221001221,
+		"VSUBS0":       0.01343101343101343,
+		"VRD0":         0.027676027676027677,
+		"VOD0":         0.06512006512006512,
+		"Overvoltage0": false,
+		"Power0":       false,
+		"VGATE1":       0.03663003663003663,
+		"VSUBS1":       0.03133903133903134,
+		"VRD1":         0.055352055352055354,
+		"VOD1":         0.1172161172161172,
+		"Overvoltage1": false,
+		"Power1":       false,
+		"VGATE2":       0.06105006105006105,
+		"VSUBS2":       0.04924704924704925,
+		"VRD2":         0.08302808302808304,
+		"VOD2":         0.1693121693121693,
+		"Overvoltage2": false,
+		"Power2":       false,
+		"VGATE3":       0.08547008547008547,
+		"VSUBS3":       0.06715506715506715,
+		"VRD3":         0.11070411070411071,
+		"VOD3":         0.2214082214082214,
+		"Overvoltage3": false,
+		"Power3":       true,
 	}
-	row := parquetrow.ParquetRow{}
-	if cpru.SetParquet(&row); !reflect.DeepEqual(row, want) {
-		t.Errorf("CPRU.SetParquet() = %v, want %v", row, want)
+	columns := cpru.Columns()
+	values := cpru.Values()
+	if len(values) != len(columns) {
+		t.Fatalf("CPRU.Values() has %v values, want %v", len(values), len(columns))
+	}
+	for name, value := range want {
+		idx := schema.Index(columns, name)
+		if idx < 0 {
+			t.Errorf("CPRU.Columns() lacks %v", name)
+			continue
+		}
+		if !reflect.DeepEqual(values[idx], value) {
+			t.Errorf("CPRU.Values() %v = %v, want %v", name, values[idx], value)
+		}
 	}
 }
@@ -132,3 +132,9 @@ func (rid *RID) String() string {
 func (rid *RID) MarshalJSON() ([]byte, error) {
 	return json.Marshal(rid.String())
 }
+
+// noWarnings keeps the Warnings column that the parquet files of exports
+// without calculation warnings have always had, it is never set
+type noWarnings struct {
+	Warnings []error `csv:"-" optional:"true" description:"Warnings from the calculations, always empty"`
+}
@@ -1,13 +1,10 @@
 package aez
 
 import (
-	"encoding/binary"
 	"fmt"
 	"io"
-	"reflect"
-	"strings"
 
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 var htrTemperatures = [...]float64{
@@ -31,19 +28,19 @@ var htrResistances = [...]float64{
 
 type htr uint16
 
-func (data *htr) voltage() float64 {
-	return voltageConstant * float64(*data)
+func (data *htr) voltage(calibration *Calibration) float64 {
+	return calibration.VoltageConstant * float64(*data)
 }
 
-func (data *htr) resistance() float64 {
-	return 3.3*3900/data.voltage() - 3900
+func (data *htr) resistance(calibration *Calibration) float64 {
+	return calibration.HTR.Resistance(data.voltage(calibration))
 }
 
-func (data *htr) temperature() (float64, error) {
-	return Interpolate(
-		data.resistance(),
-		htrResistances[:],
-		htrTemperatures[:],
+// temperature returns the temperature and its uncertainty
+func (data *htr) temperature(calibration *Calibration) (float64, float64, error) {
+	return calibration.HTR.TemperatureWithUncertainty(
+		data.voltage(calibration),
+		calibration.VoltageConstant,
 	)
 }
 
@@ -61,42 +58,60 @@ type HTR struct {
 	HTR8A  htr
 	HTR8B  htr
 	HTR8OD htr
+
+	calibration *Calibration
 }
 
 // HTRReport housekeeping report returns data on all heater regulators in useful units.
 type HTRReport struct {
-	HTR1A    float64 // Heater 1 Temperature sense A ⁰C
-	HTR1B    float64 // Heater 1 Temperature sense B ⁰C
-	HTR1OD   float64 // Heater 1 Output Drive setting voltage
-	HTR2A    float64
-	HTR2B    float64
-	HTR2OD   float64
-	HTR7A    float64
-	HTR7B    float64
-	HTR7OD   float64
-	HTR8A    float64
-	HTR8B    float64
-	HTR8OD   float64
-	Warnings []error
+	HTR1A            float64 `unit:"⁰C" description:"Heater 1 temperature sense A"`
+	HTR1B            float64 `unit:"⁰C" description:"Heater 1 temperature sense B"`
+	HTR1OD           float64 `unit:"V" description:"Heater 1 output drive setting"`
+	HTR2A            float64 `unit:"⁰C" description:"Heater 2 temperature sense A"`
+	HTR2B            float64 `unit:"⁰C" description:"Heater 2 temperature sense B"`
+	HTR2OD           float64 `unit:"V" description:"Heater 2 output drive setting"`
+	HTR7A            float64 `unit:"⁰C" description:"Heater 7 temperature sense A"`
+	HTR7B            float64 `unit:"⁰C" description:"Heater 7 temperature sense B"`
+	HTR7OD           float64 `unit:"V" description:"Heater 7 output drive setting"`
+	HTR8A            float64 `unit:"⁰C" description:"Heater 8 temperature sense A"`
+	HTR8B            float64 `unit:"⁰C" description:"Heater 8 temperature sense B"`
+	HTR8OD           float64 `unit:"V" description:"Heater 8 output drive setting"`
+	HTR1AUncertainty float64 `unit:"⁰C" description:"Uncertainty of HTR1A"`
+	HTR1BUncertainty float64 `unit:"⁰C" description:"Uncertainty of HTR1B"`
+	HTR2AUncertainty float64 `unit:"⁰C" description:"Uncertainty of HTR2A"`
+	HTR2BUncertainty float64 `unit:"⁰C" description:"Uncertainty of HTR2B"`
+	HTR7AUncertainty float64 `unit:"⁰C" description:"Uncertainty of HTR7A"`
+	HTR7BUncertainty float64 `unit:"⁰C" description:"Uncertainty of HTR7B"`
+	HTR8AUncertainty float64 `unit:"⁰C" description:"Uncertainty of HTR8A"`
+	HTR8BUncertainty float64 `unit:"⁰C" description:"Uncertainty of HTR8B"`
+	Clamped          bool    `description:"If a temperature was clamped to the end of its table, its uncertainty is then NaN"`
+	Calibration      string  `description:"ID of the calibration used"`
+	Warnings         []error `optional:"true" description:"Warnings from the temperature calculations, separated by '|' in csv"`
 }
 
 // NewHTR reads an HTR from buffer
 func NewHTR(buf io.Reader) (*HTR, error) {
 	htr := HTR{}
-	err := binary.Read(buf, binary.LittleEndian, &htr)
+	err := readPacketFields(buf, &htr)
 	return &htr, err
 }
 
+// SetCalibration sets the calibration used to convert into useful units
+func (htr *HTR) SetCalibration(calibration *Calibration) {
+	htr.calibration = calibration
+}
+
 // Report returns a HTRReport with useful units
 func (htr *HTR) Report() HTRReport {
-	temp1a, err1a := htr.HTR1A.temperature()
-	temp1b, err1b := htr.HTR1B.temperature()
-	temp2a, err2a := htr.HTR2A.temperature()
-	temp2b, err2b := htr.HTR2B.temperature()
-	temp7a, err7a := htr.HTR7A.temperature()
-	temp7b, err7b := htr.HTR7B.temperature()
-	temp8a, err8a := htr.HTR8A.temperature()
-	temp8b, err8b := htr.HTR8B.temperature()
+	calibration := calibrationOrBuiltin(htr.calibration)
+	temp1a, unc1a, err1a := htr.HTR1A.temperature(calibration)
+	temp1b, unc1b, err1b := htr.HTR1B.temperature(calibration)
+	temp2a, unc2a, err2a := htr.HTR2A.temperature(calibration)
+	temp2b, unc2b, err2b := htr.HTR2B.temperature(calibration)
+	temp7a, unc7a, err7a := htr.HTR7A.temperature(calibration)
+	temp7b, unc7b, err7b := htr.HTR7B.temperature(calibration)
+	temp8a, unc8a, err8a := htr.HTR8A.temperature(calibration)
+	temp8b, unc8b, err8b := htr.HTR8B.temperature(calibration)
 	var warnings []error
 	if err1a != nil {
 		warning := fmt.Errorf("HTR1A: %v", err1a.Error())
@@ -130,78 +145,48 @@ func (htr *HTR) Report() HTRReport {
 		warning := fmt.Errorf("HTR8B: %v", err8b.Error())
 		warnings = append(warnings, warning)
 	}
+	clamped := false
+	for _, err := range []error{err1a, err1b, err2a, err2b, err7a, err7b, err8a, err8b} {
+		clamped = clamped || isClamped(err)
+	}
 	return HTRReport{
-		HTR1A:    temp1a,
-		HTR1B:    temp1b,
-		HTR1OD:   htr.HTR1OD.voltage(),
-		HTR2A:    temp2a,
-		HTR2B:    temp2b,
-		HTR2OD:   htr.HTR2OD.voltage(),
-		HTR7A:    temp7a,
-		HTR7B:    temp7b,
-		HTR7OD:   htr.HTR7OD.voltage(),
-		HTR8A:    temp8a,
-		HTR8B:    temp8b,
-		HTR8OD:   htr.HTR8OD.voltage(),
-		Warnings: warnings,
+		HTR1A:            temp1a,
+		HTR1B:            temp1b,
+		HTR1OD:           htr.HTR1OD.voltage(calibration),
+		HTR2A:            temp2a,
+		HTR2B:            temp2b,
+		HTR2OD:           htr.HTR2OD.voltage(calibration),
+		HTR7A:            temp7a,
+		HTR7B:            temp7b,
+		HTR7OD:           htr.HTR7OD.voltage(calibration),
+		HTR8A:            temp8a,
+		HTR8B:            temp8b,
+		HTR8OD:           htr.HTR8OD.voltage(calibration),
+		HTR1AUncertainty: unc1a,
+		HTR1BUncertainty: unc1b,
+		HTR2AUncertainty: unc2a,
+		HTR2BUncertainty: unc2b,
+		HTR7AUncertainty: unc7a,
+		HTR7BUncertainty: unc7b,
+		HTR8AUncertainty: unc8a,
+		HTR8BUncertainty: unc8b,
+		Clamped:          clamped,
+		Calibration:      calibration.ID,
+		Warnings:         warnings,
 	}
 }
 
-// CSVHeaders returns the field names
-func (htr *HTR) CSVHeaders() []string {
-	return csvHeader(htr.Report())
+// Columns returns the exported columns
+func (htr *HTR) Columns() []schema.Column {
+	return schema.Columns(HTRReport{})
 }
 
-// CSVRow returns the field values
-func (htr *HTR) CSVRow() []string {
-	val := reflect.Indirect(reflect.ValueOf(htr.Report()))
-	values := make([]string, val.NumField())
-	t := val.Type()
-	for i := range values {
-		valueField := val.Field(i)
-		if t.Field(i).Name == "Warnings" {
-			if valueField.Len() == 0 {
-				values[i] = ""
-			} else {
-				var errs = make([]string, valueField.Len())
-				for j, l := 0, valueField.Len(); j < l; j++ {
-					errs[j] = fmt.Sprintf("%v", valueField.Index(j).Elem())
-				}
-				values[i] = strings.Join(errs, "|")
-			}
-		} else {
-			values[i] = fmt.Sprintf("%v", valueField.Float())
-		}
-
-	}
-	return values
+// Values returns the exported values
+func (htr *HTR) Values() []interface{} {
+	return schema.Values(htr.Report())
 }
 
 // CSVSpecifications returns the specs used in creating the struct
 func (htr *HTR) CSVSpecifications() []string {
-	return []string{"AEZ", Specification}
-}
-
-// SetParquet sets the parquet representation of the HTR
-func (htr *HTR) SetParquet(row *parquetrow.ParquetRow) {
-	report := htr.Report()
-	var warnings []string
-	if report.Warnings != nil {
-		for err := range report.Warnings {
-			warnings = append(warnings, report.Warnings[err].Error())
-		}
-	}
-	row.HTR1A = report.HTR1A
-	row.HTR1B = report.HTR1B
-	row.HTR1OD = report.HTR1OD
-	row.HTR2A = report.HTR2A
-	row.HTR2B = report.HTR2B
-	row.HTR2OD = report.HTR2OD
-	row.HTR7A = report.HTR7A
-	row.HTR7B = report.HTR7B
-	row.HTR7OD = report.HTR7OD
-	row.HTR8A = report.HTR8A
-	row.HTR8B = report.HTR8B
-	row.HTR8OD = report.HTR8OD
-	row.Warnings = warnings
+	return calibrationSpecifications(htr.calibration)
 }
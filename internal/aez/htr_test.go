@@ -1,10 +1,11 @@
 package aez
 
 import (
+	"math"
 	"reflect"
 	"testing"
 
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 func TestHTR_Report(t *testing.T) {
@@ -23,7 +24,7 @@ func TestHTR_Report(t *testing.T) {
 		HTR8OD htr
 	}
 	htr10 := htr(10)
-	temperature, _ := htr10.temperature()
+	temperature, _, _ := htr10.temperature(&BuiltinCalibration)
 	tests := []struct {
 		name   string
 		fields fields
@@ -32,16 +33,16 @@ func TestHTR_Report(t *testing.T) {
 	}{
 		{"HTR1A is temperature", fields{HTR1A: 10}, "HTR1A", temperature},
 		{"HTR1B is temperature", fields{HTR1B: 10}, "HTR1B", temperature},
-		{"HTR1OD is voltage", fields{HTR1OD: 10}, "HTR1OD", htr10.voltage()},
+		{"HTR1OD is voltage", fields{HTR1OD: 10}, "HTR1OD", htr10.voltage(&BuiltinCalibration)},
 		{"HTR2A is temperature", fields{HTR2A: 10}, "HTR2A", temperature},
 		{"HTR2B is temperature", fields{HTR2B: 10}, "HTR2B", temperature},
-		{"HTR2OD is voltage", fields{HTR2OD: 10}, "HTR2OD", htr10.voltage()},
+		{"HTR2OD is voltage", fields{HTR2OD: 10}, "HTR2OD", htr10.voltage(&BuiltinCalibration)},
 		{"HTR7A is temperature", fields{HTR7A: 10}, "HTR7A", temperature},
 		{"HTR7B is temperature", fields{HTR7B: 10}, "HTR7B", temperature},
-		{"HTR7OD is voltage", fields{HTR7OD: 10}, "HTR7OD", htr10.voltage()},
+		{"HTR7OD is voltage", fields{HTR7OD: 10}, "HTR7OD", htr10.voltage(&BuiltinCalibration)},
 		{"HTR8A is temperature", fields{HTR8A: 10}, "HTR8A", temperature},
 		{"HTR8B is temperature", fields{HTR8B: 10}, "HTR8B", temperature},
-		{"HTR8OD is voltage", fields{HTR8OD: 10}, "HTR8OD", htr10.voltage()},
+		{"HTR8OD is voltage", fields{HTR8OD: 10}, "HTR8OD", htr10.voltage(&BuiltinCalibration)},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -74,7 +75,7 @@ func TestHTR_CSVSpecifications(t *testing.T) {
 		name string
 		want []string
 	}{
-		{"Genereates spec", []string{"AEZ", Specification}},
+		{"Genereates spec", []string{"AEZ", Specification, "CALIBRATION", "builtin"}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -98,6 +99,12 @@ func TestHTR_CSVHeaders(t *testing.T) {
 				"HTR2A", "HTR2B", "HTR2OD",
 				"HTR7A", "HTR7B", "HTR7OD",
 				"HTR8A", "HTR8B", "HTR8OD",
+				"HTR1AUncertainty", "HTR1BUncertainty",
+				"HTR2AUncertainty", "HTR2BUncertainty",
+				"HTR7AUncertainty", "HTR7BUncertainty",
+				"HTR8AUncertainty", "HTR8BUncertainty",
+				"Clamped",
+				"Calibration",
 				"Warnings",
 			},
 		},
@@ -105,8 +112,8 @@ func TestHTR_CSVHeaders(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			htr := HTR{}
-			if got := htr.CSVHeaders(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("HTR.CSVHeaders() = %v, want %v", got, tt.want)
+			if got := schema.CSVHeaders(htr.Columns()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schema.CSVHeaders() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -153,6 +160,16 @@ func TestHTR_CSVRow(t *testing.T) {
 				"-55",
 				"-55",
 				"0.007326007326007326",
+				"NaN",
+				"NaN",
+				"NaN",
+				"NaN",
+				"NaN",
+				"NaN",
+				"NaN",
+				"NaN",
+				"true",
+				"builtin",
 				"HTR1A: 2.107716e+07 is too large for interpolator. Returning value for maximum.|HTR1B: 1.053663e+07 is too large for interpolator. Returning value for maximum.|HTR2A: 5.266365e+06 is too large for interpolator. Returning value for maximum.|HTR2B: 4.212312e+06 is too large for interpolator. Returning value for maximum.|HTR7A: 3.0076799999999995e+06 is too large for interpolator. Returning value for maximum.|HTR7B: 2.6312325e+06 is too large for interpolator. Returning value for maximum.|HTR8A: 2.104206e+06 is too large for interpolator. Returning value for maximum.|HTR8B: 1.9125599999999998e+06 is too large for interpolator. Returning value for maximum.",
 			},
 		},
@@ -173,34 +190,43 @@ func TestHTR_CSVRow(t *testing.T) {
 				HTR8B:  tt.fields.HTR8B,
 				HTR8OD: tt.fields.HTR8OD,
 			}
-			if got := htr.CSVRow(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("HTR.CSVRow() = %v, want %v", got, tt.want)
+			if got := schema.CSVRow(htr.Columns(), htr.Values()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schema.CSVRow() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
-func TestHTR_SetParquet(t *testing.T) {
+func TestHTR_Values(t *testing.T) {
 	htr := HTR{
 		HTR1A: 1, HTR1B: 2, HTR1OD: 3,
 		HTR2A: 4, HTR2B: 5, HTR2OD: 6,
 		HTR7A: 7, HTR7B: 8, HTR7OD: 9,
 		HTR8A: 10, HTR8B: 11, HTR8OD: 12,
 	}
-	want := parquetrow.ParquetRow{
-		HTR1A:  -55,
-		HTR1B:  -55,
-		HTR1OD: 0.0018315018315018315,
-		HTR2A:  -55,
-		HTR2B:  -55,
-		HTR2OD: 0.003663003663003663,
-		HTR7A:  -55,
-		HTR7B:  -55,
-		HTR7OD: 0.005494505494505494,
-		HTR8A:  -55,
-		HTR8B:  -55,
-		HTR8OD: 0.007326007326007326,
-		Warnings: []string{
+	want := map[string]interface{}{
+		"HTR1A":            -55.0,
+		"HTR1B":            -55.0,
+		"HTR1OD":           0.0018315018315018315,
+		"HTR2A":            -55.0,
+		"HTR2B":            -55.0,
+		"HTR2OD":           0.003663003663003663,
+		"HTR7A":            -55.0,
+		"HTR7B":            -55.0,
+		"HTR7OD":           0.005494505494505494,
+		"HTR8A":            -55.0,
+		"HTR8B":            -55.0,
+		"HTR8OD":           0.007326007326007326,
+		"HTR1AUncertainty": math.NaN(),
+		"HTR1BUncertainty": math.NaN(),
+		"HTR2AUncertainty": math.NaN(),
+		"HTR2BUncertainty": math.NaN(),
+		"HTR7AUncertainty": math.NaN(),
+		"HTR7BUncertainty": math.NaN(),
+		"HTR8AUncertainty": math.NaN(),
+		"HTR8BUncertainty": math.NaN(),
+		"Clamped":          true,
+		"Warnings": []string{
 			"HTR1A: 2.107716e+07 is too large for interpolator. Returning value for maximum.",
 			"HTR1B: 1.053663e+07 is too large for interpolator. Returning value for maximum.",
 			"HTR2A: 5.266365e+06 is too large for interpolator. Returning value for maximum.",
@@ -211,8 +237,25 @@ func TestHTR_SetParquet(t *testing.T) {
 			"HTR8B: 1.9125599999999998e+06 is too large for interpolator. Returning value for maximum.",
 		},
 	}
-	row := parquetrow.ParquetRow{}
-	if htr.SetParquet(&row); !reflect.DeepEqual(row, want) {
-		t.Errorf("HTR.SetParquet() = %v, want %v", row, want)
+	columns := htr.Columns()
+	values := htr.Values()
+	if len(values) != len(columns) {
+		t.Fatalf("HTR.Values() has %v values, want %v", len(values), len(columns))
+	}
+	for name, value := range want {
+		idx := schema.Index(columns, name)
+		if idx < 0 {
+			t.Errorf("HTR.Columns() lacks %v", name)
+			continue
+		}
+		if number, ok := value.(float64); ok && math.IsNaN(number) {
+			if got, ok := values[idx].(float64); !ok || !math.IsNaN(got) {
+				t.Errorf("HTR.Values() %v = %v, want NaN", name, values[idx])
+			}
+			continue
+		}
+		if !reflect.DeepEqual(values[idx], value) {
+			t.Errorf("HTR.Values() %v = %v, want %v", name, values[idx], value)
+		}
 	}
 }
@@ -1,8 +1,9 @@
 package aez
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"math"
 )
 
 // ErrXTooLarge x value sent to interpolator is too large
@@ -33,7 +34,79 @@ func (err ErrXTooSmall) Error() string {
 	)
 }
 
-// Note! Assumes xSlice contains a monotonically increasing values!
+// ErrXOutsideRange x value sent to interpolator is outside the table and
+// the extrapolation policy is not to clamp
+type ErrXOutsideRange struct {
+	X      float64
+	Policy ExtrapolationPolicy
+}
+
+func (err ErrXOutsideRange) Error() string {
+	if err.Policy == ExtrapolateNaN {
+		return fmt.Sprintf("%v is outside interpolator range. Returning NaN.", err.X)
+	}
+	return fmt.Sprintf(
+		"%v is outside interpolator range. Returning extrapolated value.",
+		err.X,
+	)
+}
+
+// ErrInvalidTable the table given to the interpolator can't be used
+var ErrInvalidTable = errors.New("invalid interpolation table")
+
+// InterpolationModel is the method used to convert between table values
+type InterpolationModel string
+
+const (
+	// ModelLinear interpolates linearly between neighbouring table entries
+	ModelLinear InterpolationModel = "linear"
+	// ModelMonotoneCubic uses a monotonicity preserving cubic Hermite spline
+	ModelMonotoneCubic InterpolationModel = "cubic"
+	// ModelSteinhartHart uses the Steinhart-Hart thermistor equation
+	ModelSteinhartHart InterpolationModel = "steinhart-hart"
+)
+
+// ExtrapolationPolicy is what to do with x values outside the table
+type ExtrapolationPolicy string
+
+const (
+	// ExtrapolateClamp returns the value of the closest table end
+	ExtrapolateClamp ExtrapolationPolicy = "clamp"
+	// ExtrapolateLinear continues the model beyond the table
+	ExtrapolateLinear ExtrapolationPolicy = "linear"
+	// ExtrapolateNaN returns NaN
+	ExtrapolateNaN ExtrapolationPolicy = "nan"
+)
+
+func validateTable(xSlice []float64, ySlice []float64) error {
+	if len(xSlice) != len(ySlice) {
+		return fmt.Errorf(
+			"%w: slices x and y not of same length (%d != %d)",
+			ErrInvalidTable,
+			len(xSlice),
+			len(ySlice),
+		)
+	}
+	if len(xSlice) < 2 {
+		return fmt.Errorf(
+			"%w: slices x and y must be at least of length 2 (%d < 2)",
+			ErrInvalidTable,
+			len(xSlice),
+		)
+	}
+	for i := 1; i < len(xSlice); i++ {
+		if !(xSlice[i] < xSlice[i-1]) {
+			return fmt.Errorf(
+				"%w: slice x must be monotonically decreasing (index %d)",
+				ErrInvalidTable,
+				i,
+			)
+		}
+	}
+	return nil
+}
+
+// Note! Assumes xSlice contains a monotonically decreasing values!
 func getXIndex(x float64, xSlice []float64) int {
 	var i int
 	for i = range xSlice {
@@ -47,35 +120,164 @@ func getXIndex(x float64, xSlice []float64) int {
 // Interpolate y value corresponding to x value, given
 // xSlice (e.g. resistances or voltages), and
 // ySlice (e.g. temperatures or photometer values).
+//
+// The xSlice must be monotonically decreasing. Values outside the table are
+// clamped to the closest end of the table.
 func Interpolate(
 	x float64, xSlice []float64, ySlice []float64,
 ) (float64, error) {
-	if len(xSlice) != len(ySlice) {
-		log.Fatalf(
-			"Slices x and y not of same length (%d != %d).\n",
-			len(xSlice), len(ySlice),
-		)
+	return interpolateTable(x, xSlice, ySlice, ModelLinear, ExtrapolateClamp)
+}
+
+// InterpolateMonotoneCubic is as Interpolate but uses a monotone cubic spline
+func InterpolateMonotoneCubic(
+	x float64, xSlice []float64, ySlice []float64,
+) (float64, error) {
+	return interpolateTable(x, xSlice, ySlice, ModelMonotoneCubic, ExtrapolateClamp)
+}
+
+// interpolateTable interpolates with model inside the table and applies the
+// policy outside of it
+//
+// The table is validated on each call, use a tableInterpolator to convert
+// many values.
+func interpolateTable(
+	x float64,
+	xSlice []float64,
+	ySlice []float64,
+	model InterpolationModel,
+	policy ExtrapolationPolicy,
+) (float64, error) {
+	interpolator, err := newTableInterpolator(xSlice, ySlice, model)
+	if err != nil {
+		return math.NaN(), err
 	}
-	if len(xSlice) < 2 {
-		log.Fatalf(
-			"Slices x and y must be at least of length 2 (%d < 2).\n",
-			len(xSlice),
-		)
+	return interpolator.interpolate(x, policy)
+}
+
+// tableInterpolator interpolates in a validated table
+type tableInterpolator struct {
+	xSlice   []float64
+	ySlice   []float64
+	model    InterpolationModel
+	tangents []float64 // Of the monotone cubic spline
+}
+
+// newTableInterpolator validates the table and prepares the model
+func newTableInterpolator(
+	xSlice []float64,
+	ySlice []float64,
+	model InterpolationModel,
+) (*tableInterpolator, error) {
+	err := validateTable(xSlice, ySlice)
+	if err != nil {
+		return nil, err
 	}
+	interpolator := tableInterpolator{xSlice: xSlice, ySlice: ySlice, model: model}
+	if model == ModelMonotoneCubic {
+		interpolator.tangents = monotoneCubicTangents(xSlice, ySlice)
+	}
+	return &interpolator, nil
+}
 
+// interpolate interpolates with the model inside the table and applies the
+// policy outside of it
+func (interpolator *tableInterpolator) interpolate(
+	x float64,
+	policy ExtrapolationPolicy,
+) (float64, error) {
+	xSlice := interpolator.xSlice
+	ySlice := interpolator.ySlice
+	last := len(xSlice) - 1
+	if x > xSlice[0] || x < xSlice[last] {
+		return extrapolate(x, xSlice, ySlice, policy)
+	}
 	i := getXIndex(x, xSlice)
 	if i == 0 {
-		return ySlice[0], ErrXTooLarge(x)
-	} else if i == len(xSlice)-1 {
-		return ySlice[len(xSlice)-1], ErrXTooSmall(x)
+		i = 1
+	}
+	if interpolator.model == ModelMonotoneCubic {
+		return monotoneCubic(x, i-1, xSlice, ySlice, interpolator.tangents), nil
 	}
-
 	var xs, ys [2]float64
 	copy(xs[:], xSlice[i-1:i+1])
 	copy(ys[:], ySlice[i-1:i+1])
 	return interpolate(xs, ys, x), nil
 }
 
+func extrapolate(
+	x float64,
+	xSlice []float64,
+	ySlice []float64,
+	policy ExtrapolationPolicy,
+) (float64, error) {
+	last := len(xSlice) - 1
+	switch policy {
+	case ExtrapolateLinear:
+		var xs, ys [2]float64
+		if x > xSlice[0] {
+			copy(xs[:], xSlice[:2])
+			copy(ys[:], ySlice[:2])
+		} else {
+			copy(xs[:], xSlice[last-1:])
+			copy(ys[:], ySlice[last-1:])
+		}
+		return interpolate(xs, ys, x), ErrXOutsideRange{x, policy}
+	case ExtrapolateNaN:
+		return math.NaN(), ErrXOutsideRange{x, policy}
+	default:
+		if x > xSlice[0] {
+			return ySlice[0], ErrXTooLarge(x)
+		}
+		return ySlice[last], ErrXTooSmall(x)
+	}
+}
+
 func interpolate(xs [2]float64, ys [2]float64, x float64) float64 {
 	return ((ys[1]-ys[0])/(xs[1]-xs[0]))*(x-xs[0]) + ys[0]
 }
+
+// monotoneCubicTangents returns the Fritsch-Carlson tangents of the table
+func monotoneCubicTangents(xSlice []float64, ySlice []float64) []float64 {
+	n := len(xSlice)
+	secants := make([]float64, n-1)
+	for k := range secants {
+		secants[k] = (ySlice[k+1] - ySlice[k]) / (xSlice[k+1] - xSlice[k])
+	}
+	tangents := make([]float64, n)
+	tangents[0] = secants[0]
+	tangents[n-1] = secants[n-2]
+	for k := 1; k < n-1; k++ {
+		if secants[k-1]*secants[k] > 0 {
+			tangents[k] = (secants[k-1] + secants[k]) / 2
+		}
+	}
+	for k, secant := range secants {
+		if secant == 0 {
+			tangents[k] = 0
+			tangents[k+1] = 0
+			continue
+		}
+		alpha := tangents[k] / secant
+		beta := tangents[k+1] / secant
+		if norm := alpha*alpha + beta*beta; norm > 9 {
+			tau := 3 / math.Sqrt(norm)
+			tangents[k] = tau * alpha * secant
+			tangents[k+1] = tau * beta * secant
+		}
+	}
+	return tangents
+}
+
+// monotoneCubic evaluates the spline segment starting at index k with the
+// tangents of the table
+func monotoneCubic(x float64, k int, xSlice []float64, ySlice []float64, tangents []float64) float64 {
+	h := xSlice[k+1] - xSlice[k]
+	t := (x - xSlice[k]) / h
+	t2 := t * t
+	t3 := t2 * t
+	return (2*t3-3*t2+1)*ySlice[k] +
+		(t3-2*t2+t)*h*tangents[k] +
+		(-2*t3+3*t2)*ySlice[k+1] +
+		(t3-t2)*h*tangents[k+1]
+}
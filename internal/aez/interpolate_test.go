@@ -1,6 +1,8 @@
 package aez
 
 import (
+	"errors"
+	"math"
 	"testing"
 )
 
@@ -100,6 +102,12 @@ func Test_Interpolate(t *testing.T) {
 			155,
 			true,
 		},
+		{
+			"Interpolate uses last table segment",
+			args{175, htrResistances[:], htrTemperatures[:]},
+			152.575,
+			false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -114,3 +122,79 @@ func Test_Interpolate(t *testing.T) {
 		})
 	}
 }
+
+func Test_Interpolate_invalidTable(t *testing.T) {
+	tests := []struct {
+		name         string
+		resistances  []float64
+		temperatures []float64
+	}{
+		{"Different lengths", []float64{2, 1}, []float64{1}},
+		{"Too short", []float64{1}, []float64{1}},
+		{"Not decreasing", []float64{1, 2}, []float64{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Interpolate(1.5, tt.resistances, tt.temperatures)
+			if !errors.Is(err, ErrInvalidTable) {
+				t.Errorf("Interpolate() error = %v, want %v", err, ErrInvalidTable)
+			}
+			if !math.IsNaN(got) {
+				t.Errorf("Interpolate() = %v, want NaN", got)
+			}
+		})
+	}
+}
+
+func Test_interpolateTable(t *testing.T) {
+	xs := []float64{4, 3, 2, 1}
+	ys := []float64{0, 1, 4, 9}
+	tests := []struct {
+		name    string
+		x       float64
+		model   InterpolationModel
+		policy  ExtrapolationPolicy
+		want    float64
+		wantErr error
+	}{
+		{"Linear inside", 1.5, ModelLinear, ExtrapolateClamp, 6.5, nil},
+		{"Cubic hits table", 2, ModelMonotoneCubic, ExtrapolateClamp, 4, nil},
+		{"Cubic inside", 2.5, ModelMonotoneCubic, ExtrapolateClamp, 2.25, nil},
+		{"Clamps too large", 5, ModelLinear, ExtrapolateClamp, 0, ErrXTooLarge(5)},
+		{"Clamps too small", 0, ModelMonotoneCubic, ExtrapolateClamp, 9, ErrXTooSmall(0)},
+		{
+			"Extrapolates too large",
+			5, ModelLinear, ExtrapolateLinear, -1, ErrXOutsideRange{5, ExtrapolateLinear},
+		},
+		{
+			"Extrapolates too small",
+			0, ModelMonotoneCubic, ExtrapolateLinear, 14, ErrXOutsideRange{0, ExtrapolateLinear},
+		},
+		{"NaN outside", 0, ModelLinear, ExtrapolateNaN, math.NaN(), ErrXOutsideRange{0, ExtrapolateNaN}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := interpolateTable(tt.x, xs, ys, tt.model, tt.policy)
+			if err != tt.wantErr {
+				t.Errorf("interpolateTable() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want && !(math.IsNaN(got) && math.IsNaN(tt.want)) {
+				t.Errorf("interpolateTable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterpolateMonotoneCubic(t *testing.T) {
+	previous := math.Inf(-1)
+	for r := htrResistances[0]; r >= htrResistances[len(htrResistances)-1]; r *= 0.99 {
+		got, err := InterpolateMonotoneCubic(r, htrResistances[:], htrTemperatures[:])
+		if err != nil {
+			t.Errorf("InterpolateMonotoneCubic(%v) unexpected error %v", r, err)
+		}
+		if got < previous {
+			t.Errorf("InterpolateMonotoneCubic(%v) = %v, not monotone (previous %v)", r, got, previous)
+		}
+		previous = got
+	}
+}
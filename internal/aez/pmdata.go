@@ -1,41 +1,122 @@
 package aez
 
 import (
-	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
-	"reflect"
+	"math"
 	"time"
 
 	"github.com/innosat-mats/rac-extract-payload/internal/ccsds"
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
+// errPMNoSamples is reported when a photometer input counter is zero
+var errPMNoSamples = errors.New("counter is zero, no samples to average")
+
+// pmValue returns the value unless it is NaN, which is exported as null
+func pmValue(value float64) *float64 {
+	if math.IsNaN(value) {
+		return nil
+	}
+	return &value
+}
+
+func pmMean(sum uint32, counter uint32) (*float64, error) {
+	if counter == 0 {
+		return nil, errPMNoSamples
+	}
+	return pmValue(float64(sum) / float64(counter)), nil
+}
+
+// pmVoltage returns the voltage of the mean, if the calibration has a
+// photometer voltage constant
+func pmVoltage(mean *float64, calibration *Calibration) *float64 {
+	if mean == nil || !calibration.PM.hasVoltage() {
+		return nil
+	}
+	return pmValue(calibration.PM.VoltageConstant * *mean)
+}
+
+// pmTemperature returns the temperature and its uncertainty, if the
+// calibration has a photometer thermistor table
+func pmTemperature(mean *float64, calibration *Calibration) (*float64, *float64, error) {
+	voltage := pmVoltage(mean, calibration)
+	if voltage == nil || !calibration.PM.hasThermistor() {
+		return nil, nil, nil
+	}
+	temperature, uncertainty, err := calibration.PM.Thermistor.TemperatureWithUncertainty(
+		*voltage,
+		calibration.PM.VoltageConstant,
+	)
+	return pmValue(temperature), pmValue(uncertainty), err
+}
+
 // PMData data from photometers
 type PMData struct {
-	EXPTS    uint32 // Exposure start time, seconds (CUC time format)
-	EXPTSS   uint16 // Exposure start time, subseconds (CUC time format)
-	PM1A     uint32 // Photometer 1, thermistor input A sum
-	PM1ACNTR uint32 // Photometer 1, thermistor input A counter
-	PM1B     uint32 // Photometer 1, thermistor input B sum
-	PM1BCNTR uint32 // Photometer 1, thermistor input B counter
-	PM1S     uint32 // Photometer 1, photo diode input SIG sum
-	PM1SCNTR uint32 // Photometer 1, photo diode input SIG counter
-	PM2A     uint32 // Photometer 2, thermistor input A sum
-	PM2ACNTR uint32 // Photometer 2, thermistor input A counter
-	PM2B     uint32 // Photometer 2, thermistor input B sum
-	PM2BCNTR uint32 // Photometer 2, thermistor input B counter
-	PM2S     uint32 // Photometer 2, photo diode input SIG sum
-	PM2SCNTR uint32 // Photometer 2, photo diode input SIG counter
+	EXPTS    uint32 `column:"-"` // Exposure start time, seconds (CUC time format)
+	EXPTSS   uint16 `column:"-"` // Exposure start time, subseconds (CUC time format)
+	PM1A     uint32 `description:"Photometer 1, thermistor input A sum"`
+	PM1ACNTR uint32 `description:"Photometer 1, thermistor input A counter"`
+	PM1B     uint32 `description:"Photometer 1, thermistor input B sum"`
+	PM1BCNTR uint32 `description:"Photometer 1, thermistor input B counter"`
+	PM1S     uint32 `description:"Photometer 1, photo diode input SIG sum"`
+	PM1SCNTR uint32 `description:"Photometer 1, photo diode input SIG counter"`
+	PM2A     uint32 `description:"Photometer 2, thermistor input A sum"`
+	PM2ACNTR uint32 `description:"Photometer 2, thermistor input A counter"`
+	PM2B     uint32 `description:"Photometer 2, thermistor input B sum"`
+	PM2BCNTR uint32 `description:"Photometer 2, thermistor input B counter"`
+	PM2S     uint32 `description:"Photometer 2, photo diode input SIG sum"`
+	PM2SCNTR uint32 `description:"Photometer 2, photo diode input SIG counter"`
+
+	calibration *Calibration
+}
+
+// pmTime holds the exposure time replacing the raw CUC time fields
+type pmTime struct {
+	PMTime        time.Time `csv:"PMTIME" description:"The exposure time"`
+	PMNanoseconds int64     `csv:"PMNANO" unit:"ns" description:"The exposure time since epoch"`
+}
+
+// PMReport holds the photometer data in useful units
+//
+// Values that can't be derived are null, means when the counter is zero and
+// voltages and temperatures when the calibration lacks the photometer
+// parameters.
+type PMReport struct {
+	PM1AAVG          *float64 `description:"Photometer 1, thermistor input A mean ADC value"`
+	PM1AT            *float64 `unit:"⁰C" description:"Photometer 1, thermistor input A temperature"`
+	PM1BAVG          *float64 `description:"Photometer 1, thermistor input B mean ADC value"`
+	PM1BT            *float64 `unit:"⁰C" description:"Photometer 1, thermistor input B temperature"`
+	PM1SAVG          *float64 `description:"Photometer 1, photo diode input SIG mean ADC value"`
+	PM1SV            *float64 `unit:"V" description:"Photometer 1, photo diode input SIG voltage"`
+	PM2AAVG          *float64 `description:"Photometer 2, thermistor input A mean ADC value"`
+	PM2AT            *float64 `unit:"⁰C" description:"Photometer 2, thermistor input A temperature"`
+	PM2BAVG          *float64 `description:"Photometer 2, thermistor input B mean ADC value"`
+	PM2BT            *float64 `unit:"⁰C" description:"Photometer 2, thermistor input B temperature"`
+	PM2SAVG          *float64 `description:"Photometer 2, photo diode input SIG mean ADC value"`
+	PM2SV            *float64 `unit:"V" description:"Photometer 2, photo diode input SIG voltage"`
+	PM1ATUncertainty *float64 `unit:"⁰C" description:"Uncertainty of PM1AT"`
+	PM1BTUncertainty *float64 `unit:"⁰C" description:"Uncertainty of PM1BT"`
+	PM2ATUncertainty *float64 `unit:"⁰C" description:"Uncertainty of PM2AT"`
+	PM2BTUncertainty *float64 `unit:"⁰C" description:"Uncertainty of PM2BT"`
+	Clamped          bool     `description:"If a temperature was clamped to the end of its table, its uncertainty is then null"`
+	Calibration      string   `description:"ID of the calibration used"`
+	Warnings         []error  `optional:"true" description:"Warnings from the calculations, separated by '|' in csv"`
 }
 
 // NewPMData reads a PMData from reader
 func NewPMData(buf io.Reader) (*PMData, error) {
 	pm := PMData{}
-	err := binary.Read(buf, binary.LittleEndian, &pm)
+	err := readPacketFields(buf, &pm)
 	return &pm, err
 }
 
+// SetCalibration sets the calibration used to convert into useful units
+func (pm *PMData) SetCalibration(calibration *Calibration) {
+	pm.calibration = calibration
+}
+
 // Time returns the measurement time in UTC
 func (pm *PMData) Time(epoch time.Time) time.Time {
 	if (epoch == time.Time{}) {
@@ -49,53 +130,79 @@ func (pm *PMData) Nanoseconds() int64 {
 	return ccsds.UnsegmentedTimeNanoseconds(pm.EXPTS, pm.EXPTSS)
 }
 
-// CSVSpecifications returns the version of the spec used
-func (pm *PMData) CSVSpecifications() []string {
-	return []string{"AEZ", Specification}
+// Report returns a PMReport with useful units
+func (pm *PMData) Report() PMReport {
+	calibration := calibrationOrBuiltin(pm.calibration)
+	var warnings []error
+	warn := func(name string, err error) {
+		if err != nil {
+			warnings = append(warnings, fmt.Errorf("%v: %v", name, err.Error()))
+		}
+	}
+
+	pm1a, err := pmMean(pm.PM1A, pm.PM1ACNTR)
+	warn("PM1A", err)
+	pm1b, err := pmMean(pm.PM1B, pm.PM1BCNTR)
+	warn("PM1B", err)
+	pm1s, err := pmMean(pm.PM1S, pm.PM1SCNTR)
+	warn("PM1S", err)
+	pm2a, err := pmMean(pm.PM2A, pm.PM2ACNTR)
+	warn("PM2A", err)
+	pm2b, err := pmMean(pm.PM2B, pm.PM2BCNTR)
+	warn("PM2B", err)
+	pm2s, err := pmMean(pm.PM2S, pm.PM2SCNTR)
+	warn("PM2S", err)
+
+	clamped := false
+	temperature := func(name string, mean *float64) (*float64, *float64) {
+		temp, uncertainty, err := pmTemperature(mean, calibration)
+		warn(name, err)
+		clamped = clamped || isClamped(err)
+		return temp, uncertainty
+	}
+	temp1a, unc1a := temperature("PM1AT", pm1a)
+	temp1b, unc1b := temperature("PM1BT", pm1b)
+	temp2a, unc2a := temperature("PM2AT", pm2a)
+	temp2b, unc2b := temperature("PM2BT", pm2b)
+
+	return PMReport{
+		PM1AAVG:          pm1a,
+		PM1AT:            temp1a,
+		PM1BAVG:          pm1b,
+		PM1BT:            temp1b,
+		PM1SAVG:          pm1s,
+		PM1SV:            pmVoltage(pm1s, calibration),
+		PM2AAVG:          pm2a,
+		PM2AT:            temp2a,
+		PM2BAVG:          pm2b,
+		PM2BT:            temp2b,
+		PM2SAVG:          pm2s,
+		PM2SV:            pmVoltage(pm2s, calibration),
+		PM1ATUncertainty: unc1a,
+		PM1BTUncertainty: unc1b,
+		PM2ATUncertainty: unc2a,
+		PM2BTUncertainty: unc2b,
+		Clamped:          clamped,
+		Calibration:      calibration.ID,
+		Warnings:         warnings,
+	}
 }
 
-// CSVHeaders returns the header row
-func (pm *PMData) CSVHeaders() []string {
-	var headers []string
-	headers = append(headers, "PMTIME", "PMNANO")
-	// We don't need the raw CUC Time fields, instead the iso date and
-	// nanoseconds are included above.
-	return append(headers, csvHeader(pm, "EXPTS", "EXPTSS")...)
+// CSVSpecifications returns the version of the spec used
+func (pm *PMData) CSVSpecifications() []string {
+	return calibrationSpecifications(pm.calibration)
 }
 
-// CSVRow returns the data row
-func (pm *PMData) CSVRow() []string {
-	var row []string
-	pmTime := pm.Time(GpsTime)
-	row = append(row, pmTime.Format(time.RFC3339Nano), fmt.Sprintf("%v", pm.Nanoseconds()))
-	val := reflect.Indirect(reflect.ValueOf(pm))
-	t := val.Type()
-	for i := 0; i < val.NumField(); i++ {
-		name := t.Field(i).Name
-		// We don't need the raw CUC Time fields, instead the iso date and
-		// nanoseconds are included above.
-		if name != "EXPTS" && name != "EXPTSS" {
-			valueField := val.Field(i)
-			row = append(row, fmt.Sprintf("%v", valueField.Uint()))
-		}
-	}
-	return row
+// Columns returns the exported columns
+func (pm *PMData) Columns() []schema.Column {
+	return schema.Columns(pmTime{}, PMData{}, PMReport{})
 }
 
-// SetParquet sets the parquet representation of the PMData
-func (pm *PMData) SetParquet(row *parquetrow.ParquetRow) {
-	row.PMTime = pm.Time(GpsTime)
-	row.PMNanoseconds = pm.Nanoseconds()
-	row.PM1A = pm.PM1A
-	row.PM1ACNTR = pm.PM1ACNTR
-	row.PM1B = pm.PM1B
-	row.PM1BCNTR = pm.PM1BCNTR
-	row.PM1S = pm.PM1S
-	row.PM1SCNTR = pm.PM1SCNTR
-	row.PM2A = pm.PM2A
-	row.PM2ACNTR = pm.PM2ACNTR
-	row.PM2B = pm.PM2B
-	row.PM2BCNTR = pm.PM2BCNTR
-	row.PM2S = pm.PM2S
-	row.PM2SCNTR = pm.PM2SCNTR
+// Values returns the exported values
+func (pm *PMData) Values() []interface{} {
+	return schema.Values(
+		pmTime{PMTime: pm.Time(GpsTime), PMNanoseconds: pm.Nanoseconds()},
+		pm,
+		pm.Report(),
+	)
 }
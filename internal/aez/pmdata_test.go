@@ -1,12 +1,13 @@
 package aez
 
 import (
+	"math"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/innosat-mats/rac-extract-payload/internal/ccsds"
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 func TestPMData_Time(t *testing.T) {
@@ -164,6 +165,25 @@ func TestPMData_CSVHeaders(t *testing.T) {
 				"PM2BCNTR",
 				"PM2S",
 				"PM2SCNTR",
+				"PM1AAVG",
+				"PM1AT",
+				"PM1BAVG",
+				"PM1BT",
+				"PM1SAVG",
+				"PM1SV",
+				"PM2AAVG",
+				"PM2AT",
+				"PM2BAVG",
+				"PM2BT",
+				"PM2SAVG",
+				"PM2SV",
+				"PM1ATUncertainty",
+				"PM1BTUncertainty",
+				"PM2ATUncertainty",
+				"PM2BTUncertainty",
+				"Clamped",
+				"Calibration",
+				"Warnings",
 			},
 		},
 	}
@@ -185,8 +205,8 @@ func TestPMData_CSVHeaders(t *testing.T) {
 				PM2S:     tt.fields.PM2S,
 				PM2SCNTR: tt.fields.PM2SCNTR,
 			}
-			if got := pm.CSVHeaders(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("PMData.CSVHeaders() = %v, want %v", got, tt.want)
+			if got := schema.CSVHeaders(pm.Columns()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schema.CSVHeaders() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -247,6 +267,25 @@ func TestPMData_CSVRow(t *testing.T) {
 				"12",
 				"13",
 				"14",
+				"0.75",
+				"",
+				"0.8333333333333334",
+				"",
+				"0.875",
+				"",
+				"0.9",
+				"",
+				"0.9166666666666666",
+				"",
+				"0.9285714285714286",
+				"",
+				"",
+				"",
+				"",
+				"",
+				"false",
+				"builtin",
+				"",
 			},
 		},
 	}
@@ -268,8 +307,8 @@ func TestPMData_CSVRow(t *testing.T) {
 				PM2S:     tt.fields.PM2S,
 				PM2SCNTR: tt.fields.PM2SCNTR,
 			}
-			if got := pm.CSVRow(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("PMData.CSVRow() = %v, want %v", got, tt.want)
+			if got := schema.CSVRow(pm.Columns(), pm.Values()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schema.CSVRow() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -297,7 +336,7 @@ func TestPMData_CSVSpecifications(t *testing.T) {
 		fields fields
 		want   []string
 	}{
-		{"Genereates spec", fields{}, []string{"AEZ", Specification}},
+		{"Genereates spec", fields{}, []string{"AEZ", Specification, "CALIBRATION", "builtin"}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -324,7 +363,7 @@ func TestPMData_CSVSpecifications(t *testing.T) {
 	}
 }
 
-func TestPMData_SetParquet(t *testing.T) {
+func TestPMData_Values(t *testing.T) {
 	pm := PMData{
 		EXPTS:    1,
 		EXPTSS:   2,
@@ -342,24 +381,147 @@ func TestPMData_SetParquet(t *testing.T) {
 		PM2SCNTR: 14,
 	}
 
-	want := parquetrow.ParquetRow{
-		PMTime:        pm.Time(GpsTime),
-		PMNanoseconds: 1000030518,
-		PM1A:          3,
-		PM1ACNTR:      4,
-		PM1B:          5,
-		PM1BCNTR:      6,
-		PM1S:          7,
-		PM1SCNTR:      8,
-		PM2A:          9,
-		PM2ACNTR:      10,
-		PM2B:          11,
-		PM2BCNTR:      12,
-		PM2S:          13,
-		PM2SCNTR:      14,
-	}
-	row := parquetrow.ParquetRow{}
-	if pm.SetParquet(&row); !reflect.DeepEqual(row, want) {
-		t.Errorf("PMData.SetParquet() = %v, want %v", row, want)
+	want := map[string]interface{}{
+		"PMTime":        pm.Time(GpsTime),
+		"PMNanoseconds": int64(1000030518),
+		"PM1A":          int64(3),
+		"PM1ACNTR":      int64(4),
+		"PM1B":          int64(5),
+		"PM1BCNTR":      int64(6),
+		"PM1S":          int64(7),
+		"PM1SCNTR":      int64(8),
+		"PM2A":          int64(9),
+		"PM2ACNTR":      int64(10),
+		"PM2B":          int64(11),
+		"PM2BCNTR":      int64(12),
+		"PM2S":          int64(13),
+		"PM2SCNTR":      int64(14),
+		"PM1AAVG":       0.75,
+		"PM1AT":         nil,
+		"PM1BAVG":       0.8333333333333334,
+		"PM1BT":         nil,
+		"PM1SAVG":       0.875,
+		"PM1SV":         nil,
+		"PM2AAVG":       0.9,
+		"PM2AT":         nil,
+		"PM2BAVG":       0.9166666666666666,
+		"PM2BT":         nil,
+		"PM2SAVG":       0.9285714285714286,
+		"PM2SV":         nil,
+		"Clamped":       false,
+		"Calibration":   "builtin",
+		"Warnings":      nil,
+	}
+	columns := pm.Columns()
+	values := pm.Values()
+	if len(values) != len(columns) {
+		t.Fatalf("PMData.Values() has %v values, want %v", len(values), len(columns))
+	}
+	for name, value := range want {
+		idx := schema.Index(columns, name)
+		if idx < 0 {
+			t.Errorf("PMData.Columns() lacks %v", name)
+			continue
+		}
+		if !reflect.DeepEqual(values[idx], value) {
+			t.Errorf("PMData.Values() %v = %v, want %v", name, values[idx], value)
+		}
+	}
+}
+
+// testPMCalibration has photometer parameters, which the builtin calibration lacks
+func testPMCalibration() *Calibration {
+	calibration := BuiltinCalibration.clone()
+	calibration.ID = "pm"
+	calibration.PM = PMCalibration{
+		VoltageConstant: 2.5 / (math.Pow(2, 16) - 1),
+		Thermistor: ThermistorTable{
+			DividerVoltage:    3.3,
+			DividerResistance: 3900,
+			Temperatures:      htrTemperatures[:],
+			Resistances:       htrResistances[:],
+		},
+	}
+	calibration.fit()
+	return &calibration
+}
+
+func TestPMData_Report(t *testing.T) {
+	pm := PMData{
+		PM1A:     242716,
+		PM1ACNTR: 10,
+		PM1B:     242716,
+		PM1BCNTR: 10,
+		PM1S:     65535,
+		PM1SCNTR: 2,
+		PM2A:     242716,
+		PM2ACNTR: 10,
+		PM2B:     242716,
+		PM2BCNTR: 10,
+		PM2S:     0,
+		PM2SCNTR: 0,
+	}
+	pm.SetCalibration(testPMCalibration())
+	report := pm.Report()
+	for name, value := range map[string]*float64{
+		"PM1AT": report.PM1AT,
+		"PM1BT": report.PM1BT,
+		"PM2AT": report.PM2AT,
+		"PM2BT": report.PM2BT,
+	} {
+		if value == nil || math.Abs(*value-25) > 0.01 {
+			t.Errorf("PMData.Report().%v = %v, want 25 ⁰C", name, value)
+		}
+	}
+	for name, value := range map[string]*float64{
+		"PM1ATUncertainty": report.PM1ATUncertainty,
+		"PM1BTUncertainty": report.PM1BTUncertainty,
+		"PM2ATUncertainty": report.PM2ATUncertainty,
+		"PM2BTUncertainty": report.PM2BTUncertainty,
+	} {
+		if value == nil || !(*value > 0 && *value < 0.01) {
+			t.Errorf("PMData.Report().%v = %v, want a fraction of a step of the table", name, value)
+		}
+	}
+	if report.Clamped {
+		t.Error("PMData.Report().Clamped = true, want false")
+	}
+	if report.PM1AAVG == nil || *report.PM1AAVG != 24271.6 {
+		t.Errorf("PMData.Report().PM1AAVG = %v, want 24271.6", report.PM1AAVG)
+	}
+	if report.PM1SV == nil || *report.PM1SV != 1.25 {
+		t.Errorf("PMData.Report().PM1SV = %v, want 1.25", report.PM1SV)
+	}
+	if report.PM2SAVG != nil || report.PM2SV != nil {
+		t.Errorf(
+			"PMData.Report() PM2SAVG = %v, PM2SV = %v, want nil when counter is zero",
+			report.PM2SAVG,
+			report.PM2SV,
+		)
+	}
+	wantWarnings := []string{"PM2S: counter is zero, no samples to average"}
+	var warnings []string
+	for _, warning := range report.Warnings {
+		warnings = append(warnings, warning.Error())
+	}
+	if !reflect.DeepEqual(warnings, wantWarnings) {
+		t.Errorf("PMData.Report().Warnings = %v, want %v", warnings, wantWarnings)
+	}
+}
+
+func TestPMData_Report_uncalibrated(t *testing.T) {
+	pm := PMData{PM1A: 10, PM1ACNTR: 2, PM1S: 10, PM1SCNTR: 2}
+	report := pm.Report()
+	if report.PM1AAVG == nil || *report.PM1AAVG != 5 {
+		t.Errorf("PMData.Report().PM1AAVG = %v, want 5", report.PM1AAVG)
+	}
+	for name, value := range map[string]*float64{
+		"PM1AT":            report.PM1AT,
+		"PM1ATUncertainty": report.PM1ATUncertainty,
+		"PM1SV":            report.PM1SV,
+	} {
+		if value != nil {
+			t.Errorf("PMData.Report().%v = %v, want nil without photometer calibration", name, *value)
+		}
 	}
 }
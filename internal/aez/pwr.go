@@ -1,13 +1,10 @@
 package aez
 
 import (
-	"encoding/binary"
 	"fmt"
 	"io"
-	"reflect"
-	"strings"
 
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 type pwr uint16
@@ -37,82 +34,82 @@ var pwrResistances = [...]float64{
 	1.08974e+01, 1.01410e+01,
 } // Ohm
 
-func (data *pwr) voltageADC() float64 {
-	return voltageConstant * float64(*data)
+func (data *pwr) voltageADC(calibration *Calibration) float64 {
+	return calibration.VoltageConstant * float64(*data)
 }
 
 type pwrt pwr
 
-func (data *pwrt) voltage() float64 {
+func (data *pwrt) voltage(calibration *Calibration) float64 {
 	pwr := pwr(*data)
-	return pwr.voltageADC()
+	return pwr.voltageADC(calibration)
 }
 
-func (data *pwrt) resistance() float64 {
-	return 3.3*1000/data.voltage() - 1000
+func (data *pwrt) resistance(calibration *Calibration) float64 {
+	return calibration.PWR.Resistance(data.voltage(calibration))
 }
 
 type pwrp32v pwr
 
-func (data *pwrp32v) voltage() float64 {
+func (data *pwrp32v) voltage(calibration *Calibration) float64 {
 	pwr := pwr(*data)
-	return 21 * pwr.voltageADC()
+	return calibration.PWRScales.P32V * pwr.voltageADC(calibration)
 }
 
 type pwrp32c pwr
 
-func (data *pwrp32c) current() float64 {
+func (data *pwrp32c) current(calibration *Calibration) float64 {
 	pwr := pwr(*data)
-	return 10.1 / 100 * pwr.voltageADC()
+	return calibration.PWRScales.P32C * pwr.voltageADC(calibration)
 }
 
 type pwrp16v pwr
 
-func (data *pwrp16v) voltage() float64 {
+func (data *pwrp16v) voltage(calibration *Calibration) float64 {
 	pwr := pwr(*data)
-	return 11 * pwr.voltageADC()
+	return calibration.PWRScales.P16V * pwr.voltageADC(calibration)
 }
 
 type pwrp16c pwr
 
-func (data *pwrp16c) current() float64 {
+func (data *pwrp16c) current(calibration *Calibration) float64 {
 	pwr := pwr(*data)
-	return 10.1 / 5 * pwr.voltageADC()
+	return calibration.PWRScales.P16C * pwr.voltageADC(calibration)
 }
 
 type pwrm16v pwr
 
-func (data *pwrm16v) voltage() float64 {
+func (data *pwrm16v) voltage(calibration *Calibration) float64 {
 	pwr := pwr(*data)
-	return -10 * pwr.voltageADC()
+	return calibration.PWRScales.M16V * pwr.voltageADC(calibration)
 }
 
 type pwrm16c pwr
 
-func (data *pwrm16c) current() float64 {
+func (data *pwrm16c) current(calibration *Calibration) float64 {
 	pwr := pwr(*data)
-	return 10.1 / 100 * pwr.voltageADC()
+	return calibration.PWRScales.M16C * pwr.voltageADC(calibration)
 }
 
 type pwrp3v3 pwr
 
-func (data *pwrp3v3) voltage() float64 {
+func (data *pwrp3v3) voltage(calibration *Calibration) float64 {
 	pwr := pwr(*data)
-	return 4 * pwr.voltageADC()
+	return calibration.PWRScales.P3V3 * pwr.voltageADC(calibration)
 }
 
 type pwrp3c3 pwr
 
-func (data *pwrp3c3) current() float64 {
+func (data *pwrp3c3) current(calibration *Calibration) float64 {
 	pwr := pwr(*data)
-	return 10.1 / 20 * pwr.voltageADC()
+	return calibration.PWRScales.P3C3 * pwr.voltageADC(calibration)
 }
 
-func (data *pwrt) temperature() (float64, error) {
-	return Interpolate(
-		data.resistance(),
-		pwrResistances[:],
-		pwrTemperatures[:],
+// temperature returns the temperature and its uncertainty
+func (data *pwrt) temperature(calibration *Calibration) (float64, float64, error) {
+	return calibration.PWR.TemperatureWithUncertainty(
+		data.voltage(calibration),
+		calibration.VoltageConstant,
 	)
 }
 
@@ -127,108 +124,76 @@ type PWR struct {
 	PWRM16C pwrm16c // -16V current sense 0..4095
 	PWRP3V3 pwrp3v3 // +3V3 voltage sense 0..4095
 	PWRP3C3 pwrp3c3 // +3V3 current sense 0..4095
+
+	calibration *Calibration
 }
 
 // PWRReport structure in useful units
 type PWRReport struct {
-	PWRT     float64 // Temp. sense ⁰C
-	PWRP32V  float64 // +32V voltage sense voltage
-	PWRP32C  float64 // +32V current sense current
-	PWRP16V  float64 // +16V voltage sense voltage
-	PWRP16C  float64 // +16V current sense current
-	PWRM16V  float64 // -16V voltage sense voltage
-	PWRM16C  float64 // -16V current sense current
-	PWRP3V3  float64 // +3V3 voltage sense voltage
-	PWRP3C3  float64 // +3V3 current sense current
-	Warnings []error
+	PWRT            float64 `unit:"⁰C" description:"Temperature sense"`
+	PWRP32V         float64 `unit:"V" description:"+32V voltage sense"`
+	PWRP32C         float64 `unit:"A" description:"+32V current sense"`
+	PWRP16V         float64 `unit:"V" description:"+16V voltage sense"`
+	PWRP16C         float64 `unit:"A" description:"+16V current sense"`
+	PWRM16V         float64 `unit:"V" description:"-16V voltage sense"`
+	PWRM16C         float64 `unit:"A" description:"-16V current sense"`
+	PWRP3V3         float64 `unit:"V" description:"+3V3 voltage sense"`
+	PWRP3C3         float64 `unit:"A" description:"+3V3 current sense"`
+	PWRTUncertainty float64 `unit:"⁰C" description:"Uncertainty of PWRT"`
+	Clamped         bool    `description:"If PWRT was clamped to the end of its table, its uncertainty is then NaN"`
+	Calibration     string  `description:"ID of the calibration used"`
+	Warnings        []error `optional:"true" description:"Warnings from the temperature calculation, separated by '|' in csv"`
 }
 
 // NewPWR reads a PWR from buffer
 func NewPWR(buf io.Reader) (*PWR, error) {
 	pwr := PWR{}
-	err := binary.Read(buf, binary.LittleEndian, &pwr)
+	err := readPacketFields(buf, &pwr)
 	return &pwr, err
 }
 
-//lint:ignore U1000 unused but important for documentation
-func pwrVoltageADC(data uint16) float64 {
-	return voltageConstant * float64(data)
+// SetCalibration sets the calibration used to convert into useful units
+func (pwr *PWR) SetCalibration(calibration *Calibration) {
+	pwr.calibration = calibration
 }
 
 // Report returns a PWRReport with useful units
 func (pwr *PWR) Report() PWRReport {
-	temp, err := pwr.PWRT.temperature()
+	calibration := calibrationOrBuiltin(pwr.calibration)
+	temp, uncertainty, err := pwr.PWRT.temperature(calibration)
 	var warnings []error
 	if err != nil {
 		warning := fmt.Errorf("PWRT: %v", err.Error())
 		warnings = append(warnings, warning)
 	}
 	return PWRReport{
-		PWRT:     temp,
-		PWRP32V:  pwr.PWRP32V.voltage(),
-		PWRP32C:  pwr.PWRP32C.current(),
-		PWRP16V:  pwr.PWRP16V.voltage(),
-		PWRP16C:  pwr.PWRP16C.current(),
-		PWRM16V:  pwr.PWRM16V.voltage(),
-		PWRM16C:  pwr.PWRM16C.current(),
-		PWRP3V3:  pwr.PWRP3V3.voltage(),
-		PWRP3C3:  pwr.PWRP3C3.current(),
-		Warnings: warnings,
+		PWRT:            temp,
+		PWRP32V:         pwr.PWRP32V.voltage(calibration),
+		PWRP32C:         pwr.PWRP32C.current(calibration),
+		PWRP16V:         pwr.PWRP16V.voltage(calibration),
+		PWRP16C:         pwr.PWRP16C.current(calibration),
+		PWRM16V:         pwr.PWRM16V.voltage(calibration),
+		PWRM16C:         pwr.PWRM16C.current(calibration),
+		PWRP3V3:         pwr.PWRP3V3.voltage(calibration),
+		PWRP3C3:         pwr.PWRP3C3.current(calibration),
+		PWRTUncertainty: uncertainty,
+		Clamped:         isClamped(err),
+		Calibration:     calibration.ID,
+		Warnings:        warnings,
 	}
 }
 
 // CSVSpecifications returns the specs used in creating the struct
 func (pwr *PWR) CSVSpecifications() []string {
-	return []string{"AEZ", Specification}
-}
-
-// CSVHeaders returns the field names
-func (pwr *PWR) CSVHeaders() []string {
-	return csvHeader(pwr.Report())
-}
-
-// CSVRow returns the field values
-func (pwr *PWR) CSVRow() []string {
-	val := reflect.Indirect(reflect.ValueOf(pwr.Report()))
-	values := make([]string, val.NumField())
-	t := val.Type()
-	for i := range values {
-		valueField := val.Field(i)
-		if t.Field(i).Name == "Warnings" {
-			if valueField.Len() == 0 {
-				values[i] = ""
-			} else {
-				var errs = make([]string, valueField.Len())
-				for j, l := 0, valueField.Len(); j < l; j++ {
-					errs[j] = fmt.Sprintf("%v", valueField.Index(j).Elem())
-				}
-				values[i] = strings.Join(errs, "|")
-			}
-
-		} else {
-			values[i] = fmt.Sprintf("%v", valueField.Float())
-		}
-	}
-	return values
+	return calibrationSpecifications(pwr.calibration)
 }
 
-// SetParquet setsthe parquet representation of the PWR
-func (pwr *PWR) SetParquet(row *parquetrow.ParquetRow) {
-	report := pwr.Report()
-	var warnings []string
-	if report.Warnings != nil {
-		for err := range report.Warnings {
-			warnings = append(warnings, report.Warnings[err].Error())
-		}
-	}
-	row.PWRT = report.PWRT
-	row.PWRP32V = report.PWRP32V
-	row.PWRP32C = report.PWRP32C
-	row.PWRP16V = report.PWRP16V
-	row.PWRP16C = report.PWRP16C
-	row.PWRM16V = report.PWRM16V
-	row.PWRM16C = report.PWRM16C
-	row.PWRP3V3 = report.PWRP3V3
-	row.PWRP3C3 = report.PWRP3C3
-	row.Warnings = warnings
+// Columns returns the exported columns
+func (pwr *PWR) Columns() []schema.Column {
+	return schema.Columns(PWRReport{})
+}
+
+// Values returns the exported values
+func (pwr *PWR) Values() []interface{} {
+	return schema.Values(pwr.Report())
 }
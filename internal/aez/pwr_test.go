@@ -2,10 +2,11 @@ package aez
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 	"testing"
 
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 func TestPWR_Report(t *testing.T) {
@@ -21,7 +22,7 @@ func TestPWR_Report(t *testing.T) {
 		PWRP3C3 pwrp3c3
 	}
 	pwrt10 := pwrt(10)
-	temperature, _ := pwrt10.temperature()
+	temperature, _, _ := pwrt10.temperature(&BuiltinCalibration)
 	pwrp32v10 := pwrp32v(10)
 	pwrp32c10 := pwrp32c(10)
 	pwrp16v10 := pwrp16v(10)
@@ -37,14 +38,14 @@ func TestPWR_Report(t *testing.T) {
 		want   float64
 	}{
 		{"PWRT is temperature", fields{PWRT: 10}, "PWRT", temperature},
-		{"PWRP32V is voltage", fields{PWRP32V: 10}, "PWRP32V", pwrp32v10.voltage()},
-		{"PWRP32C is current", fields{PWRP32C: 10}, "PWRP32C", pwrp32c10.current()},
-		{"PWRP16V is voltage", fields{PWRP16V: 10}, "PWRP16V", pwrp16v10.voltage()},
-		{"PWRP16C is current", fields{PWRP16C: 10}, "PWRP16C", pwrp16c10.current()},
-		{"PWRM16V is voltage", fields{PWRM16V: 10}, "PWRM16V", pwrm16v10.voltage()},
-		{"PWRM16C is current", fields{PWRM16C: 10}, "PWRM16C", pwrm16c10.current()},
-		{"PWRP3V3 is voltage", fields{PWRP3V3: 10}, "PWRP3V3", pwrp3v310.voltage()},
-		{"PWRP3C3 is current", fields{PWRP3C3: 10}, "PWRP3C3", pwrp3c310.current()},
+		{"PWRP32V is voltage", fields{PWRP32V: 10}, "PWRP32V", pwrp32v10.voltage(&BuiltinCalibration)},
+		{"PWRP32C is current", fields{PWRP32C: 10}, "PWRP32C", pwrp32c10.current(&BuiltinCalibration)},
+		{"PWRP16V is voltage", fields{PWRP16V: 10}, "PWRP16V", pwrp16v10.voltage(&BuiltinCalibration)},
+		{"PWRP16C is current", fields{PWRP16C: 10}, "PWRP16C", pwrp16c10.current(&BuiltinCalibration)},
+		{"PWRM16V is voltage", fields{PWRM16V: 10}, "PWRM16V", pwrm16v10.voltage(&BuiltinCalibration)},
+		{"PWRM16C is current", fields{PWRM16C: 10}, "PWRM16C", pwrm16c10.current(&BuiltinCalibration)},
+		{"PWRP3V3 is voltage", fields{PWRP3V3: 10}, "PWRP3V3", pwrp3v310.voltage(&BuiltinCalibration)},
+		{"PWRP3C3 is current", fields{PWRP3C3: 10}, "PWRP3C3", pwrp3c310.current(&BuiltinCalibration)},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -74,7 +75,7 @@ func TestPWR_CSVSpecifications(t *testing.T) {
 		name string
 		want []string
 	}{
-		{"Genereates spec", []string{"AEZ", Specification}},
+		{"Genereates spec", []string{"AEZ", Specification, "CALIBRATION", "builtin"}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -99,6 +100,9 @@ func TestPWR_CSVHeaders(t *testing.T) {
 				"PWRP16V", "PWRP16C",
 				"PWRM16V", "PWRM16C",
 				"PWRP3V3", "PWRP3C3",
+				"PWRTUncertainty",
+				"Clamped",
+				"Calibration",
 				"Warnings",
 			},
 		},
@@ -106,8 +110,8 @@ func TestPWR_CSVHeaders(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			pwr := PWR{}
-			if got := pwr.CSVHeaders(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("PWR.CSVHeaders() = %v, want %v", got, tt.want)
+			if got := schema.CSVHeaders(pwr.Columns()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schema.CSVHeaders() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -153,14 +157,17 @@ func TestPWR_CSVRow(t *testing.T) {
 			},
 			[]string{
 				"-55",
-				fmt.Sprintf("%v", pwrp32v2.voltage()),
-				fmt.Sprintf("%v", pwrp32c3.current()),
-				fmt.Sprintf("%v", pwrp16v4.voltage()),
-				fmt.Sprintf("%v", pwrp16c5.current()),
-				fmt.Sprintf("%v", pwrm16v6.voltage()),
-				fmt.Sprintf("%v", pwrm16c7.current()),
-				fmt.Sprintf("%v", pwrp3v38.voltage()),
-				fmt.Sprintf("%v", pwrp3c39.current()),
+				fmt.Sprintf("%v", pwrp32v2.voltage(&BuiltinCalibration)),
+				fmt.Sprintf("%v", pwrp32c3.current(&BuiltinCalibration)),
+				fmt.Sprintf("%v", pwrp16v4.voltage(&BuiltinCalibration)),
+				fmt.Sprintf("%v", pwrp16c5.current(&BuiltinCalibration)),
+				fmt.Sprintf("%v", pwrm16v6.voltage(&BuiltinCalibration)),
+				fmt.Sprintf("%v", pwrm16c7.current(&BuiltinCalibration)),
+				fmt.Sprintf("%v", pwrp3v38.voltage(&BuiltinCalibration)),
+				fmt.Sprintf("%v", pwrp3c39.current(&BuiltinCalibration)),
+				"NaN",
+				"true",
+				"builtin",
 				"PWRT: 5.4044e+06 is too large for interpolator. Returning value for maximum.",
 			},
 		},
@@ -178,14 +185,14 @@ func TestPWR_CSVRow(t *testing.T) {
 				PWRP3V3: tt.fields.PWRP3V3,
 				PWRP3C3: tt.fields.PWRP3C3,
 			}
-			if got := pwr.CSVRow(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("PWR.CSVRow() = %v, want %v", got, tt.want)
+			if got := schema.CSVRow(pwr.Columns(), pwr.Values()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schema.CSVRow() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
-func TestPWR_SetParquet(t *testing.T) {
+func TestPWR_Values(t *testing.T) {
 	pwr := PWR{
 		PWRT:    pwrt(1),
 		PWRP32V: pwrp32v(2),
@@ -205,21 +212,39 @@ func TestPWR_SetParquet(t *testing.T) {
 	pwrm16c7 := pwrm16c(7)
 	pwrp3v38 := pwrp3v3(8)
 	pwrp3c39 := pwrp3c3(9)
-	want := parquetrow.ParquetRow{
-		PWRT:     -55,
-		PWRP32V:  pwrp32v2.voltage(),
-		PWRP32C:  pwrp32c3.current(),
-		PWRP16V:  pwrp16v4.voltage(),
-		PWRP16C:  pwrp16c5.current(),
-		PWRM16V:  pwrm16v6.voltage(),
-		PWRM16C:  pwrm16c7.current(),
-		PWRP3V3:  pwrp3v38.voltage(),
-		PWRP3C3:  pwrp3c39.current(),
-		Warnings: []string{"PWRT: 5.4044e+06 is too large for interpolator. Returning value for maximum."},
+	want := map[string]interface{}{
+		"PWRT":            -55.0,
+		"PWRP32V":         pwrp32v2.voltage(&BuiltinCalibration),
+		"PWRP32C":         pwrp32c3.current(&BuiltinCalibration),
+		"PWRP16V":         pwrp16v4.voltage(&BuiltinCalibration),
+		"PWRP16C":         pwrp16c5.current(&BuiltinCalibration),
+		"PWRM16V":         pwrm16v6.voltage(&BuiltinCalibration),
+		"PWRM16C":         pwrm16c7.current(&BuiltinCalibration),
+		"PWRP3V3":         pwrp3v38.voltage(&BuiltinCalibration),
+		"PWRP3C3":         pwrp3c39.current(&BuiltinCalibration),
+		"PWRTUncertainty": math.NaN(),
+		"Clamped":         true,
+		"Warnings":        []string{"PWRT: 5.4044e+06 is too large for interpolator. Returning value for maximum."},
 	}
-
-	row := parquetrow.ParquetRow{}
-	if pwr.SetParquet(&row); !reflect.DeepEqual(row, want) {
-		t.Errorf("PWR.SetParquet() = %v, want %v", row, want)
+	columns := pwr.Columns()
+	values := pwr.Values()
+	if len(values) != len(columns) {
+		t.Fatalf("PWR.Values() has %v values, want %v", len(values), len(columns))
+	}
+	for name, value := range want {
+		idx := schema.Index(columns, name)
+		if idx < 0 {
+			t.Errorf("PWR.Columns() lacks %v", name)
+			continue
+		}
+		if number, ok := value.(float64); ok && math.IsNaN(number) {
+			if got, ok := values[idx].(float64); !ok || !math.IsNaN(got) {
+				t.Errorf("PWR.Values() %v = %v, want NaN", name, values[idx])
+			}
+			continue
+		}
+		if !reflect.DeepEqual(values[idx], value) {
+			t.Errorf("PWR.Values() %v = %v, want %v", name, values[idx], value)
+		}
 	}
 }
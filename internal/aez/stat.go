@@ -1,43 +1,125 @@
 package aez
 
 import (
-	"encoding/binary"
-	"fmt"
 	"io"
-	"reflect"
+	"math"
 	"time"
 
 	"github.com/innosat-mats/rac-extract-payload/internal/ccsds"
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 // STAT General status housekeeping report of the payload instrument.
 type STAT struct { //(34 octets)
-	SPID    uint16 // Software Part ID
-	SPREV   uint8  // Software Part Revision
-	FPID    uint16 // Firmware Part ID
-	FPREV   uint8  // Firmware Part Revision
-	SVNA    uint8  // SVN revision tag on format A.B.C
-	SVNB    uint8  // SVN revision tag on format A.B.C
-	SVNC    uint8  // SVN revision tag on format A.B.C
-	TS      uint32 // Time, seconds (CUC time format)
-	TSS     uint16 // Time, subseconds (CUC time format)
-	MODE    uint8  // Payload mode 1..2
-	EDACE   uint32 // EDAC detected single bit errors
-	EDACCE  uint32 // EDAC corrected single bit errors
-	EDACN   uint32 // EDAC memory scrubber passes through memory
-	SPWEOP  uint32 // SpaceWire received EOPs
-	SPWEEP  uint32 // SpaceWire received EEPs
-	ANOMALY uint8  // Anomalyflag (0==0 ? OK: payload power off)
+	SPID    uint16 `description:"Software Part ID"`
+	SPREV   uint8  `description:"Software Part Revision"`
+	FPID    uint16 `description:"Firmware Part ID"`
+	FPREV   uint8  `description:"Firmware Part Revision"`
+	SVNA    uint8  `description:"SVN revision tag on format A.B.C"`
+	SVNB    uint8  `description:"SVN revision tag on format A.B.C"`
+	SVNC    uint8  `description:"SVN revision tag on format A.B.C"`
+	TS      uint32 `column:"-"` // Time, seconds (CUC time format)
+	TSS     uint16 `column:"-"` // Time, subseconds (CUC time format)
+	MODE    uint8  `description:"Payload mode 1..2"`
+	EDACE   uint32 `description:"EDAC detected single bit errors"`
+	EDACCE  uint32 `description:"EDAC corrected single bit errors"`
+	EDACN   uint32 `description:"EDAC memory scrubber passes through memory"`
+	SPWEOP  uint32 `description:"SpaceWire received EOPs"`
+	SPWEEP  uint32 `description:"SpaceWire received EEPs"`
+	ANOMALY uint8  `description:"Anomaly flag (0 is OK, else payload power off)"`
+
+	rates *STATRates
+}
+
+// statTime holds the packet time replacing the raw CUC time fields
+type statTime struct {
+	STATTime        time.Time `csv:"STATTIME" description:"The time of the packet"`
+	STATNanoseconds int64     `csv:"STATNANO" unit:"ns" description:"The time of the packet since epoch"`
+}
+
+// STATRates holds the per second changes of the cumulative STAT counters
+// since the previous STAT
+type STATRates struct {
+	EDACERate    float64 `unit:"1/s" description:"EDACE increase per second"`
+	EDACCERate   float64 `unit:"1/s" description:"EDACCE increase per second"`
+	EDACNRate    float64 `unit:"1/s" description:"EDACN increase per second"`
+	SPWEOPRate   float64 `unit:"1/s" description:"SPWEOP increase per second"`
+	SPWEEPRate   float64 `unit:"1/s" description:"SPWEEP increase per second"`
+	CounterReset bool    `description:"If the payload restarted since the previous STAT, that is SPID, SPREV, FPID or FPREV changed or any counter decreased"`
+}
+
+// noSTATRates is used when there is no previous STAT to compare with
+var noSTATRates = STATRates{
+	EDACERate:  math.NaN(),
+	EDACCERate: math.NaN(),
+	EDACNRate:  math.NaN(),
+	SPWEOPRate: math.NaN(),
+	SPWEEPRate: math.NaN(),
 }
 
 // NewSTAT reads a STAT from buffer
 func NewSTAT(buf io.Reader) (*STAT, error) {
 	stat := STAT{}
-	err := binary.Read(buf, binary.LittleEndian, &stat)
+	err := readPacketFields(buf, &stat)
 	return &stat, err
 }
 
+// Rates returns the counter rates, NaN if they couldn't be derived
+func (stat *STAT) Rates() STATRates {
+	if stat.rates == nil {
+		return noSTATRates
+	}
+	return *stat.rates
+}
+
+// restartedFrom returns if the payload restarted since the previous STAT
+func (stat *STAT) restartedFrom(previous *STAT) bool {
+	return stat.SPID != previous.SPID ||
+		stat.SPREV != previous.SPREV ||
+		stat.FPID != previous.FPID ||
+		stat.FPREV != previous.FPREV ||
+		stat.EDACE < previous.EDACE ||
+		stat.EDACCE < previous.EDACCE ||
+		stat.EDACN < previous.EDACN ||
+		stat.SPWEOP < previous.SPWEOP ||
+		stat.SPWEEP < previous.SPWEEP
+}
+
+// STATRateTracker derives counter rates from consecutive STAT
+type STATRateTracker struct {
+	previous *STAT
+}
+
+// Update sets the rates of the STAT compared to the previous one
+//
+// When the payload has restarted the counters start over and no rates are
+// derived. Neither are they if the STAT isn't later than the previous.
+func (tracker *STATRateTracker) Update(stat *STAT) {
+	previous := tracker.previous
+	tracker.previous = stat
+	if previous == nil {
+		return
+	}
+	rates := noSTATRates
+	stat.rates = &rates
+	if stat.restartedFrom(previous) {
+		rates.CounterReset = true
+		return
+	}
+	seconds := float64(stat.Nanoseconds()-previous.Nanoseconds()) / 1e9
+	if seconds <= 0 {
+		return
+	}
+	rate := func(current uint32, before uint32) float64 {
+		return float64(current-before) / seconds
+	}
+	rates.EDACERate = rate(stat.EDACE, previous.EDACE)
+	rates.EDACCERate = rate(stat.EDACCE, previous.EDACCE)
+	rates.EDACNRate = rate(stat.EDACN, previous.EDACN)
+	rates.SPWEOPRate = rate(stat.SPWEOP, previous.SPWEOP)
+	rates.SPWEEPRate = rate(stat.SPWEEP, previous.SPWEEP)
+}
+
 // Time returns the measurement time in UTC
 func (stat *STAT) Time(epoch time.Time) time.Time {
 	if (epoch == time.Time{}) {
@@ -56,49 +138,17 @@ func (stat STAT) CSVSpecifications() []string {
 	return []string{"AEZ", Specification}
 }
 
-// CSVHeaders returns the header row
-func (stat *STAT) CSVHeaders() []string {
-	var headers []string
-	headers = append(headers, "STATTIME", "STATNANO")
-	// We don't need the raw CUC Time fields, instead the iso date and nanoseconds are included above.
-	return append(headers, csvHeader(stat, "TS", "TSS")...)
-}
-
-// CSVRow returns the data row
-func (stat *STAT) CSVRow() []string {
-	var row []string
-	statTime := stat.Time(GpsTime)
-	row = append(row, statTime.Format(time.RFC3339Nano), fmt.Sprintf("%v", stat.Nanoseconds()))
-	val := reflect.Indirect(reflect.ValueOf(stat))
-	t := val.Type()
-	for i := 0; i < val.NumField(); i++ {
-		name := t.Field(i).Name
-		// We don't need the raw CUC Time fields, instead the iso date and nanoseconds are included above.
-		if name != "TS" && name != "TSS" {
-			valueField := val.Field(i)
-			row = append(row, fmt.Sprintf("%v", valueField.Uint()))
-		}
-	}
-	return row
+// Columns returns the exported columns
+func (stat *STAT) Columns() []schema.Column {
+	return schema.Columns(statTime{}, STAT{}, STATRates{}, noWarnings{})
 }
 
-// STATParquet holds the parquet representation of the STAT
-// SetParquet sets the parquet representation of the STAT
-func (stat *STAT) SetParquet(row *parquetrow.ParquetRow) {
-	row.STATTime = stat.Time(GpsTime)
-	row.STATNanoseconds = stat.Nanoseconds()
-	row.SPID = stat.SPID
-	row.SPREV = stat.SPREV
-	row.FPID = stat.FPID
-	row.FPREV = stat.FPREV
-	row.SVNA = stat.SVNA
-	row.SVNB = stat.SVNB
-	row.SVNC = stat.SVNC
-	row.MODE = stat.MODE
-	row.EDACE = stat.EDACE
-	row.EDACCE = stat.EDACCE
-	row.EDACN = stat.EDACN
-	row.SPWEOP = stat.SPWEOP
-	row.SPWEEP = stat.SPWEEP
-	row.ANOMALY = stat.ANOMALY
+// Values returns the exported values
+func (stat *STAT) Values() []interface{} {
+	return schema.Values(
+		statTime{STATTime: stat.Time(GpsTime), STATNanoseconds: stat.Nanoseconds()},
+		stat,
+		stat.Rates(),
+		noWarnings{},
+	)
 }
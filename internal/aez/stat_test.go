@@ -1,12 +1,13 @@
 package aez
 
 import (
+	"math"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/innosat-mats/rac-extract-payload/internal/ccsds"
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 func TestSTAT_Time(t *testing.T) {
@@ -94,10 +95,16 @@ func TestSTAT_CSVHeaders(t *testing.T) {
 		"SPWEOP",
 		"SPWEEP",
 		"ANOMALY",
+		"EDACERate",
+		"EDACCERate",
+		"EDACNRate",
+		"SPWEOPRate",
+		"SPWEEPRate",
+		"CounterReset",
 	}
 	stat := STAT{}
-	if got := stat.CSVHeaders(); !reflect.DeepEqual(got, want) {
-		t.Errorf("STAT.CSVHeaders() = %v, want %v", got, want)
+	if got := schema.CSVHeaders(stat.Columns()); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema.CSVHeaders() = %v, want %v", got, want)
 	}
 }
 
@@ -162,6 +169,12 @@ func TestSTAT_CSVRow(t *testing.T) {
 				"14",
 				"15",
 				"16",
+				"NaN",
+				"NaN",
+				"NaN",
+				"NaN",
+				"NaN",
+				"false",
 			},
 		},
 	}
@@ -185,8 +198,8 @@ func TestSTAT_CSVRow(t *testing.T) {
 				SPWEEP:  tt.fields.SPWEEP,
 				ANOMALY: tt.fields.ANOMALY,
 			}
-			if got := stat.CSVRow(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("STAT.CSVRow() = %v, want %v", got, tt.want)
+			if got := schema.CSVRow(stat.Columns(), stat.Values()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schema.CSVRow() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -200,7 +213,7 @@ func TestSTAT_CSVSpecifications(t *testing.T) {
 	}
 }
 
-func TestSTAT_SetParquet(t *testing.T) {
+func TestSTAT_Values(t *testing.T) {
 	stat := STAT{
 		SPID:    1,
 		SPREV:   2,
@@ -219,27 +232,100 @@ func TestSTAT_SetParquet(t *testing.T) {
 		SPWEEP:  15,
 		ANOMALY: 16,
 	}
+	tracker := STATRateTracker{}
+	tracker.Update(&STAT{SPID: 1, SPREV: 2, FPID: 3, FPREV: 4, TS: 6, TSS: 9, EDACE: 1, EDACCE: 2, EDACN: 3, SPWEOP: 4, SPWEEP: 5})
+	tracker.Update(&stat)
 
-	want := parquetrow.ParquetRow{
-		STATTime:        stat.Time(GpsTime),
-		STATNanoseconds: 8000137329,
-		SPID:            1,
-		SPREV:           2,
-		FPID:            3,
-		FPREV:           4,
-		SVNA:            5,
-		SVNB:            6,
-		SVNC:            7,
-		MODE:            10,
-		EDACE:           11,
-		EDACCE:          12,
-		EDACN:           13,
-		SPWEOP:          14,
-		SPWEEP:          15,
-		ANOMALY:         16,
-	}
-	row := parquetrow.ParquetRow{}
-	if stat.SetParquet(&row); !reflect.DeepEqual(row, want) {
-		t.Errorf("STAT.SetParquet() = %v, want %v", row, want)
+	want := map[string]interface{}{
+		"STATTime":        stat.Time(GpsTime),
+		"STATNanoseconds": int64(8000137329),
+		"SPID":            int64(1),
+		"SPREV":           int64(2),
+		"FPID":            int64(3),
+		"FPREV":           int64(4),
+		"SVNA":            int64(5),
+		"SVNB":            int64(6),
+		"SVNC":            int64(7),
+		"MODE":            int64(10),
+		"EDACE":           int64(11),
+		"EDACCE":          int64(12),
+		"EDACN":           int64(13),
+		"SPWEOP":          int64(14),
+		"SPWEEP":          int64(15),
+		"ANOMALY":         int64(16),
+		"EDACERate":       5.0,
+		"EDACCERate":      5.0,
+		"EDACNRate":       5.0,
+		"SPWEOPRate":      5.0,
+		"SPWEEPRate":      5.0,
+	}
+	columns := stat.Columns()
+	values := stat.Values()
+	if len(values) != len(columns) {
+		t.Fatalf("STAT.Values() has %v values, want %v", len(values), len(columns))
+	}
+	for name, value := range want {
+		idx := schema.Index(columns, name)
+		if idx < 0 {
+			t.Errorf("STAT.Columns() lacks %v", name)
+			continue
+		}
+		if !reflect.DeepEqual(values[idx], value) {
+			t.Errorf("STAT.Values() %v = %v, want %v", name, values[idx], value)
+		}
+	}
+}
+
+func TestSTATRateTracker_Update(t *testing.T) {
+	previous := STAT{SPID: 1, FPID: 2, TS: 10, EDACE: 10, EDACCE: 10, EDACN: 10, SPWEOP: 10, SPWEEP: 10}
+	nan := math.NaN()
+	tests := []struct {
+		name string
+		stat STAT
+		want STATRates
+	}{
+		{
+			"Derives per second rates",
+			STAT{SPID: 1, FPID: 2, TS: 14, EDACE: 10, EDACCE: 12, EDACN: 14, SPWEOP: 50, SPWEEP: 11},
+			STATRates{EDACERate: 0, EDACCERate: 0.5, EDACNRate: 1, SPWEOPRate: 10, SPWEEPRate: 0.25},
+		},
+		{
+			"Detects software restart",
+			STAT{SPID: 3, FPID: 2, TS: 14, EDACE: 10, EDACCE: 12, EDACN: 14, SPWEOP: 50, SPWEEP: 11},
+			STATRates{nan, nan, nan, nan, nan, true},
+		},
+		{
+			"Detects counter reset",
+			STAT{SPID: 1, FPID: 2, TS: 14, EDACE: 10, EDACCE: 12, EDACN: 1, SPWEOP: 50, SPWEEP: 11},
+			STATRates{nan, nan, nan, nan, nan, true},
+		},
+		{
+			"Skips if not later",
+			STAT{SPID: 1, FPID: 2, TS: 10, EDACE: 10, EDACCE: 12, EDACN: 14, SPWEOP: 50, SPWEEP: 11},
+			STATRates{nan, nan, nan, nan, nan, false},
+		},
+	}
+	equal := func(a float64, b float64) bool {
+		return a == b || (math.IsNaN(a) && math.IsNaN(b))
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := STATRateTracker{}
+			first := previous
+			tracker.Update(&first)
+			if got := first.Rates(); !math.IsNaN(got.EDACERate) || got.CounterReset {
+				t.Errorf("STATRateTracker.Update() first rates = %+v, want no rates", got)
+			}
+			tracker.Update(&tt.stat)
+			got := tt.stat.Rates()
+			if !equal(got.EDACERate, tt.want.EDACERate) ||
+				!equal(got.EDACCERate, tt.want.EDACCERate) ||
+				!equal(got.EDACNRate, tt.want.EDACNRate) ||
+				!equal(got.SPWEOPRate, tt.want.SPWEOPRate) ||
+				!equal(got.SPWEEPRate, tt.want.SPWEEPRate) ||
+				got.CounterReset != tt.want.CounterReset {
+				t.Errorf("STATRateTracker.Update() rates = %+v, want %+v", got, tt.want)
+			}
+		})
 	}
 }
@@ -0,0 +1,286 @@
+package aez
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const zeroCelsius = 273.15 // K
+
+// ThermistorTable describes how a thermistor is read out and converted to a
+// temperature
+type ThermistorTable struct {
+	DividerVoltage    float64             `json:"dividerVoltage"`    // Voltage over the divider the thermistor is read through
+	DividerResistance float64             `json:"dividerResistance"` // Reference resistance in the divider, Ohm
+	Temperatures      []float64           `json:"temperatures"`      // ⁰C
+	Resistances       []float64           `json:"resistances"`       // Ohm, monotonically decreasing
+	Model             InterpolationModel  `json:"model"`             // Empty means linear
+	Extrapolation     ExtrapolationPolicy `json:"extrapolation"`     // Empty means clamp
+	SteinhartHart     []float64           `json:"steinhartHart"`     // A, B, C, fitted to the table if empty
+
+	prepared *thermistorModel // Set by fit, which must be called again if the table changes
+}
+
+// thermistorModel is the model of a table, prepared once for all conversions
+type thermistorModel struct {
+	interpolator  *tableInterpolator
+	steinhartHart [3]float64
+	err           error // Why the table can't be used
+}
+
+// Resistance returns the thermistor resistance given the measured voltage
+func (table *ThermistorTable) Resistance(voltage float64) float64 {
+	return table.DividerVoltage*table.DividerResistance/voltage - table.DividerResistance
+}
+
+// Temperature returns the temperature given the measured voltage
+func (table *ThermistorTable) Temperature(voltage float64) (float64, error) {
+	return table.temperature(table.Resistance(voltage), table.Extrapolation)
+}
+
+// TemperatureWithUncertainty returns the temperature given the measured
+// voltage together with its uncertainty
+//
+// The uncertainty is half the temperature span covered by a voltage
+// resolution, typically one ADC step. Extrapolated values also include the
+// distance to the end of the table. Clamped values have unknown uncertainty,
+// NaN, and are flagged by an ErrXTooLarge or ErrXTooSmall error.
+func (table *ThermistorTable) TemperatureWithUncertainty(
+	voltage float64,
+	resolution float64,
+) (float64, float64, error) {
+	temperature, err := table.Temperature(voltage)
+	if err != nil && !isOutsideRange(err) {
+		return temperature, math.NaN(), err
+	}
+	switch {
+	case math.IsNaN(temperature):
+		return temperature, math.NaN(), err
+	case isClamped(err):
+		return temperature, math.NaN(), err
+	}
+	low, _ := table.temperature(table.Resistance(voltage-resolution/2), ExtrapolateLinear)
+	high, _ := table.temperature(table.Resistance(voltage+resolution/2), ExtrapolateLinear)
+	uncertainty := math.Abs(high-low) / 2
+	if err != nil {
+		edge, _ := table.temperature(table.Resistance(voltage), ExtrapolateClamp)
+		uncertainty += math.Abs(temperature - edge)
+	}
+	return temperature, uncertainty, err
+}
+
+func isOutsideRange(err error) bool {
+	var outside ErrXOutsideRange
+	return isClamped(err) || errors.As(err, &outside)
+}
+
+// isClamped returns if the error tells that the value was clamped to the end
+// of the table
+func isClamped(err error) bool {
+	var tooLarge ErrXTooLarge
+	var tooSmall ErrXTooSmall
+	return errors.As(err, &tooLarge) || errors.As(err, &tooSmall)
+}
+
+func (table *ThermistorTable) temperature(
+	resistance float64,
+	policy ExtrapolationPolicy,
+) (float64, error) {
+	if policy == "" {
+		policy = ExtrapolateClamp
+	}
+	model := table.prepared
+	if model == nil {
+		model = table.prepare()
+	}
+	if model.err != nil {
+		return math.NaN(), model.err
+	}
+	if table.Model == ModelSteinhartHart {
+		return table.steinhartHartTemperature(resistance, policy, model.steinhartHart)
+	}
+	return model.interpolator.interpolate(resistance, policy)
+}
+
+// prepare validates the table and prepares its model
+func (table *ThermistorTable) prepare() *thermistorModel {
+	var model thermistorModel
+	switch table.Model {
+	case ModelSteinhartHart:
+		err := validateTable(table.Resistances, table.Temperatures)
+		if err != nil {
+			model.err = err
+		} else if len(table.SteinhartHart) == 0 {
+			model.steinhartHart, model.err = fitSteinhartHart(table.Resistances, table.Temperatures)
+		} else if len(table.SteinhartHart) != 3 {
+			model.err = fmt.Errorf(
+				"%w: expected 3 Steinhart-Hart coefficients (got %d)",
+				ErrInvalidTable,
+				len(table.SteinhartHart),
+			)
+		} else {
+			copy(model.steinhartHart[:], table.SteinhartHart)
+		}
+	case ModelMonotoneCubic:
+		model.interpolator, model.err = newTableInterpolator(
+			table.Resistances,
+			table.Temperatures,
+			ModelMonotoneCubic,
+		)
+	default:
+		model.interpolator, model.err = newTableInterpolator(
+			table.Resistances,
+			table.Temperatures,
+			ModelLinear,
+		)
+	}
+	return &model
+}
+
+func (table *ThermistorTable) steinhartHartTemperature(
+	resistance float64,
+	policy ExtrapolationPolicy,
+	coefficients [3]float64,
+) (float64, error) {
+	evaluate := func(r float64) float64 {
+		lnR := math.Log(r)
+		return 1/(coefficients[0]+coefficients[1]*lnR+coefficients[2]*lnR*lnR*lnR) - zeroCelsius
+	}
+	last := len(table.Resistances) - 1
+	if resistance > table.Resistances[0] || resistance < table.Resistances[last] {
+		switch policy {
+		case ExtrapolateLinear:
+			return evaluate(resistance), ErrXOutsideRange{resistance, policy}
+		case ExtrapolateNaN:
+			return math.NaN(), ErrXOutsideRange{resistance, policy}
+		default:
+			if resistance > table.Resistances[0] {
+				return evaluate(table.Resistances[0]), ErrXTooLarge(resistance)
+			}
+			return evaluate(table.Resistances[last]), ErrXTooSmall(resistance)
+		}
+	}
+	return evaluate(resistance), nil
+}
+
+// fitSteinhartHart least squares fits 1/T = A + B ln(R) + C ln(R)^3
+func fitSteinhartHart(resistances []float64, temperatures []float64) ([3]float64, error) {
+	var coefficients [3]float64
+	err := validateTable(resistances, temperatures)
+	if err != nil {
+		return coefficients, err
+	}
+	if len(resistances) < 3 {
+		return coefficients, fmt.Errorf(
+			"%w: Steinhart-Hart fit needs at least 3 entries (%d < 3)",
+			ErrInvalidTable,
+			len(resistances),
+		)
+	}
+	// Normal equations M c = v
+	var m [3][3]float64
+	var v [3]float64
+	for i, r := range resistances {
+		lnR := math.Log(r)
+		row := [3]float64{1, lnR, lnR * lnR * lnR}
+		y := 1 / (temperatures[i] + zeroCelsius)
+		for j := range row {
+			for k := range row {
+				m[j][k] += row[j] * row[k]
+			}
+			v[j] += row[j] * y
+		}
+	}
+	// Gaussian elimination with partial pivoting
+	for col := 0; col < 3; col++ {
+		pivot := col
+		for row := col + 1; row < 3; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if m[pivot][col] == 0 {
+			return coefficients, fmt.Errorf(
+				"%w: Steinhart-Hart fit is singular",
+				ErrInvalidTable,
+			)
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		v[col], v[pivot] = v[pivot], v[col]
+		for row := col + 1; row < 3; row++ {
+			factor := m[row][col] / m[col][col]
+			for k := col; k < 3; k++ {
+				m[row][k] -= factor * m[col][k]
+			}
+			v[row] -= factor * v[col]
+		}
+	}
+	for row := 2; row >= 0; row-- {
+		sum := v[row]
+		for k := row + 1; k < 3; k++ {
+			sum -= m[row][k] * coefficients[k]
+		}
+		coefficients[row] = sum / m[row][row]
+	}
+	return coefficients, nil
+}
+
+// fit stores fitted Steinhart-Hart coefficients unless given and prepares
+// the model used for all conversions
+func (table *ThermistorTable) fit() {
+	table.prepared = nil
+	if table.Model == ModelSteinhartHart && len(table.SteinhartHart) == 0 {
+		coefficients, err := fitSteinhartHart(table.Resistances, table.Temperatures)
+		if err == nil {
+			table.SteinhartHart = coefficients[:]
+		}
+	}
+	table.prepared = table.prepare()
+}
+
+// clone returns a copy that doesn't share tables with the original, its
+// model must be prepared by fit
+func (table ThermistorTable) clone() ThermistorTable {
+	table.prepared = nil
+	table.Temperatures = append([]float64(nil), table.Temperatures...)
+	table.Resistances = append([]float64(nil), table.Resistances...)
+	if table.SteinhartHart != nil {
+		table.SteinhartHart = append([]float64(nil), table.SteinhartHart...)
+	}
+	return table
+}
+
+func (table *ThermistorTable) validate(name string) error {
+	err := validateTable(table.Resistances, table.Temperatures)
+	if err != nil {
+		return fmt.Errorf("%v table: %v", name, err)
+	}
+	switch table.Model {
+	case "", ModelLinear, ModelMonotoneCubic:
+	case ModelSteinhartHart:
+		if len(table.SteinhartHart) == 0 {
+			_, err = fitSteinhartHart(table.Resistances, table.Temperatures)
+			if err != nil {
+				return fmt.Errorf("%v table: %v", name, err)
+			}
+		} else if len(table.SteinhartHart) != 3 {
+			return fmt.Errorf(
+				"%v table must have 3 Steinhart-Hart coefficients (%d != 3)",
+				name,
+				len(table.SteinhartHart),
+			)
+		}
+	default:
+		return fmt.Errorf("%v table has unknown model %q", name, table.Model)
+	}
+	switch table.Extrapolation {
+	case "", ExtrapolateClamp, ExtrapolateLinear, ExtrapolateNaN:
+	default:
+		return fmt.Errorf("%v table has unknown extrapolation %q", name, table.Extrapolation)
+	}
+	if table.DividerVoltage <= 0 || table.DividerResistance <= 0 {
+		return fmt.Errorf("%v table must have positive divider voltage and resistance", name)
+	}
+	return nil
+}
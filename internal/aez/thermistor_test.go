@@ -0,0 +1,161 @@
+package aez
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func Test_fitSteinhartHart(t *testing.T) {
+	coefficients, err := fitSteinhartHart(htrResistances[:], htrTemperatures[:])
+	if err != nil {
+		t.Errorf("fitSteinhartHart() unexpected error %v", err)
+		return
+	}
+	table := BuiltinCalibration.HTR.clone()
+	table.Model = ModelSteinhartHart
+	table.SteinhartHart = coefficients[:]
+	for i, resistance := range htrResistances {
+		got, err := table.temperature(resistance, ExtrapolateClamp)
+		if err != nil {
+			t.Errorf("ThermistorTable.temperature(%v) unexpected error %v", resistance, err)
+		}
+		if math.Abs(got-htrTemperatures[i]) > 0.5 {
+			t.Errorf(
+				"ThermistorTable.temperature(%v) = %v, want %v ± 0.5",
+				resistance,
+				got,
+				htrTemperatures[i],
+			)
+		}
+	}
+}
+
+func Test_fitSteinhartHart_invalidTable(t *testing.T) {
+	_, err := fitSteinhartHart([]float64{2, 1}, []float64{1, 2})
+	if !errors.Is(err, ErrInvalidTable) {
+		t.Errorf("fitSteinhartHart() error = %v, want %v", err, ErrInvalidTable)
+	}
+}
+
+func TestThermistorTable_TemperatureWithUncertainty(t *testing.T) {
+	// 10 kOhm gives 25 ⁰C in the builtin table
+	inside := 3.3 * 3900 / (3900 + 1e4)
+	outside := 0.001
+	tests := []struct {
+		name            string
+		model           InterpolationModel
+		extrapolation   ExtrapolationPolicy
+		voltage         float64
+		wantTemperature float64
+		wantUncertainty func(float64) bool
+		wantErr         bool
+	}{
+		{
+			"Small uncertainty inside table",
+			ModelLinear, ExtrapolateClamp, inside, 25,
+			func(u float64) bool { return u > 0 && u < 0.1 },
+			false,
+		},
+		{
+			"Unknown uncertainty when clamped",
+			ModelMonotoneCubic, ExtrapolateClamp, outside, -55,
+			func(u float64) bool { return math.IsNaN(u) },
+			true,
+		},
+		{
+			"Large uncertainty when extrapolated",
+			ModelLinear, ExtrapolateLinear, outside, -55 - 5*(3.3*3900/outside-3900-9.63e5)/(9.63e5-6.701e5),
+			func(u float64) bool { return u > 100 && !math.IsInf(u, 1) },
+			true,
+		},
+		{
+			"NaN uncertainty when NaN",
+			ModelSteinhartHart, ExtrapolateNaN, outside, math.NaN(),
+			math.IsNaN,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := BuiltinCalibration.HTR
+			table.Model = tt.model
+			table.Extrapolation = tt.extrapolation
+			temperature, uncertainty, err := table.TemperatureWithUncertainty(
+				tt.voltage,
+				BuiltinCalibration.VoltageConstant,
+			)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ThermistorTable.TemperatureWithUncertainty() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if math.Abs(temperature-tt.wantTemperature) > 1e-6 &&
+				!(math.IsNaN(temperature) && math.IsNaN(tt.wantTemperature)) {
+				t.Errorf(
+					"ThermistorTable.TemperatureWithUncertainty() temperature = %v, want %v",
+					temperature,
+					tt.wantTemperature,
+				)
+			}
+			if !tt.wantUncertainty(uncertainty) {
+				t.Errorf("ThermistorTable.TemperatureWithUncertainty() uncertainty = %v not as expected", uncertainty)
+			}
+		})
+	}
+}
+
+func TestThermistorTable_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*ThermistorTable)
+		wantErr bool
+	}{
+		{"Accepts builtin", func(table *ThermistorTable) {}, false},
+		{"Accepts cubic", func(table *ThermistorTable) { table.Model = ModelMonotoneCubic }, false},
+		{"Rejects unknown model", func(table *ThermistorTable) { table.Model = "spline" }, true},
+		{
+			"Rejects unknown extrapolation",
+			func(table *ThermistorTable) { table.Extrapolation = "wrap" },
+			true,
+		},
+		{
+			"Rejects wrong number of coefficients",
+			func(table *ThermistorTable) {
+				table.Model = ModelSteinhartHart
+				table.SteinhartHart = []float64{1, 2}
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := BuiltinCalibration.HTR.clone()
+			tt.modify(&table)
+			if err := table.validate("HTR"); (err != nil) != tt.wantErr {
+				t.Errorf("ThermistorTable.validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestThermistorTable_fit(t *testing.T) {
+	if BuiltinCalibration.HTR.prepared == nil || BuiltinCalibration.PM.Thermistor.prepared == nil {
+		t.Error("BuiltinCalibration has tables without prepared models")
+	}
+	cubic := BuiltinCalibration.HTR.clone()
+	cubic.Model = ModelMonotoneCubic
+	cubic.fit()
+	if cubic.prepared == nil || len(cubic.prepared.interpolator.tangents) != len(cubic.Resistances) {
+		t.Errorf("ThermistorTable.fit() prepared %+v, want the tangents of the table", cubic.prepared)
+	}
+	steinhartHart := BuiltinCalibration.HTR.clone()
+	steinhartHart.Model = ModelSteinhartHart
+	steinhartHart.fit()
+	if steinhartHart.prepared == nil || steinhartHart.prepared.steinhartHart[1] == 0 {
+		t.Errorf("ThermistorTable.fit() prepared %+v, want the fitted coefficients", steinhartHart.prepared)
+	}
+	want, _ := steinhartHart.temperature(1e4, ExtrapolateClamp)
+	unprepared := steinhartHart.clone()
+	if got, _ := unprepared.temperature(1e4, ExtrapolateClamp); got != want {
+		t.Errorf("ThermistorTable.temperature() without fit = %v, want %v", got, want)
+	}
+}
@@ -2,13 +2,27 @@ package aez
 
 import (
 	"encoding/binary"
-	"fmt"
 	"io"
-	"reflect"
 
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
+// tcvReport is the common exported format of the four TC verifications
+type tcvReport struct {
+	TCV       string `description:"Accept for acceptance reports and Exec for execution reports"`
+	TCPID     uint16 `description:"A copy of the Packet ID header field of the TC"`
+	PSC       uint16 `description:"A copy of the Sequence Control header field of the TC"`
+	ErrorCode *uint8 `parquet:"required" description:"Empty if success else the fail code, 0 in parquet"`
+}
+
+func tcvColumns() []schema.Column {
+	return schema.Columns(tcvReport{}, noWarnings{})
+}
+
+func tcvValues(report tcvReport) []interface{} {
+	return schema.Values(report, noWarnings{})
+}
+
 /*
 TCAcceptSuccess:
 */
@@ -31,30 +45,14 @@ func (tcv *TCAcceptSuccessData) CSVSpecifications() []string {
 	return []string{"AEZ", Specification}
 }
 
-// CSVHeaders returns the header row
-func (tcv *TCAcceptSuccessData) CSVHeaders() []string {
-	var headers []string
-	headers = append(headers, "TCV")
-	headers = append(headers, csvHeader(tcv)...)
-	return append(headers, "ErrorCode")
-}
-
-// CSVRow returns the data row
-func (tcv *TCAcceptSuccessData) CSVRow() []string {
-	var row []string
-	row = append(row, "Accept")
-	val := reflect.Indirect(reflect.ValueOf(tcv))
-	for i := 0; i < val.NumField(); i++ {
-		row = append(row, fmt.Sprintf("%v", val.Field(i).Uint()))
-	}
-	return append(row, "")
+// Columns returns the exported columns
+func (tcv *TCAcceptSuccessData) Columns() []schema.Column {
+	return tcvColumns()
 }
 
-// SetParquet sets the parquet representation of the TCV
-func (tcv *TCAcceptSuccessData) SetParquet(row *parquetrow.ParquetRow) {
-	row.TCV = "Accept"
-	row.PSC = tcv.PSC
-	row.TCPID = tcv.TCPID
+// Values returns the exported values
+func (tcv *TCAcceptSuccessData) Values() []interface{} {
+	return tcvValues(tcvReport{"Accept", tcv.TCPID, tcv.PSC, nil})
 }
 
 /*
@@ -80,30 +78,14 @@ func (tcv *TCAcceptFailureData) CSVSpecifications() []string {
 	return []string{"AEZ", Specification}
 }
 
-// CSVHeaders returns the header row
-func (tcv *TCAcceptFailureData) CSVHeaders() []string {
-	var headers []string
-	headers = append(headers, "TCV")
-	return append(headers, csvHeader(tcv)...)
-}
-
-// CSVRow returns the data row
-func (tcv *TCAcceptFailureData) CSVRow() []string {
-	var row []string
-	row = append(row, "Accept")
-	val := reflect.Indirect(reflect.ValueOf(tcv))
-	for i := 0; i < val.NumField(); i++ {
-		row = append(row, fmt.Sprintf("%v", val.Field(i).Uint()))
-	}
-	return row
+// Columns returns the exported columns
+func (tcv *TCAcceptFailureData) Columns() []schema.Column {
+	return tcvColumns()
 }
 
-// SetParquet sets the parquet representation of the TCV
-func (tcv *TCAcceptFailureData) SetParquet(row *parquetrow.ParquetRow) {
-	row.TCV = "Accept"
-	row.PSC = tcv.PSC
-	row.TCPID = tcv.TCPID
-	row.ErrorCode = tcv.ErrorCode
+// Values returns the exported values
+func (tcv *TCAcceptFailureData) Values() []interface{} {
+	return tcvValues(tcvReport{"Accept", tcv.TCPID, tcv.PSC, &tcv.ErrorCode})
 }
 
 /*
@@ -128,30 +110,14 @@ func (tcv *TCExecSuccessData) CSVSpecifications() []string {
 	return []string{"AEZ", Specification}
 }
 
-// CSVHeaders returns the header row
-func (tcv *TCExecSuccessData) CSVHeaders() []string {
-	var headers []string
-	headers = append(headers, "TCV")
-	headers = append(headers, csvHeader(tcv)...)
-	return append(headers, "ErrorCode")
+// Columns returns the exported columns
+func (tcv *TCExecSuccessData) Columns() []schema.Column {
+	return tcvColumns()
 }
 
-// CSVRow returns the data row
-func (tcv *TCExecSuccessData) CSVRow() []string {
-	var row []string
-	row = append(row, "Exec")
-	val := reflect.Indirect(reflect.ValueOf(tcv))
-	for i := 0; i < val.NumField(); i++ {
-		row = append(row, fmt.Sprintf("%v", val.Field(i).Uint()))
-	}
-	return append(row, "")
-}
-
-// SetParquet sets the parquet representation of the TCV
-func (tcv *TCExecSuccessData) SetParquet(row *parquetrow.ParquetRow) {
-	row.TCV = "Exec"
-	row.PSC = tcv.PSC
-	row.TCPID = tcv.TCPID
+// Values returns the exported values
+func (tcv *TCExecSuccessData) Values() []interface{} {
+	return tcvValues(tcvReport{"Exec", tcv.TCPID, tcv.PSC, nil})
 }
 
 /*
@@ -178,28 +144,12 @@ func (tcv *TCExecFailureData) CSVSpecifications() []string {
 	return []string{"AEZ", Specification}
 }
 
-// CSVHeaders returns the header row
-func (tcv *TCExecFailureData) CSVHeaders() []string {
-	var headers []string
-	headers = append(headers, "TCV")
-	return append(headers, csvHeader(tcv)...)
-}
-
-// CSVRow returns the data row
-func (tcv *TCExecFailureData) CSVRow() []string {
-	var row []string
-	row = append(row, "Exec")
-	val := reflect.Indirect(reflect.ValueOf(tcv))
-	for i := 0; i < val.NumField(); i++ {
-		row = append(row, fmt.Sprintf("%v", val.Field(i).Uint()))
-	}
-	return row
+// Columns returns the exported columns
+func (tcv *TCExecFailureData) Columns() []schema.Column {
+	return tcvColumns()
 }
 
-// SetParquet sets the parquet representation of the TCV
-func (tcv *TCExecFailureData) SetParquet(row *parquetrow.ParquetRow) {
-	row.TCV = "Exec"
-	row.PSC = tcv.PSC
-	row.TCPID = tcv.TCPID
-	row.ErrorCode = tcv.ErrorCode
+// Values returns the exported values
+func (tcv *TCExecFailureData) Values() []interface{} {
+	return tcvValues(tcvReport{"Exec", tcv.TCPID, tcv.PSC, &tcv.ErrorCode})
 }
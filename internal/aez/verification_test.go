@@ -4,7 +4,7 @@ import (
 	"reflect"
 	"testing"
 
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 func TestTCAcceptSuccess_CSVSpecifications(t *testing.T) {
@@ -42,117 +42,161 @@ func TestTCExecFailure_CSVSpecifications(t *testing.T) {
 func TestTCAcceptSuccess_CSVHeaders(t *testing.T) {
 	tcv := TCAcceptSuccessData{}
 	want := []string{"TCV", "TCPID", "PSC", "ErrorCode"}
-	if got := tcv.CSVHeaders(); !reflect.DeepEqual(got, want) {
-		t.Errorf("TCAcceptSuccessData.CSVHeaders() = %v, want %v", got, want)
+	if got := schema.CSVHeaders(tcv.Columns()); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema.CSVHeaders() = %v, want %v", got, want)
 	}
 }
 
 func TestTCAcceptFailure_CSVHeaders(t *testing.T) {
 	tcv := TCAcceptFailureData{}
 	want := []string{"TCV", "TCPID", "PSC", "ErrorCode"}
-	if got := tcv.CSVHeaders(); !reflect.DeepEqual(got, want) {
-		t.Errorf("TCAcceptFailureData.CSVHeaders() = %v, want %v", got, want)
+	if got := schema.CSVHeaders(tcv.Columns()); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema.CSVHeaders() = %v, want %v", got, want)
 	}
 }
 
 func TestTCExecSuccess_CSVHeaders(t *testing.T) {
 	tcv := TCExecSuccessData{}
 	want := []string{"TCV", "TCPID", "PSC", "ErrorCode"}
-	if got := tcv.CSVHeaders(); !reflect.DeepEqual(got, want) {
-		t.Errorf("TCExecSuccessData.CSVHeaders() = %v, want %v", got, want)
+	if got := schema.CSVHeaders(tcv.Columns()); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema.CSVHeaders() = %v, want %v", got, want)
 	}
 }
 
 func TestTCExecFailure_CSVHeaders(t *testing.T) {
 	tcv := TCExecFailureData{}
 	want := []string{"TCV", "TCPID", "PSC", "ErrorCode"}
-	if got := tcv.CSVHeaders(); !reflect.DeepEqual(got, want) {
-		t.Errorf("TCExecFailureData.CSVHeaders() = %v, want %v", got, want)
+	if got := schema.CSVHeaders(tcv.Columns()); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema.CSVHeaders() = %v, want %v", got, want)
 	}
 }
 
 func TestTCAcceptSuccess_CSVRow(t *testing.T) {
 	tcv := TCAcceptSuccessData{1, 2}
 	want := []string{"Accept", "1", "2", ""}
-	if got := tcv.CSVRow(); !reflect.DeepEqual(got, want) {
-		t.Errorf("TCAcceptSuccessData.CSVRow() = %v, want %v", got, want)
+	if got := schema.CSVRow(tcv.Columns(), tcv.Values()); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema.CSVRow() = %v, want %v", got, want)
 	}
 }
 
 func TestTCAcceptFailure_CSVRow(t *testing.T) {
 	tcv := TCAcceptFailureData{1, 2, 3}
 	want := []string{"Accept", "1", "2", "3"}
-	if got := tcv.CSVRow(); !reflect.DeepEqual(got, want) {
-		t.Errorf("TCAcceptFailureData.CSVRow() = %v, want %v", got, want)
+	if got := schema.CSVRow(tcv.Columns(), tcv.Values()); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema.CSVRow() = %v, want %v", got, want)
 	}
 }
 
 func TestTCExecSuccess_CSVRow(t *testing.T) {
 	tcv := TCExecSuccessData{1, 2}
 	want := []string{"Exec", "1", "2", ""}
-	if got := tcv.CSVRow(); !reflect.DeepEqual(got, want) {
-		t.Errorf("TCExecSuccessData.CSVRow() = %v, want %v", got, want)
+	if got := schema.CSVRow(tcv.Columns(), tcv.Values()); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema.CSVRow() = %v, want %v", got, want)
 	}
 }
 
 func TestTCExecFailure_CSVRow(t *testing.T) {
 	tcv := TCExecFailureData{1, 2, 3}
 	want := []string{"Exec", "1", "2", "3"}
-	if got := tcv.CSVRow(); !reflect.DeepEqual(got, want) {
-		t.Errorf("TCExecFailureData.CSVRow() = %v, want %v", got, want)
+	if got := schema.CSVRow(tcv.Columns(), tcv.Values()); !reflect.DeepEqual(got, want) {
+		t.Errorf("schema.CSVRow() = %v, want %v", got, want)
 	}
 }
 
-func TestTCAcceptSuccessData_SetParquet(t *testing.T) {
+func TestTCAcceptSuccessData_Values(t *testing.T) {
 	tcv := TCAcceptSuccessData{1, 2}
-	want := parquetrow.ParquetRow{
-		TCV:   "Accept",
-		TCPID: 1,
-		PSC:   2,
+	want := map[string]interface{}{
+		"TCV":   "Accept",
+		"TCPID": int64(1),
+		"PSC":   int64(2),
 	}
-	row := parquetrow.ParquetRow{}
-	if tcv.SetParquet(&row); !reflect.DeepEqual(row, want) {
-		t.Errorf("TCAcceptSuccessData.SetParquet() = %v, want %v", row, want)
+	columns := tcv.Columns()
+	values := tcv.Values()
+	if len(values) != len(columns) {
+		t.Fatalf("TCAcceptSuccessData.Values() has %v values, want %v", len(values), len(columns))
 	}
-}
-
-func TestTCAcceptFailureData_SetParquet(t *testing.T) {
-	tcv := TCAcceptFailureData{1, 2, 3}
-	want := parquetrow.ParquetRow{
-		TCV:       "Accept",
-		TCPID:     1,
-		PSC:       2,
-		ErrorCode: 3,
-	}
-	row := parquetrow.ParquetRow{}
-	if tcv.SetParquet(&row); !reflect.DeepEqual(row, want) {
-		t.Errorf("TCAcceptFailureData.SetParquet() = %v, want %v", row, want)
+	for name, value := range want {
+		idx := schema.Index(columns, name)
+		if idx < 0 {
+			t.Errorf("TCAcceptSuccessData.Columns() lacks %v", name)
+			continue
+		}
+		if !reflect.DeepEqual(values[idx], value) {
+			t.Errorf("TCAcceptSuccessData.Values() %v = %v, want %v", name, values[idx], value)
+		}
 	}
 }
 
-func TestTCExecSuccessData_SetParquet(t *testing.T) {
+func TestTCAcceptFailureData_Values(t *testing.T) {
+	tcv := TCAcceptFailureData{1, 2, 3}
+	want := map[string]interface{}{
+		"TCV":       "Accept",
+		"TCPID":     int64(1),
+		"PSC":       int64(2),
+		"ErrorCode": int64(3),
+	}
+	columns := tcv.Columns()
+	values := tcv.Values()
+	if len(values) != len(columns) {
+		t.Fatalf("TCAcceptFailureData.Values() has %v values, want %v", len(values), len(columns))
+	}
+	for name, value := range want {
+		idx := schema.Index(columns, name)
+		if idx < 0 {
+			t.Errorf("TCAcceptFailureData.Columns() lacks %v", name)
+			continue
+		}
+		if !reflect.DeepEqual(values[idx], value) {
+			t.Errorf("TCAcceptFailureData.Values() %v = %v, want %v", name, values[idx], value)
+		}
+	}
+}
+
+func TestTCExecSuccessData_Values(t *testing.T) {
 	tcv := TCExecSuccessData{1, 2}
-	want := parquetrow.ParquetRow{
-		TCV:   "Exec",
-		TCPID: 1,
-		PSC:   2,
+	want := map[string]interface{}{
+		"TCV":   "Exec",
+		"TCPID": int64(1),
+		"PSC":   int64(2),
+	}
+	columns := tcv.Columns()
+	values := tcv.Values()
+	if len(values) != len(columns) {
+		t.Fatalf("TCExecSuccessData.Values() has %v values, want %v", len(values), len(columns))
 	}
-	row := parquetrow.ParquetRow{}
-	if tcv.SetParquet(&row); !reflect.DeepEqual(row, want) {
-		t.Errorf("TCExecSuccessData.SetParquet() = %v, want %v", row, want)
+	for name, value := range want {
+		idx := schema.Index(columns, name)
+		if idx < 0 {
+			t.Errorf("TCExecSuccessData.Columns() lacks %v", name)
+			continue
+		}
+		if !reflect.DeepEqual(values[idx], value) {
+			t.Errorf("TCExecSuccessData.Values() %v = %v, want %v", name, values[idx], value)
+		}
 	}
 }
 
-func TestTCExecFailureData_SetParquet(t *testing.T) {
+func TestTCExecFailureData_Values(t *testing.T) {
 	tcv := TCExecFailureData{1, 2, 3}
-	want := parquetrow.ParquetRow{
-		TCV:       "Exec",
-		TCPID:     1,
-		PSC:       2,
-		ErrorCode: 3,
-	}
-	row := parquetrow.ParquetRow{}
-	if tcv.SetParquet(&row); !reflect.DeepEqual(row, want) {
-		t.Errorf("TCExecFailureData.SetParquet() = %v, want %v", row, want)
+	want := map[string]interface{}{
+		"TCV":       "Exec",
+		"TCPID":     int64(1),
+		"PSC":       int64(2),
+		"ErrorCode": int64(3),
+	}
+	columns := tcv.Columns()
+	values := tcv.Values()
+	if len(values) != len(columns) {
+		t.Fatalf("TCExecFailureData.Values() has %v values, want %v", len(values), len(columns))
+	}
+	for name, value := range want {
+		idx := schema.Index(columns, name)
+		if idx < 0 {
+			t.Errorf("TCExecFailureData.Columns() lacks %v", name)
+			continue
+		}
+		if !reflect.DeepEqual(values[idx], value) {
+			t.Errorf("TCExecFailureData.Values() %v = %v, want %v", name, values[idx], value)
+		}
 	}
 }
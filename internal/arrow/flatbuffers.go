@@ -0,0 +1,177 @@
+package arrow
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// fbTable is a flatbuffer table under construction
+//
+// Only what is needed for the Arrow IPC metadata is supported. Referenced
+// objects are always laid out after the object that references them, so all
+// offsets point forward as the format requires.
+type fbTable struct {
+	fields []fbField
+}
+
+type fbField struct {
+	id     int
+	scalar []byte
+	ref    interface{}
+}
+
+// fbString is a string referenced from a table
+type fbString string
+
+// fbTables is a vector of tables referenced from a table
+type fbTables []*fbTable
+
+// fbStructs is a vector of fixed size structs referenced from a table
+type fbStructs struct {
+	align int
+	count int
+	data  []byte
+}
+
+func (table *fbTable) addBool(id int, value bool) *fbTable {
+	var b byte
+	if value {
+		b = 1
+	}
+	return table.addScalar(id, []byte{b})
+}
+
+func (table *fbTable) addUint8(id int, value uint8) *fbTable {
+	return table.addScalar(id, []byte{value})
+}
+
+func (table *fbTable) addInt16(id int, value int16) *fbTable {
+	return table.addScalar(id, binary.LittleEndian.AppendUint16(nil, uint16(value)))
+}
+
+func (table *fbTable) addInt32(id int, value int32) *fbTable {
+	return table.addScalar(id, binary.LittleEndian.AppendUint32(nil, uint32(value)))
+}
+
+func (table *fbTable) addInt64(id int, value int64) *fbTable {
+	return table.addScalar(id, binary.LittleEndian.AppendUint64(nil, uint64(value)))
+}
+
+func (table *fbTable) addScalar(id int, value []byte) *fbTable {
+	table.fields = append(table.fields, fbField{id: id, scalar: value})
+	return table
+}
+
+func (table *fbTable) addRef(id int, obj interface{}) *fbTable {
+	table.fields = append(table.fields, fbField{id: id, ref: obj})
+	return table
+}
+
+type fbBuilder struct {
+	buf []byte
+}
+
+// finishFlatbuffer returns the serialized flatbuffer padded to 8 bytes
+func finishFlatbuffer(root *fbTable) []byte {
+	builder := fbBuilder{buf: make([]byte, 4, 256)}
+	pos := builder.write(root)
+	binary.LittleEndian.PutUint32(builder.buf, uint32(pos))
+	builder.pad(8)
+	return builder.buf
+}
+
+func (builder *fbBuilder) pad(align int) {
+	for len(builder.buf)%align != 0 {
+		builder.buf = append(builder.buf, 0)
+	}
+}
+
+func (builder *fbBuilder) patch(pos int, target int) {
+	binary.LittleEndian.PutUint32(builder.buf[pos:], uint32(target-pos))
+}
+
+func (builder *fbBuilder) write(obj interface{}) int {
+	switch o := obj.(type) {
+	case *fbTable:
+		return builder.writeTable(o)
+	case fbString:
+		return builder.writeString(string(o))
+	case fbTables:
+		return builder.writeTables(o)
+	case fbStructs:
+		return builder.writeStructs(o)
+	}
+	panic(fmt.Sprintf("flatbuffer can't hold %T", obj))
+}
+
+func (builder *fbBuilder) writeTable(table *fbTable) int {
+	slots := 0
+	for _, field := range table.fields {
+		if field.id >= slots {
+			slots = field.id + 1
+		}
+	}
+	builder.pad(2)
+	vtable := len(builder.buf)
+	builder.buf = append(builder.buf, make([]byte, 4+2*slots)...)
+	builder.pad(8)
+	start := len(builder.buf)
+	builder.buf = append(builder.buf, 0, 0, 0, 0)
+
+	type reference struct {
+		pos int
+		obj interface{}
+	}
+	var refs []reference
+	for _, field := range table.fields {
+		value := field.scalar
+		if field.ref != nil {
+			value = []byte{0, 0, 0, 0}
+		}
+		builder.pad(len(value))
+		pos := len(builder.buf)
+		binary.LittleEndian.PutUint16(builder.buf[vtable+4+2*field.id:], uint16(pos-start))
+		builder.buf = append(builder.buf, value...)
+		if field.ref != nil {
+			refs = append(refs, reference{pos, field.ref})
+		}
+	}
+	binary.LittleEndian.PutUint16(builder.buf[vtable:], uint16(4+2*slots))
+	binary.LittleEndian.PutUint16(builder.buf[vtable+2:], uint16(len(builder.buf)-start))
+	binary.LittleEndian.PutUint32(builder.buf[start:], uint32(int32(start-vtable)))
+	for _, ref := range refs {
+		builder.patch(ref.pos, builder.write(ref.obj))
+	}
+	return start
+}
+
+func (builder *fbBuilder) writeString(text string) int {
+	builder.pad(4)
+	pos := len(builder.buf)
+	builder.buf = binary.LittleEndian.AppendUint32(builder.buf, uint32(len(text)))
+	builder.buf = append(builder.buf, text...)
+	builder.buf = append(builder.buf, 0)
+	return pos
+}
+
+func (builder *fbBuilder) writeTables(tables fbTables) int {
+	builder.pad(4)
+	pos := len(builder.buf)
+	builder.buf = binary.LittleEndian.AppendUint32(builder.buf, uint32(len(tables)))
+	builder.buf = append(builder.buf, make([]byte, 4*len(tables))...)
+	for i, table := range tables {
+		builder.patch(pos+4+4*i, builder.write(table))
+	}
+	return pos
+}
+
+func (builder *fbBuilder) writeStructs(structs fbStructs) int {
+	builder.pad(4)
+	for structs.align > 4 && (len(builder.buf)+4)%structs.align != 0 {
+		builder.buf = append(builder.buf, 0, 0, 0, 0)
+	}
+	pos := len(builder.buf)
+	builder.buf = binary.LittleEndian.AppendUint32(builder.buf, uint32(structs.count))
+	builder.buf = append(builder.buf, structs.data...)
+	return pos
+}
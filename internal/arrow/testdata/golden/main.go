@@ -0,0 +1,87 @@
+// Command golden writes golden.arrow, the rows of TestWriter as written by
+// Apache Arrow Go.
+//
+// Run it from a module requiring github.com/apache/arrow/go/v12, e.g.
+//
+//	go mod init golden && go get github.com/apache/arrow/go/v12@v12.0.1
+//	go run . > golden.arrow
+//
+// from a copy of this directory and move golden.arrow to testdata.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/apache/arrow/go/v12/arrow"
+	"github.com/apache/arrow/go/v12/arrow/array"
+	"github.com/apache/arrow/go/v12/arrow/ipc"
+	"github.com/apache/arrow/go/v12/arrow/memory"
+)
+
+func main() {
+	metadata := arrow.NewMetadata([]string{"A", "B"}, []string{"1", "2"})
+	schema := arrow.NewSchema(
+		[]arrow.Field{
+			{Name: "Count", Type: arrow.PrimitiveTypes.Int64},
+			{Name: "Name", Type: arrow.BinaryTypes.String},
+			{Name: "Code", Type: arrow.PrimitiveTypes.Int32, Nullable: true},
+			{Name: "Flags", Type: arrow.ListOfField(arrow.Field{Name: "item", Type: arrow.PrimitiveTypes.Int32})},
+			{Name: "Notes", Type: arrow.ListOfField(arrow.Field{Name: "item", Type: arrow.BinaryTypes.String})},
+		},
+		&metadata,
+	)
+	type row struct {
+		count int64
+		name  string
+		code  *int32
+		flags []int32
+		notes []string
+	}
+	code := int32(7)
+	rows := []row{
+		{1, "first", &code, []int32{1, 2}, []string{"a", "b"}},
+		{-2, "", nil, []int32{}, []string{}},
+		{3, "third", nil, []int32{}, []string{"c"}},
+	}
+
+	writer, err := ipc.NewFileWriter(os.Stdout, ipc.WithSchema(schema))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for start := 0; start < len(rows); start += 2 {
+		end := start + 2
+		if end > len(rows) {
+			end = len(rows)
+		}
+		builder := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+		for _, r := range rows[start:end] {
+			builder.Field(0).(*array.Int64Builder).Append(r.count)
+			builder.Field(1).(*array.StringBuilder).Append(r.name)
+			if r.code != nil {
+				builder.Field(2).(*array.Int32Builder).Append(*r.code)
+			} else {
+				builder.Field(2).(*array.Int32Builder).AppendNull()
+			}
+			flags := builder.Field(3).(*array.ListBuilder)
+			flags.Append(true)
+			for _, flag := range r.flags {
+				flags.ValueBuilder().(*array.Int32Builder).Append(flag)
+			}
+			notes := builder.Field(4).(*array.ListBuilder)
+			notes.Append(true)
+			for _, note := range r.notes {
+				notes.ValueBuilder().(*array.StringBuilder).Append(note)
+			}
+		}
+		record := builder.NewRecord()
+		if err := writer.Write(record); err != nil {
+			log.Fatal(err)
+		}
+		record.Release()
+		builder.Release()
+	}
+	if err := writer.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,418 @@
+// Package arrow writes columns as Arrow IPC files (Feather v2).
+//
+// The files can be memory mapped directly by e.g. pyarrow and Arrow.jl. Rows
+// are collected into record batches of fixed size, so one file holds any
+// number of rows.
+package arrow
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
+)
+
+// magic starts and ends an Arrow IPC file
+const magic = "ARROW1"
+
+// metadataV5 is the Arrow metadata version V5 of Feather v2 files, the
+// MetadataVersion enum of Schema.fbs counts from V1 = 0
+const metadataV5 = 4
+
+// Message header types
+const (
+	headerSchema      = 1
+	headerRecordBatch = 3
+)
+
+// Arrow type ids
+const (
+	typeInt           = 2
+	typeFloatingPoint = 3
+	typeBinary        = 4
+	typeUtf8          = 5
+	typeBool          = 6
+	typeTimestamp     = 10
+	typeList          = 12
+)
+
+const (
+	precisionDouble = 2
+	unitNanosecond  = 3
+)
+
+// block locates a message in the file
+type block struct {
+	offset         int64
+	metaDataLength int32
+	bodyLength     int64
+}
+
+// Writer writes rows of values as an Arrow IPC file
+type Writer struct {
+	out       io.Writer
+	columns   []schema.Column
+	schema    *fbTable
+	batchRows int
+	rows      [][]interface{}
+	offset    int64
+	blocks    []block
+}
+
+// NewWriter writes the file header and schema and returns a writer that
+// collects batchRows rows per record batch
+func NewWriter(
+	out io.Writer,
+	columns []schema.Column,
+	metadata map[string]string,
+	batchRows int,
+) (*Writer, error) {
+	if batchRows < 1 {
+		batchRows = 1
+	}
+	writer := Writer{
+		out:       out,
+		columns:   columns,
+		schema:    schemaTable(columns, metadata),
+		batchRows: batchRows,
+	}
+	if err := writer.write([]byte(magic + "\x00\x00")); err != nil {
+		return nil, err
+	}
+	if _, err := writer.writeMessage(headerSchema, writer.schema, nil); err != nil {
+		return nil, err
+	}
+	return &writer, nil
+}
+
+// Write adds a row, the values must be normalized as by schema.Values
+func (writer *Writer) Write(values []interface{}) error {
+	if len(values) != len(writer.columns) {
+		return fmt.Errorf("got %v values for %v columns", len(values), len(writer.columns))
+	}
+	writer.rows = append(writer.rows, values)
+	if len(writer.rows) >= writer.batchRows {
+		return writer.Flush()
+	}
+	return nil
+}
+
+// Flush writes the collected rows as a record batch
+func (writer *Writer) Flush() error {
+	if len(writer.rows) == 0 {
+		return nil
+	}
+	batch := recordBatch{}
+	for i, column := range writer.columns {
+		values := make([]interface{}, len(writer.rows))
+		for j, row := range writer.rows {
+			values[j] = row[i]
+		}
+		if err := batch.addColumn(column, values); err != nil {
+			return err
+		}
+	}
+	header := new(fbTable).
+		addInt64(0, int64(len(writer.rows))).
+		addRef(1, fbStructs{align: 8, count: batch.nNodes, data: batch.nodes}).
+		addRef(2, fbStructs{align: 8, count: batch.nBuffers, data: batch.buffers})
+	writer.rows = nil
+	blk, err := writer.writeMessage(headerRecordBatch, header, batch.body)
+	if err != nil {
+		return err
+	}
+	writer.blocks = append(writer.blocks, blk)
+	return nil
+}
+
+// Close flushes remaining rows and writes the file footer, it doesn't close
+// the underlying writer
+func (writer *Writer) Close() error {
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	var blocks []byte
+	for _, blk := range writer.blocks {
+		blocks = binary.LittleEndian.AppendUint64(blocks, uint64(blk.offset))
+		blocks = binary.LittleEndian.AppendUint32(blocks, uint32(blk.metaDataLength))
+		blocks = append(blocks, 0, 0, 0, 0)
+		blocks = binary.LittleEndian.AppendUint64(blocks, uint64(blk.bodyLength))
+	}
+	footer := finishFlatbuffer(new(fbTable).
+		addInt16(0, metadataV5).
+		addRef(1, writer.schema).
+		addRef(2, fbStructs{align: 8}).
+		addRef(3, fbStructs{align: 8, count: len(writer.blocks), data: blocks}))
+	footer = binary.LittleEndian.AppendUint32(footer, uint32(len(footer)))
+	return writer.write(append(footer, magic...))
+}
+
+func (writer *Writer) write(data []byte) error {
+	n, err := writer.out.Write(data)
+	writer.offset += int64(n)
+	return err
+}
+
+// writeMessage writes an encapsulated message with its body
+func (writer *Writer) writeMessage(headerType uint8, header *fbTable, body []byte) (block, error) {
+	metadata := finishFlatbuffer(new(fbTable).
+		addInt16(0, metadataV5).
+		addUint8(1, headerType).
+		addRef(2, header).
+		addInt64(3, int64(len(body))))
+	prefix := binary.LittleEndian.AppendUint32(nil, 0xffffffff)
+	prefix = binary.LittleEndian.AppendUint32(prefix, uint32(len(metadata)))
+	blk := block{
+		offset:         writer.offset,
+		metaDataLength: int32(len(prefix) + len(metadata)),
+		bodyLength:     int64(len(body)),
+	}
+	for _, data := range [][]byte{prefix, metadata, body} {
+		if err := writer.write(data); err != nil {
+			return blk, err
+		}
+	}
+	return blk, nil
+}
+
+func schemaTable(columns []schema.Column, metadata map[string]string) *fbTable {
+	fields := make(fbTables, len(columns))
+	for i, column := range columns {
+		fields[i] = fieldTable(column.Name, column.Optional, column.Type)
+	}
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	keyValues := make(fbTables, len(keys))
+	for i, key := range keys {
+		keyValues[i] = new(fbTable).addRef(0, fbString(key)).addRef(1, fbString(metadata[key]))
+	}
+	return new(fbTable).addInt16(0, 0).addRef(1, fields).addRef(2, keyValues)
+}
+
+func fieldTable(name string, nullable bool, columnType schema.Type) *fbTable {
+	var typeID uint8
+	typeTable := new(fbTable)
+	children := fbTables{}
+	switch columnType {
+	case schema.Bool:
+		typeID = typeBool
+	case schema.Int32:
+		typeID = typeInt
+		typeTable.addInt32(0, 32).addBool(1, true)
+	case schema.Int64:
+		typeID = typeInt
+		typeTable.addInt32(0, 64).addBool(1, true)
+	case schema.Double:
+		typeID = typeFloatingPoint
+		typeTable.addInt16(0, precisionDouble)
+	case schema.String:
+		typeID = typeUtf8
+	case schema.Timestamp:
+		typeID = typeTimestamp
+		typeTable.addInt16(0, unitNanosecond).addRef(1, fbString("UTC"))
+	case schema.Bytes:
+		typeID = typeBinary
+	case schema.IntList:
+		typeID = typeList
+		children = append(children, fieldTable("item", false, schema.Int32))
+	case schema.StringList:
+		typeID = typeList
+		children = append(children, fieldTable("item", false, schema.String))
+	}
+	return new(fbTable).
+		addRef(0, fbString(name)).
+		addBool(1, nullable).
+		addUint8(2, typeID).
+		addRef(3, typeTable).
+		addRef(5, children)
+}
+
+// recordBatch holds the field nodes, buffer locations and body of a batch
+type recordBatch struct {
+	nodes    []byte
+	nNodes   int
+	buffers  []byte
+	nBuffers int
+	body     []byte
+}
+
+func (batch *recordBatch) addNode(length int, nulls int) {
+	batch.nodes = binary.LittleEndian.AppendUint64(batch.nodes, uint64(length))
+	batch.nodes = binary.LittleEndian.AppendUint64(batch.nodes, uint64(nulls))
+	batch.nNodes++
+}
+
+// addBuffer appends the data to the body, keeping buffers 8 byte aligned
+func (batch *recordBatch) addBuffer(data []byte) {
+	batch.buffers = binary.LittleEndian.AppendUint64(batch.buffers, uint64(len(batch.body)))
+	batch.buffers = binary.LittleEndian.AppendUint64(batch.buffers, uint64(len(data)))
+	batch.nBuffers++
+	batch.body = append(batch.body, data...)
+	for len(batch.body)%8 != 0 {
+		batch.body = append(batch.body, 0)
+	}
+}
+
+// addValidity adds the node and validity bitmap, which is left empty if no
+// value is missing
+func (batch *recordBatch) addValidity(values []interface{}) {
+	bitmap := make([]byte, (len(values)+7)/8)
+	nulls := 0
+	for i, value := range values {
+		if value == nil {
+			nulls++
+		} else {
+			bitmap[i/8] |= 1 << (i % 8)
+		}
+	}
+	batch.addNode(len(values), nulls)
+	if nulls == 0 {
+		bitmap = nil
+	}
+	batch.addBuffer(bitmap)
+}
+
+func (batch *recordBatch) addColumn(column schema.Column, values []interface{}) error {
+	if !column.Optional {
+		// Missing lists are empty as in parquet
+		for i, value := range values {
+			if value == nil && column.Type == schema.IntList {
+				values[i] = []int64{}
+			} else if value == nil && column.Type == schema.StringList {
+				values[i] = []string{}
+			}
+		}
+	}
+	batch.addValidity(values)
+	var data []byte
+	invalid := func(value interface{}) error {
+		return fmt.Errorf("column %v has value %v that can't be exported", column.Name, value)
+	}
+	switch column.Type {
+	case schema.Bool:
+		data = make([]byte, (len(values)+7)/8)
+		for i, value := range values {
+			flag, ok := value.(bool)
+			if !ok && value != nil {
+				return invalid(value)
+			}
+			if flag {
+				data[i/8] |= 1 << (i % 8)
+			}
+		}
+	case schema.Int32, schema.Int64:
+		for _, value := range values {
+			number, ok := value.(int64)
+			if !ok && value != nil {
+				return invalid(value)
+			}
+			if column.Type == schema.Int32 {
+				data = binary.LittleEndian.AppendUint32(data, uint32(number))
+			} else {
+				data = binary.LittleEndian.AppendUint64(data, uint64(number))
+			}
+		}
+	case schema.Double:
+		for _, value := range values {
+			number, ok := value.(float64)
+			if !ok && value != nil {
+				return invalid(value)
+			}
+			data = binary.LittleEndian.AppendUint64(data, math.Float64bits(number))
+		}
+	case schema.Timestamp:
+		for _, value := range values {
+			var nanos int64
+			switch timestamp := value.(type) {
+			case time.Time:
+				nanos = timestamp.UnixNano()
+			case nil:
+			default:
+				return invalid(value)
+			}
+			data = binary.LittleEndian.AppendUint64(data, uint64(nanos))
+		}
+	case schema.String, schema.Bytes:
+		texts := make([][]byte, len(values))
+		for i, value := range values {
+			switch text := value.(type) {
+			case string:
+				texts[i] = []byte(text)
+			case []byte:
+				texts[i] = text
+			case nil:
+			default:
+				return invalid(value)
+			}
+		}
+		batch.addVariable(texts)
+		return nil
+	case schema.IntList, schema.StringList:
+		return batch.addList(column, values, invalid)
+	}
+	batch.addBuffer(data)
+	return nil
+}
+
+// addVariable adds the offsets and data buffers of variable length values
+func (batch *recordBatch) addVariable(values [][]byte) {
+	offsets := binary.LittleEndian.AppendUint32(nil, 0)
+	var data []byte
+	for _, value := range values {
+		data = append(data, value...)
+		offsets = binary.LittleEndian.AppendUint32(offsets, uint32(len(data)))
+	}
+	batch.addBuffer(offsets)
+	batch.addBuffer(data)
+}
+
+func (batch *recordBatch) addList(
+	column schema.Column,
+	values []interface{},
+	invalid func(value interface{}) error,
+) error {
+	offsets := binary.LittleEndian.AppendUint32(nil, 0)
+	var numbers []byte
+	var texts [][]byte
+	count := 0
+	for _, value := range values {
+		switch list := value.(type) {
+		case []int64:
+			if column.Type != schema.IntList {
+				return invalid(value)
+			}
+			for _, number := range list {
+				numbers = binary.LittleEndian.AppendUint32(numbers, uint32(number))
+			}
+			count += len(list)
+		case []string:
+			if column.Type != schema.StringList {
+				return invalid(value)
+			}
+			for _, text := range list {
+				texts = append(texts, []byte(text))
+			}
+			count += len(list)
+		case nil:
+		default:
+			return invalid(value)
+		}
+		offsets = binary.LittleEndian.AppendUint32(offsets, uint32(count))
+	}
+	batch.addBuffer(offsets)
+	batch.addNode(count, 0)
+	batch.addBuffer(nil)
+	if column.Type == schema.IntList {
+		batch.addBuffer(numbers)
+	} else {
+		batch.addVariable(texts)
+	}
+	return nil
+}
@@ -0,0 +1,339 @@
+package arrow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
+)
+
+// fbView is a read-only view of a flatbuffer table used to verify output
+type fbView struct {
+	buf []byte
+	pos int
+}
+
+func u16(buf []byte, pos int) int { return int(binary.LittleEndian.Uint16(buf[pos:])) }
+func u32(buf []byte, pos int) int { return int(binary.LittleEndian.Uint32(buf[pos:])) }
+func i64(buf []byte, pos int) int64 {
+	return int64(binary.LittleEndian.Uint64(buf[pos:]))
+}
+
+func fbRoot(buf []byte) fbView {
+	return fbView{buf, u32(buf, 0)}
+}
+
+func (view fbView) field(id int) int {
+	vtable := view.pos - int(int32(binary.LittleEndian.Uint32(view.buf[view.pos:])))
+	if 4+2*id >= u16(view.buf, vtable) {
+		return -1
+	}
+	offset := u16(view.buf, vtable+4+2*id)
+	if offset == 0 {
+		return -1
+	}
+	return view.pos + offset
+}
+
+func (view fbView) ref(id int) int {
+	pos := view.field(id)
+	return pos + u32(view.buf, pos)
+}
+
+func (view fbView) table(id int) fbView {
+	return fbView{view.buf, view.ref(id)}
+}
+
+func (view fbView) text(id int) string {
+	pos := view.ref(id)
+	return string(view.buf[pos+4 : pos+4+u32(view.buf, pos)])
+}
+
+func (view fbView) tables(id int) []fbView {
+	pos := view.ref(id)
+	tables := make([]fbView, u32(view.buf, pos))
+	for i := range tables {
+		elem := pos + 4 + 4*i
+		tables[i] = fbView{view.buf, elem + u32(view.buf, elem)}
+	}
+	return tables
+}
+
+// byteAt returns the byte sized scalar, zero if left at its default
+func (view fbView) byteAt(id int) byte {
+	pos := view.field(id)
+	if pos < 0 {
+		return 0
+	}
+	return view.buf[pos]
+}
+
+// footerVersion returns the metadata version of the footer of the file
+func footerVersion(data []byte) int {
+	footerLength := u32(data, len(data)-10)
+	footer := fbRoot(data[len(data)-10-footerLength : len(data)-10])
+	pos := footer.field(0)
+	if pos < 0 {
+		return 0
+	}
+	return u16(footer.buf, pos)
+}
+
+// describeField renders the field and its children as text, reading
+// scalars left at their defaults as other writers may leave them out
+func describeField(field fbView) string {
+	description := fmt.Sprintf("%v nullable=%v type=%v", field.text(0), field.byteAt(1) == 1, field.byteAt(2))
+	if field.byteAt(2) == typeInt {
+		intType := field.table(3)
+		description += fmt.Sprintf(" bits=%v signed=%v", u32(intType.buf, intType.field(0)), intType.byteAt(1) == 1)
+	}
+	if field.field(5) >= 0 {
+		for _, child := range field.tables(5) {
+			description += " [" + describeField(child) + "]"
+		}
+	}
+	return description
+}
+
+// structs returns the start and count of a struct vector
+func (view fbView) structs(id int) (int, int) {
+	pos := view.ref(id)
+	return pos + 4, u32(view.buf, pos)
+}
+
+type testRow struct {
+	Count int64
+	Name  string
+	Code  *uint8
+	Flags []uint16
+	Notes []string
+}
+
+func decodeFile(t *testing.T, data []byte) (fbView, [][]interface{}) {
+	if string(data[:6]) != magic || string(data[len(data)-6:]) != magic {
+		t.Fatalf("file lacks magic: %v", data)
+	}
+	footerLength := u32(data, len(data)-10)
+	footer := fbRoot(data[len(data)-10-footerLength : len(data)-10])
+	start, count := footer.structs(3)
+	var rows [][]interface{}
+	for i := 0; i < count; i++ {
+		blk := start + 24*i
+		offset := int(i64(footer.buf, blk))
+		metaLength := u32(footer.buf, blk+8)
+		if offset%8 != 0 || metaLength%8 != 0 {
+			t.Errorf("block %v at %v with metadata %v is not aligned", i, offset, metaLength)
+		}
+		if u32(data, offset) != 0xffffffff {
+			t.Errorf("block %v lacks continuation marker", i)
+		}
+		message := fbRoot(data[offset+8 : offset+metaLength])
+		if message.buf[message.field(1)] != headerRecordBatch {
+			t.Fatalf("block %v is not a record batch", i)
+		}
+		body := data[offset+metaLength:]
+		rows = append(rows, decodeBatch(message.table(2), body)...)
+	}
+	return footer.table(1), rows
+}
+
+// decodeBatch decodes a record batch of testRow, whose columns have the
+// buffers validity and data (Count), validity, offsets and data (Name),
+// validity and data (Code) and validity and offsets followed by the buffers
+// of the items (Flags and Notes)
+func decodeBatch(batch fbView, body []byte) [][]interface{} {
+	length := int(i64(batch.buf, batch.field(0)))
+	buffers, _ := batch.structs(2)
+	buffer := func(n int) []byte {
+		pos := buffers + 16*n
+		offset := i64(batch.buf, pos)
+		return body[offset : offset+i64(batch.buf, pos+8)]
+	}
+	valid := func(n int, row int) bool {
+		bitmap := buffer(n)
+		return len(bitmap) == 0 || bitmap[row/8]&(1<<(row%8)) != 0
+	}
+	rows := make([][]interface{}, length)
+	for row := range rows {
+		offsets := buffer(3)
+		nameStart, nameEnd := u32(offsets, 4*row), u32(offsets, 4*row+4)
+		var code interface{}
+		if valid(5, row) {
+			code = int64(int32(u32(buffer(6), 4*row)))
+		}
+		flagOffsets := buffer(8)
+		flags := []int64{}
+		for i := u32(flagOffsets, 4*row); i < u32(flagOffsets, 4*row+4); i++ {
+			flags = append(flags, int64(u32(buffer(10), 4*i)))
+		}
+		noteOffsets := buffer(12)
+		notes := []string{}
+		for i := u32(noteOffsets, 4*row); i < u32(noteOffsets, 4*row+4); i++ {
+			textOffsets := buffer(14)
+			notes = append(notes, string(buffer(15)[u32(textOffsets, 4*i):u32(textOffsets, 4*i+4)]))
+		}
+		rows[row] = []interface{}{
+			i64(buffer(1), 8*row),
+			string(buffer(4)[nameStart:nameEnd]),
+			code,
+			flags,
+			notes,
+		}
+	}
+	return rows
+}
+
+func TestWriter(t *testing.T) {
+	columns := schema.Columns(testRow{})
+	code := uint8(7)
+	input := []testRow{
+		{Count: 1, Name: "first", Code: &code, Flags: []uint16{1, 2}, Notes: []string{"a", "b"}},
+		{Count: -2, Name: "", Flags: []uint16{}},
+		{Count: 3, Name: "third", Notes: []string{"c"}},
+	}
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, columns, map[string]string{"B": "2", "A": "1"}, 2)
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	for _, row := range input {
+		if err := writer.Write(schema.Values(row)); err != nil {
+			t.Errorf("Writer.Write() error = %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Errorf("Writer.Close() error = %v", err)
+	}
+
+	fileSchema, rows := decodeFile(t, buf.Bytes())
+	var names []string
+	var types []byte
+	var nullable []bool
+	for _, field := range fileSchema.tables(1) {
+		names = append(names, field.text(0))
+		nullable = append(nullable, field.buf[field.field(1)] == 1)
+		types = append(types, field.buf[field.field(2)])
+	}
+	if want := []string{"Count", "Name", "Code", "Flags", "Notes"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("schema fields = %v, want %v", names, want)
+	}
+	if want := []byte{typeInt, typeUtf8, typeInt, typeList, typeList}; !reflect.DeepEqual(types, want) {
+		t.Errorf("schema types = %v, want %v", types, want)
+	}
+	if want := []bool{false, false, true, false, false}; !reflect.DeepEqual(nullable, want) {
+		t.Errorf("schema nullable = %v, want %v", nullable, want)
+	}
+	var metadata []string
+	for _, keyValue := range fileSchema.tables(2) {
+		metadata = append(metadata, keyValue.text(0)+"="+keyValue.text(1))
+	}
+	if want := []string{"A=1", "B=2"}; !reflect.DeepEqual(metadata, want) {
+		t.Errorf("schema metadata = %v, want %v", metadata, want)
+	}
+
+	want := [][]interface{}{
+		{int64(1), "first", int64(7), []int64{1, 2}, []string{"a", "b"}},
+		{int64(-2), "", nil, []int64{}, []string{}},
+		{int64(3), "third", nil, []int64{}, []string{"c"}},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("Writer wrote %v, want %v", rows, want)
+	}
+}
+
+func TestWriter_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, schema.Columns(testRow{}), nil, 10)
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Errorf("Writer.Close() error = %v", err)
+	}
+	if _, rows := decodeFile(t, buf.Bytes()); len(rows) != 0 {
+		t.Errorf("Writer wrote %v, want no rows", rows)
+	}
+}
+
+func TestWriter_Write_Errors(t *testing.T) {
+	columns := []schema.Column{{Name: "Value", Type: schema.Double}}
+	tests := []struct {
+		name   string
+		values []interface{}
+	}{
+		{"Wrong number of values", []interface{}{1.0, 2.0}},
+		{"Wrong type", []interface{}{"text"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			writer, err := NewWriter(&buf, columns, nil, 1)
+			if err != nil {
+				t.Fatalf("NewWriter() error = %v", err)
+			}
+			if err := writer.Write(tt.values); err == nil {
+				t.Error("Writer.Write() gave no error")
+			}
+		})
+	}
+}
+
+// TestWriter_golden compares the output with testdata/golden.arrow, the rows
+// of TestWriter written by Apache Arrow Go with testdata/golden/main.go
+func TestWriter_golden(t *testing.T) {
+	golden, err := os.ReadFile(filepath.Join("testdata", "golden.arrow"))
+	if err != nil {
+		t.Fatalf("could not read the golden file, generate it with testdata/golden/main.go: %v", err)
+	}
+	code := uint8(7)
+	input := []testRow{
+		{Count: 1, Name: "first", Code: &code, Flags: []uint16{1, 2}, Notes: []string{"a", "b"}},
+		{Count: -2, Name: "", Flags: []uint16{}},
+		{Count: 3, Name: "third", Notes: []string{"c"}},
+	}
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, schema.Columns(testRow{}), map[string]string{"A": "1", "B": "2"}, 2)
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	for _, row := range input {
+		if err := writer.Write(schema.Values(row)); err != nil {
+			t.Errorf("Writer.Write() error = %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Errorf("Writer.Close() error = %v", err)
+	}
+
+	if got, want := footerVersion(buf.Bytes()), footerVersion(golden); got != want || got != metadataV5 {
+		t.Errorf("Writer wrote metadata version %v, want %v as Arrow Go (V5)", got, want)
+	}
+	describe := func(data []byte) ([]string, []string, [][]interface{}) {
+		fileSchema, rows := decodeFile(t, data)
+		var fields []string
+		for _, field := range fileSchema.tables(1) {
+			fields = append(fields, describeField(field))
+		}
+		var metadata []string
+		for _, keyValue := range fileSchema.tables(2) {
+			metadata = append(metadata, keyValue.text(0)+"="+keyValue.text(1))
+		}
+		return fields, metadata, rows
+	}
+	fields, metadata, rows := describe(buf.Bytes())
+	wantFields, wantMetadata, wantRows := describe(golden)
+	if !reflect.DeepEqual(fields, wantFields) {
+		t.Errorf("Writer wrote fields\n%v\nwant as Arrow Go\n%v", fields, wantFields)
+	}
+	if !reflect.DeepEqual(metadata, wantMetadata) {
+		t.Errorf("Writer wrote metadata %v, want as Arrow Go %v", metadata, wantMetadata)
+	}
+	if !reflect.DeepEqual(rows, wantRows) {
+		t.Errorf("Writer wrote %v, want as Arrow Go %v", rows, wantRows)
+	}
+}
@@ -0,0 +1,290 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// AtomicFile is an output file written under a temporary name in the same
+// directory and renamed to its name once closed, so that an interrupted run
+// leaves no partial outputs behind
+//
+// The temporary name starts with a dot so that crawlers skip it.
+type AtomicFile struct {
+	*os.File
+	path    string
+	closed  bool
+	origins []string
+}
+
+// CreateAtomic creates an output file that appears at the name when closed
+func CreateAtomic(name string) (*AtomicFile, error) {
+	file, err := os.CreateTemp(filepath.Dir(name), fmt.Sprintf(".%v.*.tmp", filepath.Base(name)))
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicFile{File: file, path: name}, nil
+}
+
+// Path returns the name the file gets when closed
+func (file *AtomicFile) Path() string {
+	return file.path
+}
+
+// AddOrigin notes that the file holds records of the origin, if known
+func (file *AtomicFile) AddOrigin(origin string) {
+	if origin == "" || ContainsString(file.origins, origin) {
+		return
+	}
+	file.origins = append(file.origins, origin)
+}
+
+// Close closes the file and renames it to its name, recording it as an
+// output of the run with its origins
+func (file *AtomicFile) Close() error {
+	file.closed = true
+	err := file.File.Close()
+	if err != nil {
+		os.Remove(file.File.Name())
+		return err
+	}
+	err = os.Chmod(file.File.Name(), 0644)
+	if err == nil {
+		err = os.Rename(file.File.Name(), file.path)
+	}
+	if err != nil {
+		os.Remove(file.File.Name())
+		return err
+	}
+	RecordOutput(file.path, file.origins...)
+	return nil
+}
+
+// Abort closes and removes the file, leaving any earlier file at its name,
+// unless the file was already closed
+func (file *AtomicFile) Abort() error {
+	if file.closed {
+		return nil
+	}
+	file.closed = true
+	file.File.Close()
+	return os.Remove(file.File.Name())
+}
+
+// AppendFile is an existing output file written to at its end, so that
+// adding to it doesn't copy it
+//
+// An aborted AppendFile is truncated to its size when opened, or removed if
+// it was created.
+type AppendFile struct {
+	*os.File
+	path    string
+	size    int64
+	created bool
+	closed  bool
+	origins []string
+}
+
+// OpenAppend opens the file for writing at its end, creating it if missing
+func OpenAppend(name string) (*AppendFile, error) {
+	_, err := os.Stat(name)
+	created := os.IsNotExist(err)
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &AppendFile{File: file, path: name, size: info.Size(), created: created}, nil
+}
+
+// Path returns the name of the file
+func (file *AppendFile) Path() string {
+	return file.path
+}
+
+// AddOrigin notes that the file holds records of the origin, if known
+func (file *AppendFile) AddOrigin(origin string) {
+	if origin == "" || ContainsString(file.origins, origin) {
+		return
+	}
+	file.origins = append(file.origins, origin)
+}
+
+// Close closes the file, recording it as an output of the run with its
+// origins
+func (file *AppendFile) Close() error {
+	file.closed = true
+	err := file.File.Close()
+	if err != nil {
+		return err
+	}
+	RecordOutput(file.path, file.origins...)
+	return nil
+}
+
+// Abort truncates the file to its size when opened, or removes it if
+// created, and closes it, unless the file was already closed
+func (file *AppendFile) Abort() error {
+	if file.closed {
+		return nil
+	}
+	file.closed = true
+	if file.created {
+		file.File.Close()
+		return os.Remove(file.path)
+	}
+	err := file.File.Truncate(file.size)
+	if closeErr := file.File.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// WriteFileAtomic writes the data to the named file as os.WriteFile but
+// through an AtomicFile holding records of the origins
+func WriteFileAtomic(name string, data []byte, origins ...string) error {
+	file, err := CreateAtomic(name)
+	if err != nil {
+		return err
+	}
+	for _, origin := range origins {
+		file.AddOrigin(origin)
+	}
+	_, err = file.Write(data)
+	if err != nil {
+		file.Abort()
+		return err
+	}
+	return file.Close()
+}
+
+// Outputs records the files completely written with the origins of their
+// records
+type Outputs struct {
+	lock    sync.Mutex
+	origins map[string]map[string]bool // The origins by output
+}
+
+// NewOutputs returns an empty record of outputs
+func NewOutputs() *Outputs {
+	return &Outputs{origins: make(map[string]map[string]bool)}
+}
+
+// Record notes that the file holding records of the origins was completely
+// written
+func (outputs *Outputs) Record(name string, origins ...string) {
+	outputs.lock.Lock()
+	defer outputs.lock.Unlock()
+	name = filepath.Clean(name)
+	if outputs.origins[name] == nil {
+		outputs.origins[name] = make(map[string]bool)
+	}
+	for _, origin := range origins {
+		outputs.origins[name][origin] = true
+	}
+}
+
+// Names returns the sorted names of the files recorded
+func (outputs *Outputs) Names() []string {
+	outputs.lock.Lock()
+	defer outputs.lock.Unlock()
+	names := make([]string, 0, len(outputs.origins))
+	for name := range outputs.origins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Of returns the sorted names of the files recorded that hold records of the
+// origin
+func (outputs *Outputs) Of(origin string) []string {
+	outputs.lock.Lock()
+	defer outputs.lock.Unlock()
+	var names []string
+	for name, origins := range outputs.origins {
+		if origins[origin] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Reset forgets the files recorded
+func (outputs *Outputs) Reset() {
+	outputs.lock.Lock()
+	defer outputs.lock.Unlock()
+	outputs.origins = make(map[string]map[string]bool)
+}
+
+var runOutputs = NewOutputs()             // Outputs not below a scoped directory
+var scopedOutputs = map[string]*Outputs{} // Outputs by scoped directory
+var scopesLock sync.Mutex
+
+// ScopeOutputs records the files written below the directory in outputs of
+// their own, rather than in those of the run, until the returned end is
+// called
+//
+// This keeps jobs writing to directories of their own from seeing or
+// resetting each other's outputs.
+func ScopeOutputs(dir string) (*Outputs, func()) {
+	scopesLock.Lock()
+	defer scopesLock.Unlock()
+	dir = filepath.Clean(dir)
+	outputs := NewOutputs()
+	scopedOutputs[dir] = outputs
+	return outputs, func() {
+		scopesLock.Lock()
+		defer scopesLock.Unlock()
+		if scopedOutputs[dir] == outputs {
+			delete(scopedOutputs, dir)
+		}
+	}
+}
+
+// outputsOf returns the outputs of the innermost scoped directory holding
+// the file, or those of the run
+func outputsOf(name string) *Outputs {
+	scopesLock.Lock()
+	defer scopesLock.Unlock()
+	for dir := filepath.Dir(name); ; dir = filepath.Dir(dir) {
+		if outputs, ok := scopedOutputs[dir]; ok {
+			return outputs
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			return runOutputs
+		}
+	}
+}
+
+// RecordOutput notes that the file holding records of the origins was
+// completely written
+func RecordOutput(name string, origins ...string) {
+	name = filepath.Clean(name)
+	outputsOf(name).Record(name, origins...)
+}
+
+// RecordedOutputs returns the sorted names of the files of the run
+// completely written since ResetOutputs
+func RecordedOutputs() []string {
+	return runOutputs.Names()
+}
+
+// OutputsOf returns the sorted names of the files of the run completely
+// written since ResetOutputs that hold records of the origin
+func OutputsOf(origin string) []string {
+	return runOutputs.Of(origin)
+}
+
+// ResetOutputs forgets the recorded outputs of the run
+func ResetOutputs() {
+	runOutputs.Reset()
+}
@@ -0,0 +1,203 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func listDir(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestAtomicFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		abort     bool
+		want      string
+		wantFiles []string
+	}{
+		{"Close replaces the file", false, "new", []string{"out.txt"}},
+		{"Abort keeps the earlier file", true, "old", []string{"out.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ResetOutputs()
+			defer ResetOutputs()
+			dir := t.TempDir()
+			name := filepath.Join(dir, "out.txt")
+			if err := os.WriteFile(name, []byte("old"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			file, err := CreateAtomic(name)
+			if err != nil {
+				t.Fatalf("CreateAtomic() error = %v", err)
+			}
+			file.WriteString("new")
+			if got := len(listDir(t, dir)); got != 2 {
+				t.Errorf("CreateAtomic() gave %v files while writing, want 2", got)
+			}
+			if tt.abort {
+				err = file.Abort()
+			} else {
+				err = file.Close()
+			}
+			if err != nil {
+				t.Errorf("AtomicFile error = %v", err)
+			}
+			file.Abort()
+			content, _ := os.ReadFile(name)
+			if string(content) != tt.want {
+				t.Errorf("AtomicFile left %q, want %q", content, tt.want)
+			}
+			if got := listDir(t, dir); !reflect.DeepEqual(got, tt.wantFiles) {
+				t.Errorf("AtomicFile left %v, want %v", got, tt.wantFiles)
+			}
+			recorded := len(RecordedOutputs()) == 1
+			if recorded == tt.abort {
+				t.Errorf("RecordedOutputs() = %v, want output recorded %v", RecordedOutputs(), !tt.abort)
+			}
+		})
+	}
+}
+
+func TestAppendFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		old       string
+		abort     bool
+		want      string
+		wantFiles []string
+	}{
+		{"Close keeps the rows added", "old\n", false, "old\nnew\n", []string{"out.txt"}},
+		{"Abort truncates to the earlier file", "old\n", true, "old\n", []string{"out.txt"}},
+		{"Close creates a missing file", "", false, "new\n", []string{"out.txt"}},
+		{"Abort removes a created file", "", true, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ResetOutputs()
+			defer ResetOutputs()
+			dir := t.TempDir()
+			name := filepath.Join(dir, "out.txt")
+			if tt.old != "" {
+				if err := os.WriteFile(name, []byte(tt.old), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			file, err := OpenAppend(name)
+			if err != nil {
+				t.Fatalf("OpenAppend() error = %v", err)
+			}
+			file.WriteString("new\n")
+			if tt.abort {
+				err = file.Abort()
+			} else {
+				err = file.Close()
+			}
+			if err != nil {
+				t.Errorf("AppendFile error = %v", err)
+			}
+			file.Abort()
+			content, _ := os.ReadFile(name)
+			if string(content) != tt.want {
+				t.Errorf("AppendFile left %q, want %q", content, tt.want)
+			}
+			if got := listDir(t, dir); !reflect.DeepEqual(got, tt.wantFiles) {
+				t.Errorf("AppendFile left %v, want %v", got, tt.wantFiles)
+			}
+			recorded := len(RecordedOutputs()) == 1
+			if recorded == tt.abort {
+				t.Errorf("RecordedOutputs() = %v, want output recorded %v", RecordedOutputs(), !tt.abort)
+			}
+		})
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	ResetOutputs()
+	defer ResetOutputs()
+	name := filepath.Join(t.TempDir(), "out.json")
+	if err := WriteFileAtomic(name, []byte("{}")); err != nil {
+		t.Fatalf("WriteFileAtomic() error = %v", err)
+	}
+	content, _ := os.ReadFile(name)
+	if string(content) != "{}" {
+		t.Errorf("WriteFileAtomic() wrote %q, want %q", content, "{}")
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("WriteFileAtomic() gave mode %v, want 0644", info.Mode().Perm())
+	}
+	if got := RecordedOutputs(); !reflect.DeepEqual(got, []string{name}) {
+		t.Errorf("RecordedOutputs() = %v, want %v", got, []string{name})
+	}
+}
+
+func TestOutputsOf(t *testing.T) {
+	ResetOutputs()
+	defer ResetOutputs()
+	dir := t.TempDir()
+	file, err := CreateAtomic(filepath.Join(dir, "a.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.AddOrigin("a.rac")
+	file.AddOrigin("a.rac")
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	shared := filepath.Join(dir, "HTR.csv")
+	if err := WriteFileAtomic(shared, []byte{}, "a.rac", "b.rac"); err != nil {
+		t.Fatal(err)
+	}
+	RecordOutput(filepath.Join(dir, "_MANIFEST.json"))
+	tests := []struct {
+		origin string
+		want   []string
+	}{
+		{"a.rac", []string{shared, filepath.Join(dir, "a.parquet")}},
+		{"b.rac", []string{shared}},
+		{"c.rac", nil},
+	}
+	for _, tt := range tests {
+		if got := OutputsOf(tt.origin); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("OutputsOf(%v) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestScopeOutputs(t *testing.T) {
+	ResetOutputs()
+	defer ResetOutputs()
+	job := filepath.Join("out", "job")
+	outputs, end := ScopeOutputs(job)
+	for _, name := range []string{"out/a.csv", "out/job/a.csv", "out/job/b/c.csv", "out/jobs.csv"} {
+		RecordOutput(filepath.FromSlash(name), "a.rac")
+	}
+	wantJob := []string{filepath.Join(job, "a.csv"), filepath.Join(job, "b", "c.csv")}
+	if got := outputs.Of("a.rac"); !reflect.DeepEqual(got, wantJob) {
+		t.Errorf("ScopeOutputs() recorded %v, want %v", got, wantJob)
+	}
+	wantRun := []string{filepath.Join("out", "a.csv"), filepath.Join("out", "jobs.csv")}
+	if got := RecordedOutputs(); !reflect.DeepEqual(got, wantRun) {
+		t.Errorf("RecordedOutputs() = %v, want %v", got, wantRun)
+	}
+	end()
+	RecordOutput(filepath.Join(job, "d.csv"))
+	if got := outputs.Names(); !reflect.DeepEqual(got, wantJob) {
+		t.Errorf("ScopeOutputs() recorded %v after its end, want %v", got, wantJob)
+	}
+}
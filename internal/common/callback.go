@@ -3,5 +3,6 @@ package common
 // Callback is the type of the callback function
 type Callback func(data DataRecord)
 
-// CallbackTeardown is a function to be called after last callback
-type CallbackTeardown func()
+// CallbackTeardown is a function to be called after last callback, it
+// returns the first error writing the outputs
+type CallbackTeardown func() error
@@ -0,0 +1,58 @@
+// Package commontest provides data records for tests of the exports and
+// of reading them back
+package commontest
+
+import (
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/innosat"
+	"github.com/innosat-mats/rac-extract-payload/internal/ramses"
+)
+
+// Records returns an HTR and a CCD record of my.rac, the image of the CCD
+// record is my_3.png
+func Records() []common.DataRecord {
+	origin := &common.OriginDescription{
+		Name:           "my.rac",
+		ProcessingDate: time.Date(2023, 1, 5, 14, 0, 0, 0, time.UTC),
+		SHA256:         "abc",
+		Run: &common.RunDescription{
+			Version:    common.FullVersion(),
+			Host:       "egse",
+			Parameters: []string{"-parquet"},
+			Inputs:     []common.InputDescription{{Name: "my.rac", SHA256: "abc"}},
+		},
+	}
+	return []common.DataRecord{
+		{
+			Origin:         origin,
+			RamsesHeader:   &ramses.Ramses{Date: 8405},
+			RamsesTMHeader: &ramses.TMHeader{VCFrameCounter: 3},
+			SourceHeader:   &innosat.SourcePacketHeader{},
+			TMHeader:       &innosat.TMHeader{CUCTimeSeconds: 10},
+			SID:            aez.SIDHTR,
+			Data:           &aez.HTR{},
+		},
+		{
+			Origin:         origin,
+			RamsesHeader:   &ramses.Ramses{Date: 8405},
+			RamsesTMHeader: &ramses.TMHeader{VCFrameCounter: 4},
+			SourceHeader:   &innosat.SourcePacketHeader{},
+			TMHeader:       &innosat.TMHeader{CUCTimeSeconds: 20},
+			RID:            aez.CCD3,
+			Data: &aez.CCDImage{
+				PackData: &aez.CCDImagePackData{
+					JPEGQ: aez.JPEGQUncompressed16bit,
+					NCOL:  1,
+					NROW:  2,
+					NBC:   2,
+				},
+				BadColumns:    []uint16{3, 4},
+				ImageFileName: "my_3.png",
+			},
+			Buffer: []byte{0xff, 0xff, 0xff, 0xff, 0x01, 0x00, 0x02, 0x00},
+		},
+	}
+}
@@ -2,12 +2,13 @@ package common
 
 import (
 	"encoding/json"
+	"reflect"
 	"strings"
 
 	"github.com/innosat-mats/rac-extract-payload/internal/aez"
 	"github.com/innosat-mats/rac-extract-payload/internal/innosat"
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
 	"github.com/innosat-mats/rac-extract-payload/internal/ramses"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 // DataRecord holds the full decode from one or many Ramses packages
@@ -102,9 +103,13 @@ func (record *DataRecord) CSVSpecifications() []string {
 			record.Data.CSVSpecifications()...,
 		)
 	}
+	if record.Origin != nil {
+		specifications = append(specifications, record.Origin.CSVSpecifications()...)
+	}
 	return specifications
 }
 
+// ParquetSpecifications returns specifications used to generate content as parquet metadata
 func (record *DataRecord) ParquetSpecifications() map[string]string {
 	specifications := map[string]string{"CODE": FullVersion()}
 
@@ -126,86 +131,91 @@ func (record *DataRecord) ParquetSpecifications() map[string]string {
 
 	if record.Data != nil {
 		spec := record.Data.CSVSpecifications()
-		specifications[spec[0]] = spec[1]
+		for idx := 0; idx+1 < len(spec); idx += 2 {
+			specifications[spec[idx]] = spec[idx+1]
+		}
+	}
+
+	if record.Origin != nil {
+		for key, value := range record.Origin.ParquetSpecifications() {
+			specifications[key] = value
+		}
 	}
 
 	return specifications
 }
 
-// CSVHeaders returns a header row for the data record
-func (record *DataRecord) CSVHeaders() []string {
-	var headers []string
-	if record.Origin != nil {
-		headers = append(headers, record.Origin.CSVHeaders()...)
-	} else {
-		headers = append(headers, (&OriginDescription{}).CSVHeaders()...)
-	}
-	if record.RamsesHeader != nil {
-		headers = append(headers, record.RamsesHeader.CSVHeaders()...)
-	} else {
-		headers = append(headers, (&ramses.Ramses{}).CSVHeaders()...)
-	}
-	if record.RamsesTMHeader != nil {
-		headers = append(headers, record.RamsesTMHeader.CSVHeaders()...)
-	} else {
-		headers = append(headers, (&ramses.TMHeader{}).CSVHeaders()...)
-	}
-	if record.SourceHeader != nil {
-		headers = append(headers, record.SourceHeader.CSVHeaders()...)
-	} else {
-		headers = append(headers, (&innosat.SourcePacketHeader{}).CSVHeaders()...)
+// recordIDs holds the exported ids of the record
+type recordIDs struct {
+	SID string `description:"The name of the SID or empty if the packet has no SID"`
+	RID string `description:"The name of the RID or empty if the packet has no RID"`
+}
+
+// recordErrors holds the exported errors of the record
+type recordErrors struct {
+	Errors []string `csv:"Error" optional:"true" description:"The error that occurred extracting the data, empty if none"`
+}
+
+// exportedHeader is a record header with exported columns
+type exportedHeader interface {
+	Columns() []schema.Column
+	Values() []interface{}
+}
+
+func (record *DataRecord) headers() []exportedHeader {
+	return []exportedHeader{
+		record.Origin,
+		record.RamsesHeader,
+		record.RamsesTMHeader,
+		record.SourceHeader,
+		record.TMHeader,
 	}
-	if record.TMHeader != nil {
-		headers = append(headers, record.TMHeader.CSVHeaders()...)
-	} else {
-		headers = append(headers, (&innosat.TMHeader{}).CSVHeaders()...)
+}
+
+// Columns returns the exported columns of the record, the headers and ids
+// followed by those of the data and finally the errors
+func (record *DataRecord) Columns() []schema.Column {
+	var columns []schema.Column
+	for _, header := range record.headers() {
+		columns = append(columns, header.Columns()...)
 	}
-	headers = append(headers, "SID", "RID")
+	columns = append(columns, schema.Columns(recordIDs{})...)
 	if record.Data != nil {
-		headers = append(headers, record.Data.CSVHeaders()...)
+		columns = append(columns, record.Data.Columns()...)
 	}
-	headers = append(headers, "Error")
-	return headers
+	return append(columns, schema.Columns(recordErrors{})...)
 }
 
-// CSVRow returns a data row for the record
-func (record *DataRecord) CSVRow() []string {
-	var row []string
-	if record.Origin != nil {
-		row = append(row, record.Origin.CSVRow()...)
-	} else {
-		row = append(row, make([]string, len((&OriginDescription{}).CSVRow()))...)
-	}
-	if record.RamsesHeader != nil {
-		row = append(row, record.RamsesHeader.CSVRow()...)
-	} else {
-		row = append(row, make([]string, len((&ramses.Ramses{}).CSVRow()))...)
-	}
-	if record.RamsesTMHeader != nil {
-		row = append(row, record.RamsesTMHeader.CSVRow()...)
-	} else {
-		row = append(row, make([]string, len((&ramses.TMHeader{}).CSVRow()))...)
-	}
-	if record.SourceHeader != nil {
-		row = append(row, record.SourceHeader.CSVRow()...)
-	} else {
-		row = append(row, make([]string, len((&innosat.SourcePacketHeader{}).CSVRow()))...)
-	}
-	if record.TMHeader != nil {
-		row = append(row, record.TMHeader.CSVRow()...)
-	} else {
-		row = append(row, make([]string, len((&innosat.TMHeader{}).CSVRow()))...)
+// Values returns the exported values of the record, missing headers have no
+// values
+func (record *DataRecord) Values() []interface{} {
+	var values []interface{}
+	for _, header := range record.headers() {
+		if reflect.ValueOf(header).IsNil() {
+			values = append(values, make([]interface{}, len(header.Columns()))...)
+		} else {
+			values = append(values, header.Values()...)
+		}
 	}
-	row = append(row, record.SID.String(), record.RID.String())
+	values = append(values, schema.Values(recordIDs{record.SID.String(), record.RID.String()})...)
 	if record.Data != nil {
-		row = append(row, record.Data.CSVRow()...)
+		values = append(values, record.Data.Values()...)
 	}
+	errors := recordErrors{}
 	if record.Error != nil {
-		row = append(row, record.Error.Error())
-	} else {
-		row = append(row, "")
+		errors.Errors = []string{record.Error.Error()}
 	}
-	return row
+	return append(values, schema.Values(errors)...)
+}
+
+// CSVHeaders returns a header row for the data record
+func (record *DataRecord) CSVHeaders() []string {
+	return schema.CSVHeaders(record.Columns())
+}
+
+// CSVRow returns a data row for the record
+func (record *DataRecord) CSVRow() []string {
+	return schema.CSVRow(record.Columns(), record.Values())
 }
 
 // OriginName returns the origin name or empty string if unknown
@@ -215,33 +225,3 @@ func (record *DataRecord) OriginName() string {
 	}
 	return ""
 }
-
-// SetParquet returns the parquet representation of the DataRecord
-func (record *DataRecord) SetParquet(row *parquetrow.ParquetRow) {
-	if record.Origin != nil {
-		record.Origin.SetParquet(row)
-	}
-
-	if record.RamsesHeader != nil {
-		record.RamsesHeader.SetParquet(row)
-	}
-
-	if record.RamsesHeader != nil {
-		record.RamsesTMHeader.SetParquet(row)
-	}
-
-	if record.SourceHeader != nil {
-		record.SourceHeader.SetParquet(row)
-	}
-
-	if record.TMHeader != nil {
-		record.TMHeader.SetParquet(row)
-	}
-
-	row.SID = record.SID.String()
-	row.RID = record.RID.String()
-
-	if record.Error != nil {
-		row.Errors = []string{record.Error.Error()}
-	}
-}
@@ -10,8 +10,8 @@ import (
 
 	"github.com/innosat-mats/rac-extract-payload/internal/aez"
 	"github.com/innosat-mats/rac-extract-payload/internal/innosat"
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
 	"github.com/innosat-mats/rac-extract-payload/internal/ramses"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 func TestDataRecord_CSVSpecifications(t *testing.T) {
@@ -53,6 +53,7 @@ func TestDataRecord_CSVSpecifications(t *testing.T) {
 				"RAMSES", ramses.Specification,
 				"INNOSAT", innosat.Specification,
 				"AEZ", aez.Specification,
+				"CALIBRATION", aez.BuiltinCalibration.ID,
 			},
 		},
 	}
@@ -153,6 +154,12 @@ func TestDataRecord_CSVHeaders(t *testing.T) {
 				"SPWEOP",
 				"SPWEEP",
 				"ANOMALY",
+				"EDACERate",
+				"EDACCERate",
+				"EDACNRate",
+				"SPWEOPRate",
+				"SPWEEPRate",
+				"CounterReset",
 				"Error",
 			},
 		},
@@ -305,6 +312,12 @@ func TestDataRecord_CSVRow(t *testing.T) {
 				"14",
 				"15",
 				"16",
+				"NaN",
+				"NaN",
+				"NaN",
+				"NaN",
+				"NaN",
+				"false",
 				"",
 			},
 		},
@@ -384,7 +397,7 @@ func TestDataRecord_OriginName(t *testing.T) {
 	}
 }
 
-func TestDataRecord_ParquetSpecifications(t *testing.T) {
+func TestDataRecord_Values(t *testing.T) {
 	data := DataRecord{
 		Origin:         &OriginDescription{Name: "Sputnik", ProcessingDate: procDate},
 		RamsesHeader:   &ramses.Ramses{Date: 24, Time: 42000},
@@ -392,25 +405,51 @@ func TestDataRecord_ParquetSpecifications(t *testing.T) {
 		SourceHeader:   &innosat.SourcePacketHeader{PacketSequenceControl: innosat.PacketSequenceControl(0xc003)},
 		TMHeader:       &innosat.TMHeader{CUCTimeSeconds: 42, CUCTimeFraction: 0xc000},
 		SID:            aez.SIDSTAT,
+		Data:           &aez.STAT{MODE: 1},
 		Error:          errors.New("Wrong satellite!"),
 	}
-	want := parquetrow.ParquetRow{
-		OriginFile:          "Sputnik",
-		ProcessingTime:      procDate,
-		RamsesTime:          data.RamsesHeader.Created(),
-		QualityIndicator:    0,
-		LossFlag:            1,
-		VCFrameCounter:      42,
-		SPSequenceCount:     3,
-		TMHeaderTime:        data.TMHeader.Time(aez.GpsTime),
-		TMHeaderNanoseconds: 42750000000,
-		SID:                 "STAT",
-		RID:                 "",
-		Errors:              []string{"Wrong satellite!"},
+	want := map[string]interface{}{
+		"OriginFile":          "Sputnik",
+		"ProcessingTime":      procDate,
+		"RamsesTime":          data.RamsesHeader.Created(),
+		"QualityIndicator":    int64(0),
+		"LossFlag":            int64(1),
+		"VCFrameCounter":      int64(42),
+		"SPSequenceCount":     int64(3),
+		"TMHeaderTime":        data.TMHeader.Time(aez.GpsTime),
+		"TMHeaderNanoseconds": int64(42750000000),
+		"SID":                 "STAT",
+		"RID":                 "",
+		"MODE":                int64(1),
+		"Errors":              []string{"Wrong satellite!"},
 	}
-	row := parquetrow.ParquetRow{}
-	if data.SetParquet(&row); !reflect.DeepEqual(row, want) {
-		t.Errorf("DataRercord.SetParquet() = %v, want %v", row, want)
+	columns := data.Columns()
+	values := data.Values()
+	if len(values) != len(columns) {
+		t.Fatalf("DataRecord.Values() has %v values, want %v", len(values), len(columns))
 	}
+	for name, value := range want {
+		idx := schema.Index(columns, name)
+		if idx < 0 {
+			t.Errorf("DataRecord.Columns() lacks %v", name)
+			continue
+		}
+		if !reflect.DeepEqual(values[idx], value) {
+			t.Errorf("DataRecord.Values() %v = %v, want %v", name, values[idx], value)
+		}
+	}
+}
 
+func TestDataRecord_Values_MissingHeaders(t *testing.T) {
+	data := DataRecord{Data: &aez.STAT{}}
+	columns := data.Columns()
+	values := data.Values()
+	if len(values) != len(columns) {
+		t.Fatalf("DataRecord.Values() has %v values, want %v", len(values), len(columns))
+	}
+	for _, name := range []string{"OriginFile", "RamsesTime", "LossFlag", "TMHeaderTime", "Errors"} {
+		if value := values[schema.Index(columns, name)]; value != nil {
+			t.Errorf("DataRecord.Values() %v = %v, want nil", name, value)
+		}
+	}
 }
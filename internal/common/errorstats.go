@@ -12,11 +12,13 @@ type ErrorStats struct {
 	total       uint // errors and not errors
 	errorsCount uint
 	errors      map[string]uint
+	alarmsCount uint
+	alarms      map[string]uint
 }
 
 // NewErrorStats ...
 func NewErrorStats() ErrorStats {
-	return ErrorStats{errors: make(map[string]uint)}
+	return ErrorStats{errors: make(map[string]uint), alarms: make(map[string]uint)}
 }
 
 // Register a new error or not error occurnace
@@ -29,6 +31,12 @@ func (stats *ErrorStats) Register(err error) {
 
 }
 
+// RegisterAlarm registers an out of limits alarm by its summary
+func (stats *ErrorStats) RegisterAlarm(summary string) {
+	stats.alarmsCount++
+	stats.alarms[summary]++
+}
+
 func max(x, y uint) uint {
 	if x < y {
 		return y
@@ -38,6 +46,40 @@ func max(x, y uint) uint {
 
 // Summarize ...
 func (stats *ErrorStats) Summarize() string {
+	if stats.alarmsCount == 0 {
+		return stats.summarizeErrors()
+	}
+	return stats.summarizeErrors() + stats.summarizeAlarms()
+}
+
+func (stats *ErrorStats) summarizeAlarms() string {
+	var indent uint = 5 // "Count" is 5 characters
+	summaries := make([]string, 0, len(stats.alarms))
+	for key, value := range stats.alarms {
+		indent = max(indent, uint(len(strconv.Itoa(int(value)))))
+		summaries = append(summaries, key)
+	}
+	indent += 3 // Spacing to next column
+	sort.SliceStable(summaries, func(i, j int) bool {
+		if stats.alarms[summaries[i]] == stats.alarms[summaries[j]] {
+			return summaries[i] < summaries[j]
+		}
+		return stats.alarms[summaries[i]] > stats.alarms[summaries[j]]
+	})
+	lines := make([]string, len(summaries))
+	for idx, summary := range summaries {
+		lines[idx] = fmt.Sprintf("%-*v%s", indent, stats.alarms[summary], summary)
+	}
+	return fmt.Sprintf(
+		"\nAlarms\n\n%-*vAlarm\n%s\n\nTotal Alarms:\t%v\n",
+		indent,
+		"Count",
+		strings.Join(lines, "\n"),
+		stats.alarmsCount,
+	)
+}
+
+func (stats *ErrorStats) summarizeErrors() string {
 	errs := make([]struct {
 		string
 		uint
@@ -8,6 +8,19 @@ import (
 	"testing"
 )
 
+func TestErrorStats_RegisterAlarm(t *testing.T) {
+	stats := NewErrorStats()
+	stats.Register(nil)
+	stats.RegisterAlarm("SOFT HIGH HTR1A")
+	stats.RegisterAlarm("HARD LOW PWRT")
+	stats.RegisterAlarm("HARD LOW PWRT")
+	want := "\nStatistics\n\nTotal Errors:\t0\nTotal Packages:\t1\n" +
+		"\nAlarms\n\nCount   Alarm\n2       HARD LOW PWRT\n1       SOFT HIGH HTR1A\n\nTotal Alarms:\t3\n"
+	if got := stats.Summarize(); got != want {
+		t.Errorf("ErrorStats.Summarize() = %v, want %v", got, want)
+	}
+}
+
 func TestErrorStats_Register_and_Summarize(t *testing.T) {
 	tests := []struct {
 		name            string
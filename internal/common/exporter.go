@@ -1,8 +1,10 @@
 package common
 
+import "github.com/innosat-mats/rac-extract-payload/internal/schema"
+
 // Exporter interface for data that can be written to target
 type Exporter interface {
-	CSVHeaders() []string
-	CSVRow() []string
+	Columns() []schema.Column
+	Values() []interface{}
 	CSVSpecifications() []string
 }
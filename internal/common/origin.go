@@ -4,30 +4,31 @@ import (
 	"path/filepath"
 	"time"
 
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 // OriginDescription describes the origin of ramses packages
 type OriginDescription struct {
-	Name           string    `json:"name"`           // Name of the batch or file
-	ProcessingDate time.Time `json:"processingTime"` // Runtime of the batch
+	Name           string          `json:"name"`             // Name of the batch or file
+	ProcessingDate time.Time       `json:"processingTime"`   // Runtime of the batch
+	SHA256         string          `json:"sha256,omitempty"` // Checksum of the file
+	Run            *RunDescription `json:"run,omitempty"`    // The run processing the batch
 }
 
-// CSVHeaders returns the field names
-func (origin *OriginDescription) CSVHeaders() []string {
-	return []string{"OriginFile", "ProcessingDate"}
+type originColumns struct {
+	OriginFile     string    `description:"Name of the rac-file from which the record originated"`
+	ProcessingTime time.Time `csv:"ProcessingDate" csvlayout:"2006-01-02T15:04:05Z07:00" description:"The time when the file was processed"`
 }
 
-// CSVRow returns the field values
-func (origin *OriginDescription) CSVRow() []string {
-	return []string{
-		filepath.Base(origin.Name),
-		origin.ProcessingDate.Format(time.RFC3339),
-	}
+// Columns returns the exported columns
+func (origin *OriginDescription) Columns() []schema.Column {
+	return schema.Columns(originColumns{})
 }
 
-// SetParquet sets the parquet representation of the OriginDescription
-func (origin *OriginDescription) SetParquet(row *parquetrow.ParquetRow) {
-	row.OriginFile = filepath.Base(origin.Name)
-	row.ProcessingTime = origin.ProcessingDate
+// Values returns the exported values
+func (origin *OriginDescription) Values() []interface{} {
+	return schema.Values(originColumns{
+		OriginFile:     filepath.Base(origin.Name),
+		ProcessingTime: origin.ProcessingDate,
+	})
 }
@@ -5,7 +5,7 @@ import (
 	"testing"
 	"time"
 
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 func TestOriginDescription_CSVHeaders(t *testing.T) {
@@ -26,8 +26,8 @@ func TestOriginDescription_CSVHeaders(t *testing.T) {
 				Name:           tt.fields.Name,
 				ProcessingDate: tt.fields.ProcessingDate,
 			}
-			if got := origin.CSVHeaders(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("OriginDescription.CSVHeaders() = %v, want %v", got, tt.want)
+			if got := schema.CSVHeaders(origin.Columns()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schema.CSVHeaders() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -58,21 +58,32 @@ func TestOriginDescription_CSVRow(t *testing.T) {
 				Name:           tt.fields.Name,
 				ProcessingDate: tt.fields.ProcessingDate,
 			}
-			if got := origin.CSVRow(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("OriginDescription.CSVRow() = %v, want %v", got, tt.want)
+			if got := schema.CSVRow(origin.Columns(), origin.Values()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schema.CSVRow() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
-func TestOriginDescription_SetParquet(t *testing.T) {
-	origin := OriginDescription{"Name", time.Time{}}
-	want := parquetrow.ParquetRow{
-		OriginFile:     "Name",
-		ProcessingTime: time.Time{},
+func TestOriginDescription_Values(t *testing.T) {
+	origin := OriginDescription{Name: "Name", ProcessingDate: time.Time{}}
+	want := map[string]interface{}{
+		"OriginFile":     "Name",
+		"ProcessingTime": time.Time{},
 	}
-	row := parquetrow.ParquetRow{}
-	if origin.SetParquet(&row); !reflect.DeepEqual(row, want) {
-		t.Errorf("OriginDescription.SetParquet() = %v, want %v", row, want)
+	columns := origin.Columns()
+	values := origin.Values()
+	if len(values) != len(columns) {
+		t.Fatalf("OriginDescription.Values() has %v values, want %v", len(values), len(columns))
+	}
+	for name, value := range want {
+		idx := schema.Index(columns, name)
+		if idx < 0 {
+			t.Errorf("OriginDescription.Columns() lacks %v", name)
+			continue
+		}
+		if !reflect.DeepEqual(values[idx], value) {
+			t.Errorf("OriginDescription.Values() %v = %v, want %v", name, values[idx], value)
+		}
 	}
 }
@@ -0,0 +1,112 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RunDescription describes how a run processed its inputs
+type RunDescription struct {
+	Version      string             `json:"version"`                // FullVersion of the code
+	Host         string             `json:"-"`                      // Name of the host processing, kept out of outputs
+	Parameters   []string           `json:"parameters"`             // Command line flags given
+	Calibrations []string           `json:"calibrations,omitempty"` // IDs of the calibrations loaded
+	Inputs       []InputDescription `json:"-"`                      // Inputs of the run
+}
+
+// InputDescription identifies an input of a run
+type InputDescription struct {
+	Name   string
+	SHA256 string
+}
+
+// ProvenanceKeys are the specification keys added by the provenance, these
+// differ between runs and outputs of the same data
+var ProvenanceKeys = []string{
+	"PROCESSED", "PARAMETERS", "CALIBRATIONS", "INPUTS", "ORIGIN", "ORIGIN_SHA256",
+}
+
+// HashFile returns the hex encoded sha256 of the content of the reader
+func HashFile(reader io.Reader) (string, error) {
+	hash := sha256.New()
+	_, err := io.Copy(hash, reader)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (run *RunDescription) specifications() []string {
+	inputs := make([]string, len(run.Inputs))
+	for idx, input := range run.Inputs {
+		inputs[idx] = fmt.Sprintf("%v:%v", filepath.Base(input.Name), input.SHA256)
+	}
+	return []string{
+		"PARAMETERS", strings.Join(run.Parameters, " "),
+		"CALIBRATIONS", strings.Join(run.Calibrations, " "),
+		"INPUTS", strings.Join(inputs, " "),
+	}
+}
+
+// CSVSpecifications returns the provenance of the origin as specifications
+//
+// A csv holds rows of all inputs of a run, so all are listed.
+func (origin *OriginDescription) CSVSpecifications() []string {
+	specifications := []string{"PROCESSED", origin.ProcessingDate.UTC().Format(time.RFC3339Nano)}
+	if origin.Run != nil {
+		specifications = append(specifications, origin.Run.specifications()...)
+	}
+	return specifications
+}
+
+// ParquetSpecifications returns the provenance of the origin as metadata
+//
+// A parquet file holds rows of a single input, so only it is listed.
+func (origin *OriginDescription) ParquetSpecifications() map[string]string {
+	specifications := map[string]string{
+		"PROCESSED": origin.ProcessingDate.UTC().Format(time.RFC3339Nano),
+		"ORIGIN":    filepath.Base(origin.Name),
+	}
+	if origin.SHA256 != "" {
+		specifications["ORIGIN_SHA256"] = origin.SHA256
+	}
+	if origin.Run != nil {
+		spec := origin.Run.specifications()
+		for idx := 0; idx+1 < len(spec); idx += 2 {
+			if spec[idx] != "INPUTS" {
+				specifications[spec[idx]] = spec[idx+1]
+			}
+		}
+	}
+	return specifications
+}
+
+// WithoutProvenance returns the specifications without the provenance
+// pairs
+func WithoutProvenance(specifications []string) []string {
+	var kept []string
+	for idx := 0; idx < len(specifications); idx += 2 {
+		if isProvenanceKey(specifications[idx]) {
+			continue
+		}
+		kept = append(kept, specifications[idx])
+		if idx+1 < len(specifications) {
+			kept = append(kept, specifications[idx+1])
+		}
+	}
+	return kept
+}
+
+func isProvenanceKey(key string) bool {
+	for _, provenanceKey := range ProvenanceKeys {
+		if key == provenanceKey {
+			return true
+		}
+	}
+	return false
+}
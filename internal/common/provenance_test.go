@@ -0,0 +1,80 @@
+package common
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testOrigin() *OriginDescription {
+	run := &RunDescription{
+		Version:      "Test Build",
+		Host:         "host",
+		Parameters:   []string{"-dregs=dregs", "-parquet=true"},
+		Calibrations: []string{"2023"},
+		Inputs: []InputDescription{
+			{Name: "racs/a.rac", SHA256: "aaa"},
+			{Name: "racs/b.rac", SHA256: "bbb"},
+		},
+	}
+	return &OriginDescription{
+		Name:           "racs/b.rac",
+		ProcessingDate: time.Date(2023, 1, 5, 14, 0, 0, 0, time.UTC),
+		SHA256:         "bbb",
+		Run:            run,
+	}
+}
+
+func TestOriginDescription_CSVSpecifications(t *testing.T) {
+	want := []string{
+		"PROCESSED", "2023-01-05T14:00:00Z",
+		"PARAMETERS", "-dregs=dregs -parquet=true",
+		"CALIBRATIONS", "2023",
+		"INPUTS", "a.rac:aaa b.rac:bbb",
+	}
+	if got := testOrigin().CSVSpecifications(); !reflect.DeepEqual(got, want) {
+		t.Errorf("OriginDescription.CSVSpecifications() = %v, want %v", got, want)
+	}
+	origin := OriginDescription{Name: "a.rac"}
+	if got := origin.CSVSpecifications(); len(got) != 2 {
+		t.Errorf("OriginDescription.CSVSpecifications() = %v, want only processing time", got)
+	}
+}
+
+func TestOriginDescription_ParquetSpecifications(t *testing.T) {
+	want := map[string]string{
+		"PROCESSED":     "2023-01-05T14:00:00Z",
+		"ORIGIN":        "b.rac",
+		"ORIGIN_SHA256": "bbb",
+		"PARAMETERS":    "-dregs=dregs -parquet=true",
+		"CALIBRATIONS":  "2023",
+	}
+	if got := testOrigin().ParquetSpecifications(); !reflect.DeepEqual(got, want) {
+		t.Errorf("OriginDescription.ParquetSpecifications() = %v, want %v", got, want)
+	}
+}
+
+func TestOriginDescription_MarshalJSON(t *testing.T) {
+	record := DataRecord{Origin: testOrigin()}
+	content, err := record.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"sha256":"bbb"`, `"version":"Test Build"`} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("DataRecord.MarshalJSON() = %s, missing %s", content, want)
+		}
+	}
+	if strings.Contains(string(content), `"host"`) {
+		t.Errorf("DataRecord.MarshalJSON() = %s, should not hold the host", content)
+	}
+}
+
+func TestWithoutProvenance(t *testing.T) {
+	record := DataRecord{Origin: testOrigin()}
+	want := []string{"CODE", "Test Build", "RAMSES", "SPU045-S2:6F", "INNOSAT", "IS-OSE-ICD-0005:1"}
+	if got := WithoutProvenance(record.CSVSpecifications()); !reflect.DeepEqual(got, want) {
+		t.Errorf("WithoutProvenance() = %v, want %v", got, want)
+	}
+}
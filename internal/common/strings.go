@@ -0,0 +1,11 @@
+package common
+
+// ContainsString returns if the value is among the values
+func ContainsString(values []string, value string) bool {
+	for _, other := range values {
+		if other == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,423 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/exports"
+	"github.com/innosat-mats/rac-extract-payload/internal/query"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+// Stats describes the outcome of a conversion
+type Stats struct {
+	Files   int // Parquet or csv files written
+	Records int
+	Images  int // Images read from or written as PNG
+	Skipped int // Inputs not converted since their timeseries is unknown
+}
+
+// streamValues returns the values of the record in the order of the columns
+// of its stream, columns the stream doesn't know are left out
+func streamValues(record *query.Record) []interface{} {
+	columns := record.Stream.Columns()
+	values := make([]interface{}, len(columns))
+	for idx, column := range columns {
+		values[idx], _ = record.Value(column.Name)
+	}
+	return values
+}
+
+// setImageData sets the ImageData of the values of a CCD record, unless the
+// image is missing
+func setImageData(values []interface{}, data []byte) {
+	if idx := schema.Index(timeseries.CCD.Columns(), "ImageData"); idx >= 0 && data != nil {
+		values[idx] = data
+	}
+}
+
+// skipUnknown skips files of unknown streams counting them as skipped
+func skipUnknown(name string, stats *Stats) func(timeseries.OutStream) bool {
+	return func(stream timeseries.OutStream) bool {
+		if stream == timeseries.Unknown {
+			log.Printf("Skipping %v since its timeseries is unknown", name)
+			stats.Skipped++
+			return true
+		}
+		return false
+	}
+}
+
+// imageFiles returns the PNG files below the paths by name and the JSON
+// sidecars of them sorted
+func imageFiles(paths ...string) (map[string]string, []string, error) {
+	images := make(map[string]string)
+	var sidecars []string
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() || !strings.EqualFold(filepath.Ext(name), ".png") {
+				return nil
+			}
+			if _, ok := images[entry.Name()]; !ok {
+				images[entry.Name()] = name
+				sidecar := exports.GetJSONFilename(name)
+				if _, err := os.Stat(sidecar); err == nil {
+					sidecars = append(sidecars, sidecar)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	sort.Strings(sidecars)
+	return images, sidecars, nil
+}
+
+// parquetWriters holds the parquet files written by stream, origin and
+// partition
+type parquetWriters struct {
+	output  string
+	writers map[string]timeseries.ParquetWriter
+	missing map[timeseries.OutStream]map[string]bool // Columns some input lacks
+	stats   *Stats
+}
+
+// lacks notes the columns of the stream that are not among the names, they
+// are written as null
+func (files *parquetWriters) lacks(stream timeseries.OutStream, names []string) {
+	present := make(map[string]bool)
+	for _, name := range names {
+		present[name] = true
+	}
+	for _, column := range stream.Columns() {
+		if present[column.Name] {
+			continue
+		}
+		if files.missing[stream] == nil {
+			files.missing[stream] = make(map[string]bool)
+		}
+		files.missing[stream][column.Name] = true
+	}
+}
+
+// columns returns the columns of the stream written with those some input
+// lacks optional
+func (files *parquetWriters) columns(stream timeseries.OutStream) []schema.Column {
+	columns := stream.Columns()
+	for idx := range columns {
+		columns[idx].Optional = columns[idx].Optional || files.missing[stream][columns[idx].Name]
+	}
+	return columns
+}
+
+// write adds the values of the stream to the parquet file of their origin
+// and partition, the file is created with the metadata if new
+func (files *parquetWriters) write(
+	stream timeseries.OutStream,
+	values []interface{},
+	metadata func(origin string) map[string]string,
+) error {
+	columns := files.columns(stream)
+	row := schema.Row{Columns: columns, Values: values}
+	origin := ""
+	if idx := schema.Index(columns, "OriginFile"); idx >= 0 {
+		origin, _ = values[idx].(string)
+	}
+	var partitionTime time.Time
+	for _, name := range []string{timeseries.ActivePartitioning().Key.Column(stream), "TMHeaderTime"} {
+		if idx := schema.Index(columns, name); idx >= 0 {
+			if moment, ok := values[idx].(time.Time); ok {
+				partitionTime = moment
+				break
+			}
+		}
+	}
+	name := filepath.Join(files.output, timeseries.ParquetNameAt(origin, stream, partitionTime))
+	writer, ok := files.writers[name]
+	if !ok {
+		if err := os.MkdirAll(filepath.Dir(name), os.ModePerm); err != nil {
+			return fmt.Errorf("could not create output prefix '%v'", name)
+		}
+		var err error
+		writer, err = timeseries.NewParquetColumns(name, stream, columns, metadata(origin))
+		if err != nil {
+			return err
+		}
+		files.writers[name] = writer
+		files.stats.Files++
+	}
+	files.stats.Records++
+	return writer.WriteData(&row)
+}
+
+// close closes the parquet files returning the first error closing them
+func (files *parquetWriters) close() error {
+	var err error
+	for _, writer := range files.writers {
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// ToParquet writes the records of the csvs below the inputs as parquet files
+// below output, named and partitioned as by rac -parquet
+//
+// Csvs of earlier versions are converted too, columns they lack are written
+// as null. Csvs of unknown timeseries are skipped and counted in the Stats.
+//
+// The ImageData of CCD records is read from the PNG named by ImageName below
+// the inputs. Images that no csv has a record of are added from their JSON
+// sidecar. The specifications rows of the csvs become the metadata of the
+// parquet files.
+func ToParquet(inputs []string, output string) (stats Stats, err error) {
+	files, err := query.Files(inputs...)
+	if err != nil {
+		return stats, err
+	}
+	pngs, sidecars, err := imageFiles(inputs...)
+	if err != nil {
+		return stats, err
+	}
+	writers := parquetWriters{
+		output:  output,
+		writers: make(map[string]timeseries.ParquetWriter),
+		missing: make(map[timeseries.OutStream]map[string]bool),
+		stats:   &stats,
+	}
+	defer func() {
+		if closeErr := writers.close(); err == nil {
+			err = closeErr
+		}
+	}()
+	var csvs []string
+	for _, file := range files {
+		if !strings.EqualFold(filepath.Ext(file), ".csv") {
+			continue
+		}
+		stream, names, err := query.Header(file)
+		if err != nil {
+			return stats, err
+		}
+		if names != nil {
+			writers.lacks(stream, names)
+		}
+		csvs = append(csvs, file)
+	}
+	images := make([]map[string]interface{}, len(sidecars))
+	imageMetadata := make([]map[string]string, len(sidecars))
+	for idx, name := range sidecars {
+		image, err := readSidecar(name)
+		if err != nil {
+			return stats, err
+		}
+		byName, err := image.values()
+		if err != nil {
+			return stats, fmt.Errorf("could not read %v: %v", name, err)
+		}
+		byName["ImageName"] = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)) + ".png"
+		names := make([]string, 0, len(byName))
+		for name := range byName {
+			names = append(names, name)
+		}
+		writers.lacks(timeseries.CCD, names)
+		images[idx] = byName
+		imageMetadata[idx] = image.metadata()
+	}
+	imageData := func(imageName string) []byte {
+		path, ok := pngs[filepath.Base(imageName)]
+		if !ok {
+			log.Printf("Could not find image %v, its ImageData is left empty", imageName)
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Could not read image %v, its ImageData is left empty: %v", path, err)
+			return nil
+		}
+		stats.Images++
+		return data
+	}
+
+	converted := make(map[string]bool)
+	for _, file := range csvs {
+		specifications, err := query.Specifications(file)
+		if err != nil {
+			return stats, err
+		}
+		metadata := func(origin string) map[string]string { return parquetMetadata(specifications, origin) }
+		err = query.ReadFile(file, skipUnknown(file, &stats), func(record *query.Record) error {
+			values := streamValues(record)
+			if record.Stream == timeseries.CCD {
+				imageName := stringValue(record, "ImageName")
+				converted[filepath.Base(imageName)] = true
+				setImageData(values, imageData(imageName))
+			}
+			return writers.write(record.Stream, values, metadata)
+		})
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	columns := timeseries.CCD.Columns()
+	for idx, byName := range images {
+		imageName, _ := byName["ImageName"].(string)
+		if converted[filepath.Base(imageName)] {
+			continue
+		}
+		converted[filepath.Base(imageName)] = true
+		values := make([]interface{}, len(columns))
+		for idx, column := range columns {
+			values[idx] = byName[column.Name]
+		}
+		setImageData(values, imageData(imageName))
+		metadata := imageMetadata[idx]
+		err := writers.write(timeseries.CCD, values, func(string) map[string]string { return metadata })
+		if err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// ToDisk writes the records of the parquet files below the inputs as csvs,
+// one per timeseries, and the images of CCD records as PNGs with JSON
+// sidecars in output, as by rac without -parquet
+//
+// The metadata of the parquet files becomes the specifications rows of the
+// csvs with their origins listed as INPUTS. Parquet files of unknown
+// timeseries are skipped and counted in the Stats.
+func ToDisk(inputs []string, output string) (Stats, error) {
+	var stats Stats
+	files, err := query.Files(inputs...)
+	if err != nil {
+		return stats, err
+	}
+	byStream := make(map[timeseries.OutStream][]string)
+	metadata := make(map[string]map[string]string)
+	for _, file := range files {
+		if !strings.EqualFold(filepath.Ext(file), ".parquet") {
+			continue
+		}
+		var stream timeseries.OutStream
+		err := query.ReadFile(file, func(fileStream timeseries.OutStream) bool {
+			stream = fileStream
+			return true
+		}, nil)
+		if err != nil {
+			return stats, err
+		}
+		if skipUnknown(file, &stats)(stream) {
+			continue
+		}
+		specifications, err := query.Specifications(file)
+		if err != nil {
+			return stats, err
+		}
+		byStream[stream] = append(byStream[stream], file)
+		metadata[file] = pairs(specifications)
+	}
+	if err := os.MkdirAll(output, os.ModePerm); err != nil {
+		return stats, err
+	}
+	for _, stream := range timeseries.Streams {
+		if len(byStream[stream]) == 0 {
+			continue
+		}
+		if err := writeStream(output, stream, byStream[stream], metadata, &stats); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// writeStream writes the records of the parquet files of the stream to its
+// csv in output
+func writeStream(
+	output string,
+	stream timeseries.OutStream,
+	files []string,
+	metadata map[string]map[string]string,
+	stats *Stats,
+) error {
+	fileMetadata := make([]map[string]string, len(files))
+	for idx, file := range files {
+		fileMetadata[idx] = metadata[file]
+	}
+	name := filepath.Join(output, fmt.Sprintf("%v.csv", stream))
+	out, err := common.CreateAtomic(name)
+	if err != nil {
+		return fmt.Errorf("could not create output file '%v'", name)
+	}
+	csv := timeseries.NewCSV(out, name)
+	columns := stream.Columns()
+	if err := csv.SetSpecifications(csvSpecifications(fileMetadata)); err != nil {
+		out.Abort()
+		return err
+	}
+	if err := csv.SetHeaderRow(schema.CSVHeaders(columns)); err != nil {
+		out.Abort()
+		return err
+	}
+	for _, file := range files {
+		err := query.ReadFile(file, nil, func(record *query.Record) error {
+			if err := csv.WriteData(schema.CSVRow(columns, streamValues(record))); err != nil {
+				return err
+			}
+			stats.Records++
+			if stream == timeseries.CCD {
+				return writeImage(output, record, metadata[file], stats)
+			}
+			return nil
+		})
+		if err != nil {
+			out.Abort()
+			return err
+		}
+	}
+	if err := csv.Close(); err != nil {
+		return err
+	}
+	stats.Files++
+	return nil
+}
+
+// writeImage writes the ImageData of the CCD record as PNG with its JSON
+// sidecar in output
+func writeImage(output string, record *query.Record, metadata map[string]string, stats *Stats) error {
+	name, err := query.SaveImage(record, output)
+	if err != nil || name == "" {
+		return err
+	}
+	stats.Images++
+	image, err := newSidecar(record, metadata)
+	if err != nil {
+		return err
+	}
+	jsonName := exports.GetJSONFilename(name)
+	jsonFile, err := common.CreateAtomic(jsonName)
+	if err != nil {
+		return fmt.Errorf("could not create output file '%v'", jsonName)
+	}
+	if err := json.NewEncoder(jsonFile).Encode(image); err != nil {
+		jsonFile.Abort()
+		return err
+	}
+	return jsonFile.Close()
+}
@@ -0,0 +1,220 @@
+package convert
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/common/commontest"
+	"github.com/innosat-mats/rac-extract-payload/internal/exports"
+	"github.com/innosat-mats/rac-extract-payload/internal/query"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+// writeOutputs writes the test records with the callback
+func writeOutputs(callback common.Callback, teardown common.CallbackTeardown) {
+	for _, pkg := range commontest.Records() {
+		callback(pkg)
+	}
+	teardown()
+}
+
+// readRecords returns the records below the dir by stream
+func readRecords(t *testing.T, dir string) map[timeseries.OutStream]*query.Record {
+	files, err := query.Files(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := make(map[timeseries.OutStream]*query.Record)
+	for _, file := range files {
+		err := query.ReadFile(file, nil, func(record *query.Record) error {
+			records[record.Stream] = record
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return records
+}
+
+// specifications returns the specifications of the files below the dir by
+// name relative to it
+func specifications(t *testing.T, dir string) map[string][]string {
+	files, err := query.Files(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string][]string)
+	for _, file := range files {
+		name, _ := filepath.Rel(dir, file)
+		if found[name], err = query.Specifications(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return found
+}
+
+func TestToParquet(t *testing.T) {
+	dir := t.TempDir()
+	disk := filepath.Join(dir, "disk")
+	writeOutputs(exports.DiskCallbackFactory(disk, true, true, exports.NewImagePool(1), exports.CSVOptions{}))
+	want := filepath.Join(dir, "want")
+	writeOutputs(exports.ParquetCallbackFactory(want, exports.NewImagePool(1)))
+
+	for _, removeCCD := range []bool{false, true} {
+		name := "From csvs"
+		if removeCCD {
+			name = "From sidecars"
+			if err := os.Remove(filepath.Join(disk, "CCD.csv")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		t.Run(name, func(t *testing.T) {
+			got := filepath.Join(dir, name)
+			stats, err := ToParquet([]string{disk}, got)
+			if err != nil {
+				t.Fatalf("ToParquet() error = %v", err)
+			}
+			if wantStats := (Stats{Files: 2, Records: 2, Images: 1}); stats != wantStats {
+				t.Errorf("ToParquet() = %+v, want %+v", stats, wantStats)
+			}
+			if gotSpecs, wantSpecs := specifications(t, got), specifications(t, want); !reflect.DeepEqual(gotSpecs, wantSpecs) {
+				t.Errorf("ToParquet() wrote metadata %v, want %v", gotSpecs, wantSpecs)
+			}
+			gotRecords, wantRecords := readRecords(t, got), readRecords(t, want)
+			for stream, wantRecord := range wantRecords {
+				gotRecord, ok := gotRecords[stream]
+				if !ok {
+					t.Errorf("ToParquet() wrote no %v", stream)
+					continue
+				}
+				if !sameValues(gotRecord.Values, wantRecord.Values) {
+					t.Errorf("ToParquet() wrote %v values %v, want %v", stream, gotRecord.Values, wantRecord.Values)
+				}
+			}
+		})
+	}
+}
+
+func TestToDisk(t *testing.T) {
+	dir := t.TempDir()
+	lake := filepath.Join(dir, "lake")
+	writeOutputs(exports.ParquetCallbackFactory(lake, exports.NewImagePool(1)))
+	want := filepath.Join(dir, "want")
+	writeOutputs(exports.DiskCallbackFactory(want, true, true, exports.NewImagePool(1), exports.CSVOptions{}))
+
+	got := filepath.Join(dir, "got")
+	stats, err := ToDisk([]string{lake}, got)
+	if err != nil {
+		t.Fatalf("ToDisk() error = %v", err)
+	}
+	if wantStats := (Stats{Files: 2, Records: 2, Images: 1}); stats != wantStats {
+		t.Errorf("ToDisk() = %+v, want %+v", stats, wantStats)
+	}
+	for _, name := range []string{"HTR.csv", "CCD.csv", "my_3.png", "my_3.json"} {
+		gotContent, err := os.ReadFile(filepath.Join(got, name))
+		if err != nil {
+			t.Errorf("ToDisk() wrote no %v: %v", name, err)
+			continue
+		}
+		wantContent, err := os.ReadFile(filepath.Join(want, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(gotContent, wantContent) {
+			t.Errorf("ToDisk() wrote %v:\n%s\nwant:\n%s", name, gotContent, wantContent)
+		}
+	}
+}
+
+func TestToParquet_baseline(t *testing.T) {
+	// testdata/baseline holds the outputs of rac before the outputs were
+	// described by the schema, with the same sidecar for an image in CCD.csv
+	got := t.TempDir()
+	stats, err := ToParquet([]string{filepath.Join("testdata", "baseline")}, got)
+	if err != nil {
+		t.Fatalf("ToParquet() error = %v", err)
+	}
+	if want := (Stats{Files: 6, Records: 7, Images: 1}); stats != want {
+		t.Errorf("ToParquet() = %+v, want %+v", stats, want)
+	}
+	processed := time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC)
+	records := readRecords(t, got)
+	tests := []struct {
+		stream timeseries.OutStream
+		column string
+		want   interface{}
+	}{
+		{timeseries.HTR, "ProcessingTime", processed},
+		{timeseries.HTR, "HTR1A", -55.0},
+		{timeseries.HTR, "HTR1AUncertainty", nil},
+		{timeseries.HTR, "Errors", []string{"bad packet [old.rac / Packet ID 0 / VC Frame Counter 15 / Date 7733, Time 0]"}},
+		{timeseries.PWR, "PWRTUncertainty", nil},
+		{timeseries.STAT, "STATTime", time.Date(1980, 1, 6, 0, 1, 22, 0, time.UTC)},
+		{timeseries.STAT, "STATNanoseconds", int64(100000000000)},
+		{timeseries.PM, "PMTime", time.Date(1980, 1, 6, 0, 1, 22, 0, time.UTC)},
+		{timeseries.PM, "PM1ACNTR", int64(4)},
+		{timeseries.CCD, "BadColumns", []int64{3, 4}},
+		{timeseries.CCD, "ImageName", "old_3.png"},
+	}
+	for _, tt := range tests {
+		record, ok := records[tt.stream]
+		if !ok {
+			t.Errorf("ToParquet() wrote no %v", tt.stream)
+			continue
+		}
+		value, ok := record.Value(tt.column)
+		if !ok || !reflect.DeepEqual(value, tt.want) {
+			t.Errorf("ToParquet() wrote %v %v = %#v, want %#v", tt.stream, tt.column, value, tt.want)
+		}
+	}
+	if ccd, ok := records[timeseries.CCD]; ok {
+		if data, _ := ccd.Value("ImageData"); data == nil {
+			t.Error("ToParquet() wrote no ImageData")
+		}
+	}
+}
+
+func TestToParquet_skipsUnknown(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input")
+	if err := os.MkdirAll(input, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("CODE,Test\nOriginFile,Something\nmy.rac,42\n")
+	if err := os.WriteFile(filepath.Join(input, "Other.csv"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := ToParquet([]string{input}, filepath.Join(dir, "output"))
+	if err != nil {
+		t.Fatalf("ToParquet() error = %v", err)
+	}
+	if want := (Stats{Skipped: 1}); stats != want {
+		t.Errorf("ToParquet() = %+v, want %+v", stats, want)
+	}
+}
+
+// sameValues returns if the values are deeply equal, taking NaN as equal to
+// NaN
+func sameValues(values []interface{}, others []interface{}) bool {
+	if len(values) != len(others) {
+		return false
+	}
+	for i := range values {
+		number, ok := values[i].(float64)
+		other, otherOk := others[i].(float64)
+		if ok && otherOk && math.IsNaN(number) && math.IsNaN(other) {
+			continue
+		}
+		if !reflect.DeepEqual(values[i], others[i]) {
+			return false
+		}
+	}
+	return true
+}
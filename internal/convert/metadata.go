@@ -0,0 +1,100 @@
+package convert
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+)
+
+// leadingKeys are the specifications first in the row of a csv, as by
+// common.DataRecord.CSVSpecifications
+var leadingKeys = []string{"CODE", "RAMSES", "INNOSAT"}
+
+// trailingKeys are the provenance specifications last in the row of a csv
+var trailingKeys = []string{"PROCESSED", "PARAMETERS", "CALIBRATIONS", "INPUTS"}
+
+// runKeys are the specifications of the run, present in a csv even if empty
+// but left out of parquet metadata when empty
+var runKeys = []string{"PARAMETERS", "CALIBRATIONS", "INPUTS"}
+
+// pairs returns the key value pairs as a map
+func pairs(specifications []string) map[string]string {
+	metadata := make(map[string]string)
+	for idx := 0; idx+1 < len(specifications); idx += 2 {
+		metadata[specifications[idx]] = specifications[idx+1]
+	}
+	return metadata
+}
+
+// parquetMetadata returns the metadata of the parquet file of the origin from
+// the specifications row of a csv
+//
+// A csv lists all rac-files of the run as INPUTS, a parquet file only its
+// own as ORIGIN and ORIGIN_SHA256.
+func parquetMetadata(specifications []string, origin string) map[string]string {
+	metadata := pairs(specifications)
+	inputs := metadata["INPUTS"]
+	delete(metadata, "INPUTS")
+	metadata["ORIGIN"] = origin
+	for _, input := range strings.Fields(inputs) {
+		idx := strings.LastIndex(input, ":")
+		if idx >= 0 && input[:idx] == origin && input[idx+1:] != "" {
+			metadata["ORIGIN_SHA256"] = input[idx+1:]
+		}
+	}
+	return metadata
+}
+
+// csvSpecifications returns the specifications row of a csv holding the rows
+// of parquet files with the metadata
+//
+// The origins of the files are listed as INPUTS if the run is known. Where
+// the files disagree the distinct values are joined by '|' as by rac compact.
+func csvSpecifications(metadata []map[string]string) []string {
+	values := make(map[string][]string)
+	hasRun := false
+	for _, fileMetadata := range metadata {
+		for key, value := range fileMetadata {
+			if key == "ORIGIN" || key == "ORIGIN_SHA256" {
+				continue
+			}
+			if common.ContainsString(runKeys, key) {
+				hasRun = true
+			}
+			if !common.ContainsString(values[key], value) {
+				values[key] = append(values[key], value)
+			}
+		}
+		if origin, ok := fileMetadata["ORIGIN"]; ok {
+			input := origin + ":" + fileMetadata["ORIGIN_SHA256"]
+			if !common.ContainsString(values["INPUTS"], input) {
+				values["INPUTS"] = append(values["INPUTS"], input)
+			}
+		}
+	}
+	if !hasRun {
+		delete(values, "INPUTS")
+	}
+	var others []string
+	for key := range values {
+		if !common.ContainsString(leadingKeys, key) && !common.ContainsString(trailingKeys, key) {
+			others = append(others, key)
+		}
+	}
+	sort.Strings(others)
+	keys := append([]string{}, leadingKeys...)
+	keys = append(keys, others...)
+	keys = append(keys, trailingKeys...)
+	var specifications []string
+	for _, key := range keys {
+		separator := "|"
+		if key == "INPUTS" {
+			separator = " "
+		}
+		if keyValues, ok := values[key]; ok || (hasRun && common.ContainsString(runKeys, key)) {
+			specifications = append(specifications, key, strings.Join(keyValues, separator))
+		}
+	}
+	return specifications
+}
@@ -0,0 +1,60 @@
+package convert
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parquetMetadata(t *testing.T) {
+	specifications := []string{
+		"CODE", "1.0", "AEZ", "AEZICD002:I", "PROCESSED", "2023-01-05T14:00:00Z",
+		"INPUTS", "other.rac:def my.rac:abc",
+	}
+	want := map[string]string{
+		"CODE":          "1.0",
+		"AEZ":           "AEZICD002:I",
+		"PROCESSED":     "2023-01-05T14:00:00Z",
+		"ORIGIN":        "my.rac",
+		"ORIGIN_SHA256": "abc",
+	}
+	if got := parquetMetadata(specifications, "my.rac"); !reflect.DeepEqual(got, want) {
+		t.Errorf("parquetMetadata() = %v, want %v", got, want)
+	}
+}
+
+func Test_csvSpecifications(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata []map[string]string
+		want     []string
+	}{
+		{
+			"Without run",
+			[]map[string]string{{"PROCESSED": "2023", "AEZ": "I", "CODE": "1.0", "ORIGIN": "my.rac"}},
+			[]string{"CODE", "1.0", "AEZ", "I", "PROCESSED", "2023"},
+		},
+		{
+			"Files of a run",
+			[]map[string]string{
+				{"CODE": "1.0", "PARAMETERS": "", "ORIGIN": "my.rac", "ORIGIN_SHA256": "abc"},
+				{"CODE": "1.0", "PARAMETERS": "", "ORIGIN": "other.rac", "ORIGIN_SHA256": "def"},
+			},
+			[]string{
+				"CODE", "1.0", "PARAMETERS", "", "CALIBRATIONS", "",
+				"INPUTS", "my.rac:abc other.rac:def",
+			},
+		},
+		{
+			"Files that disagree",
+			[]map[string]string{{"CODE": "1.0"}, {"CODE": "1.1"}, {"CODE": "1.0"}},
+			[]string{"CODE", "1.0|1.1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := csvSpecifications(tt.metadata); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("csvSpecifications() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/query"
+	"github.com/innosat-mats/rac-extract-payload/internal/ramses"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
+)
+
+// sidecar is the JSON written next to each image, as by
+// common.DataRecord.MarshalJSON
+type sidecar struct {
+	Origin         common.OriginDescription `json:"origin"`
+	RamsesHeader   ramsesSidecar            `json:"ramsesHeader"`
+	RamsesTMHeader ramses.TMHeader          `json:"ramsesTMHeader"`
+	SourceHeader   sourceSidecar            `json:"sourceHeader"`
+	TMHeader       tmSidecar                `json:"tmHeader"`
+	SID            string
+	RID            string
+	Data           json.RawMessage `json:"data"`
+}
+
+type ramsesSidecar struct {
+	Specification string    `json:"specification"`
+	RamsesTime    time.Time `json:"ramsesTime"`
+}
+
+type sourceSidecar struct {
+	Specification   string `json:"specification"`
+	SPSequenceCount int64  `json:"spSequenceCount"`
+}
+
+type tmSidecar struct {
+	TMHeaderTime        time.Time `json:"tmHeaderTime"`
+	TMHeaderNanoseconds int64     `json:"tmHeaderNanoseconds"`
+}
+
+// dataColumns are the columns of the image held by the data of a sidecar
+var dataColumns = (&aez.CCDImage{}).Columns()
+
+// readSidecar returns the sidecar in the file
+func readSidecar(name string) (*sidecar, error) {
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var image sidecar
+	if err := json.Unmarshal(content, &image); err != nil {
+		return nil, fmt.Errorf("could not read %v: %v", name, err)
+	}
+	return &image, nil
+}
+
+// values returns the values of the sidecar by column name
+func (image *sidecar) values() (map[string]interface{}, error) {
+	values := map[string]interface{}{
+		"OriginFile":          filepath.Base(image.Origin.Name),
+		"ProcessingTime":      image.Origin.ProcessingDate,
+		"RamsesTime":          image.RamsesHeader.RamsesTime,
+		"SPSequenceCount":     image.SourceHeader.SPSequenceCount,
+		"TMHeaderTime":        image.TMHeader.TMHeaderTime,
+		"TMHeaderNanoseconds": image.TMHeader.TMHeaderNanoseconds,
+		"SID":                 image.SID,
+		"RID":                 image.RID,
+	}
+	tmValues := image.RamsesTMHeader.Values()
+	for idx, column := range image.RamsesTMHeader.Columns() {
+		values[column.Name] = tmValues[idx]
+	}
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal(image.Data, &data); err != nil {
+		return nil, err
+	}
+	for _, column := range dataColumns {
+		raw, ok := data[column.CSVHeader()]
+		if !ok {
+			continue
+		}
+		value, err := fromJSON(column, raw)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %v: %v", column.Name, err)
+		}
+		values[column.Name] = value
+	}
+	return values, nil
+}
+
+// metadata returns the parquet metadata of the run that wrote the sidecar
+func (image *sidecar) metadata() map[string]string {
+	metadata := (&common.DataRecord{Origin: &image.Origin, Data: &aez.CCDImage{}}).ParquetSpecifications()
+	if image.Origin.Run != nil && image.Origin.Run.Version != "" {
+		metadata["CODE"] = image.Origin.Run.Version
+	}
+	if image.RamsesHeader.Specification != "" {
+		metadata["RAMSES"] = image.RamsesHeader.Specification
+	}
+	if image.SourceHeader.Specification != "" {
+		metadata["INNOSAT"] = image.SourceHeader.Specification
+	}
+	return metadata
+}
+
+// fromJSON returns the value of the column as by schema.Values
+func fromJSON(column schema.Column, raw json.RawMessage) (interface{}, error) {
+	if string(raw) == "null" {
+		return nil, nil
+	}
+	var value interface{}
+	var err error
+	switch column.Type {
+	case schema.Bool:
+		var flag bool
+		err = json.Unmarshal(raw, &flag)
+		value = flag
+	case schema.Int32, schema.Int64:
+		var number int64
+		err = json.Unmarshal(raw, &number)
+		value = number
+	case schema.Double:
+		var number float64
+		err = json.Unmarshal(raw, &number)
+		value = number
+	case schema.Timestamp:
+		var moment time.Time
+		err = json.Unmarshal(raw, &moment)
+		value = moment
+	case schema.IntList:
+		var list []int64
+		err = json.Unmarshal(raw, &list)
+		value = list
+	case schema.StringList:
+		var list []string
+		err = json.Unmarshal(raw, &list)
+		value = list
+	default:
+		var text string
+		err = json.Unmarshal(raw, &text)
+		value = text
+	}
+	return value, err
+}
+
+// newSidecar returns the sidecar of the CCD record of a parquet file with
+// the metadata
+func newSidecar(record *query.Record, metadata map[string]string) (*sidecar, error) {
+	dataValues := make([]interface{}, len(dataColumns))
+	for idx, column := range dataColumns {
+		dataValues[idx], _ = record.Value(column.Name)
+	}
+	data, err := aez.CCDImageJSON(dataValues)
+	if err != nil {
+		return nil, err
+	}
+	image := sidecar{
+		Origin: common.OriginDescription{
+			Name:           stringValue(record, "OriginFile"),
+			ProcessingDate: timeValue(record, "ProcessingTime"),
+			SHA256:         metadata["ORIGIN_SHA256"],
+		},
+		RamsesHeader: ramsesSidecar{
+			Specification: metadata["RAMSES"],
+			RamsesTime:    timeValue(record, "RamsesTime"),
+		},
+		RamsesTMHeader: ramses.TMHeader{
+			QualityIndicator: ramses.QualityIndicator(intValue(record, "QualityIndicator")),
+			LossFlag:         ramses.LossFlag(intValue(record, "LossFlag")),
+			VCFrameCounter:   uint8(intValue(record, "VCFrameCounter")),
+		},
+		SourceHeader: sourceSidecar{
+			Specification:   metadata["INNOSAT"],
+			SPSequenceCount: intValue(record, "SPSequenceCount"),
+		},
+		TMHeader: tmSidecar{
+			TMHeaderTime:        timeValue(record, "TMHeaderTime"),
+			TMHeaderNanoseconds: intValue(record, "TMHeaderNanoseconds"),
+		},
+		SID:  stringValue(record, "SID"),
+		RID:  stringValue(record, "RID"),
+		Data: data,
+	}
+	if version, ok := metadata["CODE"]; ok {
+		image.Origin.Run = &common.RunDescription{
+			Version:      version,
+			Parameters:   strings.Fields(metadata["PARAMETERS"]),
+			Calibrations: strings.Fields(metadata["CALIBRATIONS"]),
+		}
+	}
+	return &image, nil
+}
+
+func stringValue(record *query.Record, name string) string {
+	value, _ := record.Value(name)
+	text, _ := value.(string)
+	return text
+}
+
+func intValue(record *query.Record, name string) int64 {
+	value, _ := record.Value(name)
+	number, _ := value.(int64)
+	return number
+}
+
+func timeValue(record *query.Record, name string) time.Time {
+	value, _ := record.Value(name)
+	moment, _ := value.(time.Time)
+	return moment
+}
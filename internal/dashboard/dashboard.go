@@ -0,0 +1,276 @@
+package dashboard
+
+import (
+	"bytes"
+	_ "embed" // Embeds the page of the dashboard
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/exports"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+//go:embed index.html
+var page []byte
+
+// Streams are the housekeeping timeseries shown by the dashboard
+var Streams = []timeseries.OutStream{
+	timeseries.HTR, timeseries.PWR, timeseries.CPRU, timeseries.STAT, timeseries.TCV,
+}
+
+// ThumbnailWidth is the largest width of the CCD thumbnails
+const ThumbnailWidth = 256
+
+// subscriberBuffer is the number of events a slow subscriber may lag
+// behind before missing events
+const subscriberBuffer = 64
+
+// keepAlive is how often an idle event stream is written to so that proxies
+// keep it open
+var keepAlive = 15 * time.Second
+
+// event is a server-sent event, data is a json object
+type event struct {
+	name string
+	data []byte
+}
+
+// Dashboard keeps the latest housekeeping values and CCD thumbnails and
+// serves them as server-sent events with a page showing them
+//
+// The page is served on /, the events on /events and the thumbnails on
+// /thumbnails/CCD1.png and so on by CCD sensor number, CCDSEL.
+type Dashboard struct {
+	lock        sync.Mutex
+	latest      map[string]event  // Latest event by stream or CCD
+	thumbnails  map[string][]byte // Latest thumbnail PNG by CCD
+	updates     int               // Number of updates, so thumbnail urls change
+	subscribers map[chan event]bool
+	mux         *http.ServeMux
+}
+
+// New returns an empty dashboard
+func New() *Dashboard {
+	dashboard := Dashboard{
+		latest:      make(map[string]event),
+		thumbnails:  make(map[string][]byte),
+		subscribers: make(map[chan event]bool),
+		mux:         http.NewServeMux(),
+	}
+	dashboard.mux.HandleFunc("/", dashboard.index)
+	dashboard.mux.HandleFunc("/events", dashboard.events)
+	dashboard.mux.HandleFunc("/thumbnails/", dashboard.thumbnail)
+	return &dashboard
+}
+
+// CallbackFactory returns a callback showing the records on the dashboard,
+// the thumbnails are made by the pool
+func (dashboard *Dashboard) CallbackFactory(pool *exports.ImagePool) (common.Callback, common.CallbackTeardown) {
+	return func(pkg common.DataRecord) {
+		if pkg.Error != nil || pkg.Data == nil {
+			return
+		}
+		if ccdImage, ok := pkg.Data.(*aez.CCDImage); ok {
+			pool.Go(func() { dashboard.showImage(&pkg, ccdImage) })
+			return
+		}
+		stream := timeseries.OutStreamFromDataRecord(&pkg)
+		for _, shown := range Streams {
+			if stream == shown {
+				dashboard.showValues(&pkg, stream.String())
+				return
+			}
+		}
+	}, func() error { return nil }
+}
+
+// recordObject returns the record as a json object named as the CSV headers
+func recordObject(pkg *common.DataRecord) (map[string]interface{}, error) {
+	encoded, err := schema.MarshalJSON(pkg.Columns(), pkg.Values())
+	if err != nil {
+		return nil, err
+	}
+	object := make(map[string]interface{})
+	return object, json.Unmarshal(encoded, &object)
+}
+
+func (dashboard *Dashboard) showValues(pkg *common.DataRecord, name string) {
+	encoded, err := schema.MarshalJSON(pkg.Columns(), pkg.Values())
+	if err != nil {
+		log.Printf("could not encode json %v: %v", common.MakePackageInfo(pkg), err)
+		return
+	}
+	dashboard.lock.Lock()
+	defer dashboard.lock.Unlock()
+	dashboard.publish(event{name: name, data: encoded})
+}
+
+func (dashboard *Dashboard) showImage(pkg *common.DataRecord, ccdImage *aez.CCDImage) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Could not make thumbnail of %s, skipping (%v)", ccdImage.ImageFileName, r)
+		}
+	}()
+	var thumbnail bytes.Buffer
+	if err := png.Encode(&thumbnail, Thumbnail(ccdImage.Image(pkg.Buffer), ThumbnailWidth)); err != nil {
+		log.Printf("Could not encode thumbnail of %s: %v", ccdImage.ImageFileName, err)
+		return
+	}
+	object, err := recordObject(pkg)
+	if err != nil {
+		log.Printf("could not encode json %v: %v", common.MakePackageInfo(pkg), err)
+		return
+	}
+	name := fmt.Sprintf("CCD%v", ccdImage.PackData.CCDSEL)
+
+	dashboard.lock.Lock()
+	defer dashboard.lock.Unlock()
+	dashboard.updates++
+	object["Thumbnail"] = fmt.Sprintf("thumbnails/%v.png?update=%v", name, dashboard.updates)
+	encoded, err := json.Marshal(object)
+	if err != nil {
+		log.Printf("could not encode json %v: %v", common.MakePackageInfo(pkg), err)
+		return
+	}
+	dashboard.thumbnails[name] = thumbnail.Bytes()
+	dashboard.publish(event{name: name, data: encoded})
+}
+
+// publish keeps the event as the latest of its name and sends it to the
+// subscribers, those that lag behind miss it
+func (dashboard *Dashboard) publish(update event) {
+	dashboard.latest[update.name] = update
+	for subscriber := range dashboard.subscribers {
+		select {
+		case subscriber <- update:
+		default:
+		}
+	}
+}
+
+// subscribe returns the channel of future events and the latest events
+func (dashboard *Dashboard) subscribe() (chan event, []event) {
+	dashboard.lock.Lock()
+	defer dashboard.lock.Unlock()
+	subscriber := make(chan event, subscriberBuffer)
+	dashboard.subscribers[subscriber] = true
+	var names []string
+	for name := range dashboard.latest {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	snapshot := make([]event, len(names))
+	for idx, name := range names {
+		snapshot[idx] = dashboard.latest[name]
+	}
+	return subscriber, snapshot
+}
+
+func (dashboard *Dashboard) unsubscribe(subscriber chan event) {
+	dashboard.lock.Lock()
+	defer dashboard.lock.Unlock()
+	delete(dashboard.subscribers, subscriber)
+}
+
+func (dashboard *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	dashboard.mux.ServeHTTP(w, r)
+}
+
+func (dashboard *Dashboard) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
+
+// events streams the latest values and then each update until the client
+// goes away
+func (dashboard *Dashboard) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	subscriber, snapshot := dashboard.subscribe()
+	defer dashboard.unsubscribe(subscriber)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	for _, update := range snapshot {
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", update.name, update.data)
+	}
+	flusher.Flush()
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case update := <-subscriber:
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", update.name, update.data)
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+func (dashboard *Dashboard) thumbnail(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/thumbnails/"), ".png")
+	dashboard.lock.Lock()
+	thumbnail, ok := dashboard.thumbnails[name]
+	dashboard.lock.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(thumbnail)
+}
+
+// Thumbnail returns the image scaled down to at most the width and
+// stretched to the range of its values as 8 bit gray
+func Thumbnail(img *image.Gray16, width int) *image.Gray {
+	bounds := img.Bounds()
+	step := 1
+	if bounds.Dx() > width {
+		step = (bounds.Dx() + width - 1) / width
+	}
+	low, high := uint16(0xffff), uint16(0)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			value := img.Gray16At(x, y).Y
+			if value < low {
+				low = value
+			}
+			if value > high {
+				high = value
+			}
+		}
+	}
+	scale := 1.0
+	if high > low {
+		scale = 255 / float64(high-low)
+	}
+	thumbnail := image.NewGray(image.Rect(0, 0, (bounds.Dx()+step-1)/step, (bounds.Dy()+step-1)/step))
+	for y := 0; y < thumbnail.Rect.Dy(); y++ {
+		for x := 0; x < thumbnail.Rect.Dx(); x++ {
+			value := img.Gray16At(bounds.Min.X+x*step, bounds.Min.Y+y*step).Y
+			thumbnail.Pix[y*thumbnail.Stride+x] = uint8(float64(value-low) * scale)
+		}
+	}
+	return thumbnail
+}
@@ -0,0 +1,171 @@
+package dashboard
+
+import (
+	"bufio"
+	"encoding/json"
+	"image"
+	"image/color"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/exports"
+)
+
+// readEvent reads the next event of the stream skipping comments
+func readEvent(t *testing.T, lines *bufio.Scanner) (string, map[string]interface{}) {
+	var name string
+	var data map[string]interface{}
+	for lines.Scan() {
+		line := lines.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &data); err != nil {
+				t.Fatalf("event data %v is not json: %v", line, err)
+			}
+		case line == "" && name != "":
+			return name, data
+		}
+	}
+	t.Fatalf("event stream ended: %v", lines.Err())
+	return "", nil
+}
+
+func TestDashboard_events(t *testing.T) {
+	dashboard := New()
+	server := httptest.NewServer(dashboard)
+	defer server.Close()
+	pool := exports.NewImagePool(1)
+	callback, teardown := dashboard.CallbackFactory(pool)
+	defer teardown()
+	callback(common.DataRecord{Data: &aez.HTR{}})
+	callback(common.DataRecord{Data: &aez.PMData{}})
+	callback(common.DataRecord{Data: &aez.STAT{}, Error: io.ErrUnexpectedEOF})
+
+	response, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if got := response.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Dashboard events Content-Type = %v, want text/event-stream", got)
+	}
+	lines := bufio.NewScanner(response.Body)
+	if name, data := readEvent(t, lines); name != "HTR" || data["HTR1A"] == nil {
+		t.Errorf("Dashboard first event = %v %v, want the latest HTR", name, data)
+	}
+
+	callback(common.DataRecord{Data: &aez.STAT{}})
+	if name, data := readEvent(t, lines); name != "STAT" || data["SPID"] == nil {
+		t.Errorf("Dashboard update = %v %v, want STAT", name, data)
+	}
+
+	callback(common.DataRecord{
+		Data: &aez.CCDImage{
+			PackData: &aez.CCDImagePackData{
+				CCDSEL: 3,
+				JPEGQ:  aez.JPEGQUncompressed16bit,
+				NCOL:   2 - aez.NCOLStartOffset,
+				NROW:   1,
+			},
+			ImageFileName: "my_3.png",
+		},
+		Buffer: []byte{1, 0, 2, 0},
+	})
+	name, data := readEvent(t, lines)
+	if name != "CCD3" || data["ImageName"] != "my_3.png" {
+		t.Fatalf("Dashboard image event = %v %v, want CCD3 of my_3.png", name, data)
+	}
+	thumbnail, err := http.Get(server.URL + "/" + data["Thumbnail"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer thumbnail.Body.Close()
+	if thumbnail.StatusCode != http.StatusOK || thumbnail.Header.Get("Content-Type") != "image/png" {
+		t.Errorf("Dashboard thumbnail = %v %v, want a PNG", thumbnail.Status, thumbnail.Header.Get("Content-Type"))
+	}
+}
+
+func TestDashboard_pages(t *testing.T) {
+	server := httptest.NewServer(New())
+	defer server.Close()
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+	}{
+		{"Page", "/", http.StatusOK},
+		{"Unknown page", "/other", http.StatusNotFound},
+		{"Missing thumbnail", "/thumbnails/CCD1.png", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := http.Get(server.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.Body.Close()
+			if response.StatusCode != tt.wantCode {
+				t.Errorf("Dashboard %v status = %v, want %v", tt.path, response.StatusCode, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestDashboard_keepAlive(t *testing.T) {
+	defer func(interval time.Duration) { keepAlive = interval }(keepAlive)
+	keepAlive = time.Millisecond
+	server := httptest.NewServer(New())
+	defer server.Close()
+	response, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	line, err := bufio.NewReader(response.Body).ReadString('\n')
+	if err != nil || line != ": keep-alive\n" {
+		t.Errorf("Dashboard idle events = %q, %v, want a keep-alive comment", line, err)
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	img := image.NewGray16(image.Rect(0, 0, 10, 4))
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 4; y++ {
+			img.SetGray16(x, y, color.Gray16{Y: uint16(1000 + 100*x)})
+		}
+	}
+	tests := []struct {
+		name      string
+		width     int
+		wantSize  image.Point
+		wantFirst uint8
+		wantLast  uint8
+	}{
+		{"Keeps small images", 16, image.Pt(10, 4), 0, 255},
+		{"Scales down", 5, image.Pt(5, 2), 0, 226},
+		{"Scales down to at most the width", 4, image.Pt(4, 2), 0, 255},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Thumbnail(img, tt.width)
+			if got.Rect.Size() != tt.wantSize {
+				t.Errorf("Thumbnail() size = %v, want %v", got.Rect.Size(), tt.wantSize)
+			}
+			last := got.Rect.Dx() - 1
+			if got.GrayAt(0, 0).Y != tt.wantFirst || got.GrayAt(last, 0).Y != tt.wantLast {
+				t.Errorf(
+					"Thumbnail() values %v...%v, want %v...%v",
+					got.GrayAt(0, 0).Y, got.GrayAt(last, 0).Y, tt.wantFirst, tt.wantLast,
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package exports
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+func arrowName(dir string, pkg *common.DataRecord, stream timeseries.OutStream) string {
+	name := timeseries.ArrowName(pkg, stream)
+	return filepath.Join(dir, name)
+}
+
+func arrowFileWriterFactoryCreator(
+	dir string,
+) timeseries.ParquetFactory {
+	return func(pkg *common.DataRecord, stream timeseries.OutStream) (timeseries.ParquetWriter, error) {
+		outPath := arrowName(dir, pkg, stream)
+
+		err := os.MkdirAll(filepath.Dir(outPath), os.ModePerm)
+		if err != nil {
+			return nil, fmt.Errorf("could not create output prefix '%v'", outPath)
+		}
+		return timeseries.NewArrow(outPath, pkg)
+	}
+}
+
+// ArrowCallbackFactory returns a callback for Arrow IPC (Feather v2) disk writes
+func ArrowCallbackFactory(
+	output string,
+	pool *ImagePool,
+) (common.Callback, common.CallbackTeardown) {
+	return collectionCallbackFactory(output, arrowFileWriterFactoryCreator(output), pool)
+}
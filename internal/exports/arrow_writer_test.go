@@ -0,0 +1,47 @@
+package exports
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/innosat"
+	"github.com/innosat-mats/rac-extract-payload/internal/ramses"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+func Test_arrowName(t *testing.T) {
+	pkg := common.DataRecord{
+		Origin:   &common.OriginDescription{Name: "File1.rac"},
+		TMHeader: &innosat.TMHeader{},
+		Data:     &aez.STAT{},
+	}
+	want := filepath.FromSlash("my/dir/STAT/1980/1/5/File1.arrow")
+	if got := arrowName("my/dir", &pkg, timeseries.STAT); got != want {
+		t.Errorf("arrowName() = %v, want %v", got, want)
+	}
+}
+
+func TestArrowCallbackFactory(t *testing.T) {
+	dir := t.TempDir()
+	callback, teardown := ArrowCallbackFactory(dir, NewImagePool(2))
+	for _, data := range []common.Exporter{&aez.STAT{}, &aez.STAT{}, &aez.HTR{}} {
+		callback(common.DataRecord{
+			Origin:         &common.OriginDescription{Name: "File1.rac"},
+			RamsesHeader:   &ramses.Ramses{},
+			RamsesTMHeader: &ramses.TMHeader{},
+			SourceHeader:   &innosat.SourcePacketHeader{},
+			TMHeader:       &innosat.TMHeader{},
+			Data:           data,
+		})
+	}
+	teardown()
+	for _, stream := range []string{"STAT", "HTR"} {
+		path := filepath.Join(dir, stream, "1980", "1", "5", "File1.arrow")
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("ArrowCallbackFactory() expected to produce file '%v': %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package exports
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+// CompactedName is the name of the merged parquet file of a partition
+const CompactedName = "compacted.parquet"
+
+// ParquetPartitions returns the parquet files below root by directory
+func ParquetPartitions(root string) (map[string][]string, error) {
+	partitions := make(map[string][]string)
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".parquet") {
+			dir := filepath.Dir(path)
+			partitions[dir] = append(partitions[dir], path)
+		}
+		return nil
+	})
+	return partitions, err
+}
+
+// CompactParquetPartition merges the files of the partition into
+// CompactedName and removes the merged files
+//
+// The merged file is written as an AtomicFile and thus complete before any
+// file is removed, so an interrupted compaction leaves no rows missing.
+func CompactParquetPartition(dir string, files []string) (timeseries.CompactStats, error) {
+	sort.Strings(files)
+	compacted := filepath.Join(dir, CompactedName)
+	stats, err := timeseries.CompactParquet(files, compacted)
+	if err != nil {
+		return stats, err
+	}
+	for _, file := range files {
+		if file == compacted {
+			continue
+		}
+		if err := os.Remove(file); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// CompactParquetTree compacts each partition below root that holds more than
+// one parquet file, failing partitions are logged and left as they were
+//
+// The manifest of the project holding root, if any, is updated to list the
+// compacted files instead of the merged ones.
+func CompactParquetTree(root string) (timeseries.CompactStats, error) {
+	var total timeseries.CompactStats
+	partitions, err := ParquetPartitions(root)
+	if err != nil {
+		return total, err
+	}
+	var dirs []string
+	for dir, files := range partitions {
+		if len(files) > 1 {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	failed := 0
+	var removed, written []string
+	for _, dir := range dirs {
+		stats, err := CompactParquetPartition(dir, partitions[dir])
+		if err != nil {
+			log.Printf("could not compact %v: %v", dir, err)
+			failed++
+			continue
+		}
+		log.Printf(
+			"Compacted %v files into %v rows in %v, dropping %v duplicates",
+			stats.Files,
+			stats.Rows,
+			dir,
+			stats.Duplicates,
+		)
+		total.Files += stats.Files
+		total.Rows += stats.Rows
+		total.Duplicates += stats.Duplicates
+		compacted := filepath.Join(dir, CompactedName)
+		for _, file := range partitions[dir] {
+			if file != compacted {
+				removed = append(removed, file)
+			}
+		}
+		written = append(written, compacted)
+	}
+	if len(written) > 0 {
+		if err := UpdateManifest(root, removed, written); err != nil {
+			return total, fmt.Errorf("could not update the manifest: %v", err)
+		}
+	}
+	if failed > 0 {
+		return total, fmt.Errorf("could not compact %v of %v partitions", failed, len(dirs))
+	}
+	return total, nil
+}
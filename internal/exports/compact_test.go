@@ -0,0 +1,138 @@
+package exports
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/innosat"
+	"github.com/innosat-mats/rac-extract-payload/internal/ramses"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+func writeParquetProject(t *testing.T, dir string, origins ...string) {
+	callback, teardown := ParquetCallbackFactory(dir, NewImagePool(1))
+	for i, origin := range origins {
+		for _, data := range []common.Exporter{&aez.STAT{}, &aez.HTR{}} {
+			callback(common.DataRecord{
+				Origin:         &common.OriginDescription{Name: origin},
+				RamsesHeader:   &ramses.Ramses{},
+				RamsesTMHeader: &ramses.TMHeader{},
+				SourceHeader:   &innosat.SourcePacketHeader{PacketSequenceControl: innosat.PacketSequenceControl(i)},
+				TMHeader:       &innosat.TMHeader{},
+				Data:           data,
+			})
+		}
+	}
+	teardown()
+}
+
+func TestCompactParquetTree(t *testing.T) {
+	dir := t.TempDir()
+	writeParquetProject(t, dir, "File1.rac", "File2.rac")
+	// A partition with a lone file is left as is
+	lone := filepath.Join(dir, "lone")
+	writeParquetProject(t, lone, "File3.rac")
+
+	stats, err := CompactParquetTree(dir)
+	if err != nil {
+		t.Fatalf("CompactParquetTree() error = %v", err)
+	}
+	if want := (timeseries.CompactStats{Files: 4, Rows: 4}); stats != want {
+		t.Errorf("CompactParquetTree() = %+v, want %+v", stats, want)
+	}
+	partitions, err := ParquetPartitions(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{}
+	for _, stream := range []string{"STAT", "HTR"} {
+		partition := filepath.Join(dir, stream, "1980", "1", "5")
+		want[partition] = []string{filepath.Join(partition, CompactedName)}
+		partition = filepath.Join(lone, stream, "1980", "1", "5")
+		want[partition] = []string{filepath.Join(partition, "File3.parquet")}
+	}
+	if !reflect.DeepEqual(partitions, want) {
+		t.Errorf("CompactParquetTree() left %v, want %v", partitions, want)
+	}
+
+	// Compacting again merges new files with the compacted one, the packets
+	// of File4.rac are those of File2.rac and are dropped as duplicates
+	writeParquetProject(t, dir, "File1.rac", "File4.rac")
+	stats, err = CompactParquetTree(dir)
+	if err != nil {
+		t.Fatalf("CompactParquetTree() error = %v", err)
+	}
+	if want := (timeseries.CompactStats{Files: 6, Rows: 4, Duplicates: 4}); stats != want {
+		t.Errorf("CompactParquetTree() again = %+v, want %+v", stats, want)
+	}
+}
+
+func TestCompactParquetTree_keepsFailingPartitions(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.parquet", "b.parquet"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("not parquet"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := CompactParquetTree(dir); err == nil {
+		t.Error("CompactParquetTree() gave no error")
+	}
+	for _, name := range []string{"a.parquet", "b.parquet"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("CompactParquetTree() removed %v", name)
+		}
+	}
+	if temporary, _ := filepath.Glob(filepath.Join(dir, ".*.tmp")); len(temporary) > 0 {
+		t.Errorf("CompactParquetTree() left temporary files %v", temporary)
+	}
+}
+
+func TestCompactParquetTree_updatesManifest(t *testing.T) {
+	dir := t.TempDir()
+	if err := StartRun(dir); err != nil {
+		t.Fatal(err)
+	}
+	writeParquetProject(t, dir, "File1.rac", "File2.rac")
+	if err := WriteManifest(dir); err != nil {
+		t.Fatal(err)
+	}
+	// A later run adds files without completing
+	writeParquetProject(t, dir, "File3.rac")
+
+	if _, err := CompactParquetTree(filepath.Join(dir, "HTR")); err != nil {
+		t.Fatalf("CompactParquetTree() error = %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, file := range manifest.Files {
+		paths = append(paths, file.Path)
+	}
+	want := []string{
+		"HTR/1980/1/5/" + CompactedName,
+		"STAT/1980/1/5/File1.parquet",
+		"STAT/1980/1/5/File2.parquet",
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("CompactParquetTree() manifest lists %v, want %v", paths, want)
+	}
+	compacted, err := describeFile(filepath.Join(dir, "HTR", "1980", "1", "5", CompactedName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compacted.Path = want[0]
+	if manifest.Files[0] != compacted {
+		t.Errorf("CompactParquetTree() manifest has %+v, want %+v", manifest.Files[0], compacted)
+	}
+}
@@ -2,11 +2,10 @@ package exports
 
 import (
 	"fmt"
-	"image/png"
 	"log"
 	"os"
 	"path/filepath"
-	"sync"
+	"strings"
 
 	"github.com/innosat-mats/rac-extract-payload/internal/aez"
 	"github.com/innosat-mats/rac-extract-payload/internal/common"
@@ -18,13 +17,81 @@ func csvName(dir string, packetType string) string {
 	return filepath.Join(dir, name)
 }
 
+// CSVSplit is how the csv of a stream is split into several files
+type CSVSplit int
+
+const (
+	// SplitNone writes one csv per stream, e.g. HTR.csv
+	SplitNone CSVSplit = iota
+	// SplitDay writes one csv per stream and partition, e.g. HTR/2023/1/5/HTR.csv
+	SplitDay
+	// SplitOrigin writes one csv per stream, partition and rac-file as the
+	// parquet files, e.g. HTR/2023/1/5/my.csv
+	SplitOrigin
+)
+
+// CSVSplits are the known ways of splitting csvs
+var CSVSplits = []CSVSplit{SplitNone, SplitDay, SplitOrigin}
+
+func (split CSVSplit) String() string {
+	switch split {
+	case SplitNone:
+		return "none"
+	case SplitDay:
+		return "day"
+	case SplitOrigin:
+		return "origin"
+	default:
+		return "unknown"
+	}
+}
+
+// CSVSplitFromName returns the split with the name
+func CSVSplitFromName(name string) (CSVSplit, error) {
+	for _, split := range CSVSplits {
+		if strings.EqualFold(split.String(), name) {
+			return split, nil
+		}
+	}
+	return SplitNone, fmt.Errorf("unknown csv split '%v'", name)
+}
+
+// CSVOptions controls how csv files are written to disk
+type CSVOptions struct {
+	Append   bool // Append to existing files rather than replacing them
+	Conflict timeseries.CSVConflict
+	Split    CSVSplit
+}
+
+func csvPath(dir string, pkg *common.DataRecord, stream timeseries.OutStream, split CSVSplit) string {
+	switch split {
+	case SplitDay:
+		prefix := timeseries.ActivePartitioning().Prefix(pkg, stream)
+		return csvName(filepath.Join(dir, prefix), stream.String())
+	case SplitOrigin:
+		return filepath.Join(dir, timeseries.CSVFileName(pkg, stream))
+	default:
+		return csvName(dir, stream.String())
+	}
+}
+
 func csvFileWriterFactoryCreator(
 	dir string,
+	options CSVOptions,
 ) timeseries.CSVFactory {
 	return func(pkg *common.DataRecord, stream timeseries.OutStream) (timeseries.CSVWriter, error) {
-		outPath := csvName(dir, stream.String())
+		outPath := csvPath(dir, pkg, stream, options.Split)
+		if options.Split != SplitNone {
+			err := os.MkdirAll(filepath.Dir(outPath), os.ModePerm)
+			if err != nil {
+				return nil, fmt.Errorf("could not create output prefix '%v'", outPath)
+			}
+		}
+		if options.Append {
+			return timeseries.AppendCSV(outPath, options.Conflict)
+		}
 
-		out, err := os.Create(outPath)
+		out, err := common.CreateAtomic(outPath)
 		if err != nil {
 			return nil, fmt.Errorf("could not create output file '%v'", outPath)
 		}
@@ -32,27 +99,39 @@ func csvFileWriterFactoryCreator(
 	}
 }
 
-// DiskCallbackFactory returns a callback for disk writes
+// DiskCallbackFactory returns a callback for disk writes, images are written
+// by the pool
 func DiskCallbackFactory(
 	output string,
 	writeImages bool,
 	writeTimeseries bool,
-	wg *sync.WaitGroup,
+	pool *ImagePool,
+	options CSVOptions,
 ) (common.Callback, common.CallbackTeardown) {
-	var err error
-	timeseriesCollection := timeseries.NewCollection(csvFileWriterFactoryCreator(output))
+	var failed failures
+	factory := csvFileWriterFactoryCreator(output, options)
+	timeseriesCollection := timeseries.NewCollection(factory)
+	if options.Split != SplitNone {
+		timeseriesCollection = timeseries.NewSplitCollection(
+			factory,
+			func(pkg *common.DataRecord, stream timeseries.OutStream) string {
+				return csvPath(output, pkg, stream, options.Split)
+			},
+		)
+	}
 	errorStats := common.NewErrorStats()
+	images := imageJobs{pool: pool}
 
 	if writeImages || writeTimeseries {
 		// Create Directory and File
 		err := os.MkdirAll(output, os.ModePerm)
 		if err != nil {
-			log.Printf("Could not create output directory '%v'", output)
+			failed.add(fmt.Errorf("could not create output directory '%v'", output))
 		}
 	}
 
 	callback := func(pkg common.DataRecord) {
-		errorStats.Register(pkg.Error)
+		registerRecord(&errorStats, &pkg)
 		if pkg.Error != nil {
 			pkg.Error = fmt.Errorf(
 				"%s %s",
@@ -63,10 +142,10 @@ func DiskCallbackFactory(
 		}
 		recoverWrite := func(imageFileName string) {
 			if r := recover(); r != nil {
-				log.Printf(
-					"Processing incomplete for image %s, skipping (%v)",
+				failed.add(fmt.Errorf(
+					"processing incomplete for image %s, skipping (%v)",
 					imageFileName, r,
-				)
+				))
 				os.Remove(imageFileName)
 				os.Remove(GetJSONFilename(imageFileName))
 			}
@@ -80,46 +159,55 @@ func DiskCallbackFactory(
 					break
 				}
 
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
+				images.Go(func() {
 					imgFileName := ccdImage.FullImageName(output)
 					defer recoverWrite(imgFileName)
-					img := ccdImage.Image(pkg.Buffer)
-					imgFile, err := os.Create(imgFileName)
+					pngImg := ccdImage.PNG(pkg.Buffer)
+					if pngImg == nil {
+						log.Panicf("failed encoding %s", imgFileName)
+					}
+					imgFile, err := common.CreateAtomic(imgFileName)
 					if err != nil {
 						log.Panicf("failed creating %s: %s", imgFileName, err)
 					}
-					defer imgFile.Close()
-					err = png.Encode(imgFile, img)
+					defer imgFile.Abort()
+					imgFile.AddOrigin(pkg.OriginName())
+					_, err = imgFile.Write(pngImg)
+					if err != nil {
+						log.Panicf("failed writing %s: %s", imgFileName, err)
+					}
+					err = imgFile.Close()
 					if err != nil {
-						log.Panicf("failed encoding %s: %s", imgFileName, err)
+						log.Panicf("failed writing %s: %s", imgFileName, err)
 					}
 					jsonFileName := GetJSONFilename(imgFileName)
-					jsonFile, err := os.Create(jsonFileName)
+					jsonFile, err := common.CreateAtomic(jsonFileName)
 					if err != nil {
 						log.Panicf("failed creating %s: %s", jsonFileName, err)
 					}
-					defer jsonFile.Close()
+					defer jsonFile.Abort()
+					jsonFile.AddOrigin(pkg.OriginName())
 					WriteJSON(jsonFile, &pkg, jsonFileName)
-				}()
+					err = jsonFile.Close()
+					if err != nil {
+						log.Panicf("failed writing %s: %s", jsonFileName, err)
+					}
+				})
 
 			}
 		}
 
 		if writeTimeseries && pkg.Data != nil {
 			// Write to the dedicated target stream
-			err = timeseriesCollection.Write(&pkg)
-			if err != nil {
-				log.Println(err)
-			}
+			failed.add(timeseriesCollection.Write(&pkg))
 		}
 	}
 
-	teardown := func() {
-		timeseriesCollection.CloseAll()
-		wg.Wait()
+	teardown := func() error {
+		failed.add(timeseriesCollection.CloseAll())
+		images.Wait()
 		log.Println(errorStats.Summarize())
+		return failed.first()
 	}
 
 	return callback, teardown
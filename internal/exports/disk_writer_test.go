@@ -4,7 +4,6 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-	"sync"
 	"testing"
 
 	"github.com/innosat-mats/rac-extract-payload/internal/aez"
@@ -39,7 +38,7 @@ func TestDiskCallbackFactoryCreator(t *testing.T) {
 	type args struct {
 		writeImages     bool
 		writeTimeseries bool
-		wg              *sync.WaitGroup
+		pool            *ImagePool
 	}
 	type wantFile struct {
 		base           string
@@ -331,7 +330,7 @@ func TestDiskCallbackFactoryCreator(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.args.wg = &sync.WaitGroup{}
+			tt.args.pool = NewImagePool(2)
 			// Setup and cleanup of output directory
 			dir, err := os.MkdirTemp("", "innosat-mats")
 			if err != nil {
@@ -340,7 +339,7 @@ func TestDiskCallbackFactoryCreator(t *testing.T) {
 			defer os.RemoveAll(dir)
 
 			// Produce callback and teardown
-			callback, teardown := DiskCallbackFactory(dir, tt.args.writeImages, tt.args.writeTimeseries, tt.args.wg)
+			callback, teardown := DiskCallbackFactory(dir, tt.args.writeImages, tt.args.writeTimeseries, tt.args.pool, CSVOptions{})
 
 			// Invoke callback and then teardown
 			for _, pkg := range tt.callbackArgs {
@@ -374,3 +373,94 @@ func TestDiskCallbackFactoryCreator(t *testing.T) {
 		})
 	}
 }
+
+func TestDiskCallbackFactory_csvOptions(t *testing.T) {
+	record := func(origin string) common.DataRecord {
+		return common.DataRecord{
+			Origin:         &common.OriginDescription{Name: origin},
+			RamsesHeader:   &ramses.Ramses{},
+			RamsesTMHeader: &ramses.TMHeader{},
+			SourceHeader:   &innosat.SourcePacketHeader{},
+			TMHeader:       &innosat.TMHeader{},
+			Data:           &aez.STAT{},
+		}
+	}
+	day := filepath.Join("STAT", "1980", "1", "5")
+	tests := []struct {
+		name      string
+		options   CSVOptions
+		wantLines map[string]int
+	}{
+		{
+			"Replaces earlier runs",
+			CSVOptions{},
+			map[string]int{"STAT.csv": 3},
+		},
+		{
+			"Appends to earlier runs",
+			CSVOptions{Append: true},
+			map[string]int{"STAT.csv": 5},
+		},
+		{
+			"Splits per day",
+			CSVOptions{Append: true, Split: SplitDay},
+			map[string]int{filepath.Join(day, "STAT.csv"): 5},
+		},
+		{
+			"Splits per rac-file",
+			CSVOptions{Split: SplitOrigin},
+			map[string]int{
+				filepath.Join(day, "File1.csv"): 3,
+				filepath.Join(day, "File2.csv"): 3,
+				filepath.Join(day, "File3.csv"): 3,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			// Each run is a separate invocation of rac
+			for _, run := range [][]string{{"File1.rac", "File2.rac"}, {"File3.rac"}} {
+				callback, teardown := DiskCallbackFactory(dir, false, true, NewImagePool(1), tt.options)
+				for _, origin := range run {
+					callback(record(origin))
+				}
+				teardown()
+			}
+			for name, lines := range tt.wantLines {
+				content, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Errorf("DiskCallbackFactory() didn't write %v: %v", name, err)
+					continue
+				}
+				if got := strings.Count(string(content), "\n"); got != lines {
+					t.Errorf("DiskCallbackFactory() wrote %v lines to %v, want %v", got, name, lines)
+				}
+			}
+		})
+	}
+}
+
+func TestCSVSplitFromName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    CSVSplit
+		wantErr bool
+	}{
+		{"none", SplitNone, false},
+		{"Day", SplitDay, false},
+		{"origin", SplitOrigin, false},
+		{"hour", SplitNone, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CSVSplitFromName(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CSVSplitFromName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CSVSplitFromName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
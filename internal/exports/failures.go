@@ -0,0 +1,33 @@
+package exports
+
+import (
+	"log"
+	"sync"
+)
+
+// failures keeps the first error writing the outputs of a sink, so its
+// teardown can return it, it is safe for concurrent use
+type failures struct {
+	mutex sync.Mutex
+	err   error
+}
+
+// add logs the error, if any, and keeps it if it is the first
+func (sink *failures) add(err error) {
+	if err == nil {
+		return
+	}
+	log.Println(err)
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if sink.err == nil {
+		sink.err = err
+	}
+}
+
+// first returns the first error added
+func (sink *failures) first() error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	return sink.err
+}
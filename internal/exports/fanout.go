@@ -0,0 +1,98 @@
+package exports
+
+import (
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+// Filter decides if a record should be passed on to a sink
+type Filter func(pkg *common.DataRecord) bool
+
+// Sink is an output together with the filter of the records it receives,
+// a nil Filter passes all records
+type Sink struct {
+	Callback common.Callback
+	Teardown common.CallbackTeardown
+	Filter   Filter
+}
+
+// StreamFilter returns a filter passing the records of the streams
+func StreamFilter(streams ...timeseries.OutStream) Filter {
+	wanted := make(map[timeseries.OutStream]bool)
+	for _, stream := range streams {
+		wanted[stream] = true
+	}
+	return func(pkg *common.DataRecord) bool {
+		return wanted[timeseries.OutStreamFromDataRecord(pkg)]
+	}
+}
+
+// TimeFilter returns a filter passing the records whose time by the key is
+// within from, inclusive, and to, exclusive, a zero time leaves that end open
+//
+// Records without TM header have no known time and are stopped.
+func TimeFilter(key timeseries.PartitionKey, from time.Time, to time.Time) Filter {
+	return func(pkg *common.DataRecord) bool {
+		if pkg.TMHeader == nil {
+			return false
+		}
+		recordTime := key.Time(pkg)
+		if !from.IsZero() && recordTime.Before(from) {
+			return false
+		}
+		return to.IsZero() || recordTime.Before(to)
+	}
+}
+
+// AllFilters returns a filter passing the records all filters pass, nil
+// filters pass all records
+func AllFilters(filters ...Filter) Filter {
+	var active []Filter
+	for _, filter := range filters {
+		if filter != nil {
+			active = append(active, filter)
+		}
+	}
+	if len(active) == 0 {
+		return nil
+	}
+	return func(pkg *common.DataRecord) bool {
+		for _, filter := range active {
+			if !filter(pkg) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// FanOutCallbackFactory returns a callback passing each record to every sink
+// whose filter accepts it
+//
+// The sinks receive the same data, so images are only decoded once however
+// many of them write the image. Teardown tears down the sinks in order and
+// returns the first error of them.
+func FanOutCallbackFactory(sinks []Sink) (common.Callback, common.CallbackTeardown) {
+	if len(sinks) == 1 && sinks[0].Filter == nil {
+		return sinks[0].Callback, sinks[0].Teardown
+	}
+	callback := func(pkg common.DataRecord) {
+		for _, sink := range sinks {
+			if sink.Filter == nil || sink.Filter(&pkg) {
+				sink.Callback(pkg)
+			}
+		}
+	}
+	teardown := func() error {
+		var err error
+		for _, sink := range sinks {
+			if sinkErr := sink.Teardown(); err == nil {
+				err = sinkErr
+			}
+		}
+		return err
+	}
+	return callback, teardown
+}
@@ -0,0 +1,146 @@
+package exports
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/innosat"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+func TestStreamFilter(t *testing.T) {
+	filter := StreamFilter(timeseries.HTR, timeseries.CCD)
+	tests := []struct {
+		name string
+		data common.Exporter
+		want bool
+	}{
+		{"Passes HTR", &aez.HTR{}, true},
+		{"Passes CCD", &aez.CCDImage{}, true},
+		{"Stops STAT", &aez.STAT{}, false},
+		{"Stops records without data", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filter(&common.DataRecord{Data: tt.data}); got != tt.want {
+				t.Errorf("StreamFilter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeFilter(t *testing.T) {
+	from := (&innosat.TMHeader{CUCTimeSeconds: 10}).Time(time.Time{})
+	to := (&innosat.TMHeader{CUCTimeSeconds: 20}).Time(time.Time{})
+	tests := []struct {
+		name   string
+		filter Filter
+		header *innosat.TMHeader
+		want   bool
+	}{
+		{"Passes start", TimeFilter(timeseries.TMHeaderTime, from, to), &innosat.TMHeader{CUCTimeSeconds: 10}, true},
+		{"Stops end", TimeFilter(timeseries.TMHeaderTime, from, to), &innosat.TMHeader{CUCTimeSeconds: 20}, false},
+		{"Stops before", TimeFilter(timeseries.TMHeaderTime, from, to), &innosat.TMHeader{CUCTimeSeconds: 9}, false},
+		{"Passes open end", TimeFilter(timeseries.TMHeaderTime, from, time.Time{}), &innosat.TMHeader{CUCTimeSeconds: 99}, true},
+		{"Stops records without header", TimeFilter(timeseries.TMHeaderTime, from, to), nil, false},
+		{
+			"Combines filters",
+			AllFilters(nil, TimeFilter(timeseries.TMHeaderTime, from, to), StreamFilter(timeseries.STAT)),
+			&innosat.TMHeader{CUCTimeSeconds: 15},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter(&common.DataRecord{TMHeader: tt.header, Data: &aez.HTR{}}); got != tt.want {
+				t.Errorf("Filter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if AllFilters(nil, nil) != nil {
+		t.Error("AllFilters() of nil filters is not nil")
+	}
+}
+
+type recordingSink struct {
+	records   []string
+	teardowns *[]string
+	name      string
+	err       error
+}
+
+func (sink *recordingSink) sink(filter Filter) Sink {
+	return Sink{
+		Callback: func(pkg common.DataRecord) {
+			sink.records = append(sink.records, timeseries.OutStreamFromDataRecord(&pkg).String())
+		},
+		Teardown: func() error {
+			*sink.teardowns = append(*sink.teardowns, sink.name)
+			return sink.err
+		},
+		Filter: filter,
+	}
+}
+
+func TestFanOutCallbackFactory(t *testing.T) {
+	var teardowns []string
+	all := recordingSink{name: "all", teardowns: &teardowns}
+	htr := recordingSink{name: "htr", teardowns: &teardowns}
+	callback, teardown := FanOutCallbackFactory([]Sink{
+		all.sink(nil),
+		htr.sink(StreamFilter(timeseries.HTR)),
+	})
+	for _, data := range []common.Exporter{&aez.STAT{}, &aez.HTR{}, &aez.PWR{}} {
+		callback(common.DataRecord{Data: data})
+	}
+	if err := teardown(); err != nil {
+		t.Errorf("FanOutCallbackFactory() teardown error = %v", err)
+	}
+	if want := []string{"STAT", "HTR", "PWR"}; !reflect.DeepEqual(all.records, want) {
+		t.Errorf("FanOutCallbackFactory() passed %v to unfiltered sink, want %v", all.records, want)
+	}
+	if want := []string{"HTR"}; !reflect.DeepEqual(htr.records, want) {
+		t.Errorf("FanOutCallbackFactory() passed %v to filtered sink, want %v", htr.records, want)
+	}
+	if want := []string{"all", "htr"}; !reflect.DeepEqual(teardowns, want) {
+		t.Errorf("FanOutCallbackFactory() tore down %v, want %v", teardowns, want)
+	}
+}
+
+func TestFanOutCallbackFactory_teardownError(t *testing.T) {
+	var teardowns []string
+	failing := recordingSink{name: "failing", teardowns: &teardowns, err: errors.New("disk full")}
+	other := recordingSink{name: "other", teardowns: &teardowns}
+	_, teardown := FanOutCallbackFactory([]Sink{failing.sink(nil), other.sink(nil)})
+	if err := teardown(); err != failing.err {
+		t.Errorf("FanOutCallbackFactory() teardown error = %v, want %v", err, failing.err)
+	}
+	if want := []string{"failing", "other"}; !reflect.DeepEqual(teardowns, want) {
+		t.Errorf("FanOutCallbackFactory() tore down %v, want %v", teardowns, want)
+	}
+}
+
+func TestFanOutCallbackFactory_sharesImages(t *testing.T) {
+	image := &aez.CCDImage{PackData: &aez.CCDImagePackData{
+		JPEGQ: aez.JPEGQUncompressed16bit,
+		NCOL:  2 - aez.NCOLStartOffset,
+		NROW:  1,
+	}}
+	var pngs [][]byte
+	sink := Sink{
+		Callback: func(pkg common.DataRecord) {
+			pngs = append(pngs, pkg.Data.(*aez.CCDImage).PNG(pkg.Buffer))
+		},
+		Teardown: func() error { return nil },
+	}
+	callback, teardown := FanOutCallbackFactory([]Sink{sink, sink})
+	callback(common.DataRecord{Data: image, Buffer: []byte{1, 0, 2, 0}})
+	teardown()
+	if len(pngs) != 2 || len(pngs[0]) == 0 || &pngs[0][0] != &pngs[1][0] {
+		t.Errorf("FanOutCallbackFactory() sinks got images %v, want one shared", pngs)
+	}
+}
@@ -0,0 +1,124 @@
+package exports
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+)
+
+// ImagePool decodes and encodes images with a bounded number of workers
+//
+// Submitting blocks while all workers are busy, so that a large rac-file
+// can't fill the memory with decoded images.
+type ImagePool struct {
+	slots chan struct{}
+	wg    sync.WaitGroup
+}
+
+// NewImagePool returns a pool with the number of workers, or as many as
+// there are CPUs if workers is less than one
+func NewImagePool(workers int) *ImagePool {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	return &ImagePool{slots: make(chan struct{}, workers)}
+}
+
+// Workers returns the number of workers of the pool
+func (pool *ImagePool) Workers() int {
+	return cap(pool.slots)
+}
+
+// Go runs the job once a worker is free and returns a channel that is
+// closed when the job is done
+func (pool *ImagePool) Go(job func()) <-chan struct{} {
+	pool.slots <- struct{}{}
+	pool.wg.Add(1)
+	done := make(chan struct{})
+	go func() {
+		defer func() {
+			<-pool.slots
+			pool.wg.Done()
+			close(done)
+		}()
+		job()
+	}()
+	return done
+}
+
+// Wait waits for all jobs to finish, including those of other writers
+// sharing the pool
+func (pool *ImagePool) Wait() {
+	pool.wg.Wait()
+}
+
+// imageJobs runs jobs of one writer on a pool that may be shared, so that
+// the writer can wait for its own jobs only
+type imageJobs struct {
+	pool *ImagePool
+	wg   sync.WaitGroup
+}
+
+// Go runs the job on the pool as ImagePool.Go
+func (jobs *imageJobs) Go(job func()) <-chan struct{} {
+	jobs.wg.Add(1)
+	return jobs.pool.Go(func() {
+		defer jobs.wg.Done()
+		job()
+	})
+}
+
+// Wait waits for the jobs given to Go to finish
+func (jobs *imageJobs) Wait() {
+	jobs.wg.Wait()
+}
+
+// imageQueue writes records in order, letting the pool encode the images of
+// CCD records before they are written
+type imageQueue struct {
+	pool    *ImagePool
+	records chan queuedRecord
+	done    chan struct{}
+}
+
+type queuedRecord struct {
+	pkg     common.DataRecord
+	encoded <-chan struct{}
+}
+
+// newImageQueue starts writing the queued records with write, the queue
+// holds at most twice as many records as the pool has workers
+func newImageQueue(pool *ImagePool, write func(pkg *common.DataRecord)) *imageQueue {
+	queue := imageQueue{
+		pool:    pool,
+		records: make(chan queuedRecord, 2*pool.Workers()),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer close(queue.done)
+		for record := range queue.records {
+			if record.encoded != nil {
+				<-record.encoded
+			}
+			write(&record.pkg)
+		}
+	}()
+	return &queue
+}
+
+// Push queues the record, blocking while the queue or the pool is full
+func (queue *imageQueue) Push(pkg common.DataRecord) {
+	record := queuedRecord{pkg: pkg}
+	if ccdImage, ok := pkg.Data.(*aez.CCDImage); ok {
+		record.encoded = queue.pool.Go(func() { ccdImage.PNG(pkg.Buffer) })
+	}
+	queue.records <- record
+}
+
+// Close waits for all queued records to be written
+func (queue *imageQueue) Close() {
+	close(queue.records)
+	<-queue.done
+}
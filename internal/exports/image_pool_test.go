@@ -0,0 +1,114 @@
+package exports
+
+import (
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+func TestNewImagePool(t *testing.T) {
+	tests := []struct {
+		name    string
+		workers int
+		want    int
+	}{
+		{"Uses workers", 3, 3},
+		{"Defaults to CPUs", 0, runtime.NumCPU()},
+		{"Defaults to CPUs if negative", -1, runtime.NumCPU()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewImagePool(tt.workers).Workers(); got != tt.want {
+				t.Errorf("NewImagePool().Workers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImagePool_Go(t *testing.T) {
+	pool := NewImagePool(2)
+	var running, maxRunning, finished int32
+	var lock sync.Mutex
+	for i := 0; i < 10; i++ {
+		pool.Go(func() {
+			now := atomic.AddInt32(&running, 1)
+			lock.Lock()
+			if now > maxRunning {
+				maxRunning = now
+			}
+			lock.Unlock()
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			atomic.AddInt32(&finished, 1)
+		})
+	}
+	pool.Wait()
+	if finished != 10 {
+		t.Errorf("ImagePool.Wait() returned after %v of 10 jobs", finished)
+	}
+	if maxRunning > 2 {
+		t.Errorf("ImagePool.Go() ran %v jobs at once, want at most 2", maxRunning)
+	}
+}
+
+func TestImageJobs_Wait(t *testing.T) {
+	pool := NewImagePool(2)
+	release := make(chan struct{})
+	defer close(release)
+	pool.Go(func() { <-release })
+	jobs := imageJobs{pool: pool}
+	var finished int32
+	for i := 0; i < 3; i++ {
+		jobs.Go(func() { atomic.AddInt32(&finished, 1) })
+	}
+	waited := make(chan struct{})
+	go func() {
+		jobs.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("imageJobs.Wait() waited for the job of another writer")
+	}
+	if finished != 3 {
+		t.Errorf("imageJobs.Wait() returned after %v of 3 jobs", finished)
+	}
+}
+
+func TestImageQueue(t *testing.T) {
+	pool := NewImagePool(2)
+	var written []string
+	queue := newImageQueue(pool, func(pkg *common.DataRecord) {
+		written = append(written, timeseries.OutStreamFromDataRecord(pkg).String())
+	})
+	records := []common.Exporter{
+		&aez.CCDImage{PackData: &aez.CCDImagePackData{
+			JPEGQ: aez.JPEGQUncompressed16bit,
+			NCOL:  2 - aez.NCOLStartOffset,
+			NROW:  1,
+		}},
+		&aez.STAT{},
+		&aez.HTR{},
+		&aez.CCDImage{PackData: &aez.CCDImagePackData{
+			JPEGQ: aez.JPEGQUncompressed16bit,
+			NCOL:  2 - aez.NCOLStartOffset,
+			NROW:  1,
+		}},
+		&aez.PWR{},
+	}
+	for _, data := range records {
+		queue.Push(common.DataRecord{Data: data, Buffer: []byte{1, 0, 2, 0}})
+	}
+	queue.Close()
+	if want := []string{"CCD", "STAT", "HTR", "CCD", "PWR"}; !reflect.DeepEqual(written, want) {
+		t.Errorf("imageQueue wrote %v, want %v", written, want)
+	}
+}
@@ -0,0 +1,185 @@
+package exports
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+)
+
+// SuccessName is the empty file marking a project directory as complete
+const SuccessName = "_SUCCESS"
+
+// ManifestName is the file listing the outputs of the run that completed
+const ManifestName = "_MANIFEST.json"
+
+// ManifestFile describes a complete output of a run
+type ManifestFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest lists the outputs of a run
+type Manifest struct {
+	Files []ManifestFile `json:"files"`
+}
+
+// StartRun forgets earlier outputs and removes the success marker of the
+// directory so that consumers don't pick it up while the run writes to it
+func StartRun(dir string) error {
+	common.ResetOutputs()
+	err := os.Remove(filepath.Join(dir, SuccessName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// WriteManifest writes the manifest of the outputs recorded below dir and
+// then the success marker
+//
+// Paths in the manifest are relative to dir.
+func WriteManifest(dir string) error {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	manifest := Manifest{Files: []ManifestFile{}}
+	for _, name := range common.RecordedOutputs() {
+		path, err := filepath.Abs(name)
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(root, path)
+		if err != nil || relative == ManifestName ||
+			relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+			continue
+		}
+		file, err := describeFile(path)
+		if err != nil {
+			return err
+		}
+		file.Path = filepath.ToSlash(relative)
+		manifest.Files = append(manifest.Files, file)
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = common.WriteFileAtomic(filepath.Join(dir, ManifestName), content)
+	if err != nil {
+		return err
+	}
+	return common.WriteFileAtomic(filepath.Join(dir, SuccessName), []byte{})
+}
+
+// UpdateManifest updates the manifest of the project holding dir, if there is
+// one, after the removed files were replaced by the written files
+func UpdateManifest(dir string, removed []string, written []string) error {
+	root, err := findManifestDir(dir)
+	if err != nil || root == "" {
+		return err
+	}
+	path := filepath.Join(root, ManifestName)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var manifest Manifest
+	err = json.Unmarshal(content, &manifest)
+	if err != nil {
+		return fmt.Errorf("could not parse %v: %v", path, err)
+	}
+	relativeTo := func(names []string) (map[string]bool, error) {
+		paths := make(map[string]bool)
+		for _, name := range names {
+			absolute, err := filepath.Abs(name)
+			if err != nil {
+				return nil, err
+			}
+			relative, err := filepath.Rel(root, absolute)
+			if err != nil {
+				return nil, err
+			}
+			paths[filepath.ToSlash(relative)] = true
+		}
+		return paths, nil
+	}
+	removedPaths, err := relativeTo(removed)
+	if err != nil {
+		return err
+	}
+	writtenPaths, err := relativeTo(written)
+	if err != nil {
+		return err
+	}
+	files := []ManifestFile{}
+	for _, file := range manifest.Files {
+		if !removedPaths[file.Path] && !writtenPaths[file.Path] {
+			files = append(files, file)
+		}
+	}
+	for relative := range writtenPaths {
+		file, err := describeFile(filepath.Join(root, filepath.FromSlash(relative)))
+		if err != nil {
+			return err
+		}
+		file.Path = relative
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	manifest.Files = files
+	content, err = json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return common.WriteFileAtomic(path, content)
+}
+
+// findManifestDir returns dir or the closest directory above it holding a
+// manifest, or "" if there is none
+func findManifestDir(dir string) (string, error) {
+	current, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		_, err := os.Stat(filepath.Join(current, ManifestName))
+		if err == nil {
+			return current, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return "", nil
+		}
+		current = parent
+	}
+}
+
+func describeFile(path string) (ManifestFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	return ManifestFile{Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
@@ -0,0 +1,61 @@
+package exports
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+)
+
+func TestWriteManifest(t *testing.T) {
+	defer common.ResetOutputs()
+	dir := t.TempDir()
+	project := filepath.Join(dir, "project")
+	if err := os.MkdirAll(filepath.Join(project, "CCD"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(project, SuccessName), []byte{}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := StartRun(project); err != nil {
+		t.Fatalf("StartRun() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(project, SuccessName)); !os.IsNotExist(err) {
+		t.Errorf("StartRun() kept %v", SuccessName)
+	}
+	for _, name := range []string{
+		filepath.Join(project, "CCD", "img.png"),
+		filepath.Join(project, "HTR.csv"),
+		filepath.Join(dir, "elsewhere.ndjson"),
+	} {
+		if err := common.WriteFileAtomic(name, []byte("abc")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := WriteManifest(project); err != nil {
+		t.Fatalf("WriteManifest() error = %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(project, ManifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		t.Fatalf("WriteManifest() wrote invalid json: %v", err)
+	}
+	// sha256 of "abc"
+	hash := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	want := []ManifestFile{
+		{Path: "CCD/img.png", Size: 3, SHA256: hash},
+		{Path: "HTR.csv", Size: 3, SHA256: hash},
+	}
+	if !reflect.DeepEqual(manifest.Files, want) {
+		t.Errorf("WriteManifest() listed %+v, want %+v", manifest.Files, want)
+	}
+	if _, err := os.Stat(filepath.Join(project, SuccessName)); err != nil {
+		t.Errorf("WriteManifest() wrote no %v: %v", SuccessName, err)
+	}
+}
@@ -0,0 +1,75 @@
+package exports
+
+import (
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+// NDJSONCallbackFactory returns a callback that writes one json object per
+// record and line, named as the CSV headers
+//
+// If writeImages the CCD images are included as base64 encoded PNG in
+// ImageData, encoded by the pool, and without writeTimeseries only such
+// images are written. The summary is logged so that out only holds json.
+func NDJSONCallbackFactory(
+	out io.Writer,
+	writeImages bool,
+	writeTimeseries bool,
+	pool *ImagePool,
+) (common.Callback, common.CallbackTeardown) {
+	errorStats := common.NewErrorStats()
+	var failed failures
+	write := func(pkg *common.DataRecord) {
+		var line []byte
+		var err error
+		if writeImages {
+			row := timeseries.GetParquetRow(pkg)
+			line, err = schema.MarshalJSONWithBytes(schema.CSVNamed(row.Columns), row.Values)
+		} else {
+			line, err = schema.MarshalJSON(schema.CSVNamed(pkg.Columns()), pkg.Values())
+		}
+		if err != nil {
+			log.Printf("could not encode json %v: %v", common.MakePackageInfo(pkg), err)
+			return
+		}
+		if _, err := out.Write(append(line, '\n')); err != nil {
+			failed.add(fmt.Errorf("could not write json %v: %v", common.MakePackageInfo(pkg), err))
+		}
+	}
+	var queue *imageQueue
+	if writeImages {
+		queue = newImageQueue(pool, write)
+	}
+
+	return func(pkg common.DataRecord) {
+			registerRecord(&errorStats, &pkg)
+			_, isImage := pkg.Data.(*aez.CCDImage)
+			if !writeTimeseries && !(writeImages && isImage) {
+				return
+			}
+			if pkg.Error != nil {
+				pkg.Error = fmt.Errorf(
+					"%s %s",
+					pkg.Error,
+					common.MakePackageInfo(&pkg),
+				)
+			}
+			if queue != nil {
+				queue.Push(pkg)
+			} else {
+				write(&pkg)
+			}
+		}, func() error {
+			if queue != nil {
+				queue.Close()
+			}
+			log.Println(errorStats.Summarize())
+			return failed.first()
+		}
+}
@@ -0,0 +1,107 @@
+package exports
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/innosat"
+)
+
+func TestNDJSONCallbackFactory(t *testing.T) {
+	type args struct {
+		writeImages     bool
+		writeTimeseries bool
+	}
+	records := []common.DataRecord{
+		{
+			Origin:   &common.OriginDescription{Name: "File1.rac"},
+			TMHeader: &innosat.TMHeader{},
+			Data:     &aez.STAT{MODE: 2},
+		},
+		{
+			Origin: &common.OriginDescription{Name: "File1.rac"},
+			Error:  errors.New("bad packet"),
+		},
+		{
+			Origin: &common.OriginDescription{Name: "File1.rac"},
+			Data: &aez.CCDImage{PackData: &aez.CCDImagePackData{
+				JPEGQ: aez.JPEGQUncompressed16bit,
+				NCOL:  2 - aez.NCOLStartOffset,
+				NROW:  1,
+			}},
+			Buffer: []byte{1, 0, 2, 0},
+		},
+	}
+	tests := []struct {
+		name       string
+		args       args
+		wantLines  int
+		wantImages bool
+	}{
+		{"Writes all records", args{false, true}, 3, false},
+		{"Writes all records with images", args{true, true}, 3, true},
+		{"Writes only images", args{true, false}, 1, true},
+		{"Writes nothing", args{false, false}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			callback, teardown := NDJSONCallbackFactory(buf, tt.args.writeImages, tt.args.writeTimeseries, NewImagePool(2))
+			for _, record := range records {
+				callback(record)
+			}
+			teardown()
+			lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+			if buf.Len() == 0 {
+				lines = nil
+			}
+			if len(lines) != tt.wantLines {
+				t.Fatalf("NDJSONCallbackFactory() wrote %v lines, want %v", len(lines), tt.wantLines)
+			}
+			for _, line := range lines {
+				var object map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &object); err != nil {
+					t.Fatalf("NDJSONCallbackFactory() wrote invalid json %v: %v", line, err)
+				}
+				if object["OriginFile"] != "File1.rac" {
+					t.Errorf("NDJSONCallbackFactory() OriginFile = %v, want File1.rac", object["OriginFile"])
+				}
+				if _, ok := object["ImageData"]; ok != (tt.wantImages && object["ImageName"] != nil) {
+					t.Errorf("NDJSONCallbackFactory() has ImageData %v, want %v", ok, tt.wantImages)
+				}
+			}
+		})
+	}
+}
+
+func TestNDJSONCallbackFactory_fieldNames(t *testing.T) {
+	buf := &bytes.Buffer{}
+	callback, _ := NDJSONCallbackFactory(buf, false, true, NewImagePool(2))
+	record := common.DataRecord{
+		Origin: &common.OriginDescription{Name: "File1.rac"},
+		Data:   &aez.STAT{MODE: 2},
+		Error:  errors.New("bad packet"),
+	}
+	callback(record)
+	var object map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &object); err != nil {
+		t.Fatalf("NDJSONCallbackFactory() wrote invalid json %v: %v", buf.String(), err)
+	}
+	for _, header := range record.CSVHeaders() {
+		if _, ok := object[header]; !ok {
+			t.Errorf("NDJSONCallbackFactory() lacks CSV header %v", header)
+		}
+	}
+	if object["MODE"] != 2.0 {
+		t.Errorf("NDJSONCallbackFactory() MODE = %v, want 2", object["MODE"])
+	}
+	errs, _ := object["Error"].([]interface{})
+	if len(errs) != 1 || !strings.HasPrefix(errs[0].(string), "bad packet") {
+		t.Errorf("NDJSONCallbackFactory() Error = %v, want bad packet", object["Error"])
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"sync"
 
 	"github.com/innosat-mats/rac-extract-payload/internal/common"
 	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
@@ -26,27 +25,44 @@ func parquetFileWriterFactoryCreator(
 		if err != nil {
 			return nil, fmt.Errorf("could not create output prefix '%v'", outPath)
 		}
-		return timeseries.NewParquet(outPath, pkg), nil
+		return timeseries.NewParquet(outPath, pkg)
 	}
 }
 
-// DiskCallbackFactory returns a callback for disk writes
+// ParquetCallbackFactory returns a callback for parquet disk writes
 func ParquetCallbackFactory(
 	output string,
-	wg *sync.WaitGroup,
+	pool *ImagePool,
+) (common.Callback, common.CallbackTeardown) {
+	return collectionCallbackFactory(output, parquetFileWriterFactoryCreator(output), pool)
+}
+
+// collectionCallbackFactory returns a callback writing each stream and origin
+// to its own file created by the factory, the images are encoded by the pool
+// ahead of writing
+func collectionCallbackFactory(
+	output string,
+	factory timeseries.ParquetFactory,
+	pool *ImagePool,
 ) (common.Callback, common.CallbackTeardown) {
 	var err error
-	timeseriesCollection := timeseries.NewParquetCollection(parquetFileWriterFactoryCreator(output))
+	var failed failures
+	timeseriesCollection := timeseries.NewParquetCollection(factory)
 	errorStats := common.NewErrorStats()
 
 	// Create Directory and File
 	err = os.MkdirAll(output, os.ModePerm)
 	if err != nil {
-		log.Printf("Could not create output directory '%v'", output)
+		failed.add(fmt.Errorf("could not create output directory '%v'", output))
 	}
 
+	queue := newImageQueue(pool, func(pkg *common.DataRecord) {
+		// Write to the dedicated target stream
+		failed.add(timeseriesCollection.Write(pkg))
+	})
+
 	callback := func(pkg common.DataRecord) {
-		errorStats.Register(pkg.Error)
+		registerRecord(&errorStats, &pkg)
 		if pkg.Error != nil {
 			pkg.Error = fmt.Errorf(
 				"%s %s",
@@ -57,18 +73,15 @@ func ParquetCallbackFactory(
 		}
 
 		if pkg.Data != nil {
-			// Write to the dedicated target stream
-			err = timeseriesCollection.Write(&pkg)
-			if err != nil {
-				log.Println(err)
-			}
+			queue.Push(pkg)
 		}
 	}
 
-	teardown := func() {
-		timeseriesCollection.CloseAll()
-		wg.Wait()
+	teardown := func() error {
+		queue.Close()
+		failed.add(timeseriesCollection.CloseAll())
 		log.Println(errorStats.Summarize())
+		return failed.first()
 	}
 
 	return callback, teardown
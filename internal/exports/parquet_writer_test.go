@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"sync"
 	"testing"
 
 	"github.com/innosat-mats/rac-extract-payload/internal/aez"
@@ -72,7 +71,7 @@ func Test_parquetName(t *testing.T) {
 
 func TestParquetCallbackFactoryCreator(t *testing.T) {
 	type args struct {
-		wg *sync.WaitGroup
+		pool *ImagePool
 	}
 	type wantFile struct {
 		prefix string
@@ -297,7 +296,7 @@ func TestParquetCallbackFactoryCreator(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.args.wg = &sync.WaitGroup{}
+			tt.args.pool = NewImagePool(2)
 			// Setup and cleanup of output directory
 			dir, err := os.MkdirTemp("", "innosat-mats")
 			if err != nil {
@@ -305,7 +304,7 @@ func TestParquetCallbackFactoryCreator(t *testing.T) {
 			}
 
 			// Produce callback and teardown
-			callback, teardown := ParquetCallbackFactory(dir, tt.args.wg)
+			callback, teardown := ParquetCallbackFactory(dir, tt.args.pool)
 
 			// Invoke callback and then teardown
 			for _, pkg := range tt.callbackArgs {
@@ -0,0 +1,89 @@
+package exports
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+// SQLiteCallbackFactory returns a callback for writes to a SQLite database
+//
+// Images are stored as PNG in the ImageData column unless imageFiles, then
+// they are written as PNG-files next to the database, named as in ImageName.
+// Either way they are encoded by the pool.
+func SQLiteCallbackFactory(
+	path string,
+	writeImages bool,
+	imageFiles bool,
+	pool *ImagePool,
+) (common.Callback, common.CallbackTeardown, error) {
+	dir := filepath.Dir(path)
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create output directory '%v'", dir)
+	}
+	db, err := timeseries.NewSQLite(path, writeImages && !imageFiles)
+	if err != nil {
+		return nil, nil, err
+	}
+	errorStats := common.NewErrorStats()
+	var failed failures
+	write := func(pkg *common.DataRecord) {
+		// Write to the dedicated target table
+		failed.add(db.Write(pkg))
+	}
+	images := imageJobs{pool: pool}
+	var queue *imageQueue
+	if writeImages && !imageFiles {
+		queue = newImageQueue(pool, write)
+	}
+
+	callback := func(pkg common.DataRecord) {
+		registerRecord(&errorStats, &pkg)
+		if pkg.Error != nil {
+			pkg.Error = fmt.Errorf(
+				"%s %s",
+				pkg.Error,
+				common.MakePackageInfo(&pkg),
+			)
+			log.Println(pkg.Error)
+		}
+
+		if ccdImage, ok := pkg.Data.(*aez.CCDImage); ok && writeImages && imageFiles {
+			images.Go(func() {
+				imgFileName := ccdImage.FullImageName(dir)
+				pngImage := ccdImage.PNG(pkg.Buffer)
+				if pngImage == nil {
+					return
+				}
+				err := common.WriteFileAtomic(imgFileName, pngImage, pkg.OriginName())
+				if err != nil {
+					failed.add(fmt.Errorf("failed writing %s: %s", imgFileName, err))
+				}
+			})
+		}
+
+		if pkg.Data != nil && queue != nil {
+			queue.Push(pkg)
+		} else if pkg.Data != nil {
+			write(&pkg)
+		}
+	}
+
+	teardown := func() error {
+		if queue != nil {
+			queue.Close()
+		}
+		images.Wait()
+		failed.add(db.Close())
+		log.Println(errorStats.Summarize())
+		return failed.first()
+	}
+
+	return callback, teardown, nil
+}
@@ -0,0 +1,46 @@
+package exports
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/innosat"
+	"github.com/innosat-mats/rac-extract-payload/internal/ramses"
+)
+
+func TestSQLiteCallbackFactory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "rac.db")
+	callback, teardown, err := SQLiteCallbackFactory(path, false, false, NewImagePool(2))
+	if err != nil {
+		t.Fatalf("SQLiteCallbackFactory() error = %v", err)
+	}
+	for _, data := range []common.Exporter{&aez.STAT{}, &aez.HTR{}} {
+		callback(common.DataRecord{
+			Origin:         &common.OriginDescription{Name: "File1.rac"},
+			RamsesHeader:   &ramses.Ramses{},
+			RamsesTMHeader: &ramses.TMHeader{},
+			SourceHeader:   &innosat.SourcePacketHeader{},
+			TMHeader:       &innosat.TMHeader{},
+			Data:           data,
+		})
+	}
+	teardown()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("SQLiteCallbackFactory() expected to produce file '%v': %v", path, err)
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, table := range []string{"STAT", "HTR"} {
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM "` + table + `"`).Scan(&count); err != nil || count != 1 {
+			t.Errorf("SQLiteCallbackFactory() wrote %v rows to %v (%v), want 1", count, table, err)
+		}
+	}
+}
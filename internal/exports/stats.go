@@ -0,0 +1,16 @@
+package exports
+
+import (
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/limits"
+)
+
+// registerRecord adds the record to the run statistics, alarms are counted
+// separately from packages
+func registerRecord(stats *common.ErrorStats, pkg *common.DataRecord) {
+	if alarm, ok := pkg.Data.(*limits.Alarm); ok {
+		stats.RegisterAlarm(alarm.Summary())
+		return
+	}
+	stats.Register(pkg.Error)
+}
@@ -15,7 +15,7 @@ func StdoutCallbackFactory(
 	errorStats := common.NewErrorStats()
 
 	return func(pkg common.DataRecord) {
-			errorStats.Register(pkg.Error)
+			registerRecord(&errorStats, &pkg)
 			if writeTimeseries {
 				if pkg.Error != nil {
 					pkg.Error = fmt.Errorf(
@@ -26,7 +26,8 @@ func StdoutCallbackFactory(
 				}
 				fmt.Fprintf(out, "%+v\n", pkg)
 			}
-		}, func() {
-			fmt.Fprint(out, errorStats.Summarize())
+		}, func() error {
+			_, err := fmt.Fprint(out, errorStats.Summarize())
+			return err
 		}
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/limits"
 )
 
 func Test_StdoutCallbackFactory(t *testing.T) {
@@ -40,3 +41,15 @@ func Test_StdoutCallbackFactory(t *testing.T) {
 		})
 	}
 }
+
+func Test_StdoutCallbackFactory_countsAlarms(t *testing.T) {
+	buf := &bytes.Buffer{}
+	callback, teardown := StdoutCallbackFactory(buf, false)
+	callback(common.DataRecord{})
+	callback(common.DataRecord{Data: &limits.Alarm{AlarmField: "HTR1A", AlarmSeverity: limits.Hard, AlarmBound: limits.Low}})
+	teardown()
+	got := buf.String()
+	if !strings.Contains(got, "Total Packages:\t1\n") || !strings.Contains(got, "1       HARD LOW HTR1A") {
+		t.Errorf("StdoutCallbackFactory() summary = %v, want one package and one alarm", got)
+	}
+}
@@ -10,12 +10,18 @@ import (
 	"github.com/innosat-mats/rac-extract-payload/internal/common"
 )
 
-// DecodeAEZ parses AEZ packages
-func DecodeAEZ(target chan<- common.DataRecord, source <-chan common.DataRecord) {
+// DecodeAEZ parses AEZ packages, converting housekeeping data with the
+// calibration valid at the time of each packet
+func DecodeAEZ(
+	target chan<- common.DataRecord,
+	source <-chan common.DataRecord,
+	calibrations aez.Calibrations,
+) {
 	defer close(target)
 	var exportable common.Exporter
 	var err error
 	var buffer *bytes.Buffer
+	var statRates aez.STATRateTracker
 	for sourcePacket := range source {
 		if sourcePacket.Error != nil {
 			target <- sourcePacket
@@ -46,6 +52,14 @@ func DecodeAEZ(target chan<- common.DataRecord, source <-chan common.DataRecord)
 		if err != io.EOF {
 			sourcePacket.Error = err
 		}
+		if calibrated, ok := exportable.(aez.Calibrated); ok {
+			calibrated.SetCalibration(
+				calibrations.Select(sourcePacket.TMHeader.Time(aez.GpsTime)),
+			)
+		}
+		if stat, ok := exportable.(*aez.STAT); ok && sourcePacket.Error == nil {
+			statRates.Update(stat)
+		}
 		sourcePacket.Data = exportable
 		sourcePacket.Buffer = buffer.Bytes()
 		target <- sourcePacket
@@ -3,9 +3,11 @@ package extractors
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"io"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/innosat-mats/rac-extract-payload/internal/aez"
 	"github.com/innosat-mats/rac-extract-payload/internal/common"
@@ -100,7 +102,7 @@ func TestDecodeAEZ(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			source := make(chan common.DataRecord)
 			target := make(chan common.DataRecord)
-			go DecodeAEZ(target, source)
+			go DecodeAEZ(target, source, nil)
 			source <- tt.arg
 			close(source)
 			got := <-target
@@ -122,3 +124,75 @@ func TestDecodeAEZ(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeAEZ_STATRates(t *testing.T) {
+	makeSTAT := func(stat aez.STAT) common.DataRecord {
+		// STAT holds derived state so only the packet fields are written
+		var buf bytes.Buffer
+		val := reflect.ValueOf(stat)
+		for i := 0; i < val.NumField(); i++ {
+			if val.Type().Field(i).IsExported() {
+				binary.Write(&buf, binary.LittleEndian, val.Field(i).Interface())
+			}
+		}
+		return common.DataRecord{
+			TMHeader: &innosat.TMHeader{PUS: 16, ServiceType: 3, ServiceSubType: 25},
+			Buffer:   makeInstrumentData(uint16(aez.SIDSTAT), buf.Bytes(), []byte{}),
+		}
+	}
+	source := make(chan common.DataRecord, 3)
+	target := make(chan common.DataRecord, 3)
+	source <- makeSTAT(aez.STAT{SPID: 1, TS: 10, EDACN: 10})
+	source <- makeSTAT(aez.STAT{SPID: 1, TS: 12, EDACN: 20})
+	source <- makeSTAT(aez.STAT{SPID: 2, TS: 1, EDACN: 0})
+	close(source)
+	DecodeAEZ(target, source, nil)
+	want := []string{"NaN", "5", "NaN"}
+	wantReset := []bool{false, false, true}
+	idx := 0
+	for record := range target {
+		stat, ok := record.Data.(*aez.STAT)
+		if !ok {
+			t.Errorf("DecodeAEZ() record %v data = %v, want STAT", idx, record.Data)
+			return
+		}
+		rates := stat.Rates()
+		if got := fmt.Sprintf("%v", rates.EDACNRate); got != want[idx] {
+			t.Errorf("DecodeAEZ() record %v EDACNRate = %v, want %v", idx, got, want[idx])
+		}
+		if rates.CounterReset != wantReset[idx] {
+			t.Errorf("DecodeAEZ() record %v CounterReset = %v, want %v", idx, rates.CounterReset, wantReset[idx])
+		}
+		idx++
+	}
+}
+
+func TestDecodeAEZ_calibrations(t *testing.T) {
+	early := aez.BuiltinCalibration
+	early.ID = "early"
+	early.ValidTo = aez.GpsTime.Add(100 * time.Second)
+	makeHTR := func(seconds uint32) common.DataRecord {
+		return common.DataRecord{
+			TMHeader: &innosat.TMHeader{PUS: 16, ServiceType: 3, ServiceSubType: 25, CUCTimeSeconds: seconds},
+			Buffer:   makeInstrumentData(uint16(aez.SIDHTR), [12]uint16{}, []byte{}),
+		}
+	}
+	source := make(chan common.DataRecord, 2)
+	target := make(chan common.DataRecord, 2)
+	source <- makeHTR(10)
+	source <- makeHTR(200)
+	close(source)
+	DecodeAEZ(target, source, aez.Calibrations{early})
+	want := []string{"early", "builtin"}
+	idx := 0
+	for record := range target {
+		htr, ok := record.Data.(*aez.HTR)
+		if !ok {
+			t.Fatalf("DecodeAEZ() record %v data = %v, want HTR", idx, record.Data)
+		}
+		if got := htr.Report().Calibration; got != want[idx] {
+			t.Errorf("DecodeAEZ() record %v calibration = %v, want %v", idx, got, want[idx])
+		}
+		idx++
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/innosat-mats/rac-extract-payload/internal/common"
 )
@@ -22,8 +23,59 @@ var ErrNoDregsPath error = errors.New("no dregs path specified") // error return
 //	between batch runs. Dregs is short for "Data Remaining after Extracting
 //	Group of Source packets"
 type Dregs struct {
-	Path    string // Path to dregs directory
-	MaxDiff int64  // Maximum deviation allowed for match [ns]
+	Path    string       // Path to dregs directory
+	MaxDiff int64        // Maximum deviation allowed for match [ns]
+	Memory  *DregsMemory // Keeps dregs between batch runs of the same process if set
+}
+
+// DregsMemory holds dregs in memory, a dregs is forgotten once matched or
+// once a packet arrives more than the maximum deviation after it
+type DregsMemory struct {
+	lock    sync.Mutex
+	buffers map[int64][]byte
+}
+
+// NewDregsMemory returns an empty dregs memory
+func NewDregsMemory() *DregsMemory {
+	return &DregsMemory{buffers: make(map[int64][]byte)}
+}
+
+func (memory *DregsMemory) put(timestamp int64, buffer []byte, maxDiff int64) {
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	memory.evict(timestamp, maxDiff)
+	memory.buffers[timestamp] = append([]byte{}, buffer...)
+}
+
+// take returns and forgets the best match of the timestamp if any
+func (memory *DregsMemory) take(timestamp int64, maxDiff int64) ([]byte, bool) {
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	memory.evict(timestamp, maxDiff)
+	var best int64
+	bestDiff := maxDiff
+	for t := range memory.buffers {
+		diff := timestamp - t
+		if diff > 0 && diff < bestDiff {
+			bestDiff = diff
+			best = t
+		}
+	}
+	if bestDiff == maxDiff {
+		return nil, false
+	}
+	buffer := memory.buffers[best]
+	delete(memory.buffers, best)
+	return buffer, true
+}
+
+// evict forgets the dregs too old to match a packet of the timestamp
+func (memory *DregsMemory) evict(timestamp int64, maxDiff int64) {
+	for t := range memory.buffers {
+		if timestamp-t >= maxDiff {
+			delete(memory.buffers, t)
+		}
+	}
 }
 
 func (dregs *Dregs) getDregsFileName(data common.DataRecord) string {
@@ -34,8 +86,14 @@ func (dregs *Dregs) getDregsFileName(data common.DataRecord) string {
 	)
 }
 
-// DumpDregs Write buffer dregs file to specified directory
+// DumpDregs Write buffer dregs file to specified directory and memory
 func (dregs *Dregs) DumpDregs(data common.DataRecord) error {
+	if dregs.Memory != nil {
+		dregs.Memory.put(data.TMHeader.Nanoseconds(), data.Buffer, dregs.MaxDiff)
+		if dregs.Path == "" {
+			return nil
+		}
+	}
 	if dregs.Path == "" {
 		return ErrNoDregsPath
 	}
@@ -53,9 +111,17 @@ func (dregs *Dregs) DumpDregs(data common.DataRecord) error {
 	return nil
 }
 
-// GetDregs Read buffer dregs and return best match (if any)
+// GetDregs Read buffer dregs and return best match (if any), memory first
 func (dregs *Dregs) GetDregs(timestamp int64) ([]byte, error) {
+	if dregs.Memory != nil {
+		if buffer, ok := dregs.Memory.take(timestamp, dregs.MaxDiff); ok {
+			return buffer, nil
+		}
+	}
 	if dregs.Path == "" {
+		if dregs.Memory != nil {
+			return nil, fmt.Errorf("found no matching dregs for timestamp %v", timestamp)
+		}
 		return nil, ErrNoDregsPath
 	}
 
@@ -173,3 +173,50 @@ func TestDregs_GetDregs(t *testing.T) {
 		})
 	}
 }
+
+func TestDregs_Memory(t *testing.T) {
+	dregs := Dregs{MaxDiff: MaxDeviationNanos, Memory: NewDregsMemory()}
+	record := common.DataRecord{
+		TMHeader: &innosat.TMHeader{CUCTimeSeconds: 42},
+		Buffer:   []byte("Hello"),
+	}
+	if err := dregs.DumpDregs(record); err != nil {
+		t.Fatalf("Dregs.DumpDregs() error = %v", err)
+	}
+	record.Buffer[0] = 'J'
+	if _, err := dregs.GetDregs(41 * secondsToNano); err == nil {
+		t.Error("Dregs.GetDregs() matched dregs written after the timestamp")
+	}
+	got, err := dregs.GetDregs(43 * secondsToNano)
+	if err != nil {
+		t.Fatalf("Dregs.GetDregs() error = %v", err)
+	}
+	if string(got) != "Hello" {
+		t.Errorf("Dregs.GetDregs() = %q, want %q", got, "Hello")
+	}
+	if _, err := dregs.GetDregs(43 * secondsToNano); err == nil {
+		t.Error("Dregs.GetDregs() matched the same dregs twice")
+	}
+}
+
+func TestDregs_Memory_maxDiff(t *testing.T) {
+	dregs := Dregs{MaxDiff: 10 * secondsToNano, Memory: NewDregsMemory()}
+	for _, seconds := range []uint32{10, 100} {
+		record := common.DataRecord{
+			TMHeader: &innosat.TMHeader{CUCTimeSeconds: seconds},
+			Buffer:   []byte("Hello"),
+		}
+		if err := dregs.DumpDregs(record); err != nil {
+			t.Fatalf("Dregs.DumpDregs() error = %v", err)
+		}
+	}
+	if got := len(dregs.Memory.buffers); got != 1 {
+		t.Errorf("DregsMemory holds %v dregs, want the one still within MaxDiff", got)
+	}
+	if _, err := dregs.GetDregs(120 * secondsToNano); err == nil {
+		t.Error("Dregs.GetDregs() matched dregs older than MaxDiff")
+	}
+	if got := len(dregs.Memory.buffers); got != 0 {
+		t.Errorf("DregsMemory holds %v dregs after a packet past MaxDiff, want none", got)
+	}
+}
@@ -3,6 +3,7 @@ package extractors
 import (
 	"sync"
 
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
 	"github.com/innosat-mats/rac-extract-payload/internal/common"
 )
 
@@ -10,15 +11,18 @@ import (
 type ExtractFunction func(
 	callback common.Callback,
 	dregs Dregs,
+	calibrations aez.Calibrations,
 	streamBatch ...StreamBatch,
 )
 
 const channelBufferSize int = 1024
 
-// ExtractData reads Ramses data packages and extract the instrument data.
+// ExtractData reads Ramses data packages and extract the instrument data,
+// housekeeping data is converted using the calibrations.
 func ExtractData(
 	callback common.Callback,
 	dregs Dregs,
+	calibrations aez.Calibrations,
 	streamBatch ...StreamBatch,
 ) {
 	var waitGroup sync.WaitGroup
@@ -29,7 +33,7 @@ func ExtractData(
 
 	go DecodeRamses(ramsesChannel, streamBatch...)
 	go Aggregator(aggregatorChannel, innosatChannel, dregs)
-	go DecodeAEZ(aezChannel, aggregatorChannel)
+	go DecodeAEZ(aezChannel, aggregatorChannel, calibrations)
 
 	waitGroup.Add(1)
 	go func() {
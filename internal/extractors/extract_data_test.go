@@ -100,6 +100,7 @@ func Example() {
 	ExtractData(
 		simpleOutput,
 		Dregs{},
+		nil,
 		StreamBatch{reader1, &common.OriginDescription{Name: "Set1", ProcessingDate: innosat.Epoch}},
 		StreamBatch{reader2, &common.OriginDescription{Name: "Set2", ProcessingDate: innosat.Epoch}},
 	)
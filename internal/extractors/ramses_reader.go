@@ -107,7 +107,7 @@ func getRecord(stream StreamBatch) (common.DataRecord, bool) {
 	header.Length -= uint16(binary.Size(tmHeader))
 
 	payload := make([]byte, header.Length)
-	n, _ := stream.Buf.Read(payload)
+	n, _ := io.ReadFull(stream.Buf, payload)
 	if n != int(header.Length) {
 		return common.DataRecord{
 			Origin:         stream.Origin,
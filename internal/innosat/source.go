@@ -6,7 +6,7 @@ import (
 	"fmt"
 	"io"
 
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 // SourcePacketHeaderType is the type of the source packet (TM/TC)
@@ -113,18 +113,20 @@ func (sph *SourcePacketHeader) CSVSpecifications() []string {
 	return []string{"INNOSAT", Specification}
 }
 
-// CSVHeaders returns the header row
-func (sph *SourcePacketHeader) CSVHeaders() []string {
-	return []string{
-		"SPSequenceCount",
-	}
+type sourcePacketHeaderColumns struct {
+	SPSequenceCount uint16 `description:"A counter that increases with each packet, may never short cycle and should wrap around to zero after 2^14-1"`
 }
 
-// CSVRow returns the data row
-func (sph *SourcePacketHeader) CSVRow() []string {
-	return []string{
-		fmt.Sprintf("%v", sph.PacketSequenceControl.SequenceCount()),
-	}
+// Columns returns the exported columns
+func (sph *SourcePacketHeader) Columns() []schema.Column {
+	return schema.Columns(sourcePacketHeaderColumns{})
+}
+
+// Values returns the exported values
+func (sph *SourcePacketHeader) Values() []interface{} {
+	return schema.Values(sourcePacketHeaderColumns{
+		SPSequenceCount: sph.PacketSequenceControl.SequenceCount(),
+	})
 }
 
 // MarshalJSON makes a custom json of what is of interest in the struct
@@ -137,8 +139,3 @@ func (sph *SourcePacketHeader) MarshalJSON() ([]byte, error) {
 		SPSequenceCount: sph.PacketSequenceControl.SequenceCount(),
 	})
 }
-
-// SetParquet sets the parquet representation of the SourcePacketHeader
-func (sph *SourcePacketHeader) SetParquet(row *parquetrow.ParquetRow) {
-	row.SPSequenceCount = sph.PacketSequenceControl.SequenceCount()
-}
@@ -5,7 +5,7 @@ import (
 	"reflect"
 	"testing"
 
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 func TestVersion(t *testing.T) {
@@ -182,8 +182,8 @@ func TestSourcePacketHeader_CSVHeaders(t *testing.T) {
 				PacketSequenceControl: tt.fields.PacketSequenceControl,
 				PacketLength:          tt.fields.PacketLength,
 			}
-			if got := sph.CSVHeaders(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("SourcePacketHeader.CSVHeaders() = %v, want %v", got, tt.want)
+			if got := schema.CSVHeaders(sph.Columns()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schema.CSVHeaders() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -213,8 +213,8 @@ func TestSourcePacketHeader_CSVRow(t *testing.T) {
 				PacketSequenceControl: tt.fields.PacketSequenceControl,
 				PacketLength:          tt.fields.PacketLength,
 			}
-			if got := sph.CSVRow(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("SourcePacketHeader.CSVRow() = %v, want %v", got, tt.want)
+			if got := schema.CSVRow(sph.Columns(), sph.Values()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schema.CSVRow() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -258,13 +258,24 @@ func TestSourcePacketHeader_MarshalJSON(t *testing.T) {
 	}
 }
 
-func TestSourcePacketHeader_SetParquet(t *testing.T) {
+func TestSourcePacketHeader_Values(t *testing.T) {
 	source := SourcePacketHeader{41, 42, 43}
-	want := parquetrow.ParquetRow{
-		SPSequenceCount: 42,
+	want := map[string]interface{}{
+		"SPSequenceCount": int64(42),
 	}
-	row := parquetrow.ParquetRow{}
-	if source.SetParquet(&row); !reflect.DeepEqual(row, want) {
-		t.Errorf("SourcePacketHeader.SetParquet() = %v, want %v", row, want)
+	columns := source.Columns()
+	values := source.Values()
+	if len(values) != len(columns) {
+		t.Fatalf("SourcePacketHeader.Values() has %v values, want %v", len(values), len(columns))
+	}
+	for name, value := range want {
+		idx := schema.Index(columns, name)
+		if idx < 0 {
+			t.Errorf("SourcePacketHeader.Columns() lacks %v", name)
+			continue
+		}
+		if !reflect.DeepEqual(values[idx], value) {
+			t.Errorf("SourcePacketHeader.Values() %v = %v, want %v", name, values[idx], value)
+		}
 	}
 }
@@ -3,13 +3,12 @@ package innosat
 import (
 	"encoding/binary"
 	"encoding/json"
-	"fmt"
 	"io"
 	"time"
 
 	"github.com/innosat-mats/rac-extract-payload/internal/aez"
 	"github.com/innosat-mats/rac-extract-payload/internal/ccsds"
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 type pus uint8
@@ -67,21 +66,22 @@ func (header *TMHeader) IsTCVerification() bool {
 			header.ServiceSubType == TCExecFailure)
 }
 
-// CSVHeaders returns the header row
-func (header *TMHeader) CSVHeaders() []string {
-	return []string{
-		"TMHeaderTime",
-		"TMHeaderNanoseconds",
-	}
+type tmHeaderColumns struct {
+	TMHeaderTime        time.Time `description:"The time of the TM packet creation"`
+	TMHeaderNanoseconds int64     `unit:"ns" description:"The time of the TM packet creation since epoch"`
 }
 
-// CSVRow returns the data row
-func (header *TMHeader) CSVRow() []string {
-	tmTime := header.Time(aez.GpsTime)
-	return []string{
-		tmTime.Format(time.RFC3339Nano),
-		fmt.Sprintf("%v", header.Nanoseconds()),
-	}
+// Columns returns the exported columns
+func (header *TMHeader) Columns() []schema.Column {
+	return schema.Columns(tmHeaderColumns{})
+}
+
+// Values returns the exported values
+func (header *TMHeader) Values() []interface{} {
+	return schema.Values(tmHeaderColumns{
+		TMHeaderTime:        header.Time(aez.GpsTime),
+		TMHeaderNanoseconds: header.Nanoseconds(),
+	})
 }
 
 // MarshalJSON makes a custom json of what is of interest in the struct
@@ -94,9 +94,3 @@ func (header *TMHeader) MarshalJSON() ([]byte, error) {
 		TMHeaderNanoseconds: header.Nanoseconds(),
 	})
 }
-
-// SetParquet returns the parquet representation of the TMHeader
-func (header *TMHeader) SetParquet(row *parquetrow.ParquetRow) {
-	row.TMHeaderTime = header.Time(aez.GpsTime)
-	row.TMHeaderNanoseconds = header.Nanoseconds()
-}
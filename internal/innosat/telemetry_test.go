@@ -7,7 +7,7 @@ import (
 
 	"github.com/innosat-mats/rac-extract-payload/internal/aez"
 	"github.com/innosat-mats/rac-extract-payload/internal/ccsds"
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 func TestTMHeader_PUSVersion(t *testing.T) {
@@ -204,8 +204,8 @@ func TestTMHeader_CSVHeaders(t *testing.T) {
 				CUCTimeSeconds:  tt.fields.CUCTimeSeconds,
 				CUCTimeFraction: tt.fields.CUCTimeFraction,
 			}
-			if got := tmdfh.CSVHeaders(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("TMHeader.CSVHeaders() = %v, want %v", got, tt.want)
+			if got := schema.CSVHeaders(tmdfh.Columns()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schema.CSVHeaders() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -239,8 +239,8 @@ func TestTMHeader_CSVRow(t *testing.T) {
 				CUCTimeSeconds:  tt.fields.CUCTimeSeconds,
 				CUCTimeFraction: tt.fields.CUCTimeFraction,
 			}
-			if got := tmdfh.CSVRow(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("TMHeader.CSVRow() = %v, want %v", got, tt.want)
+			if got := schema.CSVRow(tmdfh.Columns(), tmdfh.Values()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schema.CSVRow() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -325,17 +325,28 @@ func TestTMHeader_IsTCVerification(t *testing.T) {
 	}
 }
 
-func TestTMHeader_SetParquet(t *testing.T) {
+func TestTMHeader_Values(t *testing.T) {
 	header := TMHeader{
 		CUCTimeSeconds:  42,
 		CUCTimeFraction: 0xc000,
 	}
-	want := parquetrow.ParquetRow{
-		TMHeaderTime:        header.Time(aez.GpsTime),
-		TMHeaderNanoseconds: 42750000000,
+	want := map[string]interface{}{
+		"TMHeaderTime":        header.Time(aez.GpsTime),
+		"TMHeaderNanoseconds": int64(42750000000),
 	}
-	row := parquetrow.ParquetRow{}
-	if header.SetParquet(&row); !reflect.DeepEqual(row, want) {
-		t.Errorf("TMHeader.SetParquet() = %v, want %v", row, want)
+	columns := header.Columns()
+	values := header.Values()
+	if len(values) != len(columns) {
+		t.Fatalf("TMHeader.Values() has %v values, want %v", len(values), len(columns))
+	}
+	for name, value := range want {
+		idx := schema.Index(columns, name)
+		if idx < 0 {
+			t.Errorf("TMHeader.Columns() lacks %v", name)
+			continue
+		}
+		if !reflect.DeepEqual(values[idx], value) {
+			t.Errorf("TMHeader.Values() %v = %v, want %v", name, values[idx], value)
+		}
 	}
 }
@@ -0,0 +1,216 @@
+package ledger
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+
+	// Registers the sqlite3 database driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Outcome tells how processing an input ended
+type Outcome string
+
+const (
+	// Done means the input was processed and its outputs written
+	Done Outcome = "done"
+	// Failed means the run processing the input failed or that records of
+	// the input could not be decoded
+	Failed Outcome = "failed"
+)
+
+const ledgerTable = `CREATE TABLE IF NOT EXISTS "inputs" (
+	"SHA256" TEXT PRIMARY KEY,
+	"Name" TEXT,
+	"Processed" TEXT,
+	"Outcome" TEXT,
+	"Error" TEXT,
+	"Outputs" TEXT,
+	"Version" TEXT,
+	"Host" TEXT,
+	"Parameters" TEXT
+)`
+
+// Entry is what the ledger knows of a processed input
+type Entry struct {
+	SHA256     string
+	Name       string
+	Processed  time.Time // When the run ended
+	Outcome    Outcome
+	Error      string   // Why processing the input failed if it did
+	Outputs    []string // Files written with records of the input
+	Version    string
+	Host       string
+	Parameters string
+}
+
+// Result is how processing an input of a run ended
+type Result struct {
+	Err     error    // Why processing the input failed, nil if it didn't
+	Outputs []string // Files written with records of the input
+}
+
+// Filter selects entries of the ledger, empty fields select all
+type Filter struct {
+	Name    string // Pattern of the base name as for filepath.Match
+	SHA256  string
+	Outcome Outcome
+	Since   time.Time
+	Until   time.Time
+}
+
+// Ledger is a persistent record of the inputs processed keyed by their
+// sha256, so that the same rac-file is only processed once
+type Ledger struct {
+	db *sql.DB
+}
+
+// Open opens or creates the ledger at the path
+func Open(path string) (*Ledger, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(ledgerTable); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not prepare ledger %v: %v", path, err)
+	}
+	return &Ledger{db: db}, nil
+}
+
+// Close closes the ledger
+func (ledger *Ledger) Close() error {
+	return ledger.db.Close()
+}
+
+// Done returns if the input with the sha256 was processed successfully
+func (ledger *Ledger) Done(sha256 string) (bool, error) {
+	var outcome string
+	err := ledger.db.QueryRow(`SELECT "Outcome" FROM "inputs" WHERE "SHA256" = ?`, sha256).Scan(&outcome)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return Outcome(outcome) == Done, err
+}
+
+// Record records the inputs of the run with their results by input name,
+// replacing what was known of them
+//
+// Inputs without result are recorded as done without outputs.
+func (ledger *Ledger) Record(run *common.RunDescription, results map[string]Result, processed time.Time) error {
+	tx, err := ledger.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, input := range run.Inputs {
+		if input.SHA256 == "" {
+			continue
+		}
+		result := results[input.Name]
+		outcome := Done
+		message := ""
+		if result.Err != nil {
+			outcome = Failed
+			message = result.Err.Error()
+		}
+		outputs := result.Outputs
+		if outputs == nil {
+			outputs = []string{}
+		}
+		encodedOutputs, err := json.Marshal(outputs)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		_, err = tx.Exec(
+			`INSERT OR REPLACE INTO "inputs" VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			input.SHA256,
+			input.Name,
+			processed.UTC().Format(timeseries.SQLiteTimeFormat),
+			string(outcome),
+			message,
+			string(encodedOutputs),
+			run.Version,
+			run.Host,
+			strings.Join(run.Parameters, " "),
+		)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("could not record %v in ledger: %v", input.Name, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Entries returns the entries selected by the filter ordered by when they
+// were processed
+func (ledger *Ledger) Entries(filter Filter) ([]Entry, error) {
+	query := `SELECT * FROM "inputs" WHERE 1 = 1`
+	var args []interface{}
+	if filter.SHA256 != "" {
+		query += ` AND "SHA256" = ?`
+		args = append(args, filter.SHA256)
+	}
+	if filter.Outcome != "" {
+		query += ` AND "Outcome" = ?`
+		args = append(args, string(filter.Outcome))
+	}
+	if !filter.Since.IsZero() {
+		query += ` AND "Processed" >= ?`
+		args = append(args, filter.Since.UTC().Format(timeseries.SQLiteTimeFormat))
+	}
+	if !filter.Until.IsZero() {
+		query += ` AND "Processed" < ?`
+		args = append(args, filter.Until.UTC().Format(timeseries.SQLiteTimeFormat))
+	}
+	rows, err := ledger.db.Query(query+` ORDER BY "Processed", "Name"`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []Entry
+	for rows.Next() {
+		var entry Entry
+		var processed, outcome, outputs string
+		err := rows.Scan(
+			&entry.SHA256,
+			&entry.Name,
+			&processed,
+			&outcome,
+			&entry.Error,
+			&outputs,
+			&entry.Version,
+			&entry.Host,
+			&entry.Parameters,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if filter.Name != "" {
+			match, err := filepath.Match(filter.Name, filepath.Base(entry.Name))
+			if err != nil {
+				return nil, err
+			}
+			if !match {
+				continue
+			}
+		}
+		entry.Processed, err = time.Parse(timeseries.SQLiteTimeFormat, processed)
+		if err != nil {
+			return nil, err
+		}
+		entry.Outcome = Outcome(outcome)
+		if err := json.Unmarshal([]byte(outputs), &entry.Outputs); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
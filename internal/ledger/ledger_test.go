@@ -0,0 +1,158 @@
+package ledger
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+)
+
+func testLedger(t *testing.T) *Ledger {
+	ledger, err := Open(filepath.Join(t.TempDir(), "ledger.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { ledger.Close() })
+	run := &common.RunDescription{
+		Version:    "Test Build",
+		Host:       "host",
+		Parameters: []string{"-parquet=true"},
+		Inputs: []common.InputDescription{
+			{Name: "racs/a.rac", SHA256: "aaa"},
+			{Name: "racs/b.rac", SHA256: "bbb"},
+		},
+	}
+	day := time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)
+	results := map[string]Result{
+		"racs/a.rac": {Outputs: []string{"lake/a.parquet"}},
+		"racs/b.rac": {Outputs: []string{"lake/b.parquet"}},
+	}
+	if err := ledger.Record(run, results, day); err != nil {
+		t.Fatalf("Ledger.Record() error = %v", err)
+	}
+	run.Inputs = []common.InputDescription{{Name: "racs/c.rac", SHA256: "ccc"}, {Name: "racs/d.rac"}}
+	results = map[string]Result{"racs/c.rac": {Err: errors.New("oops")}, "racs/d.rac": {Err: errors.New("oops")}}
+	if err := ledger.Record(run, results, day.Add(24*time.Hour)); err != nil {
+		t.Fatalf("Ledger.Record() error = %v", err)
+	}
+	return ledger
+}
+
+func TestLedger_Done(t *testing.T) {
+	ledger := testLedger(t)
+	tests := []struct {
+		sha256 string
+		want   bool
+	}{
+		{"aaa", true},
+		{"ccc", false},
+		{"ddd", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sha256, func(t *testing.T) {
+			got, err := ledger.Done(tt.sha256)
+			if err != nil {
+				t.Errorf("Ledger.Done() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Ledger.Done() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLedger_Entries(t *testing.T) {
+	ledger := testLedger(t)
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"Lists all", Filter{}, []string{"aaa", "bbb", "ccc"}},
+		{"Selects name", Filter{Name: "b.*"}, []string{"bbb"}},
+		{"Selects sha256", Filter{SHA256: "ccc"}, []string{"ccc"}},
+		{"Selects outcome", Filter{Outcome: Failed}, []string{"ccc"}},
+		{"Selects since", Filter{Since: time.Date(2023, 1, 6, 0, 0, 0, 0, time.UTC)}, []string{"ccc"}},
+		{"Selects until", Filter{Until: time.Date(2023, 1, 6, 0, 0, 0, 0, time.UTC)}, []string{"aaa", "bbb"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := ledger.Entries(tt.filter)
+			if err != nil {
+				t.Fatalf("Ledger.Entries() error = %v", err)
+			}
+			var got []string
+			for _, entry := range entries {
+				got = append(got, entry.SHA256)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Ledger.Entries() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLedger_Entries_fields(t *testing.T) {
+	ledger := testLedger(t)
+	entries, err := ledger.Entries(Filter{SHA256: "aaa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Entry{
+		SHA256:     "aaa",
+		Name:       "racs/a.rac",
+		Processed:  time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC),
+		Outcome:    Done,
+		Outputs:    []string{"lake/a.parquet"},
+		Version:    "Test Build",
+		Host:       "host",
+		Parameters: "-parquet=true",
+	}
+	if len(entries) != 1 || !reflect.DeepEqual(entries[0], want) {
+		t.Errorf("Ledger.Entries() = %+v, want %+v", entries, want)
+	}
+	failed, _ := ledger.Entries(Filter{SHA256: "ccc"})
+	if len(failed) != 1 || failed[0].Error != "oops" || len(failed[0].Outputs) != 0 {
+		t.Errorf("Ledger.Entries() = %+v, want failure oops without outputs", failed)
+	}
+}
+
+func TestLedger_Record_perInput(t *testing.T) {
+	ledger, err := Open(filepath.Join(t.TempDir(), "ledger.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer ledger.Close()
+	run := &common.RunDescription{
+		Inputs: []common.InputDescription{
+			{Name: "racs/good.rac", SHA256: "good"},
+			{Name: "racs/corrupt.rac", SHA256: "corrupt"},
+		},
+	}
+	results := map[string]Result{
+		"racs/good.rac":    {Outputs: []string{"lake/good.parquet"}},
+		"racs/corrupt.rac": {Err: errors.New("3 packets had errors"), Outputs: []string{"lake/corrupt.parquet"}},
+	}
+	if err := ledger.Record(run, results, time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Ledger.Record() error = %v", err)
+	}
+	entries, err := ledger.Entries(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]Entry)
+	for _, entry := range entries {
+		got[entry.SHA256] = entry
+	}
+	if entry := got["good"]; entry.Outcome != Done || !reflect.DeepEqual(entry.Outputs, []string{"lake/good.parquet"}) {
+		t.Errorf("Ledger.Record() recorded %+v, want done with its own output", entry)
+	}
+	entry := got["corrupt"]
+	if entry.Outcome != Failed || entry.Error != "3 packets had errors" ||
+		!reflect.DeepEqual(entry.Outputs, []string{"lake/corrupt.parquet"}) {
+		t.Errorf("Ledger.Record() recorded %+v, want failed with its own output", entry)
+	}
+}
@@ -0,0 +1,38 @@
+package limits
+
+import (
+	"fmt"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
+)
+
+// Alarm describes a field value outside of its limits
+type Alarm struct {
+	AlarmField    string   `description:"The name of the field, e.g. HTR1A"`
+	AlarmValue    float64  `description:"The value of the field"`
+	AlarmSeverity Severity `description:"SOFT or HARD"`
+	AlarmBound    Bound    `description:"LOW or HIGH"`
+	AlarmLimit    float64  `description:"The limit that was passed"`
+
+	limitsID string
+}
+
+// Summary returns a short description suitable for grouping alarms
+func (alarm *Alarm) Summary() string {
+	return fmt.Sprintf("%v %v %v", alarm.AlarmSeverity, alarm.AlarmBound, alarm.AlarmField)
+}
+
+// CSVSpecifications returns the specs used in creating the struct
+func (alarm *Alarm) CSVSpecifications() []string {
+	return []string{"LIMITS", alarm.limitsID}
+}
+
+// Columns returns the exported columns
+func (alarm *Alarm) Columns() []schema.Column {
+	return schema.Columns(Alarm{})
+}
+
+// Values returns the exported values
+func (alarm *Alarm) Values() []interface{} {
+	return schema.Values(alarm)
+}
@@ -0,0 +1,31 @@
+package limits
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
+)
+
+func TestAlarm(t *testing.T) {
+	alarm := Alarm{"HTR1A", -55, Hard, Low, -40, "test"}
+	if got := alarm.Summary(); got != "HARD LOW HTR1A" {
+		t.Errorf("Alarm.Summary() = %v, want HARD LOW HTR1A", got)
+	}
+	wantSpecs := []string{"LIMITS", "test"}
+	if got := alarm.CSVSpecifications(); !reflect.DeepEqual(got, wantSpecs) {
+		t.Errorf("Alarm.CSVSpecifications() = %v, want %v", got, wantSpecs)
+	}
+	wantHeaders := []string{"AlarmField", "AlarmValue", "AlarmSeverity", "AlarmBound", "AlarmLimit"}
+	if got := schema.CSVHeaders(alarm.Columns()); !reflect.DeepEqual(got, wantHeaders) {
+		t.Errorf("schema.CSVHeaders() = %v, want %v", got, wantHeaders)
+	}
+	wantRow := []string{"HTR1A", "-55", "HARD", "LOW", "-40"}
+	if got := schema.CSVRow(alarm.Columns(), alarm.Values()); !reflect.DeepEqual(got, wantRow) {
+		t.Errorf("schema.CSVRow() = %v, want %v", got, wantRow)
+	}
+	wantValues := []interface{}{"HTR1A", -55.0, "HARD", "LOW", -40.0}
+	if got := alarm.Values(); !reflect.DeepEqual(got, wantValues) {
+		t.Errorf("Alarm.Values() = %v, want %v", got, wantValues)
+	}
+}
@@ -0,0 +1,173 @@
+package limits
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+)
+
+// Severity tells how serious a limit violation is
+type Severity string
+
+const (
+	// Soft is a violation that needs attention
+	Soft Severity = "SOFT"
+	// Hard is a violation that needs action
+	Hard Severity = "HARD"
+)
+
+// Bound tells which side of the allowed range a value is on
+type Bound string
+
+const (
+	// Low means the value is below the lower limit
+	Low Bound = "LOW"
+	// High means the value is above the upper limit
+	High Bound = "HIGH"
+)
+
+// Limit holds the allowed range of a field, missing limits are not checked
+type Limit struct {
+	SoftLow  *float64 `json:"softLow"`
+	SoftHigh *float64 `json:"softHigh"`
+	HardLow  *float64 `json:"hardLow"`
+	HardHigh *float64 `json:"hardHigh"`
+}
+
+// Check returns the most severe violation of the limit if any
+func (limit *Limit) Check(value float64) (Severity, Bound, float64, bool) {
+	if math.IsNaN(value) {
+		return "", "", 0, false
+	}
+	checks := []struct {
+		severity Severity
+		bound    Bound
+		limit    *float64
+	}{
+		{Hard, Low, limit.HardLow},
+		{Hard, High, limit.HardHigh},
+		{Soft, Low, limit.SoftLow},
+		{Soft, High, limit.SoftHigh},
+	}
+	for _, check := range checks {
+		if check.limit == nil {
+			continue
+		}
+		if (check.bound == Low && value < *check.limit) ||
+			(check.bound == High && value > *check.limit) {
+			return check.severity, check.bound, *check.limit, true
+		}
+	}
+	return "", "", 0, false
+}
+
+func (limit *Limit) validate(field string) error {
+	ordered := func(low *float64, high *float64) bool {
+		return low == nil || high == nil || *low <= *high
+	}
+	if !ordered(limit.SoftLow, limit.SoftHigh) || !ordered(limit.HardLow, limit.HardHigh) {
+		return fmt.Errorf("limit %v has low above high", field)
+	}
+	if !ordered(limit.HardLow, limit.SoftLow) || !ordered(limit.SoftHigh, limit.HardHigh) {
+		return fmt.Errorf("limit %v has soft limits outside hard limits", field)
+	}
+	return nil
+}
+
+// Limits holds the limits per field name
+type Limits struct {
+	ID     string           `json:"id"`
+	Fields map[string]Limit `json:"limits"`
+}
+
+// LoadLimits reads limits from json
+func LoadLimits(buf io.Reader) (*Limits, error) {
+	limits := Limits{}
+	err := json.NewDecoder(buf).Decode(&limits)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse limits: %v", err)
+	}
+	if limits.ID == "" {
+		return nil, errors.New("limits lacks id")
+	}
+	for field, limit := range limits.Fields {
+		err = limit.validate(field)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &limits, nil
+}
+
+// fieldValue returns the numeric value of an exported value, booleans count
+// as 0 or 1
+func fieldValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// Check returns an alarm record for each field in the record outside its limits
+func (limits *Limits) Check(record *common.DataRecord) []common.DataRecord {
+	if record.Data == nil || record.Error != nil || len(limits.Fields) == 0 {
+		return nil
+	}
+	if _, ok := record.Data.(*Alarm); ok {
+		return nil
+	}
+	columns := record.Data.Columns()
+	values := record.Data.Values()
+	var alarms []common.DataRecord
+	for idx, column := range columns {
+		field := column.Name
+		limit, ok := limits.Fields[field]
+		if !ok || idx >= len(values) {
+			continue
+		}
+		value, ok := fieldValue(values[idx])
+		if !ok {
+			continue
+		}
+		severity, bound, threshold, violated := limit.Check(value)
+		if !violated {
+			continue
+		}
+		alarm := *record
+		alarm.Buffer = nil
+		alarm.Data = &Alarm{
+			AlarmField:    field,
+			AlarmValue:    value,
+			AlarmSeverity: severity,
+			AlarmBound:    bound,
+			AlarmLimit:    threshold,
+			limitsID:      limits.ID,
+		}
+		alarms = append(alarms, alarm)
+	}
+	return alarms
+}
+
+// CheckingCallback returns a callback that passes each record on to callback
+// followed by alarm records for its values outside the limits
+func CheckingCallback(callback common.Callback, limits *Limits) common.Callback {
+	return func(record common.DataRecord) {
+		alarms := limits.Check(&record)
+		callback(record)
+		for _, alarm := range alarms {
+			callback(alarm)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package limits
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+)
+
+var errTest = errors.New("test")
+
+func float(value float64) *float64 {
+	return &value
+}
+
+func TestLimit_Check(t *testing.T) {
+	limit := Limit{SoftLow: float(0), SoftHigh: float(10), HardLow: float(-5), HardHigh: float(15)}
+	tests := []struct {
+		name         string
+		limit        Limit
+		value        float64
+		wantSeverity Severity
+		wantBound    Bound
+		wantLimit    float64
+		wantViolated bool
+	}{
+		{"Inside limits", limit, 5, "", "", 0, false},
+		{"On limit", limit, 10, "", "", 0, false},
+		{"Soft low", limit, -1, Soft, Low, 0, true},
+		{"Soft high", limit, 11, Soft, High, 10, true},
+		{"Hard low", limit, -6, Hard, Low, -5, true},
+		{"Hard high", limit, 16, Hard, High, 15, true},
+		{"NaN is not checked", limit, math.NaN(), "", "", 0, false},
+		{"Missing limits are not checked", Limit{HardHigh: float(0)}, -100, "", "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			severity, bound, threshold, violated := tt.limit.Check(tt.value)
+			if severity != tt.wantSeverity || bound != tt.wantBound ||
+				threshold != tt.wantLimit || violated != tt.wantViolated {
+				t.Errorf(
+					"Limit.Check() = %v, %v, %v, %v, want %v, %v, %v, %v",
+					severity, bound, threshold, violated,
+					tt.wantSeverity, tt.wantBound, tt.wantLimit, tt.wantViolated,
+				)
+			}
+		})
+	}
+}
+
+func TestLoadLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantFields int
+		wantErr    bool
+	}{
+		{"Loads limits", `{"id": "a", "limits": {"HTR1A": {"softLow": 0, "hardLow": -5}}}`, 1, false},
+		{"Loads no limits", `{"id": "a"}`, 0, false},
+		{"Fails on missing id", `{"limits": {}}`, 0, true},
+		{"Fails on bad json", `{"id": `, 0, true},
+		{"Fails on low above high", `{"id": "a", "limits": {"HTR1A": {"softLow": 5, "softHigh": 0}}}`, 0, true},
+		{
+			"Fails on soft outside hard",
+			`{"id": "a", "limits": {"HTR1A": {"softHigh": 5, "hardHigh": 0}}}`,
+			0,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadLimits(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadLimits() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && len(got.Fields) != tt.wantFields {
+				t.Errorf("LoadLimits() fields = %v, want %v", len(got.Fields), tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestLimits_Check(t *testing.T) {
+	limits := Limits{
+		ID: "test",
+		Fields: map[string]Limit{
+			"HTR1A":        {HardLow: float(-40)},
+			"HTR1OD":       {SoftHigh: float(0)},
+			"HTR2OD":       {SoftHigh: float(1)},
+			"Overvoltage0": {HardHigh: float(0)},
+			"ANOMALY":      {HardHigh: float(0)},
+		},
+	}
+	origin := &common.OriginDescription{Name: "test.rac"}
+	tests := []struct {
+		name   string
+		record common.DataRecord
+		want   []Alarm
+	}{
+		{
+			"Reports HTR violations",
+			common.DataRecord{Origin: origin, Data: &aez.HTR{HTR1A: 1, HTR1OD: 10}, Buffer: []byte{1}},
+			[]Alarm{
+				{"HTR1A", -55, Hard, Low, -40, "test"},
+				{"HTR1OD", 10 * aez.BuiltinCalibration.VoltageConstant, Soft, High, 0, "test"},
+			},
+		},
+		{
+			"Reports boolean violations",
+			common.DataRecord{Origin: origin, Data: &aez.CPRU{STAT: 0x80}},
+			[]Alarm{{"Overvoltage0", 1, Hard, High, 0, "test"}},
+		},
+		{
+			"Reports STAT violations",
+			common.DataRecord{Origin: origin, Data: &aez.STAT{ANOMALY: 1}},
+			[]Alarm{{"ANOMALY", 1, Hard, High, 0, "test"}},
+		},
+		{
+			"Skips records with errors",
+			common.DataRecord{Origin: origin, Data: &aez.STAT{ANOMALY: 1}, Error: errTest},
+			nil,
+		},
+		{"Skips records without data", common.DataRecord{Origin: origin}, nil},
+		{
+			"Skips alarms",
+			common.DataRecord{Origin: origin, Data: &Alarm{AlarmField: "ANOMALY", AlarmValue: 1}},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := limits.Check(&tt.record)
+			if len(got) != len(tt.want) {
+				t.Errorf("Limits.Check() = %v alarms, want %v", len(got), len(tt.want))
+				return
+			}
+			for idx, record := range got {
+				alarm, ok := record.Data.(*Alarm)
+				if !ok {
+					t.Errorf("Limits.Check()[%v] data = %v, want an Alarm", idx, record.Data)
+					continue
+				}
+				if !reflect.DeepEqual(*alarm, tt.want[idx]) {
+					t.Errorf("Limits.Check()[%v] = %+v, want %+v", idx, *alarm, tt.want[idx])
+				}
+				if record.Origin != origin || record.Buffer != nil {
+					t.Errorf("Limits.Check()[%v] should keep origin and drop buffer, got %+v", idx, record)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckingCallback(t *testing.T) {
+	limits := Limits{ID: "test", Fields: map[string]Limit{"ANOMALY": {HardHigh: float(0)}}}
+	var got []common.DataRecord
+	callback := CheckingCallback(
+		func(record common.DataRecord) { got = append(got, record) },
+		&limits,
+	)
+	callback(common.DataRecord{Data: &aez.STAT{ANOMALY: 1}})
+	callback(common.DataRecord{Data: &aez.STAT{ANOMALY: 0}})
+	if len(got) != 3 {
+		t.Errorf("CheckingCallback() passed on %v records, want 3", len(got))
+		return
+	}
+	if _, ok := got[1].Data.(*Alarm); !ok {
+		t.Errorf("CheckingCallback() second record = %+v, want alarm following its record", got[1])
+	}
+}
@@ -0,0 +1,31 @@
+package live
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Address is where a live stream of RAMSES frames is received
+type Address struct {
+	Network string // tcp or udp
+	Host    string // host:port, the host may be empty to listen on all interfaces
+}
+
+// ParseAddress parses an address such as tcp://:5000 or udp://127.0.0.1:5000
+func ParseAddress(text string) (Address, error) {
+	parsed, err := url.Parse(text)
+	if err != nil {
+		return Address{}, fmt.Errorf("invalid address '%v': %v", text, err)
+	}
+	if parsed.Scheme != "tcp" && parsed.Scheme != "udp" {
+		return Address{}, fmt.Errorf("invalid address '%v', use e.g. tcp://:5000 or udp://:5000", text)
+	}
+	if parsed.Host == "" || parsed.Port() == "" || (parsed.Path != "" && parsed.Path != "/") {
+		return Address{}, fmt.Errorf("invalid address '%v', expected host:port, e.g. tcp://:5000", text)
+	}
+	return Address{Network: parsed.Scheme, Host: parsed.Host}, nil
+}
+
+func (address Address) String() string {
+	return fmt.Sprintf("%v://%v", address.Network, address.Host)
+}
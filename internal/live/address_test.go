@@ -0,0 +1,35 @@
+package live
+
+import (
+	"testing"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    Address
+		wantErr bool
+	}{
+		{"TCP on all interfaces", "tcp://:5000", Address{"tcp", ":5000"}, false},
+		{"UDP on a host", "udp://127.0.0.1:5000", Address{"udp", "127.0.0.1:5000"}, false},
+		{"Unknown network", "http://:5000", Address{}, true},
+		{"No scheme", ":5000", Address{}, true},
+		{"No port", "tcp://localhost", Address{}, true},
+		{"Path", "tcp://:5000/egse", Address{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAddress(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAddress() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseAddress() = %v, want %v", got, tt.want)
+			}
+			if !tt.wantErr && got.String() != tt.text {
+				t.Errorf("Address.String() = %v, want %v", got.String(), tt.text)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+// maxDatagram is the largest UDP datagram
+const maxDatagram = 1 << 16
+
+// Handler reads a stream of RAMSES frames from the sender with the name
+type Handler func(name string, stream io.Reader)
+
+// Listener receives streams of RAMSES frames on an address
+type Listener struct {
+	address Address
+	streams net.Listener   // Accepts the connections over TCP
+	packets net.PacketConn // Receives the datagrams over UDP
+}
+
+// Listen starts listening on the address
+func Listen(address Address) (*Listener, error) {
+	listener := Listener{address: address}
+	var err error
+	switch address.Network {
+	case "tcp":
+		listener.streams, err = net.Listen("tcp", address.Host)
+	case "udp":
+		listener.packets, err = net.ListenPacket("udp", address.Host)
+	default:
+		err = errors.New("unknown network " + address.Network)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &listener, nil
+}
+
+// Addr returns the address listened on, useful if the port was left to the
+// system
+func (listener *Listener) Addr() Address {
+	if listener.streams != nil {
+		return Address{Network: listener.address.Network, Host: listener.streams.Addr().String()}
+	}
+	return Address{Network: listener.address.Network, Host: listener.packets.LocalAddr().String()}
+}
+
+// Serve passes the streams received to the handler until the context is done
+// and the handlers returned
+//
+// Each TCP connection is a stream and they are handled concurrently. The
+// datagrams received over UDP are one stream, which is handled again if the
+// handler returns before the context is done, e.g. after a frame that could
+// not be decoded. Streams end with io.EOF when the context is done.
+func (listener *Listener) Serve(ctx context.Context, handle Handler) error {
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	if listener.packets != nil {
+		stream := &datagramReader{conn: listener.packets, buffer: make([]byte, maxDatagram)}
+		for ctx.Err() == nil {
+			handle(listener.Addr().String(), stream)
+			if stream.err != nil && stream.err != io.EOF {
+				return stream.err
+			}
+			// The rest of the datagram can't be decoded without its start
+			stream.pending = nil
+		}
+		return nil
+	}
+	var handlers sync.WaitGroup
+	defer handlers.Wait()
+	for {
+		conn, err := listener.streams.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		handlers.Add(1)
+		go func() {
+			defer handlers.Done()
+			defer conn.Close()
+			done := make(chan struct{})
+			defer close(done)
+			go func() {
+				select {
+				case <-ctx.Done():
+					conn.Close()
+				case <-done:
+				}
+			}()
+			handle(conn.RemoteAddr().String(), closedReader{conn})
+		}()
+	}
+}
+
+// Close stops listening
+func (listener *Listener) Close() error {
+	if listener.streams != nil {
+		return listener.streams.Close()
+	}
+	return listener.packets.Close()
+}
+
+// closedReader reads a connection reporting it being closed as io.EOF
+type closedReader struct {
+	reader io.Reader
+}
+
+func (reader closedReader) Read(p []byte) (int, error) {
+	n, err := reader.reader.Read(p)
+	if errors.Is(err, net.ErrClosed) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// datagramReader reads the datagrams received as one stream
+type datagramReader struct {
+	conn    net.PacketConn
+	buffer  []byte
+	pending []byte // What is left of the last datagram
+	err     error
+}
+
+func (reader *datagramReader) Read(p []byte) (int, error) {
+	for len(reader.pending) == 0 {
+		if reader.err != nil {
+			return 0, reader.err
+		}
+		n, _, err := reader.conn.ReadFrom(reader.buffer)
+		if errors.Is(err, net.ErrClosed) {
+			err = io.EOF
+		}
+		if err != nil {
+			reader.err = err
+			return 0, err
+		}
+		reader.pending = reader.buffer[:n]
+	}
+	n := copy(p, reader.pending)
+	reader.pending = reader.pending[n:]
+	return n, nil
+}
@@ -0,0 +1,71 @@
+package live
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/extractors"
+)
+
+func TestListener_Serve(t *testing.T) {
+	for _, network := range []string{"tcp", "udp"} {
+		t.Run(network, func(t *testing.T) {
+			listener, err := Listen(Address{Network: network, Host: "127.0.0.1:0"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			records := make(chan common.DataRecord, 10)
+			ctx, cancel := context.WithCancel(context.Background())
+			served := make(chan error)
+			go func() {
+				served <- listener.Serve(ctx, func(name string, stream io.Reader) {
+					extractors.ExtractData(
+						func(pkg common.DataRecord) { records <- pkg },
+						extractors.Dregs{},
+						nil,
+						extractors.StreamBatch{Buf: stream, Origin: &common.OriginDescription{Name: name}},
+					)
+				})
+			}()
+
+			conn, err := Dial(listener.Addr())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			source := append(append([]byte{}, statFrame...), statFrame...)
+			if _, err := Replay(conn, bytes.NewReader(source), time.Millisecond); err != nil {
+				t.Fatalf("Replay() error = %v", err)
+			}
+			for idx := 0; idx < 2; idx++ {
+				select {
+				case pkg := <-records:
+					if pkg.Error != nil || pkg.Data == nil {
+						t.Errorf("Listener.Serve() record %v = %+v, want STAT data", idx, pkg)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("Listener.Serve() passed %v records, want 2", idx)
+				}
+			}
+
+			cancel()
+			select {
+			case err := <-served:
+				if err != nil {
+					t.Errorf("Listener.Serve() error = %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Listener.Serve() didn't return when the context was done")
+			}
+			select {
+			case pkg := <-records:
+				t.Errorf("Listener.Serve() passed %+v when the stream ended", pkg)
+			default:
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package live
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/ramses"
+)
+
+// Dial connects to the address to replay rac-files to it
+func Dial(address Address) (net.Conn, error) {
+	return net.Dial(address.Network, address.Host)
+}
+
+// Replay writes the RAMSES frames of the rac-file to out, one write per frame
+// so that each is a datagram over UDP, waiting the interval between frames,
+// and returns the number of frames written
+func Replay(out io.Writer, source io.Reader, interval time.Duration) (int, error) {
+	header := make([]byte, binary.Size(ramses.Ramses{}))
+	frames := 0
+	for {
+		_, err := io.ReadFull(source, header)
+		if err == io.EOF {
+			return frames, nil
+		}
+		if err != nil {
+			return frames, fmt.Errorf("could not read frame %v: %v", frames+1, err)
+		}
+		frameHeader, err := ramses.NewRamses(bytes.NewReader(header))
+		if err != nil {
+			return frames, err
+		}
+		if !frameHeader.Valid() {
+			return frames, fmt.Errorf("frame %v is not a RAMSES frame", frames+1)
+		}
+		frame := make([]byte, len(header)+int(frameHeader.Length))
+		copy(frame, header)
+		if _, err := io.ReadFull(source, frame[len(header):]); err != nil {
+			return frames, fmt.Errorf("could not read frame %v: %v", frames+1, err)
+		}
+		if frames > 0 && interval > 0 {
+			time.Sleep(interval)
+		}
+		if _, err := out.Write(frame); err != nil {
+			return frames, err
+		}
+		frames++
+	}
+}
@@ -0,0 +1,65 @@
+package live
+
+import (
+	"bytes"
+	"testing"
+)
+
+// statFrame is a RAMSES frame of one STAT packet
+var statFrame = []byte{
+	// Ramses header
+	0x90, 0xeb, 0x48, 0x00, 0x79, 0xd8, 0x00, 0x00,
+	0xfb, 0xad, 0xc8, 0x04, 0xda, 0x1c, 0x00, 0x00,
+	// Ramses TM header
+	0x00, 0x00, 0x2e, 0x02, 0x00, 0x00, 0x64, 0x00,
+	0x00, 0x00, 0x00, 0xcc, 0xcc, 0xcc, 0xcc, 0x00,
+	// Ramses TM Payload
+	0x08, 0x64, 0xc8, 0x98, 0x00, 0x31, 0x10, 0x03,
+	0x19, 0x00, 0x00, 0x12, 0x19, 0xe3, 0x39, 0x00,
+	0x01, 0x7f, 0x04, 0x02, 0x82, 0x04, 0x02, 0x02,
+	0x06, 0x01, 0x19, 0x12, 0x00, 0x00, 0x0c, 0xe3,
+	0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x01, 0x00, 0x00, 0x00, 0x41, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x30, 0xfb,
+}
+
+// frameWriter records the writes
+type frameWriter struct {
+	writes [][]byte
+}
+
+func (writer *frameWriter) Write(p []byte) (int, error) {
+	writer.writes = append(writer.writes, append([]byte{}, p...))
+	return len(p), nil
+}
+
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     []byte
+		wantFrames int
+		wantErr    bool
+	}{
+		{"Empty rac-file", []byte{}, 0, false},
+		{"Frames", append(append([]byte{}, statFrame...), statFrame...), 2, false},
+		{"Truncated frame", append(append([]byte{}, statFrame...), statFrame[:40]...), 1, true},
+		{"Not a frame", append([]byte{0x00}, statFrame...), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := frameWriter{}
+			frames, err := Replay(&out, bytes.NewReader(tt.source), 0)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Replay() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if frames != tt.wantFrames || len(out.writes) != tt.wantFrames {
+				t.Errorf("Replay() = %v frames in %v writes, want %v", frames, len(out.writes), tt.wantFrames)
+			}
+			for _, write := range out.writes {
+				if !bytes.Equal(write, statFrame) {
+					t.Errorf("Replay() wrote %v, want a frame %v", write, statFrame)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package live
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+)
+
+// Opener opens the outputs of the records from the origin, returning the
+// callback of the records and a function completing the outputs
+type Opener func(origin *common.OriginDescription) (common.Callback, func() error, error)
+
+// Roller passes records to outputs that are completed and replaced by new
+// ones each period, so the records of a stream are available while it goes
+// on
+//
+// The records are given an origin named by the name and the start of the
+// period, e.g. live_20230105T140000Z.rac, so each period is written to
+// files of its own. The records are given the processing time of the roller
+// or, if it is zero, the time their period's outputs were opened.
+type Roller struct {
+	lock      sync.Mutex
+	name      string
+	period    time.Duration
+	run       *common.RunDescription
+	processed time.Time
+	open      Opener
+	end       time.Time // End of the period of the outputs, zero if none are open
+	origin    *common.OriginDescription
+	callback  common.Callback
+	finish    func() error
+}
+
+// NewRoller returns a roller opening outputs for the records of each period
+// as described by the run, recorded as processed at the time unless zero
+func NewRoller(
+	name string,
+	period time.Duration,
+	run *common.RunDescription,
+	processed time.Time,
+	open Opener,
+) *Roller {
+	return &Roller{name: name, period: period, run: run, processed: processed, open: open}
+}
+
+// Callback passes the record to the outputs of the current period
+func (roller *Roller) Callback(pkg common.DataRecord) {
+	roller.Write(pkg, time.Now())
+}
+
+// Write passes the record to the outputs of the period of the time,
+// completing those of an earlier period
+func (roller *Roller) Write(pkg common.DataRecord, now time.Time) {
+	roller.lock.Lock()
+	defer roller.lock.Unlock()
+	if err := roller.roll(now); err != nil {
+		log.Println(err)
+	}
+	if roller.end.IsZero() {
+		if err := roller.start(now); err != nil {
+			log.Printf("Could not open outputs, dropping record: %v", err)
+			return
+		}
+	}
+	pkg.Origin = roller.origin
+	roller.callback(pkg)
+}
+
+// Roll completes the outputs if their period is over at the time
+func (roller *Roller) Roll(now time.Time) error {
+	roller.lock.Lock()
+	defer roller.lock.Unlock()
+	return roller.roll(now)
+}
+
+// Close completes the outputs
+func (roller *Roller) Close() error {
+	roller.lock.Lock()
+	defer roller.lock.Unlock()
+	return roller.complete()
+}
+
+func (roller *Roller) start(now time.Time) error {
+	begin := now.Truncate(roller.period)
+	name := fmt.Sprintf("%v_%v.rac", roller.name, begin.UTC().Format("20060102T150405Z"))
+	run := *roller.run
+	run.Inputs = []common.InputDescription{{Name: name}}
+	processed := roller.processed
+	if processed.IsZero() {
+		processed = now
+	}
+	origin := &common.OriginDescription{Name: name, ProcessingDate: processed, Run: &run}
+	callback, finish, err := roller.open(origin)
+	if err != nil {
+		return err
+	}
+	roller.origin = origin
+	roller.callback = callback
+	roller.finish = finish
+	roller.end = begin.Add(roller.period)
+	return nil
+}
+
+func (roller *Roller) roll(now time.Time) error {
+	if roller.end.IsZero() || now.Before(roller.end) {
+		return nil
+	}
+	return roller.complete()
+}
+
+func (roller *Roller) complete() error {
+	if roller.end.IsZero() {
+		return nil
+	}
+	name := roller.origin.Name
+	err := roller.finish()
+	roller.end = time.Time{}
+	roller.origin = nil
+	roller.callback = nil
+	roller.finish = nil
+	if err != nil {
+		return fmt.Errorf("could not complete outputs of %v: %v", name, err)
+	}
+	return nil
+}
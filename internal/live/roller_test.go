@@ -0,0 +1,142 @@
+package live
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+)
+
+// rollLog records what a roller did with its outputs
+type rollLog struct {
+	events []string
+	err    error
+}
+
+func (rolls *rollLog) open(origin *common.OriginDescription) (common.Callback, func() error, error) {
+	rolls.events = append(rolls.events, "open "+origin.Name)
+	callback := func(pkg common.DataRecord) {
+		rolls.events = append(rolls.events, "write "+pkg.Origin.Name)
+	}
+	return callback, func() error {
+		rolls.events = append(rolls.events, "finish "+origin.Name)
+		return rolls.err
+	}, nil
+}
+
+func TestRoller(t *testing.T) {
+	start := time.Date(2023, 1, 5, 14, 3, 0, 0, time.UTC)
+	rolls := rollLog{}
+	roller := NewRoller("egse", 10*time.Minute, &common.RunDescription{Host: "ground"}, time.Time{}, rolls.open)
+	record := common.DataRecord{Origin: &common.OriginDescription{Name: "127.0.0.1:40000"}}
+
+	roller.Write(record, start)
+	roller.Write(record, start.Add(6*time.Minute))
+	if err := roller.Roll(start.Add(7 * time.Minute)); err != nil {
+		t.Fatalf("Roller.Roll() error = %v", err)
+	}
+	roller.Write(record, start.Add(8*time.Minute))
+	if err := roller.Roll(start.Add(30 * time.Minute)); err != nil {
+		t.Fatalf("Roller.Roll() error = %v", err)
+	}
+	if err := roller.Roll(start.Add(40 * time.Minute)); err != nil {
+		t.Fatalf("Roller.Roll() error = %v", err)
+	}
+	roller.Write(record, start.Add(45*time.Minute))
+	if err := roller.Close(); err != nil {
+		t.Fatalf("Roller.Close() error = %v", err)
+	}
+	want := []string{
+		"open egse_20230105T140000Z.rac",
+		"write egse_20230105T140000Z.rac",
+		"write egse_20230105T140000Z.rac",
+		"finish egse_20230105T140000Z.rac",
+		"open egse_20230105T141000Z.rac",
+		"write egse_20230105T141000Z.rac",
+		"finish egse_20230105T141000Z.rac",
+		"open egse_20230105T144000Z.rac",
+		"write egse_20230105T144000Z.rac",
+		"finish egse_20230105T144000Z.rac",
+	}
+	if !reflect.DeepEqual(rolls.events, want) {
+		t.Errorf("Roller events = %v, want %v", rolls.events, want)
+	}
+}
+
+func TestRoller_origin(t *testing.T) {
+	start := time.Date(2023, 1, 5, 14, 3, 0, 0, time.UTC)
+	var got *common.OriginDescription
+	roller := NewRoller(
+		"egse",
+		time.Hour,
+		&common.RunDescription{Host: "ground"},
+		time.Time{},
+		func(origin *common.OriginDescription) (common.Callback, func() error, error) {
+			return func(pkg common.DataRecord) { got = pkg.Origin }, func() error { return nil }, nil
+		},
+	)
+	roller.Write(common.DataRecord{}, start)
+	want := &common.OriginDescription{
+		Name:           "egse_20230105T140000Z.rac",
+		ProcessingDate: start,
+		Run: &common.RunDescription{
+			Host:   "ground",
+			Inputs: []common.InputDescription{{Name: "egse_20230105T140000Z.rac"}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Roller record origin = %+v, want %+v", got, want)
+	}
+}
+
+func TestRoller_processed(t *testing.T) {
+	start := time.Date(2023, 1, 5, 14, 3, 0, 0, time.UTC)
+	processed := time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)
+	var got []time.Time
+	roller := NewRoller(
+		"egse",
+		10*time.Minute,
+		&common.RunDescription{},
+		processed,
+		func(origin *common.OriginDescription) (common.Callback, func() error, error) {
+			return func(pkg common.DataRecord) {
+				got = append(got, pkg.Origin.ProcessingDate)
+			}, func() error { return nil }, nil
+		},
+	)
+	roller.Write(common.DataRecord{}, start)
+	roller.Write(common.DataRecord{}, start.Add(time.Hour))
+	want := []time.Time{processed, processed}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Roller record processing dates = %v, want %v", got, want)
+	}
+}
+
+func TestRoller_errors(t *testing.T) {
+	start := time.Date(2023, 1, 5, 14, 3, 0, 0, time.UTC)
+	rolls := rollLog{err: errors.New("disk full")}
+	roller := NewRoller("egse", time.Hour, &common.RunDescription{}, time.Time{}, rolls.open)
+	roller.Write(common.DataRecord{}, start)
+	if err := roller.Close(); err == nil {
+		t.Error("Roller.Close() didn't return the error completing the outputs")
+	}
+	if err := roller.Close(); err != nil {
+		t.Errorf("Roller.Close() of completed outputs error = %v", err)
+	}
+
+	failing := NewRoller(
+		"egse",
+		time.Hour,
+		&common.RunDescription{},
+		time.Time{},
+		func(origin *common.OriginDescription) (common.Callback, func() error, error) {
+			return nil, nil, errors.New("no project")
+		},
+	)
+	failing.Write(common.DataRecord{}, start)
+	if err := failing.Close(); err != nil {
+		t.Errorf("Roller.Close() without outputs error = %v", err)
+	}
+}
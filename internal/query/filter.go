@@ -0,0 +1,188 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+// DefaultTimeColumn is the column compared with the time range unless told
+// otherwise
+const DefaultTimeColumn = "TMHeaderTime"
+
+// Filter selects records, empty fields select all
+type Filter struct {
+	Streams    []timeseries.OutStream
+	TimeColumn string    // Column compared with From and To, DefaultTimeColumn if empty
+	From       time.Time // Only records at or after the time
+	To         time.Time // Only records before the time
+	CCDs       []int     // Only images of the CCDs, e.g. 3 for CCD3
+	Predicates []Predicate
+}
+
+// SkipStream returns if no record of the stream can match, so that files of
+// it need not be read
+func (filter Filter) SkipStream(stream timeseries.OutStream) bool {
+	if len(filter.CCDs) > 0 && stream != timeseries.CCD {
+		return true
+	}
+	if len(filter.Streams) == 0 {
+		return false
+	}
+	for _, wanted := range filter.Streams {
+		if stream == wanted {
+			return false
+		}
+	}
+	return true
+}
+
+// Match returns if the filter selects the record
+func (filter Filter) Match(record *Record) bool {
+	if filter.SkipStream(record.Stream) {
+		return false
+	}
+	if !filter.From.IsZero() || !filter.To.IsZero() {
+		column := filter.TimeColumn
+		if column == "" {
+			column = DefaultTimeColumn
+		}
+		value, _ := record.Value(column)
+		at, ok := value.(time.Time)
+		if !ok || (!filter.From.IsZero() && at.Before(filter.From)) ||
+			(!filter.To.IsZero() && !at.Before(filter.To)) {
+			return false
+		}
+	}
+	if len(filter.CCDs) > 0 {
+		rid, _ := record.Value("RID")
+		found := false
+		for _, ccd := range filter.CCDs {
+			found = found || rid == fmt.Sprintf("CCD%v", ccd)
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, predicate := range filter.Predicates {
+		if !predicate.Match(record) {
+			return false
+		}
+	}
+	return true
+}
+
+// Predicate compares the value of a column
+type Predicate struct {
+	Column   string
+	Operator string // One of Operators
+	Value    string
+}
+
+// Operators are the comparisons of predicates, ~ is contains
+var Operators = []string{"!=", "<=", ">=", "=", "<", ">", "~"}
+
+// ParsePredicate parses a predicate such as HTR1A>20 or RID=CCD3
+func ParsePredicate(text string) (Predicate, error) {
+	at := -1
+	operator := ""
+	for _, candidate := range Operators {
+		if idx := strings.Index(text, candidate); idx > 0 && (at < 0 || idx < at) {
+			at = idx
+			operator = candidate
+		}
+	}
+	if at < 0 {
+		return Predicate{}, fmt.Errorf(
+			"invalid predicate '%v', expected e.g. HTR1A>20 with one of %v",
+			text,
+			strings.Join(Operators, " "),
+		)
+	}
+	return Predicate{
+		Column:   strings.TrimSpace(text[:at]),
+		Operator: operator,
+		Value:    strings.TrimSpace(text[at+len(operator):]),
+	}, nil
+}
+
+func (predicate Predicate) String() string {
+	return predicate.Column + predicate.Operator + predicate.Value
+}
+
+// Match returns if the value of the column of the record compares as the
+// predicate says, records without a value don't match
+//
+// Numbers and times are compared by value, other values as their csv text.
+func (predicate Predicate) Match(record *Record) bool {
+	value, _ := record.Value(predicate.Column)
+	if value == nil {
+		return false
+	}
+	if predicate.Operator == "~" {
+		return strings.Contains(schema.FormatCSV(value), predicate.Value)
+	}
+	var order int
+	switch v := value.(type) {
+	case int64:
+		wanted, err := strconv.ParseFloat(predicate.Value, 64)
+		if err != nil {
+			return false
+		}
+		order = compareFloats(float64(v), wanted)
+	case float64:
+		wanted, err := strconv.ParseFloat(predicate.Value, 64)
+		if err != nil {
+			return false
+		}
+		order = compareFloats(v, wanted)
+	case time.Time:
+		wanted, err := ParseTime(predicate.Value)
+		if err != nil {
+			return false
+		}
+		order = compareFloats(float64(v.Sub(wanted)), 0)
+	default:
+		order = strings.Compare(schema.FormatCSV(value), predicate.Value)
+	}
+	switch predicate.Operator {
+	case "=":
+		return order == 0
+	case "!=":
+		return order != 0
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	}
+	return false
+}
+
+func compareFloats(a float64, b float64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+// ParseTime parses a time such as 2023-01-05T14:00:00Z or a date such as
+// 2023-01-05
+func ParseTime(text string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+		parsed, err := time.Parse(layout, text)
+		if err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time '%v', use e.g. 2023-01-05 or 2023-01-05T14:00:00Z", text)
+}
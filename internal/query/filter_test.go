@@ -0,0 +1,167 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+func testRecord() *Record {
+	return &Record{
+		Stream: timeseries.CCD,
+		Columns: []schema.Column{
+			{Name: "TMHeaderTime", Type: schema.Timestamp},
+			{Name: "RID", Type: schema.String},
+			{Name: "TEXPMS", Type: schema.Int64},
+			{Name: "TEMP", Type: schema.Double},
+			{Name: "Errors", Type: schema.StringList},
+			{Name: "Missing", Type: schema.Int64},
+		},
+		Values: []interface{}{
+			time.Date(2023, 1, 5, 14, 0, 0, 0, time.UTC),
+			"CCD3",
+			int64(3000),
+			21.5,
+			[]string{"bad crc"},
+			nil,
+		},
+	}
+}
+
+func TestParsePredicate(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    Predicate
+		wantErr bool
+	}{
+		{"HTR1A>20", Predicate{"HTR1A", ">", "20"}, false},
+		{"HTR1A >= 20", Predicate{"HTR1A", ">=", "20"}, false},
+		{"RID!=CCD3", Predicate{"RID", "!=", "CCD3"}, false},
+		{"Errors~crc", Predicate{"Errors", "~", "crc"}, false},
+		{"ImageName=a=b.png", Predicate{"ImageName", "=", "a=b.png"}, false},
+		{"HTR1A", Predicate{}, true},
+		{"=20", Predicate{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := ParsePredicate(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePredicate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParsePredicate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPredicate_Match(t *testing.T) {
+	tests := []struct {
+		predicate string
+		want      bool
+	}{
+		{"TEXPMS=3000", true},
+		{"TEXPMS>3000", false},
+		{"TEXPMS>=3000", true},
+		{"TEXPMS<1e4", true},
+		{"TEXPMS=many", false},
+		{"TEMP<=21.5", true},
+		{"TEMP>21", true},
+		{"TMHeaderTime>=2023-01-05", true},
+		{"TMHeaderTime<2023-01-05T13:00:00Z", false},
+		{"RID=CCD3", true},
+		{"RID!=CCD3", false},
+		{"RID<CCD4", true},
+		{"Errors~crc", true},
+		{"Errors=bad crc", true},
+		{"Missing!=1", false},
+		{"Unknown=1", false},
+	}
+	record := testRecord()
+	for _, tt := range tests {
+		t.Run(tt.predicate, func(t *testing.T) {
+			predicate, err := ParsePredicate(tt.predicate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := predicate.Match(record); got != tt.want {
+				t.Errorf("Predicate.Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	at := time.Date(2023, 1, 5, 14, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"Empty filter", Filter{}, true},
+		{"Stream", Filter{Streams: []timeseries.OutStream{timeseries.HTR, timeseries.CCD}}, true},
+		{"Other stream", Filter{Streams: []timeseries.OutStream{timeseries.HTR}}, false},
+		{"Within time range", Filter{From: at, To: at.Add(time.Second)}, true},
+		{"Before time range", Filter{From: at.Add(time.Second)}, false},
+		{"At end of time range", Filter{To: at}, false},
+		{"Time of missing column", Filter{TimeColumn: "EXPDate", From: at}, false},
+		{"CCD", Filter{CCDs: []int{1, 3}}, true},
+		{"Other CCD", Filter{CCDs: []int{1}}, false},
+		{"Predicates", Filter{Predicates: []Predicate{{"TEXPMS", ">", "1000"}, {"RID", "=", "CCD3"}}}, true},
+		{"Failing predicate", Filter{Predicates: []Predicate{{"TEXPMS", ">", "1000"}, {"RID", "=", "CCD1"}}}, false},
+	}
+	record := testRecord()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(record); got != tt.want {
+				t.Errorf("Filter.Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_SkipStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		stream timeseries.OutStream
+		want   bool
+	}{
+		{"Empty filter", Filter{}, timeseries.HTR, false},
+		{"Selected stream", Filter{Streams: []timeseries.OutStream{timeseries.HTR}}, timeseries.HTR, false},
+		{"Other stream", Filter{Streams: []timeseries.OutStream{timeseries.HTR}}, timeseries.PWR, true},
+		{"Only CCD has CCDs", Filter{CCDs: []int{3}}, timeseries.HTR, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.SkipStream(tt.stream); got != tt.want {
+				t.Errorf("Filter.SkipStream() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    time.Time
+		wantErr bool
+	}{
+		{"2023-01-05", time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC), false},
+		{"2023-01-05T14:00:00.5Z", time.Date(2023, 1, 5, 14, 0, 0, 5e8, time.UTC), false},
+		{"yesterday", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := ParseTime(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
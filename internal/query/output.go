@@ -0,0 +1,173 @@
+package query
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
+)
+
+// Format is how records are written
+type Format string
+
+const (
+	// Table aligns the values in columns for reading, with a header for each
+	// set of columns
+	Table Format = "table"
+	// CSV writes a header and one row per record, all with the same columns
+	CSV Format = "csv"
+	// JSON writes one json object per record and line as rac -ndjson
+	JSON Format = "json"
+)
+
+// Formats are the known formats
+var Formats = []Format{Table, CSV, JSON}
+
+// FormatFromName returns the format with the name
+func FormatFromName(name string) (Format, error) {
+	for _, format := range Formats {
+		if strings.EqualFold(string(format), name) {
+			return format, nil
+		}
+	}
+	return Table, fmt.Errorf("unknown format '%v', use table, csv or json", name)
+}
+
+// Writer writes the records in a format
+type Writer struct {
+	format  Format
+	columns []string // Columns to write, all but binary ones if empty
+	table   *tabwriter.Writer
+	csv     *csv.Writer
+	out     io.Writer
+	headers []string // Headers of the latest record written
+}
+
+// NewWriter returns a writer of the columns of the records in the format,
+// all columns but binary ones are written if none are given
+func NewWriter(out io.Writer, format Format, columns []string) *Writer {
+	writer := Writer{format: format, columns: columns, out: out}
+	switch format {
+	case Table:
+		writer.table = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	case CSV:
+		writer.csv = csv.NewWriter(out)
+	}
+	return &writer
+}
+
+// selection returns the columns and values of the record to write
+func (writer *Writer) selection(record *Record) ([]schema.Column, []interface{}) {
+	var columns []schema.Column
+	var values []interface{}
+	if len(writer.columns) == 0 {
+		for idx, column := range record.Columns {
+			if column.Type != schema.Bytes {
+				columns = append(columns, column)
+				values = append(values, record.Values[idx])
+			}
+		}
+		return columns, values
+	}
+	for _, name := range writer.columns {
+		value, ok := record.Value(name)
+		column := schema.Column{Name: name, Optional: true}
+		if ok {
+			column = record.Columns[schema.Index(record.Columns, name)]
+		}
+		columns = append(columns, column)
+		values = append(values, value)
+	}
+	return columns, values
+}
+
+// Write writes the record
+func (writer *Writer) Write(record *Record) error {
+	columns, values := writer.selection(record)
+	headers := schema.Names(columns)
+	changed := !equalStrings(headers, writer.headers)
+	switch writer.format {
+	case Table:
+		if changed {
+			if writer.headers != nil {
+				writer.table.Flush()
+				fmt.Fprintln(writer.out)
+			}
+			fmt.Fprintln(writer.table, strings.Join(headers, "\t"))
+		}
+		writer.headers = headers
+		_, err := fmt.Fprintln(writer.table, strings.Join(schema.CSVRow(columns, values), "\t"))
+		return err
+	case CSV:
+		if changed {
+			if writer.headers != nil {
+				return fmt.Errorf(
+					"%v has other columns than the records before, query one stream or choose the columns",
+					record.File,
+				)
+			}
+			writer.headers = headers
+			if err := writer.csv.Write(headers); err != nil {
+				return err
+			}
+		}
+		return writer.csv.Write(schema.CSVRow(columns, values))
+	default:
+		line, err := schema.MarshalJSON(columns, values)
+		if err != nil {
+			return err
+		}
+		_, err = writer.out.Write(append(line, '\n'))
+		return err
+	}
+}
+
+// Close writes what remains buffered
+func (writer *Writer) Close() error {
+	switch writer.format {
+	case Table:
+		return writer.table.Flush()
+	case CSV:
+		writer.csv.Flush()
+		return writer.csv.Error()
+	}
+	return nil
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+// SaveImage writes the PNG in the ImageData of the record to the directory
+// named by its ImageName and returns the path, or an empty path if the
+// record holds no image, as records of csvs don't
+func SaveImage(record *Record, dir string) (string, error) {
+	data, _ := record.Value("ImageData")
+	png, ok := data.([]byte)
+	if !ok || len(png) == 0 {
+		return "", nil
+	}
+	name, _ := record.Value("ImageName")
+	base, _ := name.(string)
+	if base = filepath.Base(base); base == "." || base == string(filepath.Separator) {
+		return "", fmt.Errorf("image of %v has no name", record.File)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, base)
+	return path, os.WriteFile(path, png, 0644)
+}
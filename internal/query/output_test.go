@@ -0,0 +1,130 @@
+package query
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+func TestFormatFromName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Format
+		wantErr bool
+	}{
+		{"table", Table, false},
+		{"CSV", CSV, false},
+		{"json", JSON, false},
+		{"xml", Table, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FormatFromName(tt.name)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("FormatFromName() = %v, %v, want %v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriter(t *testing.T) {
+	htr := &Record{
+		File:    "HTR.csv",
+		Stream:  timeseries.HTR,
+		Columns: []schema.Column{{Name: "SID", Type: schema.String}, {Name: "HTR1A", Type: schema.Double}},
+		Values:  []interface{}{"HTR", 21.5},
+	}
+	ccd := &Record{
+		File:   "CCD.parquet",
+		Stream: timeseries.CCD,
+		Columns: []schema.Column{
+			{Name: "RID", Type: schema.String},
+			{Name: "ImageData", Type: schema.Bytes},
+			{Name: "BadColumns", Type: schema.IntList},
+		},
+		Values: []interface{}{"CCD3", []byte{1}, []int64{3, 4}},
+	}
+	tests := []struct {
+		name    string
+		format  Format
+		columns []string
+		records []*Record
+		want    string
+		wantErr bool
+	}{
+		{
+			"Table with header per columns",
+			Table,
+			nil,
+			[]*Record{htr, htr, ccd},
+			"SID  HTR1A\nHTR  21.5\nHTR  21.5\n\nRID   BadColumns\nCCD3  [3 4]\n",
+			false,
+		},
+		{"CSV", CSV, nil, []*Record{htr, htr}, "SID,HTR1A\nHTR,21.5\nHTR,21.5\n", false},
+		{"CSV of other columns", CSV, nil, []*Record{htr, ccd}, "SID,HTR1A\nHTR,21.5\n", true},
+		{"CSV of chosen columns", CSV, []string{"RID", "SID"}, []*Record{htr, ccd}, "RID,SID\n,HTR\nCCD3,\n", false},
+		{
+			"JSON",
+			JSON,
+			nil,
+			[]*Record{htr, ccd},
+			`{"SID":"HTR","HTR1A":21.5}` + "\n" + `{"RID":"CCD3","BadColumns":[3,4]}` + "\n",
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			writer := NewWriter(&out, tt.format, tt.columns)
+			var err error
+			for _, record := range tt.records {
+				if err = writer.Write(record); err != nil {
+					break
+				}
+			}
+			if closeErr := writer.Close(); err == nil {
+				err = closeErr
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Writer.Write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if out.String() != tt.want {
+				t.Errorf("Writer wrote %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveImage(t *testing.T) {
+	dir := t.TempDir()
+	columns := []schema.Column{{Name: "ImageName", Type: schema.String}, {Name: "ImageData", Type: schema.Bytes}}
+	tests := []struct {
+		name     string
+		values   []interface{}
+		wantPath string
+		wantErr  bool
+	}{
+		{"Writes image", []interface{}{"sub/my_3.png", []byte{1, 2}}, filepath.Join(dir, "my_3.png"), false},
+		{"Skips records without image", []interface{}{"my_3.png", nil}, "", false},
+		{"Requires a name", []interface{}{"", []byte{1, 2}}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := SaveImage(&Record{File: "CCD.parquet", Columns: columns, Values: tt.values}, dir)
+			if (err != nil) != tt.wantErr || path != tt.wantPath {
+				t.Fatalf("SaveImage() = %v, %v, want %v, error %v", path, err, tt.wantPath, tt.wantErr)
+			}
+			if path == "" {
+				return
+			}
+			content, err := os.ReadFile(path)
+			if err != nil || !bytes.Equal(content, tt.values[1].([]byte)) {
+				t.Errorf("SaveImage() wrote %v, %v, want %v", content, err, tt.values[1])
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package query
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	goparquet "github.com/fraugster/parquet-go"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+// Record is a row read back from a parquet or csv output
+type Record struct {
+	File    string
+	Stream  timeseries.OutStream
+	Columns []schema.Column
+	Values  []interface{} // As given by schema.Values, nil if missing
+}
+
+// Value returns the value of the named column and if the record has it
+func (record *Record) Value(name string) (interface{}, bool) {
+	idx := schema.Index(record.Columns, name)
+	if idx < 0 {
+		return nil, false
+	}
+	return record.Values[idx], true
+}
+
+// Files returns the parquet and csv files of the paths sorted, directories
+// are searched for them
+//
+// Hidden files, such as those still being written, are left out and files
+// are listed once.
+func Files(paths ...string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() || (name != path && strings.HasPrefix(entry.Name(), ".")) {
+				return nil
+			}
+			switch strings.ToLower(filepath.Ext(name)) {
+			case ".parquet", ".csv":
+				if !seen[name] {
+					seen[name] = true
+					files = append(files, name)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// fileColumns returns the columns of the stream with the names in order,
+// columns the stream doesn't know have no type
+func fileColumns(stream timeseries.OutStream, names []string) []schema.Column {
+	known := stream.Columns()
+	columns := make([]schema.Column, len(names))
+	for idx, name := range names {
+		if knownIdx := schema.Index(known, name); knownIdx >= 0 {
+			columns[idx] = known[knownIdx]
+		} else {
+			columns[idx] = schema.Column{Name: name, Optional: true}
+		}
+	}
+	return columns
+}
+
+// ReadFile reads the parquet or csv file passing each record to visit
+//
+// Reading stops at the first error visit returns, which is wrapped in the
+// error returned. Files of streams that skip returns true for are not read.
+func ReadFile(name string, skip func(timeseries.OutStream) bool, visit func(*Record) error) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if strings.EqualFold(filepath.Ext(name), ".csv") {
+		err = readCSV(name, file, skip, visit)
+	} else {
+		err = readParquet(name, file, skip, visit)
+	}
+	if err != nil {
+		return fmt.Errorf("could not read %v: %w", name, err)
+	}
+	return nil
+}
+
+// Header returns the stream and the column names of a parquet or csv file
+func Header(name string) (timeseries.OutStream, []string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return timeseries.Unknown, nil, err
+	}
+	defer file.Close()
+	var names []string
+	if strings.EqualFold(filepath.Ext(name), ".csv") {
+		reader := csv.NewReader(file)
+		reader.FieldsPerRecord = -1
+		names, err = csvNames(reader)
+	} else {
+		var reader *goparquet.FileReader
+		reader, err = goparquet.NewFileReader(file)
+		if err == nil {
+			names = parquetNames(reader)
+		}
+	}
+	if err != nil {
+		return timeseries.Unknown, nil, fmt.Errorf("could not read %v: %w", name, err)
+	}
+	return timeseries.OutStreamFromFile(name, names), names, nil
+}
+
+// Specifications returns the key value pairs of the specifications row of a
+// csv or of the metadata of a parquet file, the latter sorted by key
+func Specifications(name string) ([]string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if strings.EqualFold(filepath.Ext(name), ".csv") {
+		reader := csv.NewReader(file)
+		reader.FieldsPerRecord = -1
+		specifications, err := reader.Read()
+		if err == io.EOF {
+			return nil, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("could not read %v: %w", name, err)
+		}
+		return specifications, nil
+	}
+	reader, err := goparquet.NewFileReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %v: %w", name, err)
+	}
+	metadata := reader.MetaData()
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	specifications := make([]string, 0, 2*len(keys))
+	for _, key := range keys {
+		specifications = append(specifications, key, metadata[key])
+	}
+	return specifications, nil
+}
+
+func readParquet(
+	name string,
+	file io.ReadSeeker,
+	skip func(timeseries.OutStream) bool,
+	visit func(*Record) error,
+) error {
+	reader, err := goparquet.NewFileReader(file)
+	if err != nil {
+		return err
+	}
+	names := parquetNames(reader)
+	stream := timeseries.OutStreamFromFile(name, names)
+	if skip != nil && skip(stream) {
+		return nil
+	}
+	columns := fileColumns(stream, names)
+	for {
+		row, err := reader.NextRow()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		record := Record{File: name, Stream: stream, Columns: columns, Values: make([]interface{}, len(columns))}
+		for idx, column := range columns {
+			record.Values[idx] = fromParquet(column, row[column.Name])
+		}
+		if err := visit(&record); err != nil {
+			return err
+		}
+	}
+}
+
+// parquetNames returns the column names of a parquet file
+func parquetNames(reader *goparquet.FileReader) []string {
+	var names []string
+	for _, column := range reader.GetSchemaDefinition().RootColumn.Children {
+		names = append(names, column.SchemaElement.Name)
+	}
+	return names
+}
+
+// fromParquet returns the value read from parquet as by schema.Values
+func fromParquet(column schema.Column, value interface{}) interface{} {
+	switch v := value.(type) {
+	case int32:
+		return int64(v)
+	case int64:
+		if column.Type == schema.Timestamp {
+			return time.Unix(0, v).UTC()
+		}
+		return v
+	case []byte:
+		if column.Type == schema.Bytes {
+			return v
+		}
+		return string(v)
+	case map[string]interface{}:
+		elements := listElements(v["list"])
+		if column.Type == schema.IntList {
+			list := make([]int64, 0, len(elements))
+			for _, element := range elements {
+				if number, ok := fromParquet(schema.Column{}, element["element"]).(int64); ok {
+					list = append(list, number)
+				}
+			}
+			return list
+		}
+		list := make([]string, 0, len(elements))
+		for _, element := range elements {
+			list = append(list, fmt.Sprintf("%v", fromParquet(schema.Column{}, element["element"])))
+		}
+		return list
+	default:
+		return v
+	}
+}
+
+// listElements returns the elements of a parquet list
+func listElements(list interface{}) []map[string]interface{} {
+	switch entries := list.(type) {
+	case []map[string]interface{}:
+		return entries
+	case []interface{}:
+		elements := make([]map[string]interface{}, 0, len(entries))
+		for _, entry := range entries {
+			if element, ok := entry.(map[string]interface{}); ok {
+				elements = append(elements, element)
+			}
+		}
+		return elements
+	}
+	return nil
+}
+
+func readCSV(
+	name string,
+	file io.Reader,
+	skip func(timeseries.OutStream) bool,
+	visit func(*Record) error,
+) error {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	names, err := csvNames(reader)
+	if err != nil || names == nil {
+		return err
+	}
+	stream := timeseries.OutStreamFromFile(name, names)
+	if skip != nil && skip(stream) {
+		return nil
+	}
+	columns := fileColumns(stream, names)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		record := Record{File: name, Stream: stream, Columns: columns, Values: make([]interface{}, len(columns))}
+		for idx, column := range columns {
+			if idx < len(row) {
+				record.Values[idx] = fromCSV(column, row[idx])
+			}
+		}
+		if err := visit(&record); err != nil {
+			return err
+		}
+	}
+}
+
+// csvNames returns the column names of the header row of a csv after its
+// specifications row, nil if the csv is empty
+func csvNames(reader *csv.Reader) ([]string, error) {
+	if _, err := reader.Read(); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+	headers, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("missing header row")
+	} else if err != nil {
+		return nil, err
+	}
+	return timeseries.ColumnNamesFromCSV(headers), nil
+}
+
+// fromCSV returns the value of the text written by schema.FormatCSV, the
+// text itself if it can't be parsed as the type of the column
+func fromCSV(column schema.Column, text string) interface{} {
+	if text == "" && column.Type != schema.String && column.Type != "" {
+		return nil
+	}
+	var value interface{}
+	var err error
+	switch column.Type {
+	case schema.Bool:
+		value, err = strconv.ParseBool(text)
+	case schema.Int32, schema.Int64:
+		value, err = strconv.ParseInt(text, 10, 64)
+	case schema.Double:
+		value, err = strconv.ParseFloat(text, 64)
+	case schema.Timestamp:
+		value, err = time.Parse(time.RFC3339Nano, text)
+	case schema.IntList:
+		list := []int64{}
+		for _, field := range strings.Fields(strings.Trim(text, "[]")) {
+			number, parseErr := strconv.ParseInt(field, 10, 64)
+			if parseErr != nil {
+				err = parseErr
+			}
+			list = append(list, number)
+		}
+		value = list
+	case schema.StringList:
+		value = strings.Split(text, "|")
+	default:
+		value = text
+	}
+	if err != nil {
+		return text
+	}
+	return value
+}
@@ -0,0 +1,194 @@
+package query
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/common/commontest"
+	"github.com/innosat-mats/rac-extract-payload/internal/exports"
+	"github.com/innosat-mats/rac-extract-payload/internal/innosat"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+// testRecords are the common test records with an error for the CCD record
+func testRecords() []common.DataRecord {
+	records := commontest.Records()
+	records[1].Error = os.ErrNotExist
+	return records
+}
+
+// writeTestOutputs writes the test records as parquet and csv below dir
+func writeTestOutputs(t *testing.T, dir string) {
+	pool := exports.NewImagePool(1)
+	for _, factory := range []func() (common.Callback, common.CallbackTeardown){
+		func() (common.Callback, common.CallbackTeardown) {
+			return exports.ParquetCallbackFactory(filepath.Join(dir, "parquet"), pool)
+		},
+		func() (common.Callback, common.CallbackTeardown) {
+			return exports.DiskCallbackFactory(filepath.Join(dir, "csv"), false, true, pool, exports.CSVOptions{})
+		},
+	} {
+		callback, teardown := factory()
+		for _, pkg := range testRecords() {
+			callback(pkg)
+		}
+		teardown()
+	}
+}
+
+func readAll(t *testing.T, dir string) []*Record {
+	files, err := Files(dir)
+	if err != nil {
+		t.Fatalf("Files() error = %v", err)
+	}
+	var records []*Record
+	for _, file := range files {
+		err := ReadFile(file, nil, func(record *Record) error {
+			records = append(records, record)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+	}
+	return records
+}
+
+func TestReadFile(t *testing.T) {
+	dir := t.TempDir()
+	writeTestOutputs(t, dir)
+	for _, format := range []string{"csv", "parquet"} {
+		t.Run(format, func(t *testing.T) {
+			records := readAll(t, filepath.Join(dir, format))
+			if len(records) != 2 {
+				t.Fatalf("ReadFile() read %v records, want 2", len(records))
+			}
+			byStream := make(map[timeseries.OutStream]*Record)
+			for _, record := range records {
+				byStream[record.Stream] = record
+			}
+			htr := byStream[timeseries.HTR]
+			ccd := byStream[timeseries.CCD]
+			if htr == nil || ccd == nil {
+				t.Fatalf("ReadFile() read streams %v, want HTR and CCD", records)
+			}
+			want := (&innosat.TMHeader{CUCTimeSeconds: 20}).Time(time.Time{})
+			checks := []struct {
+				record *Record
+				column string
+				want   interface{}
+			}{
+				{htr, "SID", "HTR"},
+				{htr, "HTR1A", htr.Values[mustIndex(t, htr, "HTR1A")]},
+				{ccd, "TMHeaderTime", want},
+				{ccd, "RID", "CCD3"},
+				{ccd, "NROW", int64(2)},
+				{ccd, "BadColumns", []int64{3, 4}},
+				{ccd, "Errors", []string{os.ErrNotExist.Error() + " [my.rac / Packet ID 0 / VC Frame Counter 4 / Date 8405, Time 0]"}},
+			}
+			for _, check := range checks {
+				got, ok := check.record.Value(check.column)
+				if !ok || !reflect.DeepEqual(got, check.want) {
+					t.Errorf("Record.Value(%v) = %#v, %v, want %#v", check.column, got, ok, check.want)
+				}
+			}
+			if _, ok := htr.Values[mustIndex(t, htr, "HTR1A")].(float64); !ok {
+				t.Errorf("Record HTR1A = %#v, want a number", htr.Values[mustIndex(t, htr, "HTR1A")])
+			}
+			image, _ := ccd.Value("ImageData")
+			if _, isPNG := image.([]byte); isPNG != (format == "parquet") {
+				t.Errorf("Record ImageData = %T, want bytes only from parquet", image)
+			}
+		})
+	}
+}
+
+func mustIndex(t *testing.T, record *Record, name string) int {
+	for idx, column := range record.Columns {
+		if column.Name == name {
+			return idx
+		}
+	}
+	t.Fatalf("Record has no column %v", name)
+	return -1
+}
+
+func TestReadFile_skip(t *testing.T) {
+	dir := t.TempDir()
+	writeTestOutputs(t, dir)
+	files, err := Files(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		err := ReadFile(
+			file,
+			func(stream timeseries.OutStream) bool { return stream != timeseries.HTR },
+			func(record *Record) error {
+				if record.Stream != timeseries.HTR {
+					t.Errorf("ReadFile() read %v of skipped stream %v", file, record.Stream)
+				}
+				return nil
+			},
+		)
+		if err != nil {
+			t.Errorf("ReadFile() error = %v", err)
+		}
+	}
+}
+
+func TestSpecifications(t *testing.T) {
+	dir := t.TempDir()
+	writeTestOutputs(t, dir)
+	files, err := Files(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		specifications, err := Specifications(file)
+		if err != nil {
+			t.Fatalf("Specifications() error = %v", err)
+		}
+		found := make(map[string]string)
+		for idx := 0; idx+1 < len(specifications); idx += 2 {
+			found[specifications[idx]] = specifications[idx+1]
+		}
+		if found["CODE"] == "" || found["AEZ"] == "" {
+			t.Errorf("Specifications(%v) = %v, want CODE and AEZ", file, specifications)
+		}
+		if _, ok := found["ORIGIN"]; ok != (filepath.Ext(file) == ".parquet") {
+			t.Errorf("Specifications(%v) = %v, want ORIGIN only in parquet", file, specifications)
+		}
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"HTR.csv", ".HTR.csv.tmp.csv", "notes.txt", filepath.Join("CCD", "my.parquet")} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte{}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := Files(dir, filepath.Join(dir, "HTR.csv"))
+	if err != nil {
+		t.Fatalf("Files() error = %v", err)
+	}
+	want := []string{
+		filepath.Join(dir, "CCD", "my.parquet"),
+		filepath.Join(dir, "HTR.csv"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Files() = %v, want %v", got, want)
+	}
+	if _, err := Files(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Files() of missing path gave no error")
+	}
+}
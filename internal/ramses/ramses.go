@@ -5,11 +5,10 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"time"
 
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 // Ramses data header
@@ -34,8 +33,9 @@ func NewRamses(buf io.Reader) (*Ramses, error) {
 	ramses := Ramses{}
 	size := binary.Size(ramses)
 	tmpBuf := make([]byte, size)
-	n, err := buf.Read(tmpBuf)
-	if err != nil && err != io.EOF {
+	// A connection may return less than asked for even if more is coming
+	n, err := io.ReadFull(buf, tmpBuf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
 		return nil, err
 	}
 	if n == 0 {
@@ -73,18 +73,18 @@ func (ramses *Ramses) CSVSpecifications() []string {
 	return []string{"RAMSES", Specification}
 }
 
-// CSVHeaders returns the field names
-func (ramses *Ramses) CSVHeaders() []string {
-	return []string{
-		"RamsesTime",
-	}
+type ramsesColumns struct {
+	RamsesTime time.Time `description:"The time when the ramses file was created"`
 }
 
-// CSVRow returns the field values
-func (ramses *Ramses) CSVRow() []string {
-	return []string{
-		fmt.Sprintf("%v", ramses.Created().Format(time.RFC3339Nano)),
-	}
+// Columns returns the exported columns
+func (ramses *Ramses) Columns() []schema.Column {
+	return schema.Columns(ramsesColumns{})
+}
+
+// Values returns the exported values
+func (ramses *Ramses) Values() []interface{} {
+	return schema.Values(ramsesColumns{RamsesTime: ramses.Created()})
 }
 
 // MarshalJSON makes a custom json of what is of interest in the struct
@@ -97,8 +97,3 @@ func (ramses *Ramses) MarshalJSON() ([]byte, error) {
 		RamsesTime:    ramses.Created().Format(time.RFC3339Nano),
 	})
 }
-
-// SetParquet sets the parquet representation of the Ramses header
-func (ramses *Ramses) SetParquet(row *parquetrow.ParquetRow) {
-	row.RamsesTime = ramses.Created()
-}
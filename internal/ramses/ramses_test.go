@@ -8,7 +8,7 @@ import (
 	"testing"
 	"time"
 
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 func TestRamses_Created(t *testing.T) {
@@ -134,8 +134,8 @@ func TestRamses_CSVHeaders(t *testing.T) {
 				Time:   tt.fields.Time,
 				Date:   tt.fields.Date,
 			}
-			if got := ramses.CSVHeaders(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("Ramses.CSVHeaders() = %v, want %v", got, tt.want)
+			if got := schema.CSVHeaders(ramses.Columns()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schema.CSVHeaders() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -173,8 +173,8 @@ func TestRamses_CSVRow(t *testing.T) {
 				Time:   tt.fields.Time,
 				Date:   tt.fields.Date,
 			}
-			if got := ramses.CSVRow(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("Ramses.CSVRow() = %v, want %v", got, tt.want)
+			if got := schema.CSVRow(ramses.Columns(), ramses.Values()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schema.CSVRow() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -317,13 +317,24 @@ func TestNewRamses(t *testing.T) {
 	}
 }
 
-func TestRamses_SetParquet(t *testing.T) {
+func TestRamses_Values(t *testing.T) {
 	ramses := Ramses{Date: 24, Time: 42}
-	want := parquetrow.ParquetRow{
-		RamsesTime: ramses.Created(),
+	want := map[string]interface{}{
+		"RamsesTime": ramses.Created(),
 	}
-	row := parquetrow.ParquetRow{}
-	if ramses.SetParquet(&row); !reflect.DeepEqual(row, want) {
-		t.Errorf("Ramses.SetParquet() = %v, want %v", row, want)
+	columns := ramses.Columns()
+	values := ramses.Values()
+	if len(values) != len(columns) {
+		t.Fatalf("Ramses.Values() has %v values, want %v", len(values), len(columns))
+	}
+	for name, value := range want {
+		idx := schema.Index(columns, name)
+		if idx < 0 {
+			t.Errorf("Ramses.Columns() lacks %v", name)
+			continue
+		}
+		if !reflect.DeepEqual(values[idx], value) {
+			t.Errorf("Ramses.Values() %v = %v, want %v", name, values[idx], value)
+		}
 	}
 }
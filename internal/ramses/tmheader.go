@@ -3,9 +3,8 @@ package ramses
 import (
 	"encoding/binary"
 	"io"
-	"strconv"
 
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 // QualityIndicator indicates whether the transported data is complete or partial
@@ -31,9 +30,9 @@ const (
 // TMHeader is the OHBSE CCSDS TM Packet Header in the specification
 type TMHeader struct {
 	_                [8]byte
-	QualityIndicator QualityIndicator `json:"qualityIndicator"` // QualityIndicator indicates whether the transported data is complete or partial (0 = Complete, 1 = partial)
-	LossFlag         LossFlag         `json:"lossFlag"`         // LossFlag is used to indicate that a sequence discontinuity has been detected
-	VCFrameCounter   uint8            `json:"vcFrameCounter"`   // VCFrameCounter is a counter of the transfer fram the payload packet arrived in
+	QualityIndicator QualityIndicator `json:"qualityIndicator" description:"Indicates whether the transported data is complete or partial (0 = Complete, 1 = partial)"`
+	LossFlag         LossFlag         `json:"lossFlag" description:"Used to indicate that a sequence discontinuity has been detected"`
+	VCFrameCounter   uint8            `json:"vcFrameCounter" description:"Counter of the transfer frame the payload packet arrived in, wraps at 255"`
 	_                [5]byte
 }
 
@@ -44,27 +43,12 @@ func NewTMHeader(buf io.Reader) (*TMHeader, error) {
 	return &header, err
 }
 
-// CSVHeaders returns the field names
-func (header *TMHeader) CSVHeaders() []string {
-	return []string{
-		"QualityIndicator",
-		"LossFlag",
-		"VCFrameCounter",
-	}
+// Columns returns the exported columns
+func (header *TMHeader) Columns() []schema.Column {
+	return schema.Columns(TMHeader{})
 }
 
-// CSVRow returns the field values
-func (header *TMHeader) CSVRow() []string {
-	return []string{
-		strconv.Itoa(int(header.QualityIndicator)),
-		strconv.Itoa(int(header.LossFlag)),
-		strconv.Itoa(int(header.VCFrameCounter)),
-	}
-}
-
-// SetParquet sets the parquet representation of the TMHeader
-func (header *TMHeader) SetParquet(row *parquetrow.ParquetRow) {
-	row.QualityIndicator = uint8(header.QualityIndicator)
-	row.LossFlag = uint8(header.LossFlag)
-	row.VCFrameCounter = header.VCFrameCounter
+// Values returns the exported values
+func (header *TMHeader) Values() []interface{} {
+	return schema.Values(header)
 }
@@ -4,13 +4,13 @@ import (
 	"reflect"
 	"testing"
 
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 func TestTMHeader_CSVHeader(t *testing.T) {
 	header := TMHeader{}
 	want := []string{"QualityIndicator", "LossFlag", "VCFrameCounter"}
-	if got := header.CSVHeaders(); !reflect.DeepEqual(got, want) {
+	if got := schema.CSVHeaders(header.Columns()); !reflect.DeepEqual(got, want) {
 		t.Errorf("TMHeader.CSVHeader() = %v, wnat %v", got, want)
 	}
 }
@@ -24,25 +24,36 @@ func TestTMHeader_CSVRow(t *testing.T) {
 
 	want := []string{"0", "1", "42"}
 
-	if got := header.CSVRow(); !reflect.DeepEqual(got, want) {
+	if got := schema.CSVRow(header.Columns(), header.Values()); !reflect.DeepEqual(got, want) {
 		t.Errorf("TMHeader.CSVROW() = %v, want %v", got, want)
 	}
 }
 
-func TestTMHeader_SetParquet(t *testing.T) {
+func TestTMHeader_Values(t *testing.T) {
 	header := TMHeader{
 		QualityIndicator: CompletePacket,
 		LossFlag:         Discontinuities,
 		VCFrameCounter:   42,
 	}
 
-	want := parquetrow.ParquetRow{
-		QualityIndicator: 0,
-		LossFlag:         1,
-		VCFrameCounter:   42,
+	want := map[string]interface{}{
+		"QualityIndicator": int64(0),
+		"LossFlag":         int64(1),
+		"VCFrameCounter":   int64(42),
+	}
+	columns := header.Columns()
+	values := header.Values()
+	if len(values) != len(columns) {
+		t.Fatalf("TMHeader.Values() has %v values, want %v", len(values), len(columns))
 	}
-	row := parquetrow.ParquetRow{}
-	if header.SetParquet(&row); !reflect.DeepEqual(row, want) {
-		t.Errorf("TMHeader.SetParquet() = %v, want %v", row, want)
+	for name, value := range want {
+		idx := schema.Index(columns, name)
+		if idx < 0 {
+			t.Errorf("TMHeader.Columns() lacks %v", name)
+			continue
+		}
+		if !reflect.DeepEqual(values[idx], value) {
+			t.Errorf("TMHeader.Values() %v = %v, want %v", name, values[idx], value)
+		}
 	}
 }
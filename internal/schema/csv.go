@@ -0,0 +1,62 @@
+package schema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVHeaders returns the names of the columns exported to CSV
+func CSVHeaders(columns []Column) []string {
+	var headers []string
+	for _, column := range columns {
+		if column.InCSV() {
+			headers = append(headers, column.CSVHeader())
+		}
+	}
+	return headers
+}
+
+// CSVRow returns the values of the columns exported to CSV
+func CSVRow(columns []Column, values []interface{}) []string {
+	var row []string
+	for i, column := range columns {
+		if !column.InCSV() {
+			continue
+		}
+		var value interface{}
+		if i < len(values) {
+			value = values[i]
+		}
+		if moment, ok := value.(time.Time); ok && column.CSVLayout != "" {
+			row = append(row, moment.Format(column.CSVLayout))
+			continue
+		}
+		row = append(row, FormatCSV(value))
+	}
+	return row
+}
+
+// FormatCSV returns the CSV representation of a value
+//
+// Missing values are empty, times are RFC3339 with nanoseconds and the
+// entries of text lists are separated by '|'.
+func FormatCSV(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []string:
+		return strings.Join(v, "|")
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,95 @@
+package schema
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCSVHeaders(t *testing.T) {
+	want := []string{"Flag", "Small", "Large", "Value", "Name", "Time", "Columns", "Warnings", "Code"}
+	if got := CSVHeaders(Columns(testReport{})); !reflect.DeepEqual(got, want) {
+		t.Errorf("CSVHeaders() = %v, want %v", got, want)
+	}
+}
+
+func TestCSVRow(t *testing.T) {
+	columns := Columns(testReport{})
+	values := []interface{}{
+		true, int64(4), int64(-5), 0.5, "Test", time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC),
+		[]byte{1}, []int64{6, 7}, []string{"a", "b"}, nil,
+	}
+	want := []string{"true", "4", "-5", "0.5", "Test", "2020-01-02T03:04:05.000000006Z", "[6 7]", "a|b", ""}
+	if got := CSVRow(columns, values); !reflect.DeepEqual(got, want) {
+		t.Errorf("CSVRow() = %v, want %v", got, want)
+	}
+}
+
+func TestCSVHeaders_CSVName(t *testing.T) {
+	type renamed struct {
+		Time  time.Time `csv:"TIME" csvlayout:"2006-01-02T15:04:05Z07:00"`
+		Value int
+	}
+	columns := Columns(renamed{})
+	want := []string{"TIME", "Value"}
+	if got := CSVHeaders(columns); !reflect.DeepEqual(got, want) {
+		t.Errorf("CSVHeaders() = %v, want %v", got, want)
+	}
+	if got := Names(columns); !reflect.DeepEqual(got, []string{"Time", "Value"}) {
+		t.Errorf("Names() = %v, want [Time Value]", got)
+	}
+	row := CSVRow(columns, []interface{}{time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC), int64(1)})
+	if want := []string{"2020-01-02T03:04:05Z", "1"}; !reflect.DeepEqual(row, want) {
+		t.Errorf("CSVRow() = %v, want %v", row, want)
+	}
+	if got := CSVIndex(columns, "TIME"); got != 0 {
+		t.Errorf("CSVIndex() = %v, want 0", got)
+	}
+	if got := Names(CSVNamed(columns)); !reflect.DeepEqual(got, want) {
+		t.Errorf("CSVNamed() = %v, want %v", got, want)
+	}
+}
+
+func TestFormatCSV(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"nil", nil, ""},
+		{"bool", false, "false"},
+		{"int", int64(42), "42"},
+		{"float", 1.25, "1.25"},
+		{"NaN", math.NaN(), "NaN"},
+		{"Inf", math.Inf(-1), "-Inf"},
+		{"string", "text", "text"},
+		{"time", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), "2020-01-02T03:04:05Z"},
+		{"empty list", []string{}, ""},
+		{"list", []string{"a", "b"}, "a|b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatCSV(tt.value); got != tt.want {
+				t.Errorf("FormatCSV() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVHeaders_NoCSV(t *testing.T) {
+	type parquetOnly struct {
+		Value    int
+		Warnings []error `csv:"-" optional:"true"`
+	}
+	columns := Columns(parquetOnly{})
+	if !columns[1].NoCSV || columns[1].CSVName != "" {
+		t.Errorf("Columns() = %v, want Warnings left out of CSV", columns)
+	}
+	if got := CSVHeaders(columns); !reflect.DeepEqual(got, []string{"Value"}) {
+		t.Errorf("CSVHeaders() = %v, want [Value]", got)
+	}
+	if got := CSVRow(columns, []interface{}{int64(1), []string{"a"}}); !reflect.DeepEqual(got, []string{"1"}) {
+		t.Errorf("CSVRow() = %v, want [1]", got)
+	}
+}
@@ -0,0 +1,77 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+const helpWidth = 78
+
+// Describe returns a help text listing the columns with unit and description
+func Describe(columns []Column) string {
+	var lines []string
+	for _, column := range columns {
+		title := fmt.Sprintf("- %v", column.Name)
+		if column.NoCSV {
+			title = fmt.Sprintf("%v (not in CSV)", title)
+		} else if column.CSVHeader() != column.Name {
+			title = fmt.Sprintf("%v (CSV: %v)", title, column.CSVHeader())
+		}
+		if column.Unit != "" {
+			title = fmt.Sprintf("%v (%v)", title, column.Unit)
+		}
+		lines = append(lines, title)
+		lines = append(lines, wrap(column.Description, "  ", helpWidth)...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Markdown returns a table of the columns with type, unit and description
+func Markdown(columns []Column) string {
+	lines := []string{
+		"| Column | Type | Unit | Description |",
+		"| --- | --- | --- | --- |",
+	}
+	escape := strings.NewReplacer("|", "\\|", "\n", " ")
+	for _, column := range columns {
+		columnType := string(column.Type)
+		if column.Optional {
+			columnType += ", optional"
+		}
+		name := column.Name
+		if column.NoCSV {
+			name = fmt.Sprintf("%v (not in CSV)", name)
+		} else if column.CSVHeader() != column.Name {
+			name = fmt.Sprintf("%v (CSV: %v)", name, column.CSVHeader())
+		}
+		lines = append(lines, fmt.Sprintf(
+			"| %v | %v | %v | %v |",
+			name,
+			columnType,
+			escape.Replace(column.Unit),
+			escape.Replace(column.Description),
+		))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// wrap splits text into indented lines of at most width characters
+// unless a single word is longer
+func wrap(text string, indent string, width int) []string {
+	var lines []string
+	line := indent
+	for _, word := range strings.Fields(text) {
+		if line != indent && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = indent
+		}
+		if line != indent {
+			line += " "
+		}
+		line += word
+	}
+	if line != indent {
+		lines = append(lines, line)
+	}
+	return lines
+}
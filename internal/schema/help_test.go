@@ -0,0 +1,52 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDescribe(t *testing.T) {
+	columns := []Column{
+		{Name: "HTR1A", Unit: "⁰C", Description: "Heater 1 temperature sense A"},
+		{Name: "SID"},
+	}
+	want := "- HTR1A (⁰C)\n  Heater 1 temperature sense A\n- SID"
+	if got := Describe(columns); got != want {
+		t.Errorf("Describe() = %q, want %q", got, want)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	columns := []Column{
+		{Name: "HTR1A", Type: Double, Unit: "⁰C", Description: "Heater 1 | sense A"},
+		{Name: "Errors", Type: StringList, Optional: true},
+	}
+	want := "| Column | Type | Unit | Description |\n" +
+		"| --- | --- | --- | --- |\n" +
+		"| HTR1A | double | ⁰C | Heater 1 \\| sense A |\n" +
+		"| Errors | string-list, optional |  |  |"
+	if got := Markdown(columns); got != want {
+		t.Errorf("Markdown() = %q, want %q", got, want)
+	}
+}
+
+func Test_wrap(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		width int
+		want  []string
+	}{
+		{"Empty", "", 10, nil},
+		{"Fits", "a b c", 10, []string{"  a b c"}},
+		{"Wraps", "aaa bbb ccc", 9, []string{"  aaa bbb", "  ccc"}},
+		{"Long word", "aaaaaaaaaaaa b", 5, []string{"  aaaaaaaaaaaa", "  b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wrap(tt.text, "  ", tt.width); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wrap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+)
+
+// MarshalJSON returns the columns as a json object in column order
+//
+// Missing and non-finite values are null and binary columns are left out.
+func MarshalJSON(columns []Column, values []interface{}) ([]byte, error) {
+	return marshalJSON(columns, values, false)
+}
+
+// MarshalJSONWithBytes is as MarshalJSON but keeps binary columns as base64
+// encoded strings
+func MarshalJSONWithBytes(columns []Column, values []interface{}) ([]byte, error) {
+	return marshalJSON(columns, values, true)
+}
+
+func marshalJSON(columns []Column, values []interface{}, withBytes bool) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	first := true
+	for i, column := range columns {
+		if column.Type == Bytes && !withBytes {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		name, err := json.Marshal(column.Name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		var value interface{}
+		if i < len(values) {
+			value = values[i]
+		}
+		if number, ok := value.(float64); ok && (math.IsNaN(number) || math.IsInf(number, 0)) {
+			value = nil
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(encoded)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,72 @@
+package schema
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	columns := []Column{
+		{Name: "B", Type: Double},
+		{Name: "A", Type: Double},
+		{Name: "Data", Type: Bytes},
+		{Name: "Text", Type: String},
+		{Name: "List", Type: StringList},
+		{Name: "Missing", Type: Int32},
+	}
+	tests := []struct {
+		name   string
+		values []interface{}
+		want   string
+	}{
+		{
+			"Keeps column order and skips bytes",
+			[]interface{}{2.5, 1.0, []byte{1}, "x", []string{"w"}, int64(3)},
+			`{"B":2.5,"A":1,"Text":"x","List":["w"],"Missing":3}`,
+		},
+		{
+			"Non-finite and missing values are null",
+			[]interface{}{math.NaN(), math.Inf(1), nil, "x"},
+			`{"B":null,"A":null,"Text":"x","List":null,"Missing":null}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MarshalJSON(columns, tt.values)
+			if err != nil {
+				t.Errorf("MarshalJSON() error = %v", err)
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("MarshalJSON() = %v, want %v", string(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalJSONWithBytes(t *testing.T) {
+	columns := []Column{
+		{Name: "A", Type: Double},
+		{Name: "Data", Type: Bytes},
+	}
+	tests := []struct {
+		name   string
+		values []interface{}
+		want   string
+	}{
+		{"Bytes as base64", []interface{}{1.5, []byte("png")}, `{"A":1.5,"Data":"cG5n"}`},
+		{"Missing bytes are null", []interface{}{1.5, []byte(nil)}, `{"A":1.5,"Data":null}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MarshalJSONWithBytes(columns, tt.values)
+			if err != nil {
+				t.Errorf("MarshalJSONWithBytes() error = %v", err)
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("MarshalJSONWithBytes() = %v, want %v", string(got), tt.want)
+			}
+		})
+	}
+}
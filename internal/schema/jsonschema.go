@@ -0,0 +1,139 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// JSONSchemaDialect is the version of JSON Schema of ObjectSchema
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// ObjectSchema is the JSON Schema of the json objects of the columns, as
+// written by MarshalJSON
+//
+// All columns are required since missing values are null, except binary
+// columns that are only present as base64 when written by
+// MarshalJSONWithBytes.
+type ObjectSchema struct {
+	Dialect     string // The $schema, empty when embedded in another schema
+	Title       string
+	Description string
+	Columns     []Column
+}
+
+// propertySchema is the JSON Schema of the values of a column
+type propertySchema struct {
+	Type        interface{}     `json:"type"`
+	Format      string          `json:"format,omitempty"`
+	Encoding    string          `json:"contentEncoding,omitempty"`
+	Items       *propertySchema `json:"items,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Unit        string          `json:"x-unit,omitempty"`
+}
+
+func newPropertySchema(column Column) *propertySchema {
+	property := propertySchema{Description: column.Description, Unit: column.Unit}
+	var jsonType string
+	nullable := column.Optional
+	switch column.Type {
+	case Bool:
+		jsonType = "boolean"
+	case Int32, Int64:
+		jsonType = "integer"
+	case Double:
+		// Non-finite values are null
+		jsonType = "number"
+		nullable = true
+	case Timestamp:
+		jsonType = "string"
+		property.Format = "date-time"
+	case IntList:
+		jsonType = "array"
+		property.Items = &propertySchema{Type: "integer"}
+		nullable = true
+	case StringList:
+		jsonType = "array"
+		property.Items = &propertySchema{Type: "string"}
+		nullable = true
+	case Bytes:
+		jsonType = "string"
+		property.Encoding = "base64"
+		nullable = true
+	default:
+		jsonType = "string"
+	}
+	if nullable {
+		property.Type = []string{jsonType, "null"}
+	} else {
+		property.Type = jsonType
+	}
+	return &property
+}
+
+// MarshalJSON returns the JSON Schema with the properties in column order
+func (object ObjectSchema) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	add := func(key string, value interface{}) error {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		name, _ := json.Marshal(key)
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(encoded)
+		return nil
+	}
+	for _, pair := range []struct {
+		key   string
+		value string
+	}{{"$schema", object.Dialect}, {"title", object.Title}, {"description", object.Description}} {
+		if pair.value != "" {
+			if err := add(pair.key, pair.value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := add("type", "object"); err != nil {
+		return nil, err
+	}
+
+	var properties bytes.Buffer
+	properties.WriteByte('{')
+	required := []string{}
+	for idx, column := range object.Columns {
+		if idx > 0 {
+			properties.WriteByte(',')
+		}
+		name, err := json.Marshal(column.Name)
+		if err != nil {
+			return nil, err
+		}
+		encoded, err := json.Marshal(newPropertySchema(column))
+		if err != nil {
+			return nil, err
+		}
+		properties.Write(name)
+		properties.WriteByte(':')
+		properties.Write(encoded)
+		if column.Type != Bytes {
+			required = append(required, column.Name)
+		}
+	}
+	properties.WriteByte('}')
+	if err := add("properties", json.RawMessage(properties.Bytes())); err != nil {
+		return nil, err
+	}
+	if err := add("required", required); err != nil {
+		return nil, err
+	}
+	if err := add("additionalProperties", false); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
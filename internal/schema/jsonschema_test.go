@@ -0,0 +1,56 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestObjectSchema_MarshalJSON(t *testing.T) {
+	columns := []Column{
+		{Name: "Time", Type: Timestamp},
+		{Name: "HTR1A", Type: Double, Unit: "⁰C", Description: "Heater 1 temperature sense A"},
+		{Name: "Data", Type: Bytes},
+		{Name: "Code", Type: Int32, Optional: true},
+		{Name: "Columns", Type: IntList},
+	}
+	tests := []struct {
+		name   string
+		object ObjectSchema
+		want   string
+	}{
+		{
+			"Embedded",
+			ObjectSchema{Columns: columns},
+			`{"type":"object","properties":{` +
+				`"Time":{"type":"string","format":"date-time"},` +
+				`"HTR1A":{"type":["number","null"],"description":"Heater 1 temperature sense A","x-unit":"⁰C"},` +
+				`"Data":{"type":["string","null"],"contentEncoding":"base64"},` +
+				`"Code":{"type":["integer","null"]},` +
+				`"Columns":{"type":["array","null"],"items":{"type":"integer"}}},` +
+				`"required":["Time","HTR1A","Code","Columns"],"additionalProperties":false}`,
+		},
+		{
+			"Document",
+			ObjectSchema{Dialect: JSONSchemaDialect, Title: "HTR", Description: "Heaters", Columns: columns[:1]},
+			`{"$schema":"https://json-schema.org/draft/2020-12/schema","title":"HTR","description":"Heaters",` +
+				`"type":"object","properties":{"Time":{"type":"string","format":"date-time"}},` +
+				`"required":["Time"],"additionalProperties":false}`,
+		},
+		{
+			"No columns",
+			ObjectSchema{},
+			`{"type":"object","properties":{},"required":[],"additionalProperties":false}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.object)
+			if err != nil {
+				t.Fatalf("ObjectSchema.MarshalJSON() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("ObjectSchema.MarshalJSON() = %v, want %v", string(got), tt.want)
+			}
+		})
+	}
+}
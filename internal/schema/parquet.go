@@ -0,0 +1,151 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/fraugster/parquet-go/floor/interfaces"
+)
+
+// ParquetSchema returns the parquet schema definition of the columns
+func ParquetSchema(columns []Column) string {
+	return ParquetMessage("schema", columns)
+}
+
+// ParquetMessage returns the parquet schema definition of the columns as a
+// message with the name
+func ParquetMessage(name string, columns []Column) string {
+	var lines []string
+	lines = append(lines, fmt.Sprintf("message %v {", name))
+	for _, column := range columns {
+		repetition := "required"
+		if column.Optional && !column.ParquetRequired {
+			repetition = "optional"
+		}
+		switch column.Type {
+		case Bool:
+			lines = append(lines, fmt.Sprintf("\t%v boolean %v;", repetition, column.Name))
+		case Int32:
+			lines = append(lines, fmt.Sprintf("\t%v int32 %v;", repetition, column.Name))
+		case Int64:
+			lines = append(lines, fmt.Sprintf("\t%v int64 %v;", repetition, column.Name))
+		case Double:
+			lines = append(lines, fmt.Sprintf("\t%v double %v;", repetition, column.Name))
+		case String:
+			lines = append(lines, fmt.Sprintf("\t%v binary %v (STRING);", repetition, column.Name))
+		case Timestamp:
+			lines = append(
+				lines,
+				fmt.Sprintf("\t%v int64 %v (TIMESTAMP(NANOS, true));", repetition, column.Name),
+			)
+		case Bytes:
+			lines = append(lines, fmt.Sprintf("\t%v binary %v;", repetition, column.Name))
+		case IntList:
+			lines = append(lines, parquetList(repetition, column.Name, "int32 element")...)
+		case StringList:
+			lines = append(lines, parquetList(repetition, column.Name, "binary element (STRING)")...)
+		}
+	}
+	lines = append(lines, "}")
+	return strings.Join(lines, "\n")
+}
+
+func parquetList(repetition string, name string, element string) []string {
+	return []string{
+		fmt.Sprintf("\t%v group %v (LIST) {", repetition, name),
+		"\t\trepeated group list {",
+		fmt.Sprintf("\t\t\trequired %v;", element),
+		"\t\t}",
+		"\t}",
+	}
+}
+
+// Row is a parquet row of values for the columns
+type Row struct {
+	Columns []Column
+	Values  []interface{}
+}
+
+// Set sets the value of the named column, returns false if there is none
+func (row *Row) Set(name string, val interface{}) bool {
+	idx := Index(row.Columns, name)
+	if idx < 0 || idx >= len(row.Values) {
+		return false
+	}
+	row.Values[idx] = value(reflect.ValueOf(val))
+	return true
+}
+
+// MarshalParquet adds the values to the parquet object, leaving missing values
+// and empty lists out unless the column is required in parquet
+func (row *Row) MarshalParquet(obj interfaces.MarshalObject) error {
+	for i, column := range row.Columns {
+		var val interface{}
+		if i < len(row.Values) {
+			val = row.Values[i]
+		}
+		if val == nil && column.ParquetRequired {
+			val = zeroValue(column.Type)
+		}
+		if val == nil {
+			continue
+		}
+		field := obj.AddField(column.Name)
+		switch value := val.(type) {
+		case bool:
+			field.SetBool(value)
+		case int64:
+			if column.Type == Int32 {
+				field.SetInt32(int32(value))
+			} else {
+				field.SetInt64(value)
+			}
+		case float64:
+			field.SetFloat64(value)
+		case string:
+			field.SetByteArray([]byte(value))
+		case time.Time:
+			field.SetInt64(value.UnixNano())
+		case []byte:
+			field.SetByteArray(value)
+		case []int64:
+			list := field.List()
+			for _, number := range value {
+				list.Add().SetInt32(int32(number))
+			}
+		case []string:
+			list := field.List()
+			for _, text := range value {
+				list.Add().SetByteArray([]byte(text))
+			}
+		default:
+			return fmt.Errorf("column %v has value %v that can't be exported", column.Name, value)
+		}
+	}
+	return nil
+}
+
+// zeroValue returns the zero value of the type as exported
+func zeroValue(columnType Type) interface{} {
+	switch columnType {
+	case Bool:
+		return false
+	case Int32, Int64:
+		return int64(0)
+	case Double:
+		return 0.0
+	case String:
+		return ""
+	case Timestamp:
+		return time.Unix(0, 0)
+	case Bytes:
+		return []byte{}
+	case IntList:
+		return []int64{}
+	case StringList:
+		return []string{}
+	}
+	return nil
+}
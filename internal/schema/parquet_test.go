@@ -0,0 +1,142 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	goparquet "github.com/fraugster/parquet-go"
+	"github.com/fraugster/parquet-go/floor"
+	"github.com/fraugster/parquet-go/parquetschema"
+)
+
+func TestParquetSchema(t *testing.T) {
+	columns := []Column{
+		{Name: "Flag", Type: Bool},
+		{Name: "Time", Type: Timestamp, Optional: true},
+		{Name: "Columns", Type: IntList},
+	}
+	want := `message schema {
+	required boolean Flag;
+	optional int64 Time (TIMESTAMP(NANOS, true));
+	required group Columns (LIST) {
+		repeated group list {
+			required int32 element;
+		}
+	}
+}`
+	if got := ParquetSchema(columns); got != want {
+		t.Errorf("ParquetSchema() = %v, want %v", got, want)
+	}
+}
+
+func TestParquetMessage(t *testing.T) {
+	want := "message HTR {\n\trequired boolean Flag;\n}"
+	if got := ParquetMessage("HTR", []Column{{Name: "Flag", Type: Bool}}); got != want {
+		t.Errorf("ParquetMessage() = %q, want %q", got, want)
+	}
+}
+
+func TestParquetSchema_Parses(t *testing.T) {
+	columns := Columns(testReport{})
+	if _, err := parquetschema.ParseSchemaDefinition(ParquetSchema(columns)); err != nil {
+		t.Errorf("ParquetSchema() could not be parsed: %v", err)
+	}
+}
+
+func TestRow_Set(t *testing.T) {
+	row := Row{Columns: Columns(testReport{}), Values: Values(testReport{})}
+	if !row.Set("Data", []byte{1, 2}) {
+		t.Error("Row.Set() = false, want true")
+	}
+	if got := row.Values[Index(row.Columns, "Data")]; !reflect.DeepEqual(got, []byte{1, 2}) {
+		t.Errorf("Row.Set() gave %v, want [1 2]", got)
+	}
+	var missing []byte
+	row.Set("Data", missing)
+	if got := row.Values[Index(row.Columns, "Data")]; got != nil {
+		t.Errorf("Row.Set() gave %v, want nil", got)
+	}
+	if row.Set("Unknown", 1) {
+		t.Error("Row.Set() = true for unknown column, want false")
+	}
+}
+
+func TestRow_MarshalParquet(t *testing.T) {
+	columns := Columns(testReport{})
+	sd, err := parquetschema.ParseSchemaDefinition(ParquetSchema(columns))
+	if err != nil {
+		t.Fatalf("could not parse schema: %v", err)
+	}
+	name := filepath.Join(t.TempDir(), "test.parquet")
+	writer, err := floor.NewFileWriter(name, goparquet.WithSchemaDefinition(sd))
+	if err != nil {
+		t.Fatalf("could not create writer: %v", err)
+	}
+	code := uint8(3)
+	report := testReport{
+		Flag:     true,
+		Time:     time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC),
+		Columns:  []uint16{6, 7},
+		Warnings: []error{},
+		Code:     &code,
+	}
+	for _, row := range []*Row{
+		{Columns: columns, Values: Values(report)},
+		{Columns: columns, Values: Values(testReport{Columns: []uint16{}})},
+	} {
+		if err := writer.Write(row); err != nil {
+			t.Errorf("Row.MarshalParquet() error = %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Errorf("could not close writer: %v", err)
+	}
+}
+
+func TestRow_MarshalParquet_ParquetRequired(t *testing.T) {
+	type required struct {
+		Code *uint8 `parquet:"required"`
+	}
+	columns := Columns(required{})
+	want := "message schema {\n\trequired int32 Code;\n}"
+	if got := ParquetSchema(columns); got != want {
+		t.Errorf("ParquetSchema() = %q, want %q", got, want)
+	}
+	if got := ParquetSchema(Union(columns)); got == want {
+		t.Errorf("ParquetSchema() of Union() = %q, want optional", got)
+	}
+	sd, err := parquetschema.ParseSchemaDefinition(want)
+	if err != nil {
+		t.Fatalf("could not parse schema: %v", err)
+	}
+	name := filepath.Join(t.TempDir(), "test.parquet")
+	writer, err := floor.NewFileWriter(name, goparquet.WithSchemaDefinition(sd))
+	if err != nil {
+		t.Fatalf("could not create writer: %v", err)
+	}
+	if err := writer.Write(&Row{Columns: columns, Values: Values(required{})}); err != nil {
+		t.Errorf("Row.MarshalParquet() error = %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("could not close writer: %v", err)
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := goparquet.NewFileReader(file)
+	if err != nil {
+		t.Fatalf("could not read %v: %v", name, err)
+	}
+	row, err := reader.NextRow()
+	if err != nil {
+		t.Fatalf("could not read row: %v", err)
+	}
+	if got := row["Code"]; got != int32(0) {
+		t.Errorf("Row.MarshalParquet() wrote Code %v, want missing value written as 0", got)
+	}
+}
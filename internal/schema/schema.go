@@ -0,0 +1,273 @@
+// Package schema describes exported data as typed columns.
+//
+// Each exporter declares its columns once as exported fields of plain structs
+// and the CSV, parquet and JSON representations as well as the help texts are
+// all derived from those. The column name is the field name and the tags
+// `unit` and `description` document the column. The tag `column:"-"` excludes
+// a field, `optional:"true"` marks a column that may lack value and pointer
+// fields are always optional. The tags `csv` and `csvlayout` keep the CSV
+// header and time format of columns that have other names or formats in CSV,
+// `csv:"-"` leaves a column out of CSV and `parquet:"required"` keeps an
+// optional column required in parquet, where missing values are zero.
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Type is the data type of a column
+type Type string
+
+const (
+	// Bool is a boolean
+	Bool Type = "bool"
+	// Int32 is an integer of at most 32 bits
+	Int32 Type = "int32"
+	// Int64 is a 64 bit integer
+	Int64 Type = "int64"
+	// Double is a 64 bit float
+	Double Type = "double"
+	// String is a text
+	String Type = "string"
+	// Timestamp is a time in UTC with nanosecond resolution
+	Timestamp Type = "timestamp"
+	// Bytes is binary data, only exported to parquet
+	Bytes Type = "bytes"
+	// IntList is a list of integers
+	IntList Type = "int-list"
+	// StringList is a list of texts
+	StringList Type = "string-list"
+)
+
+// Column describes one exported value
+type Column struct {
+	Name        string
+	CSVName     string // The CSV header if other than Name
+	CSVLayout   string // The CSV time layout if other than time.RFC3339Nano
+	Type        Type
+	Unit        string
+	Description string
+	Optional    bool
+	NoCSV       bool // Left out of CSV, as are binary columns
+	// ParquetRequired keeps an optional column required in parquet, where
+	// missing values are written as zero
+	ParquetRequired bool
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+func columnType(t reflect.Type) (Type, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return Bool, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return Int32, nil
+	case reflect.Int64, reflect.Uint64:
+		return Int64, nil
+	case reflect.Float32, reflect.Float64:
+		return Double, nil
+	case reflect.String:
+		return String, nil
+	case reflect.Struct:
+		if t == timeType {
+			return Timestamp, nil
+		}
+	case reflect.Slice:
+		elem := t.Elem()
+		switch {
+		case elem.Kind() == reflect.Uint8:
+			return Bytes, nil
+		case elem.Kind() == reflect.String || elem.Implements(errorType):
+			return StringList, nil
+		}
+		if elemType, err := columnType(elem); err == nil && (elemType == Int32 || elemType == Int64) {
+			return IntList, nil
+		}
+	}
+	return "", fmt.Errorf("type %v can't be exported", t)
+}
+
+// Columns returns the columns declared by the fields of the structs
+//
+// Panics if a field has a type that can't be exported.
+func Columns(structs ...interface{}) []Column {
+	var columns []Column
+	for _, obj := range structs {
+		t := reflect.Indirect(reflect.ValueOf(obj)).Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() || field.Tag.Get("column") == "-" {
+				continue
+			}
+			columnType, err := columnType(field.Type)
+			if err != nil {
+				panic(fmt.Sprintf("column %v: %v", field.Name, err))
+			}
+			csvName := field.Tag.Get("csv")
+			noCSV := csvName == "-"
+			if noCSV {
+				csvName = ""
+			}
+			columns = append(columns, Column{
+				Name:            field.Name,
+				CSVName:         csvName,
+				CSVLayout:       field.Tag.Get("csvlayout"),
+				Type:            columnType,
+				Unit:            field.Tag.Get("unit"),
+				Description:     field.Tag.Get("description"),
+				Optional:        field.Type.Kind() == reflect.Ptr || field.Tag.Get("optional") == "true",
+				NoCSV:           noCSV,
+				ParquetRequired: field.Tag.Get("parquet") == "required",
+			})
+		}
+	}
+	return columns
+}
+
+// Values returns the values of the columns declared by the fields of the
+// structs
+//
+// The values are nil, bool, int64, float64, string, time.Time, []byte,
+// []int64 or []string.
+func Values(structs ...interface{}) []interface{} {
+	var values []interface{}
+	for _, obj := range structs {
+		val := reflect.Indirect(reflect.ValueOf(obj))
+		t := val.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() || field.Tag.Get("column") == "-" {
+				continue
+			}
+			values = append(values, value(val.Field(i)))
+		}
+	}
+	return values
+}
+
+func value(val reflect.Value) interface{} {
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if val.IsNil() {
+			return nil
+		}
+		return value(val.Elem())
+	case reflect.Bool:
+		return val.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return val.Int()
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(val.Uint())
+	case reflect.Float32, reflect.Float64:
+		return val.Float()
+	case reflect.String:
+		return val.String()
+	case reflect.Struct:
+		return val.Interface()
+	case reflect.Slice:
+		if val.IsNil() {
+			return nil
+		}
+		if val.Type().Elem().Kind() == reflect.Uint8 {
+			return val.Bytes()
+		}
+		if val.Type().Elem().Kind() == reflect.String || val.Type().Elem().Implements(errorType) {
+			texts := make([]string, 0, val.Len())
+			for i := 0; i < val.Len(); i++ {
+				switch elem := val.Index(i).Interface().(type) {
+				case error:
+					texts = append(texts, elem.Error())
+				case nil:
+				default:
+					texts = append(texts, val.Index(i).String())
+				}
+			}
+			return texts
+		}
+		numbers := make([]int64, val.Len())
+		for i := range numbers {
+			numbers[i] = value(val.Index(i)).(int64)
+		}
+		return numbers
+	}
+	return nil
+}
+
+// Names returns the names of the columns
+func Names(columns []Column) []string {
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// InCSV returns if the column is exported to CSV
+func (column Column) InCSV() bool {
+	return column.Type != Bytes && !column.NoCSV
+}
+
+// CSVHeader returns the name of the column in CSV
+func (column Column) CSVHeader() string {
+	if column.CSVName != "" {
+		return column.CSVName
+	}
+	return column.Name
+}
+
+// Index returns the index of the named column or -1 if missing
+func Index(columns []Column, name string) int {
+	for i, column := range columns {
+		if column.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// CSVNamed returns the columns named by their CSV headers
+func CSVNamed(columns []Column) []Column {
+	named := make([]Column, len(columns))
+	for i, column := range columns {
+		column.Name = column.CSVHeader()
+		column.CSVName = ""
+		named[i] = column
+	}
+	return named
+}
+
+// CSVIndex returns the index of the column with the CSV header or -1 if
+// missing
+func CSVIndex(columns []Column, header string) int {
+	for i, column := range columns {
+		if column.CSVHeader() == header {
+			return i
+		}
+	}
+	return -1
+}
+
+// Union returns all columns in order of first appearance, all optional
+func Union(columnSets ...[]Column) []Column {
+	var columns []Column
+	seen := make(map[string]bool)
+	for _, set := range columnSets {
+		for _, column := range set {
+			if seen[column.Name] {
+				continue
+			}
+			seen[column.Name] = true
+			column.Optional = true
+			column.ParquetRequired = false
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
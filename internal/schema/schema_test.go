@@ -0,0 +1,139 @@
+package schema
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testReport struct {
+	Flag     bool    `description:"A flag"`
+	Small    uint16  `unit:"V"`
+	Large    int64   `unit:"ns"`
+	Value    float64 `unit:"⁰C" description:"A value"`
+	Name     string
+	Time     time.Time
+	Data     []byte `optional:"true"`
+	Columns  []uint16
+	Warnings []error
+	Code     *uint8
+	Skipped  int `column:"-"`
+	hidden   int
+}
+
+func TestColumns(t *testing.T) {
+	want := []Column{
+		{Name: "Flag", Type: Bool, Description: "A flag"},
+		{Name: "Small", Type: Int32, Unit: "V"},
+		{Name: "Large", Type: Int64, Unit: "ns"},
+		{Name: "Value", Type: Double, Unit: "⁰C", Description: "A value"},
+		{Name: "Name", Type: String},
+		{Name: "Time", Type: Timestamp},
+		{Name: "Data", Type: Bytes, Optional: true},
+		{Name: "Columns", Type: IntList},
+		{Name: "Warnings", Type: StringList},
+		{Name: "Code", Type: Int32, Optional: true},
+	}
+	if got := Columns(testReport{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Columns() = %v, want %v", got, want)
+	}
+}
+
+func TestColumns_Multiple(t *testing.T) {
+	type first struct{ A int }
+	type second struct{ B string }
+	want := []string{"A", "B"}
+	if got := Names(Columns(first{}, &second{})); !reflect.DeepEqual(got, want) {
+		t.Errorf("Columns() = %v, want %v", got, want)
+	}
+}
+
+func TestColumns_PanicsOnUnsupported(t *testing.T) {
+	type bad struct{ Lookup map[string]int }
+	defer func() {
+		if recover() == nil {
+			t.Error("Columns() didn't panic on unsupported type")
+		}
+	}()
+	Columns(bad{})
+}
+
+func TestValues(t *testing.T) {
+	now := time.Now()
+	code := uint8(3)
+	report := testReport{
+		Flag:     true,
+		Small:    4,
+		Large:    -5,
+		Value:    0.5,
+		Name:     "Test",
+		Time:     now,
+		Data:     []byte{1},
+		Columns:  []uint16{6, 7},
+		Warnings: []error{errors.New("warning"), nil},
+		Code:     &code,
+		Skipped:  8,
+		hidden:   9,
+	}
+	tests := []struct {
+		name   string
+		report testReport
+		want   []interface{}
+	}{
+		{
+			"Normalizes values",
+			report,
+			[]interface{}{
+				true, int64(4), int64(-5), 0.5, "Test", now, []byte{1}, []int64{6, 7}, []string{"warning"}, int64(3),
+			},
+		},
+		{
+			"Missing values are nil",
+			testReport{},
+			[]interface{}{
+				false, int64(0), int64(0), 0.0, "", time.Time{}, nil, nil, nil, nil,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Values(&tt.report); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Values() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndex(t *testing.T) {
+	columns := Columns(testReport{})
+	tests := []struct {
+		name   string
+		column string
+		want   int
+	}{
+		{"Finds first", "Flag", 0},
+		{"Finds later", "Time", 5},
+		{"Missing", "Unknown", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Index(columns, tt.column); got != tt.want {
+				t.Errorf("Index() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnion(t *testing.T) {
+	first := []Column{{Name: "A", Type: Int32}, {Name: "B", Type: String}}
+	second := []Column{{Name: "B", Type: Double}, {Name: "C", Type: Bool}}
+	want := []Column{
+		{Name: "A", Type: Int32, Optional: true},
+		{Name: "B", Type: String, Optional: true},
+		{Name: "C", Type: Bool, Optional: true},
+	}
+	if got := Union(first, second); !reflect.DeepEqual(got, want) {
+		t.Errorf("Union() = %v, want %v", got, want)
+	}
+}
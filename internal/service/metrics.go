@@ -0,0 +1,122 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+type requestKey struct {
+	format Format
+	code   int
+}
+
+// Metrics counts the requests and records of the service, written in the
+// Prometheus text format when served
+type Metrics struct {
+	lock          sync.Mutex
+	requests      map[requestKey]int64
+	seconds       float64
+	receivedBytes int64
+	records       map[timeseries.OutStream]int64
+	recordErrors  int64
+}
+
+// NewMetrics returns zeroed metrics
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests: make(map[requestKey]int64),
+		records:  make(map[timeseries.OutStream]int64),
+	}
+}
+
+// CountRequest counts an extraction request answered with the code
+func (metrics *Metrics) CountRequest(format Format, code int, duration time.Duration) {
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+	metrics.requests[requestKey{format, code}]++
+	metrics.seconds += duration.Seconds()
+}
+
+// AddReceived counts the bytes of a received rac-file
+func (metrics *Metrics) AddReceived(bytes int64) {
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+	metrics.receivedBytes += bytes
+}
+
+// CountRecord counts a decoded record by stream and whether it has an error
+func (metrics *Metrics) CountRecord(pkg *common.DataRecord) {
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+	metrics.records[timeseries.OutStreamFromDataRecord(pkg)]++
+	if pkg.Error != nil {
+		metrics.recordErrors++
+	}
+}
+
+// WriteTo writes the metrics in the Prometheus text format
+func (metrics *Metrics) WriteTo(out io.Writer) (int64, error) {
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+	var lines []string
+	add := func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+
+	add("# HELP rac_requests_total Extraction requests by format and status code.")
+	add("# TYPE rac_requests_total counter")
+	var keys []requestKey
+	var requests int64
+	for key, count := range metrics.requests {
+		keys = append(keys, key)
+		requests += count
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].format != keys[j].format {
+			return keys[i].format < keys[j].format
+		}
+		return keys[i].code < keys[j].code
+	})
+	for _, key := range keys {
+		add(`rac_requests_total{format="%v",code="%v"} %v`, key.format, key.code, metrics.requests[key])
+	}
+	add("# HELP rac_request_duration_seconds Time spent answering extraction requests.")
+	add("# TYPE rac_request_duration_seconds summary")
+	add("rac_request_duration_seconds_sum %v", metrics.seconds)
+	add("rac_request_duration_seconds_count %v", requests)
+	add("# HELP rac_received_bytes_total Bytes of the rac-files received.")
+	add("# TYPE rac_received_bytes_total counter")
+	add("rac_received_bytes_total %v", metrics.receivedBytes)
+	add("# HELP rac_records_total Records decoded by stream.")
+	add("# TYPE rac_records_total counter")
+	for _, stream := range append([]timeseries.OutStream{timeseries.Unknown}, timeseries.Streams...) {
+		if count, ok := metrics.records[stream]; ok {
+			add(`rac_records_total{stream="%v"} %v`, stream, count)
+		}
+	}
+	add("# HELP rac_record_errors_total Records decoded with an error.")
+	add("# TYPE rac_record_errors_total counter")
+	add("rac_record_errors_total %v", metrics.recordErrors)
+
+	var written int64
+	for _, line := range lines {
+		n, err := fmt.Fprintln(out, line)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func (metrics *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metrics.WriteTo(w)
+}
@@ -0,0 +1,356 @@
+package service
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/exports"
+	"github.com/innosat-mats/rac-extract-payload/internal/extractors"
+	"github.com/innosat-mats/rac-extract-payload/internal/timeseries"
+)
+
+// DefaultMaxSize is the largest rac-file accepted unless told otherwise
+const DefaultMaxSize int64 = 512 << 20
+
+// ErrorTrailer is the trailer of ndjson and csv answers holding the error
+// writing the records, as the status is sent before the first record
+const ErrorTrailer = "Rac-Error"
+
+// Options configure the service
+type Options struct {
+	MaxSize      int64                  // Largest rac-file accepted in bytes
+	ImageWorkers int                    // Image workers shared by all requests, as -image-workers
+	Calibrations aez.Calibrations       // Converts the housekeeping data, the builtin if empty
+	Run          *common.RunDescription // Provenance of the records, the inputs are set per request
+}
+
+// Service decodes rac-files posted to /extract and reports its health on
+// /healthz and its counters on /metrics
+type Service struct {
+	options Options
+	metrics *Metrics
+	pool    *exports.ImagePool
+	mux     *http.ServeMux
+}
+
+// New returns a service with the options
+func New(options Options) *Service {
+	if options.MaxSize <= 0 {
+		options.MaxSize = DefaultMaxSize
+	}
+	if options.Run == nil {
+		options.Run = &common.RunDescription{Version: common.FullVersion()}
+	}
+	service := Service{
+		options: options,
+		metrics: NewMetrics(),
+		pool:    exports.NewImagePool(options.ImageWorkers),
+		mux:     http.NewServeMux(),
+	}
+	service.mux.HandleFunc("/extract", service.extract)
+	service.mux.HandleFunc("/healthz", service.healthz)
+	service.mux.Handle("/metrics", service.metrics)
+	return &service
+}
+
+func (service *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	service.mux.ServeHTTP(w, r)
+}
+
+func (service *Service) healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// Format is the format of the extracted records
+type Format string
+
+const (
+	// NDJSON writes one json object per record and line as rac -ndjson
+	NDJSON Format = "ndjson"
+	// CSV writes the records of one stream as rac writes its csv
+	CSV Format = "csv"
+	// Zip writes a zip of the parquet files and the images as PNG with JSON
+	Zip Format = "zip"
+)
+
+// request is what an extraction request asks for
+type request struct {
+	format  Format
+	streams []timeseries.OutStream
+	key     timeseries.PartitionKey
+	from    time.Time
+	to      time.Time
+	images  bool
+	name    string
+}
+
+// parseRequest reads the query of an extraction
+//
+// format is ndjson, csv or zip, stream may be repeated or comma separated,
+// from and to are RFC 3339 times of the records by key, images includes the
+// images and name is the rac-file name recorded as origin.
+func parseRequest(r *http.Request) (request, error) {
+	query := r.URL.Query()
+	parsed := request{
+		format: NDJSON,
+		key:    timeseries.TMHeaderTime,
+		name:   "upload.rac",
+	}
+	if format := query.Get("format"); format != "" {
+		parsed.format = Format(strings.ToLower(format))
+	}
+	switch parsed.format {
+	case NDJSON, CSV:
+	case Zip:
+		parsed.images = true
+	default:
+		return parsed, fmt.Errorf("unknown format '%v', use ndjson, csv or zip", parsed.format)
+	}
+	for _, value := range query["stream"] {
+		for _, name := range strings.Split(value, ",") {
+			stream := timeseries.OutStreamFromName(strings.TrimSpace(name))
+			if stream == timeseries.Unknown {
+				return parsed, fmt.Errorf("unknown stream '%v'", name)
+			}
+			parsed.streams = append(parsed.streams, stream)
+		}
+	}
+	if parsed.format == CSV && len(parsed.streams) != 1 {
+		return parsed, errors.New("csv needs exactly one stream")
+	}
+	var err error
+	if key := query.Get("key"); key != "" {
+		if parsed.key, err = timeseries.PartitionKeyFromName(key); err != nil {
+			return parsed, err
+		}
+	}
+	for _, bound := range []struct {
+		name   string
+		target *time.Time
+	}{{"from", &parsed.from}, {"to", &parsed.to}} {
+		if value := query.Get(bound.name); value != "" {
+			if *bound.target, err = time.Parse(time.RFC3339Nano, value); err != nil {
+				return parsed, fmt.Errorf("invalid %v '%v', use e.g. 2023-01-05T14:00:00Z", bound.name, value)
+			}
+		}
+	}
+	if images := query.Get("images"); images != "" {
+		if parsed.images, err = strconv.ParseBool(images); err != nil {
+			return parsed, fmt.Errorf("invalid images '%v'", images)
+		}
+	}
+	if name := query.Get("name"); name != "" {
+		parsed.name = filepath.Base(name)
+	}
+	return parsed, nil
+}
+
+// filter returns the filter of the records asked for
+func (parsed request) filter() exports.Filter {
+	var streamFilter, timeFilter exports.Filter
+	if len(parsed.streams) > 0 {
+		streamFilter = exports.StreamFilter(parsed.streams...)
+	}
+	if !parsed.from.IsZero() || !parsed.to.IsZero() {
+		timeFilter = exports.TimeFilter(parsed.key, parsed.from, parsed.to)
+	}
+	return exports.AllFilters(streamFilter, timeFilter)
+}
+
+func (service *Service) extract(w http.ResponseWriter, r *http.Request) {
+	started := time.Now()
+	parsed, err := parseRequest(r)
+	status := service.handleExtract(w, r, parsed, err)
+	service.metrics.CountRequest(parsed.format, status, time.Since(started))
+}
+
+// handleExtract answers the extraction and returns the status code
+func (service *Service) handleExtract(w http.ResponseWriter, r *http.Request, parsed request, err error) int {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "post a rac-file", http.StatusMethodNotAllowed)
+		return http.StatusMethodNotAllowed
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return http.StatusBadRequest
+	}
+	input, err := os.CreateTemp("", "rac-serve-*.rac")
+	if err != nil {
+		http.Error(w, "could not store rac-file", http.StatusInternalServerError)
+		return http.StatusInternalServerError
+	}
+	defer os.Remove(input.Name())
+	defer input.Close()
+	body := http.MaxBytesReader(w, r.Body, service.options.MaxSize)
+	hash, err := common.HashFile(io.TeeReader(body, input))
+	if err == nil {
+		_, err = input.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("rac-file larger than %v bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, "could not read rac-file", http.StatusBadRequest)
+		return http.StatusBadRequest
+	}
+	info, _ := input.Stat()
+	service.metrics.AddReceived(info.Size())
+
+	run := *service.options.Run
+	run.Inputs = []common.InputDescription{{Name: parsed.name, SHA256: hash}}
+	batch := extractors.StreamBatch{
+		Buf: input,
+		Origin: &common.OriginDescription{
+			Name:           parsed.name,
+			ProcessingDate: time.Now(),
+			SHA256:         hash,
+			Run:            &run,
+		},
+	}
+	if parsed.format == Zip {
+		return service.extractZip(w, parsed, batch)
+	}
+	w.Header().Set("Trailer", ErrorTrailer)
+	switch parsed.format {
+	case CSV:
+		w.Header().Set("Content-Type", "text/csv")
+		collection := timeseries.NewCollection(func(pkg *common.DataRecord, stream timeseries.OutStream) (timeseries.CSVWriter, error) {
+			return timeseries.NewCSV(w, stream.String()), nil
+		})
+		var writeErr error
+		err = service.run(batch, parsed.filter(), func(pkg common.DataRecord) {
+			if pkg.Data == nil || writeErr != nil {
+				return
+			}
+			writeErr = collection.Write(&pkg)
+		}, collection.CloseAll)
+		if writeErr != nil {
+			err = writeErr
+		}
+	default:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		callback, teardown := exports.NDJSONCallbackFactory(w, parsed.images, true, service.pool)
+		err = service.run(batch, parsed.filter(), callback, teardown)
+	}
+	if err != nil {
+		// The status is already sent, so the trailer tells the client
+		log.Printf("could not answer with all of %v: %v", parsed.name, err)
+		w.Header().Set(ErrorTrailer, err.Error())
+		return http.StatusInternalServerError
+	}
+	return http.StatusOK
+}
+
+// run extracts the batch passing the records the filter accepts to the
+// callback and counts them, it returns the error of the teardown
+func (service *Service) run(
+	batch extractors.StreamBatch,
+	filter exports.Filter,
+	callback common.Callback,
+	teardown common.CallbackTeardown,
+) error {
+	counted := func(pkg common.DataRecord) {
+		service.metrics.CountRecord(&pkg)
+		callback(pkg)
+	}
+	filtered, filteredTeardown := exports.FanOutCallbackFactory(
+		[]exports.Sink{{Callback: counted, Teardown: teardown, Filter: filter}},
+	)
+	extractors.ExtractData(filtered, extractors.Dregs{}, service.options.Calibrations, batch)
+	return filteredTeardown()
+}
+
+// extractZip writes the parquet files, and images if asked for, to a
+// temporary directory and answers with a zip of it
+func (service *Service) extractZip(
+	w http.ResponseWriter,
+	parsed request,
+	batch extractors.StreamBatch,
+) int {
+	dir, err := os.MkdirTemp("", "rac-serve-")
+	if err != nil {
+		http.Error(w, "could not prepare outputs", http.StatusInternalServerError)
+		return http.StatusInternalServerError
+	}
+	defer os.RemoveAll(dir)
+	// The outputs of the job are its own, leaving those of other jobs and
+	// of any run in the process alone
+	_, endOutputs := common.ScopeOutputs(dir)
+	defer endOutputs()
+
+	sinks := []exports.Sink{}
+	callback, teardown := exports.ParquetCallbackFactory(dir, service.pool)
+	sinks = append(sinks, exports.Sink{Callback: callback, Teardown: teardown})
+	if parsed.images {
+		callback, teardown := exports.DiskCallbackFactory(
+			filepath.Join(dir, "images"),
+			true,
+			false,
+			service.pool,
+			exports.CSVOptions{},
+		)
+		sinks = append(sinks, exports.Sink{Callback: callback, Teardown: teardown})
+	}
+	callback, teardown = exports.FanOutCallbackFactory(sinks)
+	if err := service.run(batch, parsed.filter(), callback, teardown); err != nil {
+		log.Printf("could not extract %v: %v", parsed.name, err)
+		http.Error(w, "could not write outputs", http.StatusInternalServerError)
+		return http.StatusInternalServerError
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set(
+		"Content-Disposition",
+		fmt.Sprintf(`attachment; filename="%v.zip"`, strings.TrimSuffix(parsed.name, filepath.Ext(parsed.name))),
+	)
+	if err := writeZip(w, dir); err != nil {
+		log.Printf("could not send zip of %v: %v", parsed.name, err)
+	}
+	return http.StatusOK
+}
+
+// writeZip writes the files below dir as a zip with slash separated paths
+// relative to dir
+func writeZip(out io.Writer, dir string) error {
+	archive := zip.NewWriter(out)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		relative, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		target, err := archive.Create(filepath.ToSlash(relative))
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(target, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return archive.Close()
+}
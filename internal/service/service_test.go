@@ -0,0 +1,230 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+)
+
+// statPacket is a rac-file of one STAT packet
+var statPacket = []byte{
+	// Ramses header
+	0x90, 0xeb, 0x48, 0x00, 0x79, 0xd8, 0x00, 0x00,
+	0xfb, 0xad, 0xc8, 0x04, 0xda, 0x1c, 0x00, 0x00,
+	// Ramses TM header
+	0x00, 0x00, 0x2e, 0x02, 0x00, 0x00, 0x64, 0x00,
+	0x00, 0x00, 0x00, 0xcc, 0xcc, 0xcc, 0xcc, 0x00,
+	// Ramses TM Payload
+	0x08, 0x64, 0xc8, 0x98, 0x00, 0x31, 0x10, 0x03,
+	0x19, 0x00, 0x00, 0x12, 0x19, 0xe3, 0x39, 0x00,
+	0x01, 0x7f, 0x04, 0x02, 0x82, 0x04, 0x02, 0x02,
+	0x06, 0x01, 0x19, 0x12, 0x00, 0x00, 0x0c, 0xe3,
+	0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x01, 0x00, 0x00, 0x00, 0x41, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x30, 0xfb,
+}
+
+func post(t *testing.T, server *httptest.Server, query string, body []byte) *http.Response {
+	response, err := http.Post(server.URL+"/extract"+query, "application/octet-stream", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { response.Body.Close() })
+	return response
+}
+
+func readBody(t *testing.T, response *http.Response) string {
+	content, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestService_extract(t *testing.T) {
+	server := httptest.NewServer(New(Options{}))
+	defer server.Close()
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantType  string
+		wantLines int
+		wantText  string
+	}{
+		{"Extracts ndjson", "?name=my.rac", http.StatusOK, "application/x-ndjson", 1, `"OriginFile":"my.rac"`},
+		{"Selects streams", "?stream=HTR,PWR", http.StatusOK, "application/x-ndjson", 0, ""},
+		{"Selects time", "?from=2023-01-01T00:00:00Z", http.StatusOK, "application/x-ndjson", 0, ""},
+		{"Extracts csv", "?format=csv&stream=STAT", http.StatusOK, "text/csv", 3, "STAT"},
+		{"Rejects csv of all streams", "?format=csv", http.StatusBadRequest, "", 0, ""},
+		{"Rejects unknown stream", "?stream=NOPE", http.StatusBadRequest, "", 0, ""},
+		{"Rejects unknown format", "?format=xml", http.StatusBadRequest, "", 0, ""},
+		{"Rejects bad time", "?to=tomorrow", http.StatusBadRequest, "", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := post(t, server, tt.query, statPacket)
+			body := readBody(t, response)
+			if response.StatusCode != tt.wantCode {
+				t.Fatalf("POST /extract%v = %v %v, want %v", tt.query, response.StatusCode, body, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if got := response.Header.Get("Content-Type"); got != tt.wantType {
+				t.Errorf("POST /extract%v gave content type %v, want %v", tt.query, got, tt.wantType)
+			}
+			lines := strings.Split(strings.TrimSpace(body), "\n")
+			if body == "" {
+				lines = nil
+			}
+			if len(lines) != tt.wantLines {
+				t.Errorf("POST /extract%v gave %v lines, want %v:\n%v", tt.query, len(lines), tt.wantLines, body)
+			}
+			if !strings.Contains(body, tt.wantText) {
+				t.Errorf("POST /extract%v gave %v, want it to contain %v", tt.query, body, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestService_extract_ndjsonIsJSON(t *testing.T) {
+	server := httptest.NewServer(New(Options{}))
+	defer server.Close()
+	body := readBody(t, post(t, server, "", statPacket))
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &record); err != nil {
+		t.Fatalf("POST /extract gave invalid json %v: %v", body, err)
+	}
+	if record["SID"] != "STAT" {
+		t.Errorf("POST /extract gave SID %v, want STAT", record["SID"])
+	}
+}
+
+func TestService_extract_zip(t *testing.T) {
+	common.ResetOutputs()
+	defer common.ResetOutputs()
+	server := httptest.NewServer(New(Options{}))
+	defer server.Close()
+	response := post(t, server, "?format=zip&name=my.rac", statPacket)
+	body := readBody(t, response)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("POST /extract?format=zip = %v %v", response.StatusCode, body)
+	}
+	if got := response.Header.Get("Content-Disposition"); !strings.Contains(got, `"my.zip"`) {
+		t.Errorf("POST /extract?format=zip gave disposition %v, want my.zip", got)
+	}
+	archive, err := zip.NewReader(strings.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("POST /extract?format=zip gave invalid zip: %v", err)
+	}
+	var names []string
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+	}
+	if len(names) != 1 || !strings.HasPrefix(names[0], "STAT/") || !strings.HasSuffix(names[0], "/my.parquet") {
+		t.Errorf("POST /extract?format=zip gave files %v, want the STAT parquet file", names)
+	}
+	if got := common.OutputsOf("my.rac"); len(got) != 0 {
+		t.Errorf("POST /extract?format=zip recorded %v as outputs of the run, want none", got)
+	}
+}
+
+// failingWriter is a response writer that can't send the body
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w failingWriter) Write(content []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestService_extract_writeErrors(t *testing.T) {
+	service := New(Options{})
+	for _, query := range []string{"?format=csv&stream=STAT", "?format=ndjson"} {
+		t.Run(query, func(t *testing.T) {
+			w := failingWriter{httptest.NewRecorder()}
+			r := httptest.NewRequest(http.MethodPost, "/extract"+query, bytes.NewReader(statPacket))
+			parsed, err := parseRequest(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := service.handleExtract(w, r, parsed, nil); got != http.StatusInternalServerError {
+				t.Errorf("Service.handleExtract() = %v, want %v", got, http.StatusInternalServerError)
+			}
+			if got := w.Header().Get(ErrorTrailer); !strings.Contains(got, "connection reset") {
+				t.Errorf("Service.handleExtract() gave trailer %v = %q, want the write error", ErrorTrailer, got)
+			}
+		})
+	}
+}
+
+func TestNew_sharesImagePool(t *testing.T) {
+	service := New(Options{ImageWorkers: 2})
+	if got := service.pool.Workers(); got != 2 {
+		t.Errorf("New().pool.Workers() = %v, want 2", got)
+	}
+}
+
+func TestService_limits(t *testing.T) {
+	server := httptest.NewServer(New(Options{MaxSize: 10}))
+	defer server.Close()
+	if response := post(t, server, "", statPacket); response.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("POST /extract of large file = %v, want %v", response.StatusCode, http.StatusRequestEntityTooLarge)
+	}
+	response, err := http.Get(server.URL + "/extract")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET /extract = %v, want %v", response.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestService_healthzAndMetrics(t *testing.T) {
+	server := httptest.NewServer(New(Options{}))
+	defer server.Close()
+	post(t, server, "?stream=STAT", statPacket)
+	post(t, server, "?format=xml", statPacket)
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/healthz", []string{"ok"}},
+		{
+			"/metrics",
+			[]string{
+				`rac_requests_total{format="ndjson",code="200"} 1`,
+				`rac_requests_total{format="xml",code="400"} 1`,
+				"rac_request_duration_seconds_count 2",
+				"rac_received_bytes_total 88",
+				`rac_records_total{stream="STAT"} 1`,
+				"rac_record_errors_total 0",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			response, err := http.Get(server.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+			body := readBody(t, response)
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("GET %v = %v, want it to contain %v", tt.path, body, want)
+				}
+			}
+		})
+	}
+}
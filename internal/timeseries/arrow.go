@@ -0,0 +1,82 @@
+package timeseries
+
+import (
+	"bufio"
+	"fmt"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/arrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
+)
+
+// ArrowBatchRows is the number of rows per record batch in arrow files
+const ArrowBatchRows = 4096
+
+// ArrowImageBatchRows is the number of rows per record batch in arrow files
+// with images, kept small since each row holds a whole image
+const ArrowImageBatchRows = 16
+
+// Arrow gives easy access for arrow writing
+type Arrow struct {
+	file   *common.AtomicFile
+	buffer *bufio.Writer
+	writer *arrow.Writer
+	Name   string
+}
+
+// ArrowName returns the whole name of the arrow file, using the same
+// partitioning as ParquetName
+func ArrowName(pkg *common.DataRecord, stream OutStream) string {
+	return partitionedName(pkg, stream, ".arrow")
+}
+
+// NewArrow returns a Timeseries as an Arrow IPC file
+func NewArrow(name string, pkg *common.DataRecord) (ParquetWriter, error) {
+	file, err := common.CreateAtomic(name)
+	if err != nil {
+		return nil, fmt.Errorf("could not create %v: %v", name, err)
+	}
+	stream := OutStreamFromDataRecord(pkg)
+	batchRows := ArrowBatchRows
+	if stream == CCD {
+		batchRows = ArrowImageBatchRows
+	}
+	buffer := bufio.NewWriter(file)
+	writer, err := arrow.NewWriter(buffer, stream.Columns(), pkg.ParquetSpecifications(), batchRows)
+	if err != nil {
+		file.Abort()
+		return nil, fmt.Errorf("could not write schema to %v: %v", name, err)
+	}
+	return &Arrow{file: file, buffer: buffer, writer: writer, Name: name}, nil
+}
+
+// AddOrigin notes that the file holds records of the origin
+func (arrowFile *Arrow) AddOrigin(origin string) {
+	arrowFile.file.AddOrigin(origin)
+}
+
+// Close writes remaining rows and the footer and closes the file
+func (arrowFile *Arrow) Close() error {
+	err := arrowFile.writer.Close()
+	if err == nil {
+		err = arrowFile.buffer.Flush()
+	}
+	if err != nil {
+		arrowFile.file.Abort()
+		return fmt.Errorf("could not finish %v: %v", arrowFile.Name, err)
+	}
+	err = arrowFile.file.Close()
+	if err != nil {
+		return fmt.Errorf("could not finish %v: %v", arrowFile.Name, err)
+	}
+	return nil
+}
+
+// WriteData writes a data row as returned by GetParquetRow
+func (arrowFile *Arrow) WriteData(data interface{}) error {
+	row, ok := data.(*schema.Row)
+	if !ok {
+		return fmt.Errorf("%v can't hold %T", arrowFile.Name, data)
+	}
+	return arrowFile.writer.Write(row.Values)
+}
@@ -0,0 +1,73 @@
+package timeseries
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/innosat"
+)
+
+func TestArrowName(t *testing.T) {
+	pkg := common.DataRecord{
+		Origin:   &common.OriginDescription{Name: filepath.FromSlash("some/dir/test1.rac")},
+		TMHeader: &innosat.TMHeader{},
+		Data:     &aez.STAT{},
+	}
+	tests := []struct {
+		name   string
+		stream OutStream
+		want   string
+	}{
+		{"Daily partition", STAT, filepath.FromSlash("STAT/1980/1/5/test1.arrow")},
+		{"Hourly partition for CCD", CCD, filepath.FromSlash("CCD/1980/1/5/23/test1.arrow")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ArrowName(&pkg, tt.stream); got != tt.want {
+				t.Errorf("ArrowName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewArrow(t *testing.T) {
+	pkg := common.DataRecord{
+		Origin:   &common.OriginDescription{Name: "test1.rac"},
+		TMHeader: &innosat.TMHeader{},
+		Data:     &aez.HTR{},
+	}
+	name := filepath.Join(t.TempDir(), "test1.arrow")
+	writer, err := NewArrow(name, &pkg)
+	if err != nil {
+		t.Fatalf("NewArrow() error = %v", err)
+	}
+	if err := writer.WriteData(GetParquetRow(&pkg)); err != nil {
+		t.Errorf("Arrow.WriteData() error = %v", err)
+	}
+	if err := writer.WriteData(pkg); err == nil {
+		t.Error("Arrow.WriteData() accepted a non-row")
+	}
+	writer.Close()
+	content, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("NewArrow() didn't produce a file: %v", err)
+	}
+	if len(content) < 12 || string(content[:6]) != "ARROW1" || string(content[len(content)-6:]) != "ARROW1" {
+		t.Errorf("NewArrow() produced %v bytes that is not an arrow file", len(content))
+	}
+}
+
+func TestNewArrow_missingDirectory(t *testing.T) {
+	pkg := common.DataRecord{
+		Origin:   &common.OriginDescription{Name: "test1.rac"},
+		TMHeader: &innosat.TMHeader{},
+		Data:     &aez.HTR{},
+	}
+	name := filepath.Join(t.TempDir(), "missing", "test1.arrow")
+	if _, err := NewArrow(name, &pkg); err == nil {
+		t.Error("NewArrow() gave no error for a missing directory")
+	}
+}
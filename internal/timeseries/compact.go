@@ -0,0 +1,180 @@
+package timeseries
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	goparquet "github.com/fraugster/parquet-go"
+	"github.com/fraugster/parquet-go/parquetschema"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/ramses"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
+)
+
+// CompactStats describes the outcome of compacting parquet files
+type CompactStats struct {
+	Files      int
+	Rows       int
+	Duplicates int
+}
+
+// compactTransportColumns are the columns describing how a packet reached us
+// rather than the packet itself, they are left out when finding duplicates
+var compactTransportColumns = func() map[string]bool {
+	columns := make(map[string]bool)
+	for _, header := range []exportedColumns{
+		&common.OriginDescription{},
+		&ramses.Ramses{},
+		&ramses.TMHeader{},
+	} {
+		for _, column := range header.Columns() {
+			columns[column.Name] = true
+		}
+	}
+	return columns
+}()
+
+type exportedColumns interface {
+	Columns() []schema.Column
+}
+
+type compactRow struct {
+	nanoseconds int64
+	data        map[string]interface{}
+}
+
+// CompactParquet merges the parquet files into output sorted on
+// TMHeaderNanoseconds, leaving out packets already written from another file
+//
+// The files must have the same schema. Their key-value metadata is kept, where
+// the files disagree the distinct values are joined by '|'. All rows are held
+// in memory while sorting. The output is written using the codec, row group
+// size and dictionary of the active parquet tuning of the stream, it is
+// always one file. It replaces output once complete, so output may be one of
+// the files.
+func CompactParquet(inputs []string, output string) (CompactStats, error) {
+	stats := CompactStats{Files: len(inputs)}
+	var rows []compactRow
+	var schemaDefinition string
+	var reader *goparquet.FileReader
+	metadata := make(map[string][]string)
+	seen := make(map[[sha256.Size]byte]bool)
+	for _, input := range inputs {
+		file, err := os.Open(input)
+		if err != nil {
+			return stats, err
+		}
+		reader, err = goparquet.NewFileReader(file)
+		if err != nil {
+			file.Close()
+			return stats, fmt.Errorf("could not read %v: %v", input, err)
+		}
+		if schemaDefinition == "" {
+			schemaDefinition = columnsDefinition(reader.GetSchemaDefinition())
+		} else if columnsDefinition(reader.GetSchemaDefinition()) != schemaDefinition {
+			file.Close()
+			return stats, fmt.Errorf("%v has another schema than %v", input, inputs[0])
+		}
+		for key, value := range reader.MetaData() {
+			if !common.ContainsString(metadata[key], value) {
+				metadata[key] = append(metadata[key], value)
+			}
+		}
+		for {
+			data, err := reader.NextRow()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				file.Close()
+				return stats, fmt.Errorf("could not read %v: %v", input, err)
+			}
+			identity := packetIdentity(data)
+			if seen[identity] {
+				stats.Duplicates++
+				continue
+			}
+			seen[identity] = true
+			nanoseconds, _ := data["TMHeaderNanoseconds"].(int64)
+			rows = append(rows, compactRow{nanoseconds, data})
+		}
+		file.Close()
+	}
+	if reader == nil {
+		return stats, fmt.Errorf("no files to compact into %v", output)
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].nanoseconds < rows[j].nanoseconds })
+
+	joined := make(map[string]string)
+	for key, values := range metadata {
+		joined[key] = strings.Join(values, "|")
+	}
+	sd := reader.GetSchemaDefinition()
+	var names []string
+	for _, column := range sd.RootColumn.Children {
+		names = append(names, column.SchemaElement.GetName())
+	}
+	settings := ActiveParquetTuning().Settings(OutStreamFromColumns(names))
+	file, err := common.CreateAtomic(output)
+	if err != nil {
+		return stats, err
+	}
+	writer := goparquet.NewFileWriter(file, parquetWriterOptions(sd, joined, settings)...)
+	if !settings.Dictionary {
+		if err := addPlainColumns(writer, sd); err != nil {
+			file.Abort()
+			return stats, fmt.Errorf("could not write %v: %v", output, err)
+		}
+	}
+	for _, row := range rows {
+		if err := writer.AddData(row.data); err != nil {
+			file.Abort()
+			return stats, fmt.Errorf("could not write %v: %v", output, err)
+		}
+		stats.Rows++
+	}
+	if err := writer.Close(); err != nil {
+		file.Abort()
+		return stats, fmt.Errorf("could not write %v: %v", output, err)
+	}
+	return stats, file.Close()
+}
+
+// columnsDefinition returns the schema definition without the name of the
+// message, which depends on how the columns were added when written
+func columnsDefinition(sd *parquetschema.SchemaDefinition) string {
+	root := *sd.RootColumn
+	element := *root.SchemaElement
+	element.Name = "schema"
+	root.SchemaElement = &element
+	definition := parquetschema.SchemaDefinition{RootColumn: &root}
+	return definition.String()
+}
+
+// packetIdentity hashes the values of all but the transport columns
+func packetIdentity(data map[string]interface{}) [sha256.Size]byte {
+	var names []string
+	for name := range data {
+		if !compactTransportColumns[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	hash := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(hash, "%q=", name)
+		if value, ok := data[name].([]byte); ok {
+			fmt.Fprintf(hash, "%d:", len(value))
+			hash.Write(value)
+		} else {
+			fmt.Fprintf(hash, "%#v", data[name])
+		}
+		hash.Write([]byte{0})
+	}
+	var identity [sha256.Size]byte
+	copy(identity[:], hash.Sum(nil))
+	return identity
+}
@@ -0,0 +1,165 @@
+package timeseries
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	goparquet "github.com/fraugster/parquet-go"
+	"github.com/fraugster/parquet-go/parquet"
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/innosat"
+	"github.com/innosat-mats/rac-extract-payload/internal/ramses"
+)
+
+func writeTestParquet(t *testing.T, name string, origin string, data common.Exporter, seconds ...uint32) {
+	var writer ParquetWriter
+	for _, second := range seconds {
+		pkg := common.DataRecord{
+			Origin:         &common.OriginDescription{Name: origin},
+			RamsesHeader:   &ramses.Ramses{Date: int32(len(origin))},
+			RamsesTMHeader: &ramses.TMHeader{VCFrameCounter: uint8(len(origin))},
+			SourceHeader:   &innosat.SourcePacketHeader{PacketSequenceControl: innosat.PacketSequenceControl(second)},
+			TMHeader:       &innosat.TMHeader{CUCTimeSeconds: second},
+			Data:           data,
+		}
+		if writer == nil {
+			var err error
+			writer, err = NewParquet(name, &pkg)
+			if err != nil {
+				t.Fatalf("NewParquet() error = %v", err)
+			}
+		}
+		if err := writer.WriteData(GetParquetRow(&pkg)); err != nil {
+			t.Fatalf("could not write %v: %v", name, err)
+		}
+	}
+	writer.Close()
+}
+
+func readTestParquet(t *testing.T, name string) ([]map[string]interface{}, map[string]string) {
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := goparquet.NewFileReader(file)
+	if err != nil {
+		t.Fatalf("could not read %v: %v", name, err)
+	}
+	var rows []map[string]interface{}
+	for {
+		row, err := reader.NextRow()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("could not read %v: %v", name, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, reader.MetaData()
+}
+
+func TestCompactParquet(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.parquet")
+	second := filepath.Join(dir, "second.parquet")
+	output := filepath.Join(dir, "compacted")
+	writeTestParquet(t, first, "first.rac", &aez.HTR{}, 3, 1, 5)
+	// The packet at 3 s was received in both rac-files
+	writeTestParquet(t, second, "second-longer.rac", &aez.HTR{}, 4, 3, 2)
+
+	stats, err := CompactParquet([]string{first, second}, output)
+	if err != nil {
+		t.Fatalf("CompactParquet() error = %v", err)
+	}
+	if want := (CompactStats{Files: 2, Rows: 5, Duplicates: 1}); stats != want {
+		t.Errorf("CompactParquet() = %+v, want %+v", stats, want)
+	}
+	rows, metadata := readTestParquet(t, output)
+	var sequence []int32
+	var origins []string
+	for _, row := range rows {
+		sequence = append(sequence, row["SPSequenceCount"].(int32))
+		origins = append(origins, string(row["OriginFile"].([]byte)))
+	}
+	if want := []int32{1, 2, 3, 4, 5}; !reflect.DeepEqual(sequence, want) {
+		t.Errorf("CompactParquet() wrote packets %v, want %v", sequence, want)
+	}
+	if origins[2] != "first.rac" {
+		t.Errorf("CompactParquet() kept duplicate from %v, want first.rac", origins[2])
+	}
+	_, inputMetadata := readTestParquet(t, first)
+	// The origins differ and are thus joined
+	inputMetadata["ORIGIN"] = "first.rac|second-longer.rac"
+	if !reflect.DeepEqual(metadata, inputMetadata) {
+		t.Errorf("CompactParquet() metadata = %v, want %v", metadata, inputMetadata)
+	}
+}
+
+func TestCompactParquet_Errors(t *testing.T) {
+	dir := t.TempDir()
+	htr := filepath.Join(dir, "htr.parquet")
+	stat := filepath.Join(dir, "stat.parquet")
+	writeTestParquet(t, htr, "first.rac", &aez.HTR{}, 1)
+	writeTestParquet(t, stat, "first.rac", &aez.STAT{}, 1)
+	tests := []struct {
+		name   string
+		inputs []string
+	}{
+		{"No inputs", nil},
+		{"Missing input", []string{filepath.Join(dir, "missing.parquet")}},
+		{"Different schemas", []string{htr, stat}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := CompactParquet(tt.inputs, filepath.Join(dir, "out")); err == nil {
+				t.Error("CompactParquet() gave no error")
+			}
+		})
+	}
+}
+
+func TestCompactParquet_settings(t *testing.T) {
+	defer SetParquetTuning(ActiveParquetTuning())
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.parquet")
+	second := filepath.Join(dir, "second.parquet")
+	writeTestParquet(t, first, "first.rac", &aez.HTR{}, 1, 2)
+	writeTestParquet(t, second, "second.rac", &aez.HTR{}, 3)
+	settings := ParquetSettings{Codec: parquet.CompressionCodec_ZSTD}
+	SetParquetTuning(ParquetTuning{Streams: map[OutStream]ParquetSettings{HTR: settings}})
+
+	// The output replaces one of the inputs
+	if _, err := CompactParquet([]string{first, second}, first); err != nil {
+		t.Fatalf("CompactParquet() error = %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("CompactParquet() left %v files, want the two inputs", len(entries))
+	}
+	file, err := os.Open(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := goparquet.NewFileReader(file)
+	if err != nil {
+		t.Fatalf("could not read %v: %v", first, err)
+	}
+	if err := reader.PreLoad(); err != nil {
+		t.Fatalf("could not read %v: %v", first, err)
+	}
+	if got := reader.CurrentRowGroup().Columns[0].MetaData.Codec; got != settings.Codec {
+		t.Errorf("CompactParquet() wrote codec %v, want %v", got, settings.Codec)
+	}
+	if got := reader.NumRows(); got != 3 {
+		t.Errorf("CompactParquet() wrote %v rows, want 3", got)
+	}
+}
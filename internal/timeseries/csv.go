@@ -4,7 +4,8 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
-	"os"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
 )
 
 // CSV gives easy access for csv writing
@@ -15,6 +16,8 @@ type CSV struct {
 	HasSpec   bool
 	HasHead   bool
 	NHeaders  int
+	appendTo  *csvAppend
+	padding   int
 }
 
 // NewCSV returns a Timeseries CSV
@@ -24,19 +27,48 @@ func NewCSV(out io.Writer, name string) CSVWriter {
 
 // CSVWriter implements ease of use writing functions
 type CSVWriter interface {
-	Close()
+	AddOrigin(origin string)
+	Close() error
 	SetSpecifications(specs []string) error
 	SetHeaderRow(columns []string) error
 	WriteData(data []string) error
 }
 
+// outputFile is a file that can be left as it was before written to
+type outputFile interface {
+	io.WriteCloser
+	AddOrigin(origin string)
+	Abort() error
+}
+
+// AddOrigin notes that the file, if any, holds records of the origin
+func (csv *CSV) AddOrigin(origin string) {
+	if file, ok := csv.writer.(outputFile); ok {
+		file.AddOrigin(origin)
+	}
+}
+
 // Close flushes and closes underlying file if any
-func (csv *CSV) Close() {
+func (csv *CSV) Close() error {
+	if csv.csvWriter == nil {
+		return nil
+	}
 	csv.csvWriter.Flush()
-	f, ok := csv.writer.(*os.File)
-	if ok {
-		f.Close()
+	err := csv.csvWriter.Error()
+	if file, ok := csv.writer.(outputFile); ok && err != nil {
+		file.Abort()
+	} else if f, ok := csv.writer.(io.Closer); ok {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err == nil && csv.appendTo != nil && csv.appendTo.appended {
+		err = appendProvenance(csv.Name, csv.appendTo.newSpecs)
 	}
+	if err != nil {
+		return fmt.Errorf("could not close csv output %v: %v", csv.Name, err)
+	}
+	return nil
 }
 
 // SetSpecifications writes specifications, only allows once
@@ -44,6 +76,11 @@ func (csv *CSV) SetSpecifications(specs []string) error {
 	if csv.HasSpec {
 		return fmt.Errorf("specifications already set for csv output %v", csv.Name)
 	}
+	if csv.appendTo != nil {
+		csv.appendTo.newSpecs = specs
+		csv.HasSpec = true
+		return nil
+	}
 	csv.csvWriter.Write(specs)
 	csv.HasSpec = true
 	return nil
@@ -57,6 +94,9 @@ func (csv *CSV) SetHeaderRow(columns []string) error {
 	if csv.HasHead {
 		return fmt.Errorf("header row already set for csv output %v", csv.Name)
 	}
+	if csv.appendTo != nil {
+		return csv.appendHeaderRow(columns)
+	}
 	csv.csvWriter.Write(columns)
 	csv.HasHead = true
 	csv.NHeaders = len(columns)
@@ -79,6 +119,69 @@ func (csv *CSV) WriteData(data []string) error {
 			csv.Name,
 		)
 	}
+	if csv.padding > 0 {
+		data = append(data, make([]string, csv.padding)...)
+	}
 	csv.csvWriter.Write(data)
 	return nil
 }
+
+// appendHeaderRow checks the specifications and header row against those of
+// the file appended to and opens the file for adding rows at its end if they
+// match, else migrates the file if allowed to
+func (csv *CSV) appendHeaderRow(columns []string) error {
+	existing := csv.appendTo
+	sameSpecs := equalStrings(
+		common.WithoutProvenance(existing.newSpecs),
+		common.WithoutProvenance(existing.specifications),
+	)
+	if sameSpecs && equalStrings(columns, existing.headers) {
+		file, err := common.OpenAppend(csv.Name)
+		if err != nil {
+			csv.discard()
+			return fmt.Errorf("could not append to csv output %v: %v", csv.Name, err)
+		}
+		csv.setWriter(file)
+		existing.appended = true
+		csv.HasHead = true
+		csv.NHeaders = len(columns)
+		return nil
+	}
+	if existing.conflict != MigrateConflict {
+		csv.discard()
+		return fmt.Errorf(
+			"specifications or header row differ from those of csv output %v, refusing to append",
+			csv.Name,
+		)
+	}
+	file, err := common.CreateAtomic(csv.Name)
+	if err != nil {
+		csv.discard()
+		return fmt.Errorf("could not create output file '%v'", csv.Name)
+	}
+	headers := mergeHeaders(columns, existing.headers)
+	if err := migrateCSV(file, csv.Name, existing.newSpecs, headers); err != nil {
+		file.Abort()
+		csv.discard()
+		return fmt.Errorf("could not migrate csv output %v: %v", csv.Name, err)
+	}
+	csv.setWriter(file)
+	csv.HasHead = true
+	csv.NHeaders = len(columns)
+	csv.padding = len(headers) - len(columns)
+	return nil
+}
+
+func (csv *CSV) setWriter(out io.Writer) {
+	csv.writer = out
+	csv.csvWriter = newCSVWriter(out)
+}
+
+func newCSVWriter(out io.Writer) *csv.Writer {
+	return csv.NewWriter(out)
+}
+
+// discard drops all rows, leaving the file appended to as it was
+func (csv *CSV) discard() {
+	csv.setWriter(io.Discard)
+}
@@ -0,0 +1,208 @@
+package timeseries
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+)
+
+// CSVConflict decides what happens when appending to a csv whose
+// specifications or header row differ from those of the new rows
+type CSVConflict int
+
+const (
+	// RefuseConflict leaves the file as is and writes nothing to it
+	RefuseConflict CSVConflict = iota
+	// MigrateConflict rewrites the file with the new specifications and the
+	// columns of both, leaving the cells of missing columns empty
+	MigrateConflict
+)
+
+// CSVConflicts are the known ways of handling conflicts
+var CSVConflicts = []CSVConflict{RefuseConflict, MigrateConflict}
+
+func (conflict CSVConflict) String() string {
+	switch conflict {
+	case RefuseConflict:
+		return "refuse"
+	case MigrateConflict:
+		return "migrate"
+	default:
+		return "unknown"
+	}
+}
+
+// CSVConflictFromName returns the conflict handling with the name
+func CSVConflictFromName(name string) (CSVConflict, error) {
+	for _, conflict := range CSVConflicts {
+		if strings.EqualFold(conflict.String(), name) {
+			return conflict, nil
+		}
+	}
+	return RefuseConflict, fmt.Errorf("unknown csv conflict handling '%v'", name)
+}
+
+// csvAppend holds the heading of an existing csv that is appended to
+type csvAppend struct {
+	specifications []string
+	headers        []string
+	conflict       CSVConflict
+	newSpecs       []string // Specifications of the rows appended
+	appended       bool     // If rows are added at the end of the file
+}
+
+// CSVProvenanceName returns the name of the file listing the specifications
+// of the runs that added rows to the end of the csv
+func CSVProvenanceName(name string) string {
+	return name + ".provenance"
+}
+
+// AppendCSV returns a Timeseries CSV adding rows to the end of the file
+//
+// If the file is missing or empty it is written as by NewCSV, else the
+// specifications, apart from the provenance, and header row set must match
+// those of the file or the conflict is handled as asked. Matching rows are
+// written at the end of the file, which keeps its first row, so the
+// specifications of the run are added to the CSVProvenanceName file once
+// closed. A migrated file is rewritten with the specifications of the run.
+func AppendCSV(name string, conflict CSVConflict) (CSVWriter, error) {
+	specifications, headers, err := readCSVFileHeading(name)
+	if err == io.EOF {
+		file, err := common.CreateAtomic(name)
+		if err != nil {
+			return nil, fmt.Errorf("could not create output file '%v'", name)
+		}
+		return NewCSV(file, name), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not append to %v: %v", name, err)
+	}
+	return &CSV{
+		Name: name,
+		appendTo: &csvAppend{
+			specifications: specifications,
+			headers:        headers,
+			conflict:       conflict,
+		},
+	}, nil
+}
+
+// appendProvenance adds the specifications as a row of the provenance file
+// of the csv named
+func appendProvenance(name string, specifications []string) error {
+	file, err := common.OpenAppend(CSVProvenanceName(name))
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(file)
+	writer.Write(specifications)
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		file.Abort()
+		return err
+	}
+	return file.Close()
+}
+
+// readCSVFileHeading returns the specifications and header rows of the file
+// or io.EOF if the file is missing or empty
+func readCSVFileHeading(name string) ([]string, []string, error) {
+	file, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, nil, io.EOF
+	} else if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	return readCSVHeading(reader)
+}
+
+// readCSVHeading returns the specifications and header rows read or io.EOF
+// if there are none
+func readCSVHeading(reader *csv.Reader) ([]string, []string, error) {
+	specifications, err := reader.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	headers, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("missing header row")
+	}
+	return specifications, headers, err
+}
+
+// mergeHeaders returns the headers followed by the old headers missing from
+// them
+func mergeHeaders(headers []string, oldHeaders []string) []string {
+	merged := append([]string{}, headers...)
+	for _, header := range oldHeaders {
+		if !common.ContainsString(headers, header) {
+			merged = append(merged, header)
+		}
+	}
+	return merged
+}
+
+// migrateCSV writes the csv named to out with the specifications and
+// headers, moving the values of its rows to the columns of the same name
+func migrateCSV(out io.Writer, name string, specifications []string, headers []string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	_, oldHeaders, err := readCSVHeading(reader)
+	if err != nil {
+		return err
+	}
+	columns := make([]int, len(headers))
+	for i, header := range headers {
+		columns[i] = -1
+		for j, oldHeader := range oldHeaders {
+			if header == oldHeader {
+				columns[i] = j
+				break
+			}
+		}
+	}
+
+	writer := csv.NewWriter(out)
+	writer.Write(specifications)
+	writer.Write(headers)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		migrated := make([]string, len(headers))
+		for i, column := range columns {
+			if column >= 0 && column < len(row) {
+				migrated[i] = row[column]
+			}
+		}
+		writer.Write(migrated)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,183 @@
+package timeseries
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestCSV(t *testing.T, name string, conflict CSVConflict, specs []string, headers []string, rows ...[]string) error {
+	writer, err := AppendCSV(name, conflict)
+	if err != nil {
+		t.Fatalf("AppendCSV() error = %v", err)
+	}
+	defer writer.Close()
+	if err := writer.SetSpecifications(specs); err != nil {
+		return err
+	}
+	if err := writer.SetHeaderRow(headers); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := writer.WriteData(row); err != nil {
+			t.Fatalf("CSV.WriteData() error = %v", err)
+		}
+	}
+	return nil
+}
+
+func TestAppendCSV(t *testing.T) {
+	specs := []string{"CODE", "1.0"}
+	headers := []string{"Time", "Value"}
+	tests := []struct {
+		name     string
+		conflict CSVConflict
+		specs    []string
+		headers  []string
+		row      []string
+		wantErr  bool
+		want     string
+		// wantProvenance is the content of the provenance file, if any
+		wantProvenance string
+	}{
+		{
+			"Appends matching rows",
+			RefuseConflict,
+			specs,
+			headers,
+			[]string{"2", "b"},
+			false,
+			"CODE,1.0\nTime,Value\n1,a\n2,b\n",
+			"CODE,1.0\n",
+		},
+		{
+			"Appends with other provenance",
+			RefuseConflict,
+			[]string{"CODE", "1.0", "PROCESSED", "2023-01-05T14:00:00Z"},
+			headers,
+			[]string{"2", "b"},
+			false,
+			"CODE,1.0\nTime,Value\n1,a\n2,b\n",
+			"CODE,1.0,PROCESSED,2023-01-05T14:00:00Z\n",
+		},
+		{
+			"Refuses other specifications",
+			RefuseConflict,
+			[]string{"CODE", "2.0"},
+			headers,
+			[]string{"2", "b"},
+			true,
+			"CODE,1.0\nTime,Value\n1,a\n",
+			"",
+		},
+		{
+			"Refuses other headers",
+			RefuseConflict,
+			specs,
+			[]string{"Time", "Other"},
+			[]string{"2", "b"},
+			true,
+			"CODE,1.0\nTime,Value\n1,a\n",
+			"",
+		},
+		{
+			"Migrates other specifications",
+			MigrateConflict,
+			[]string{"CODE", "2.0"},
+			headers,
+			[]string{"2", "b"},
+			false,
+			"CODE,2.0\nTime,Value\n1,a\n2,b\n",
+			"",
+		},
+		{
+			"Migrates to the columns of both",
+			MigrateConflict,
+			specs,
+			[]string{"Time", "Other"},
+			[]string{"2", "x"},
+			false,
+			"CODE,1.0\nTime,Other,Value\n1,,a\n2,x,\n",
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "HTR.csv")
+			if err := writeTestCSV(t, name, RefuseConflict, specs, headers, []string{"1", "a"}); err != nil {
+				t.Fatalf("could not create %v: %v", name, err)
+			}
+			var rows [][]string
+			if !tt.wantErr {
+				rows = append(rows, tt.row)
+			}
+			before, err := os.Stat(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = writeTestCSV(t, name, tt.conflict, tt.specs, tt.headers, rows...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AppendCSV() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			content, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != tt.want {
+				t.Errorf("AppendCSV() wrote %q, want %q", string(content), tt.want)
+			}
+			after, err := os.Stat(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.conflict == RefuseConflict && !os.SameFile(before, after) {
+				t.Error("AppendCSV() replaced the file, want rows added to its end")
+			}
+			wantFiles := 1
+			provenance, err := os.ReadFile(CSVProvenanceName(name))
+			if err == nil {
+				wantFiles = 2
+			}
+			if string(provenance) != tt.wantProvenance {
+				t.Errorf("AppendCSV() wrote provenance %q, want %q", string(provenance), tt.wantProvenance)
+			}
+			entries, _ := os.ReadDir(filepath.Dir(name))
+			if len(entries) != wantFiles {
+				t.Errorf("AppendCSV() left %v files, want %v", len(entries), wantFiles)
+			}
+		})
+	}
+}
+
+func TestAppendCSV_rejectsBrokenFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "HTR.csv")
+	if err := os.WriteFile(name, []byte("CODE,1.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AppendCSV(name, MigrateConflict); err == nil {
+		t.Error("AppendCSV() gave no error for a file without header row")
+	}
+}
+
+func TestCSVConflictFromName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    CSVConflict
+		wantErr bool
+	}{
+		{"refuse", RefuseConflict, false},
+		{"Migrate", MigrateConflict, false},
+		{"overwrite", RefuseConflict, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CSVConflictFromName(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CSVConflictFromName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CSVConflictFromName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -9,15 +9,40 @@ import (
 // CSVFactory is a function that creates CSVWriters
 type CSVFactory func(pkg *common.DataRecord, stream OutStream) (CSVWriter, error)
 
+// CSVName is a function naming the csv of a record
+type CSVName func(pkg *common.DataRecord, stream OutStream) string
+
 // CSVCollection hold all active csv writers
 type CSVCollection struct {
 	streams map[OutStream]CSVWriter
 	factory CSVFactory
+	name    CSVName
+	files   map[string]CSVWriter
+	failed  map[string]bool
 }
 
 // NewCollection returns a novel ready to use CSVCollection
 func NewCollection(factory CSVFactory) CSVCollection {
-	return CSVCollection{factory: factory, streams: make(map[OutStream]CSVWriter)}
+	return CSVCollection{
+		factory: factory,
+		streams: make(map[OutStream]CSVWriter),
+		failed:  make(map[string]bool),
+	}
+}
+
+// NewSplitCollection returns a CSVCollection with one writer for each name
+// of the records in a stream rather than one per stream
+func NewSplitCollection(factory CSVFactory, name CSVName) CSVCollection {
+	collection := NewCollection(factory)
+	collection.name = name
+	collection.files = make(map[string]CSVWriter)
+	return collection
+}
+
+// CSVFileName returns the name of the csv of the record within the active
+// partitioning, as ParquetName
+func CSVFileName(pkg *common.DataRecord, stream OutStream) string {
+	return partitionedName(pkg, stream, ".csv")
 }
 
 // Write adds a csv row into the relevant out stream
@@ -31,34 +56,63 @@ func (collection *CSVCollection) Write(pkg *common.DataRecord) error {
 		return nil
 	}
 
-	writer, ok = collection.streams[stream]
+	key := stream.String()
+	if collection.name != nil {
+		key = collection.name(pkg, stream)
+		writer, ok = collection.files[key]
+	} else {
+		writer, ok = collection.streams[stream]
+	}
 	if !ok {
+		if collection.failed[key] {
+			// The error was returned when the writer was set up
+			return nil
+		}
 		writer, err = collection.factory(pkg, stream)
 		if err != nil {
+			collection.failed[key] = true
 			return err
 		}
 		if writer != nil {
 			err := writer.SetSpecifications((*pkg).CSVSpecifications())
-			if err != nil {
-				return err
+			if err == nil {
+				err = writer.SetHeaderRow((*pkg).CSVHeaders())
 			}
-			err = writer.SetHeaderRow((*pkg).CSVHeaders())
 			if err != nil {
+				writer.Close()
+				collection.failed[key] = true
 				return err
 			}
 		}
-		collection.streams[stream] = writer
+		if collection.name != nil {
+			collection.files[key] = writer
+		} else {
+			collection.streams[stream] = writer
+		}
+	}
+	if pkg.Origin != nil {
+		writer.AddOrigin(pkg.Origin.Name)
 	}
 	return writer.WriteData(pkg.CSVRow())
 }
 
-// CloseAll closes all open streams
-func (collection *CSVCollection) CloseAll() {
+// CloseAll closes all open streams, returning the first error closing them
+func (collection *CSVCollection) CloseAll() error {
+	var err error
 	for stream := range collection.streams {
 		oldWriter, ok := collection.streams[stream]
 		if ok {
-			oldWriter.Close()
+			if closeErr := oldWriter.Close(); err == nil {
+				err = closeErr
+			}
 			collection.streams[stream] = nil
 		}
 	}
+	for name, writer := range collection.files {
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
+		delete(collection.files, name)
+	}
+	return err
 }
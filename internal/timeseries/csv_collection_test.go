@@ -2,6 +2,8 @@ package timeseries
 
 import (
 	"bytes"
+	"errors"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -156,3 +158,68 @@ func TestCSVCollection_Write(t *testing.T) {
 		})
 	}
 }
+
+func TestNewSplitCollection(t *testing.T) {
+	buffers := make(map[string]*bytes.Buffer)
+	factory := func(pkg *common.DataRecord, stream OutStream) (CSVWriter, error) {
+		name := CSVFileName(pkg, stream)
+		buffers[name] = bytes.NewBuffer([]byte{})
+		return NewCSV(buffers[name], name), nil
+	}
+	collection := NewSplitCollection(factory, CSVFileName)
+	for _, origin := range []string{"File1.rac", "File2.rac", "File1.rac"} {
+		err := collection.Write(&common.DataRecord{
+			Origin:         &common.OriginDescription{Name: origin},
+			RamsesHeader:   &ramses.Ramses{},
+			RamsesTMHeader: &ramses.TMHeader{},
+			SourceHeader:   &innosat.SourcePacketHeader{},
+			TMHeader:       &innosat.TMHeader{},
+			Data:           &aez.HTR{},
+		})
+		if err != nil {
+			t.Errorf("CSVCollection.Write() error = %v", err)
+		}
+	}
+	collection.CloseAll()
+	want := map[string]int{
+		filepath.Join("HTR", "1980", "1", "5", "File1.csv"): 4,
+		filepath.Join("HTR", "1980", "1", "5", "File2.csv"): 3,
+	}
+	if len(buffers) != len(want) {
+		t.Errorf("CSVCollection.Write() wrote %v files, want %v", len(buffers), len(want))
+	}
+	for name, lines := range want {
+		buf, ok := buffers[name]
+		if !ok {
+			t.Errorf("CSVCollection.Write() didn't write %v", name)
+			continue
+		}
+		if got := strings.Count(buf.String(), "\n"); got != lines {
+			t.Errorf("CSVCollection.Write() wrote %v lines to %v, want %v", got, name, lines)
+		}
+	}
+}
+
+func TestCSVCollection_Write_reportsFailedWriterOnce(t *testing.T) {
+	calls := 0
+	factory := func(pkg *common.DataRecord, stream OutStream) (CSVWriter, error) {
+		calls++
+		return nil, errors.New("no disk")
+	}
+	collection := NewCollection(factory)
+	var errs int
+	for i := 0; i < 3; i++ {
+		err := collection.Write(&common.DataRecord{
+			Origin:       &common.OriginDescription{},
+			SourceHeader: &innosat.SourcePacketHeader{},
+			TMHeader:     &innosat.TMHeader{},
+			Data:         &aez.HTR{},
+		})
+		if err != nil {
+			errs++
+		}
+	}
+	if calls != 1 || errs != 1 {
+		t.Errorf("CSVCollection.Write() set up the writer %v times with %v errors, want once", calls, errs)
+	}
+}
@@ -0,0 +1,53 @@
+package timeseries
+
+// streamDescriptions explain the records of each stream beyond their columns
+var streamDescriptions = map[OutStream]string{
+	HTR: `All voltages are the calculated float values of their respective type
+according to the specification and not the raw encoded integer of the rac.
+
+All temperatures are calculated from the calibration. Their uncertainties are
+half the temperature span of one ADC step. Extrapolated temperatures also
+include the distance to the end of the calibration table and clamped ones
+have infinite (+Inf) uncertainty.
+
+The warnings come from the interpolator and probably indicate the measured
+resistance is out of range.`,
+	PWR: `All voltages and currents are the calculated float values of their respective
+type according to the specification and not the raw encoded integer of the
+rac.
+
+The temperature and its uncertainty are calculated as for HTR.`,
+	CPRU: `All voltages are the calculated float values of their respective type
+according to the specification and not the raw encoded integer of the rac.`,
+	STAT: `The fields are read out exactly as they are encoded in the rac, except for TS
+and TSS that are replaced by STATTime and STATNanoseconds (STATTIME and
+STATNANO in CSV).
+
+The cumulative counters are also given as rates compared to the previous STAT
+in the processing, by STAT time. Rates are NaN for the first STAT, after a
+restart and if the STAT time did not advance.`,
+	PM: `The sums and counters are read out exactly as they are encoded in the rac,
+except for EXPTS and EXPTSS that are replaced by PMTime and PMNanoseconds
+(PMTIME and PMNANO in CSV).
+
+The means are NaN if the counter is zero. The temperatures are interpolated
+from the thermistor calibration table and their warnings come from the
+interpolator and probably indicate the measured resistance is out of range.`,
+	CCD: `The columns CCDSEL, WDWOV, JPEGQ, FRAME, NROW, NRBIN, NRSKIP, NCOL, NCSKIP,
+NFLUSH, TEXPMS, TEMP, FBINOV, LBLNK, TBLNK, ZERO, TIMING1, TIMING2, VERSION,
+TIMING3, NBC and BadColumns directly export the data in the rac, the others
+parse the values further.
+
+The image is written as a PNG-file named as in ImageName, or as PNG-encoded
+binary data in the ImageData column of parquet, arrow and SQLite outputs.`,
+	TCV: `This contains all the four telecommand verification types, acceptance and
+execution reports of success or failure. ErrorCode is empty for success
+reports, except in parquet where it is 0 as it has always been.`,
+	ALARMS: `Each value outside the limits given with -limits is a record. The common
+columns describe the packet the value came from.`,
+}
+
+// Description explains the records of the stream beyond their columns
+func (stream OutStream) Description() string {
+	return streamDescriptions[stream]
+}
@@ -0,0 +1,35 @@
+package timeseries
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
+)
+
+func TestOutStream_Description(t *testing.T) {
+	for _, stream := range Streams {
+		t.Run(stream.String(), func(t *testing.T) {
+			if stream.Description() == "" {
+				t.Errorf("OutStream.Description() of %v is empty", stream)
+			}
+		})
+	}
+	if got := Unknown.Description(); got != "" {
+		t.Errorf("OutStream.Description() of Unknown = %v, want empty", got)
+	}
+}
+
+func TestOutStream_Description_namesColumns(t *testing.T) {
+	// The columns exported directly from the rac are listed by name
+	description := CCD.Description()
+	direct := description[strings.Index(description, "CCDSEL"):strings.Index(description, " directly")]
+	for _, name := range strings.FieldsFunc(direct, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		if name == "and" {
+			continue
+		}
+		if schema.Index(CCD.DataColumns(), name) < 0 {
+			t.Errorf("OutStream.Description() of CCD names %v that is no column", name)
+		}
+	}
+}
@@ -1,8 +1,13 @@
 package timeseries
 
 import (
+	"path/filepath"
+	"strings"
+
 	"github.com/innosat-mats/rac-extract-payload/internal/aez"
 	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/limits"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 // OutStream is the type for the outstream enum
@@ -25,8 +30,53 @@ const (
 	CCD
 	// TCV is a TCV timeseries out stream
 	TCV
+	// ALARMS is a out stream of limit violations
+	ALARMS
 )
 
+// Streams are the known out streams
+var Streams = []OutStream{HTR, PWR, CPRU, STAT, PM, CCD, TCV, ALARMS}
+
+// streamExporters holds an instance of each type of data in the stream
+var streamExporters = map[OutStream][]common.Exporter{
+	HTR:  {&aez.HTR{}},
+	PWR:  {&aez.PWR{}},
+	CPRU: {&aez.CPRU{}},
+	STAT: {&aez.STAT{}},
+	PM:   {&aez.PMData{}},
+	CCD:  {&aez.CCDImage{PackData: &aez.CCDImagePackData{}}},
+	TCV: {
+		&aez.TCAcceptSuccessData{},
+		&aez.TCAcceptFailureData{},
+		&aez.TCExecSuccessData{},
+		&aez.TCExecFailureData{},
+	},
+	ALARMS: {&limits.Alarm{}},
+}
+
+// Columns returns the exported columns of the records in the stream
+//
+// The columns of the unknown stream are those of all streams, all optional.
+func (stream OutStream) Columns() []schema.Column {
+	if stream == Unknown {
+		var columnSets [][]schema.Column
+		for _, known := range Streams {
+			columnSets = append(columnSets, known.Columns())
+		}
+		return schema.Union(columnSets...)
+	}
+	return (&common.DataRecord{Data: streamExporters[stream][0]}).Columns()
+}
+
+// DataColumns returns the exported columns specific to the stream
+func (stream OutStream) DataColumns() []schema.Column {
+	exporters, ok := streamExporters[stream]
+	if !ok {
+		return nil
+	}
+	return exporters[0].Columns()
+}
+
 func (stream OutStream) String() string {
 	switch stream {
 	case HTR:
@@ -43,11 +93,83 @@ func (stream OutStream) String() string {
 		return "CCD"
 	case TCV:
 		return "TCV"
+	case ALARMS:
+		return "ALARMS"
 	default:
 		return "unknown"
 	}
 }
 
+// OutStreamFromName returns the out stream with the name, ignoring case, or
+// Unknown
+func OutStreamFromName(name string) OutStream {
+	for _, stream := range Streams {
+		if strings.EqualFold(stream.String(), name) {
+			return stream
+		}
+	}
+	return Unknown
+}
+
+// streamKeyColumns are data columns that identify the stream, all outputs
+// of the stream have had them since the first release
+var streamKeyColumns = map[OutStream][]string{
+	HTR:    {"HTR1A", "HTR1B", "HTR1OD", "HTR8A", "HTR8B", "HTR8OD"},
+	PWR:    {"PWRT", "PWRP32V", "PWRP32C", "PWRP3V3", "PWRP3C3"},
+	CPRU:   {"VGATE0", "VSUBS0", "VRD0", "VOD0", "Overvoltage0", "Power0"},
+	STAT:   {"SPID", "SPREV", "FPID", "FPREV", "MODE", "ANOMALY"},
+	PM:     {"PM1A", "PM1ACNTR", "PM2S", "PM2SCNTR"},
+	CCD:    {"CCDSEL", "EXPNanoseconds", "WDWMode", "NROW", "NCOL", "NBC"},
+	TCV:    {"TCV", "TCPID", "PSC"},
+	ALARMS: {"AlarmField", "AlarmValue", "AlarmSeverity"},
+}
+
+// OutStreamFromColumns returns the stream of an output with the column
+// names, the stream that has all its identifying columns present, or
+// Unknown
+//
+// Only columns that every version of the outputs has are required, so that
+// outputs of earlier versions are recognized.
+func OutStreamFromColumns(names []string) OutStream {
+	present := make(map[string]bool)
+	for _, name := range names {
+		present[name] = true
+	}
+	for _, stream := range Streams {
+		all := true
+		for _, name := range streamKeyColumns[stream] {
+			all = all && present[name]
+		}
+		if all {
+			return stream
+		}
+	}
+	return Unknown
+}
+
+// OutStreamFromFile returns the stream of an output file with the column
+// names, by its columns or else by its file name such as HTR.csv
+func OutStreamFromFile(name string, names []string) OutStream {
+	if stream := OutStreamFromColumns(names); stream != Unknown {
+		return stream
+	}
+	return OutStreamFromName(strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)))
+}
+
+// ColumnNamesFromCSV returns the column names of the csv headers, headers of
+// no known column are kept as they are
+func ColumnNamesFromCSV(headers []string) []string {
+	columns := Unknown.Columns()
+	names := make([]string, len(headers))
+	for idx, header := range headers {
+		names[idx] = header
+		if known := schema.CSVIndex(columns, header); known >= 0 {
+			names[idx] = columns[known].Name
+		}
+	}
+	return names
+}
+
 // OutStreamFromDataRecord infers stream based on data
 func OutStreamFromDataRecord(pkg *common.DataRecord) OutStream {
 	switch pkg.Data.(type) {
@@ -65,6 +187,8 @@ func OutStreamFromDataRecord(pkg *common.DataRecord) OutStream {
 		return STAT
 	case *aez.TCAcceptSuccessData, *aez.TCAcceptFailureData, *aez.TCExecSuccessData, *aez.TCExecFailureData:
 		return TCV
+	case *limits.Alarm:
+		return ALARMS
 	default:
 		return Unknown
 	}
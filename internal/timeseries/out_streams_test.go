@@ -1,10 +1,18 @@
 package timeseries
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
+	"github.com/fraugster/parquet-go/parquetschema"
+
 	"github.com/innosat-mats/rac-extract-payload/internal/aez"
 	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/limits"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 func TestOutStream_String(t *testing.T) {
@@ -20,6 +28,7 @@ func TestOutStream_String(t *testing.T) {
 		{"PM", PM, "PM"},
 		{"CCD", CCD, "CCD"},
 		{"TCV", TCV, "TCV"},
+		{"ALARMS", ALARMS, "ALARMS"},
 		{"default", Unknown, "unknown"},
 	}
 	for _, tt := range tests {
@@ -31,6 +40,86 @@ func TestOutStream_String(t *testing.T) {
 	}
 }
 
+func TestOutStreamFromName(t *testing.T) {
+	tests := []struct {
+		name string
+		want OutStream
+	}{
+		{"HTR", HTR},
+		{"ccd", CCD},
+		{"Alarms", ALARMS},
+		{"Unknown", Unknown},
+		{"", Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OutStreamFromName(tt.name); got != tt.want {
+				t.Errorf("OutStreamFromName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutStreamFromColumns(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		want  OutStream
+	}{
+		{"HTR", schema.CSVHeaders(HTR.Columns()), HTR},
+		{"CCD", schema.Names(CCD.Columns()), CCD},
+		{"CCD csv", ColumnNamesFromCSV(schema.CSVHeaders(CCD.Columns())), CCD},
+		{"TCV", schema.CSVHeaders(TCV.Columns()), TCV},
+		{"ALARMS", schema.CSVHeaders(ALARMS.Columns()), ALARMS},
+		{"PM without later columns", []string{"PMTime", "PM1A", "PM1ACNTR", "PM2S", "PM2SCNTR"}, PM},
+		{"Missing data columns", []string{"TMHeaderTime", "HTR1A"}, Unknown},
+		{"No columns", nil, Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OutStreamFromColumns(tt.names); got != tt.want {
+				t.Errorf("OutStreamFromColumns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutStreamFromColumns_allStreams(t *testing.T) {
+	for _, stream := range Streams {
+		if got := OutStreamFromColumns(schema.Names(stream.Columns())); got != stream {
+			t.Errorf("OutStreamFromColumns(%v columns) = %v", stream, got)
+		}
+	}
+}
+
+func TestOutStreamFromFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		names []string
+		want  OutStream
+	}{
+		{"By columns", "other.csv", schema.Names(STAT.Columns()), STAT},
+		{"By name", "/data/HTR.csv", []string{"OriginFile"}, HTR},
+		{"Unknown", "/data/other.csv", []string{"OriginFile"}, Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OutStreamFromFile(tt.file, tt.names); got != tt.want {
+				t.Errorf("OutStreamFromFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnNamesFromCSV(t *testing.T) {
+	headers := []string{"ProcessingDate", "STATTIME", "SPID", "BC", "Error", "Custom"}
+	want := []string{"ProcessingTime", "STATTime", "SPID", "BadColumns", "Errors", "Custom"}
+	if got := ColumnNamesFromCSV(headers); !reflect.DeepEqual(got, want) {
+		t.Errorf("ColumnNamesFromCSV() = %v, want %v", got, want)
+	}
+}
+
 func TestOutStreamFromDataRecord(t *testing.T) {
 	type args struct {
 		pkg *common.DataRecord
@@ -50,6 +139,7 @@ func TestOutStreamFromDataRecord(t *testing.T) {
 		{"TCV, accept fail", args{&common.DataRecord{Data: &aez.TCAcceptFailureData{}}}, TCV},
 		{"TCV, exec success", args{&common.DataRecord{Data: &aez.TCExecSuccessData{}}}, TCV},
 		{"TCV, exec fail", args{&common.DataRecord{Data: &aez.TCExecFailureData{}}}, TCV},
+		{"ALARMS", args{&common.DataRecord{Data: &limits.Alarm{}}}, ALARMS},
 		{"Unknown", args{&common.DataRecord{}}, Unknown},
 	}
 	for _, tt := range tests {
@@ -60,3 +150,104 @@ func TestOutStreamFromDataRecord(t *testing.T) {
 		})
 	}
 }
+
+func TestOutStream_Columns(t *testing.T) {
+	for _, stream := range Streams {
+		t.Run(stream.String(), func(t *testing.T) {
+			want := stream.Columns()
+			for _, exporter := range streamExporters[stream] {
+				record := common.DataRecord{Data: exporter}
+				if got := record.Columns(); !reflect.DeepEqual(got, want) {
+					t.Errorf("%T columns = %v, want %v", exporter, got, want)
+				}
+				if got := len(record.Values()); got != len(want) {
+					t.Errorf("%T has %v values, want %v", exporter, got, len(want))
+				}
+			}
+			data := stream.DataColumns()
+			if len(data) == 0 || !reflect.DeepEqual(data, want[len(want)-len(data)-1:len(want)-1]) {
+				t.Errorf("OutStream.DataColumns() = %v, not part of %v", data, want)
+			}
+		})
+	}
+}
+
+func TestOutStream_Columns_Unknown(t *testing.T) {
+	columns := Unknown.Columns()
+	for _, stream := range Streams {
+		for _, column := range stream.Columns() {
+			idx := schema.Index(columns, column.Name)
+			if idx < 0 {
+				t.Errorf("OutStream.Columns() lacks %v of %v", column.Name, stream)
+				continue
+			}
+			if !columns[idx].Optional {
+				t.Errorf("OutStream.Columns() %v is not optional", column.Name)
+			}
+		}
+	}
+	if Unknown.DataColumns() != nil {
+		t.Errorf("OutStream.DataColumns() = %v, want nil", Unknown.DataColumns())
+	}
+}
+
+// describeParquetColumn renders the repetition, types and children of the
+// column
+func describeParquetColumn(column *parquetschema.ColumnDefinition) string {
+	element := column.SchemaElement
+	description := fmt.Sprintf("%v %v %v", element.GetRepetitionType(), element.GetName(), element.Type)
+	if element.IsSetConvertedType() {
+		description += fmt.Sprintf(" %v", element.GetConvertedType())
+	}
+	if element.IsSetLogicalType() {
+		description += fmt.Sprintf(" %v", element.GetLogicalType())
+	}
+	for _, child := range column.Children {
+		description += " {" + describeParquetColumn(child) + "}"
+	}
+	return description
+}
+
+func TestOutStream_Columns_keepsBaselineParquet(t *testing.T) {
+	for _, stream := range []OutStream{CCD, PM, HTR, PWR, CPRU, STAT, TCV} {
+		t.Run(stream.String(), func(t *testing.T) {
+			// testdata/baseline holds the parquet schemas written before the
+			// columns were declared by the exporters
+			content, err := os.ReadFile(filepath.Join("testdata", "baseline", stream.String()+".parquet-schema"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			baseline, err := parquetschema.ParseSchemaDefinition(string(content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			current, err := parquetschema.ParseSchemaDefinition(schema.ParquetSchema(stream.Columns()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			last := -1
+			for _, column := range baseline.RootColumn.Children {
+				name := column.SchemaElement.GetName()
+				idx := -1
+				for i, currentColumn := range current.RootColumn.Children {
+					if currentColumn.SchemaElement.GetName() == name {
+						idx = i
+						break
+					}
+				}
+				if idx < 0 {
+					t.Errorf("%v lacks the baseline column %v", stream, name)
+					continue
+				}
+				want := describeParquetColumn(column)
+				if got := describeParquetColumn(current.RootColumn.Children[idx]); got != want {
+					t.Errorf("%v column is %v, want as baseline %v", stream, got, want)
+				}
+				if idx < last {
+					t.Errorf("%v column %v moved before earlier baseline columns", stream, name)
+				}
+				last = idx
+			}
+		})
+	}
+}
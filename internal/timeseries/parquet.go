@@ -1,127 +1,183 @@
 package timeseries
 
 import (
-	"log"
+	"fmt"
+	"strings"
 
 	goparquet "github.com/fraugster/parquet-go"
 	"github.com/fraugster/parquet-go/floor"
-	"github.com/fraugster/parquet-go/parquet"
 	"github.com/fraugster/parquet-go/parquetschema"
 	"github.com/innosat-mats/rac-extract-payload/internal/aez"
 	"github.com/innosat-mats/rac-extract-payload/internal/common"
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 // Parquet gives easy access for parquet writing
 type Parquet struct {
 	parquetWriter *floor.Writer
+	fileWriter    *goparquet.FileWriter
+	file          *common.AtomicFile
 	Name          string
 	NHeaders      int
+	sd            *parquetschema.SchemaDefinition
+	metadata      map[string]string
+	settings      ParquetSettings
+	rows          int64
+	parts         int
+	origins       []string
 }
 
-var streamToScheme = map[OutStream]string{
-	Unknown: parquetrow.RacSchema,
-	HTR:     parquetrow.RacHTRSchema,
-	PWR:     parquetrow.RacPWRSchema,
-	CPRU:    parquetrow.RacCPRUSchema,
-	STAT:    parquetrow.RacSTATSchema,
-	PM:      parquetrow.RacPMSchema,
-	CCD:     parquetrow.RacCCDSchema,
-	TCV:     parquetrow.RacTCVSchema,
+// NewParquet returns a Timeseries as parquet using the active parquet tuning
+// of the stream
+func NewParquet(name string, pkg *common.DataRecord) (ParquetWriter, error) {
+	return NewParquetStream(name, OutStreamFromDataRecord(pkg), pkg.ParquetSpecifications())
 }
 
-// NewParquet returns a Timeseries as parquet
-func NewParquet(name string, pkg *common.DataRecord) ParquetWriter {
-	schema := streamToScheme[OutStreamFromDataRecord(pkg)]
-	sd, err := parquetschema.ParseSchemaDefinition(schema)
+// NewParquetStream returns a Timeseries of the stream as parquet with the
+// metadata using the active parquet tuning of the stream
+func NewParquetStream(name string, stream OutStream, metadata map[string]string) (ParquetWriter, error) {
+	return NewParquetColumns(name, stream, stream.Columns(), metadata)
+}
+
+// NewParquetColumns returns a Timeseries of the stream as parquet with the
+// columns and the metadata using the active parquet tuning of the stream
+func NewParquetColumns(
+	name string,
+	stream OutStream,
+	columns []schema.Column,
+	metadata map[string]string,
+) (ParquetWriter, error) {
+	sd, err := parquetschema.ParseSchemaDefinition(schema.ParquetSchema(columns))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse parquet schema definition: %v", err)
+	}
+	writer := Parquet{
+		Name:     name,
+		sd:       sd,
+		metadata: metadata,
+		settings: ActiveParquetTuning().Settings(stream),
+	}
+	err = writer.open(name)
+	if err != nil {
+		return nil, fmt.Errorf("could not create %v: %v", name, err)
+	}
+	return &writer, nil
+}
+
+// ParquetPartName returns the name of the part of a parquet file written
+// after rolling over, the first part keeps the name
+func ParquetPartName(name string, part int) string {
+	if part == 0 {
+		return name
+	}
+	return fmt.Sprintf("%v.part%v.parquet", strings.TrimSuffix(name, ".parquet"), part)
+}
+
+func (parquet *Parquet) open(name string) error {
+	file, err := common.CreateAtomic(name)
 	if err != nil {
-		log.Fatalf("could not parse parquet schema definition: %v", err)
-	}
-	metadata := pkg.ParquetSpecifications()
-	writer, err := floor.NewFileWriter(
-		name,
-		goparquet.WithSchemaDefinition(sd),
-		goparquet.WithMetaData(metadata),
-		goparquet.WithCompressionCodec(parquet.CompressionCodec_SNAPPY),
+		return err
+	}
+	fileWriter := goparquet.NewFileWriter(
+		file,
+		parquetWriterOptions(parquet.sd, parquet.metadata, parquet.settings)...,
 	)
+	if !parquet.settings.Dictionary {
+		err = addPlainColumns(fileWriter, parquet.sd)
+		if err != nil {
+			file.Abort()
+			return err
+		}
+	}
+	for _, origin := range parquet.origins {
+		file.AddOrigin(origin)
+	}
+	parquet.file = file
+	parquet.fileWriter = fileWriter
+	parquet.parquetWriter = floor.NewWriter(fileWriter)
+	parquet.rows = 0
+	return nil
+}
+
+// full returns if the current file has reached its maximum size, a file
+// without rows is never full since it would be closed empty
+func (parquet *Parquet) full() bool {
+	if parquet.rows == 0 {
+		return false
+	}
+	if parquet.settings.MaxRows > 0 && parquet.rows >= parquet.settings.MaxRows {
+		return true
+	}
+	if parquet.settings.MaxBytes > 0 {
+		size := parquet.fileWriter.CurrentFileSize() + parquet.fileWriter.CurrentRowGroupSize()
+		return size >= parquet.settings.MaxBytes
+	}
+	return false
+}
+
+// rollOver closes the current file and continues in the next part
+func (parquet *Parquet) rollOver() error {
+	err := parquet.closeFile()
+	if err != nil {
+		return err
+	}
+	parquet.parts++
+	return parquet.open(ParquetPartName(parquet.Name, parquet.parts))
+}
+
+func (parquet *Parquet) closeFile() error {
+	err := parquet.parquetWriter.Close()
 	if err != nil {
-		log.Fatalf("could not create %v: %v", name, err)
+		parquet.file.Abort()
+		return err
 	}
-	return &Parquet{parquetWriter: writer, Name: name}
+	return parquet.file.Close()
 }
 
 // ParquetWriter implements ease of use writing functions
 type ParquetWriter interface {
-	Close()
+	AddOrigin(origin string)
+	Close() error
 	WriteData(data interface{}) error
 }
 
+// AddOrigin notes that the file, and the parts to come, hold records of the
+// origin
+func (parquet *Parquet) AddOrigin(origin string) {
+	if common.ContainsString(parquet.origins, origin) {
+		return
+	}
+	parquet.origins = append(parquet.origins, origin)
+	parquet.file.AddOrigin(origin)
+}
+
 // Close flushes and closes underlying file if any
-func (parquet *Parquet) Close() {
-	parquet.parquetWriter.Close()
+func (parquet *Parquet) Close() error {
+	err := parquet.closeFile()
+	if err != nil {
+		return fmt.Errorf("could not close %v: %v", ParquetPartName(parquet.Name, parquet.parts), err)
+	}
+	return nil
 }
 
-// WriteData writes a data row
+// WriteData writes a data row, rolling over to a new file once the current
+// is full
 func (parquet *Parquet) WriteData(data interface{}) error {
+	if parquet.full() {
+		err := parquet.rollOver()
+		if err != nil {
+			return err
+		}
+	}
+	parquet.rows++
 	return parquet.parquetWriter.Write(data)
 }
 
 // GetParquetRow returns the exportable parquet representation of a record, including common attributes
-func GetParquetRow(pkg *common.DataRecord) parquetrow.ParquetRow {
-	row := parquetrow.ParquetRow{}
-	pkg.SetParquet(&row)
-
-	switch pkg.Data.(type) {
-	case *aez.CCDImage:
-		ccd, ok := pkg.Data.(*aez.CCDImage)
-		if ok {
-			ccd.SetParquet(&row, pkg.Buffer)
-		}
-	case *aez.PMData:
-		pm, ok := pkg.Data.(*aez.PMData)
-		if ok {
-			pm.SetParquet(&row)
-		}
-	case *aez.HTR:
-		htr, ok := pkg.Data.(*aez.HTR)
-		if ok {
-			htr.SetParquet(&row)
-		}
-	case *aez.PWR:
-		pwr, ok := pkg.Data.(*aez.PWR)
-		if ok {
-			pwr.SetParquet(&row)
-		}
-	case *aez.CPRU:
-		cpru, ok := pkg.Data.(*aez.CPRU)
-		if ok {
-			cpru.SetParquet(&row)
-		}
-	case *aez.STAT:
-		stat, ok := pkg.Data.(*aez.STAT)
-		if ok {
-			stat.SetParquet(&row)
-		}
-	case *aez.TCAcceptSuccessData:
-		tcv, ok := pkg.Data.(*aez.TCAcceptSuccessData)
-		if ok {
-			tcv.SetParquet(&row)
-		}
-	case *aez.TCAcceptFailureData:
-		tcv, ok := pkg.Data.(*aez.TCAcceptFailureData)
-		if ok {
-			tcv.SetParquet(&row)
-		}
-	case *aez.TCExecSuccessData:
-		tcv, ok := pkg.Data.(*aez.TCExecSuccessData)
-		if ok {
-			tcv.SetParquet(&row)
-		}
-	case *aez.TCExecFailureData:
-		tcv, ok := pkg.Data.(*aez.TCExecFailureData)
-		if ok {
-			tcv.SetParquet(&row)
-		}
+func GetParquetRow(pkg *common.DataRecord) *schema.Row {
+	row := schema.Row{Columns: pkg.Columns(), Values: pkg.Values()}
+	if ccd, ok := pkg.Data.(*aez.CCDImage); ok {
+		row.Set("ImageData", ccd.PNG(pkg.Buffer))
 	}
-	return row
+	return &row
 }
@@ -26,22 +26,27 @@ func NewParquetCollection(factory ParquetFactory) ParquetCollection {
 
 // ParquetName returns the whole name of the parquet, including partitioning prefix
 func ParquetName(pkg *common.DataRecord, stream OutStream) string {
-	tmTime := pkg.TMHeader.Time(time.Time{})
-	prefix := filepath.Join(
-		stream.String(),
-		fmt.Sprintf("%v", tmTime.Year()),
-		fmt.Sprintf("%v", int(tmTime.Month())),
-		fmt.Sprintf("%v", tmTime.Day()),
-	)
-	if stream.String() == "CCD" {
-		prefix = filepath.Join(
-			prefix,
-			fmt.Sprintf("%v", tmTime.Hour()),
-		)
-	}
-	baseName := filepath.Base(pkg.Origin.Name)
-	ext := filepath.Ext(pkg.Origin.Name)
-	name := fmt.Sprintf("%v.parquet", strings.TrimSuffix(baseName, ext))
+	return partitionedName(pkg, stream, ".parquet")
+}
+
+// ParquetNameAt returns the whole name of the parquet of records from the
+// origin in the stream at the time, including partitioning prefix
+func ParquetNameAt(origin string, stream OutStream, t time.Time) string {
+	return originName(ActivePartitioning().PrefixAt(stream, t), origin, ".parquet")
+}
+
+// partitionedName returns the name of the file with the extension, including
+// the prefix of the active partitioning
+func partitionedName(pkg *common.DataRecord, stream OutStream, extension string) string {
+	return originName(ActivePartitioning().Prefix(pkg, stream), pkg.Origin.Name, extension)
+}
+
+// originName returns the name of the file of the origin in the prefix with
+// the extension
+func originName(prefix string, origin string, extension string) string {
+	baseName := filepath.Base(origin)
+	ext := filepath.Ext(origin)
+	name := fmt.Sprintf("%v%v", strings.TrimSuffix(baseName, ext), extension)
 	return filepath.Join(prefix, name)
 }
 
@@ -65,16 +70,23 @@ func (collection ParquetCollection) Write(pkg *common.DataRecord) error {
 		}
 		collection.streams[streamName] = writer
 	}
+	if pkg.Origin != nil {
+		writer.AddOrigin(pkg.Origin.Name)
+	}
 	return writer.WriteData(GetParquetRow(pkg))
 }
 
-// CloseAll closes all open streams
-func (collection *ParquetCollection) CloseAll() {
+// CloseAll closes all open streams, returning the first error closing them
+func (collection *ParquetCollection) CloseAll() error {
+	var err error
 	for stream := range collection.streams {
 		oldWriter, ok := collection.streams[stream]
 		if ok {
-			oldWriter.Close()
+			if closeErr := oldWriter.Close(); err == nil {
+				err = closeErr
+			}
 			collection.streams[stream] = nil
 		}
 	}
+	return err
 }
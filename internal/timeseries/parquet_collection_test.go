@@ -137,8 +137,7 @@ func TestParquetCollection_Write(t *testing.T) {
 			}
 			factory := func(pkg *common.DataRecord, stream OutStream) (ParquetWriter, error) {
 				f := filepath.Join(dir, "test")
-				parquet := NewParquet(f, pkg)
-				return parquet, nil
+				return NewParquet(f, pkg)
 			}
 			col := NewParquetCollection(factory)
 			for _, pkg := range tt.pkgs {
@@ -0,0 +1,234 @@
+package timeseries
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	goparquet "github.com/fraugster/parquet-go"
+	"github.com/fraugster/parquet-go/parquet"
+	"github.com/fraugster/parquet-go/parquetschema"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ParquetSettings tunes how the parquet files of a stream are written
+type ParquetSettings struct {
+	Codec        parquet.CompressionCodec
+	RowGroupSize int64 // Bytes of a row group before it is flushed, 0 for one row group per file
+	MaxRows      int64 // Rows of a file before rolling over to the next, 0 for no limit
+	MaxBytes     int64 // Approximate bytes of a file before rolling over, 0 for no limit
+	Dictionary   bool  // Dictionary encodes the columns
+}
+
+// DefaultParquetSettings are the settings used unless told otherwise
+var DefaultParquetSettings = ParquetSettings{
+	Codec:      parquet.CompressionCodec_SNAPPY,
+	Dictionary: true,
+}
+
+// ParquetSettingNames are the names of the settings accepted by Set
+var ParquetSettingNames = []string{"codec", "row-group", "max-rows", "max-bytes", "dictionary"}
+
+var parquetCodecs = map[string]parquet.CompressionCodec{
+	"snappy": parquet.CompressionCodec_SNAPPY,
+	"gzip":   parquet.CompressionCodec_GZIP,
+	"zstd":   parquet.CompressionCodec_ZSTD,
+	"none":   parquet.CompressionCodec_UNCOMPRESSED,
+}
+
+// Set sets the setting with the name from its text, e.g. "codec" to "zstd"
+// or "row-group" to "64M"
+func (settings *ParquetSettings) Set(name string, value string) error {
+	var err error
+	switch name {
+	case "codec":
+		codec, ok := parquetCodecs[strings.ToLower(value)]
+		if !ok {
+			return fmt.Errorf("unknown parquet codec '%v', use snappy, gzip, zstd or none", value)
+		}
+		settings.Codec = codec
+	case "row-group":
+		settings.RowGroupSize, err = ParseByteSize(value)
+	case "max-rows":
+		settings.MaxRows, err = strconv.ParseInt(value, 10, 64)
+		if err == nil && settings.MaxRows < 0 {
+			err = fmt.Errorf("negative number of rows %v", value)
+		}
+	case "max-bytes":
+		settings.MaxBytes, err = ParseByteSize(value)
+	case "dictionary":
+		switch strings.ToLower(value) {
+		case "on":
+			settings.Dictionary = true
+		case "off":
+			settings.Dictionary = false
+		default:
+			settings.Dictionary, err = strconv.ParseBool(value)
+		}
+	default:
+		return fmt.Errorf("unknown parquet setting '%v'", name)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid parquet %v '%v': %v", name, value, err)
+	}
+	return nil
+}
+
+// ParseByteSize parses a number of bytes, optionally with the suffix K, M or
+// G for multiples of 1024
+func ParseByteSize(value string) (int64, error) {
+	text := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(value)), "B")
+	text = strings.TrimSuffix(text, "I")
+	multiplier := int64(1)
+	for suffix, factor := range map[string]int64{"K": 1 << 10, "M": 1 << 20, "G": 1 << 30} {
+		if strings.HasSuffix(text, suffix) {
+			text = strings.TrimSuffix(text, suffix)
+			multiplier = factor
+			break
+		}
+	}
+	size, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("not a size in bytes")
+	}
+	if size < 0 {
+		return 0, fmt.Errorf("negative size")
+	}
+	return size * multiplier, nil
+}
+
+// ParquetTuning holds the parquet settings of each stream
+type ParquetTuning struct {
+	Default ParquetSettings
+	Streams map[OutStream]ParquetSettings
+}
+
+// Settings returns the settings of the stream
+func (tuning ParquetTuning) Settings(stream OutStream) ParquetSettings {
+	if settings, ok := tuning.Streams[stream]; ok {
+		return settings
+	}
+	return tuning.Default
+}
+
+var activeParquetTuning = ParquetTuning{Default: DefaultParquetSettings}
+var activeParquetTuningLock sync.RWMutex
+
+// SetParquetTuning sets the settings used by NewParquet
+func SetParquetTuning(tuning ParquetTuning) {
+	activeParquetTuningLock.Lock()
+	defer activeParquetTuningLock.Unlock()
+	activeParquetTuning = tuning
+}
+
+// ActiveParquetTuning returns the settings used by NewParquet
+func ActiveParquetTuning() ParquetTuning {
+	activeParquetTuningLock.RLock()
+	defer activeParquetTuningLock.RUnlock()
+	return activeParquetTuning
+}
+
+// parquetWriterOptions returns the options of a file writer of the schema
+//
+// Without dictionary the columns are added to the writer by plainColumns
+// since the library dictionary encodes all columns of a schema definition.
+func parquetWriterOptions(
+	sd *parquetschema.SchemaDefinition,
+	metadata map[string]string,
+	settings ParquetSettings,
+) []goparquet.FileWriterOption {
+	options := []goparquet.FileWriterOption{
+		goparquet.WithMetaData(metadata),
+		goparquet.WithCompressionCodec(settings.Codec),
+	}
+	if settings.Dictionary {
+		options = append(options, goparquet.WithSchemaDefinition(sd))
+	}
+	if settings.RowGroupSize > 0 {
+		options = append(options, goparquet.WithMaxRowGroupSize(settings.RowGroupSize))
+	}
+	return options
+}
+
+// addPlainColumns adds the columns of the schema without dictionary encoding
+func addPlainColumns(writer *goparquet.FileWriter, sd *parquetschema.SchemaDefinition) error {
+	for _, definition := range sd.RootColumn.Children {
+		column, err := plainColumn(definition)
+		if err != nil {
+			return err
+		}
+		err = writer.AddColumnByPath(goparquet.ColumnPath{definition.SchemaElement.GetName()}, column)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func plainColumn(definition *parquetschema.ColumnDefinition) (*goparquet.Column, error) {
+	element := definition.SchemaElement
+	if len(definition.Children) > 0 {
+		// Lists are the only groups of the schemas
+		if len(definition.Children) != 1 || len(definition.Children[0].Children) != 1 {
+			return nil, fmt.Errorf("column %v is not a list", element.GetName())
+		}
+		item, err := plainColumn(definition.Children[0].Children[0])
+		if err != nil {
+			return nil, err
+		}
+		return goparquet.NewListColumn(item, element.GetRepetitionType())
+	}
+	params := &goparquet.ColumnParameters{
+		LogicalType:   element.LogicalType,
+		ConvertedType: element.ConvertedType,
+		TypeLength:    element.TypeLength,
+		FieldID:       element.FieldID,
+		Scale:         element.Scale,
+		Precision:     element.Precision,
+	}
+	var store *goparquet.ColumnStore
+	var err error
+	switch element.GetType() {
+	case parquet.Type_BOOLEAN:
+		store, err = goparquet.NewBooleanStore(parquet.Encoding_PLAIN, params)
+	case parquet.Type_INT32:
+		store, err = goparquet.NewInt32Store(parquet.Encoding_PLAIN, false, params)
+	case parquet.Type_INT64:
+		store, err = goparquet.NewInt64Store(parquet.Encoding_PLAIN, false, params)
+	case parquet.Type_DOUBLE:
+		store, err = goparquet.NewDoubleStore(parquet.Encoding_PLAIN, false, params)
+	case parquet.Type_BYTE_ARRAY:
+		store, err = goparquet.NewByteArrayStore(parquet.Encoding_PLAIN, false, params)
+	default:
+		return nil, fmt.Errorf("column %v has unsupported type %v", element.GetName(), element.GetType())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return goparquet.NewDataColumn(store, element.GetRepetitionType()), nil
+}
+
+// zstdCompressor compresses parquet pages with zstd, which the parquet
+// library leaves to its users
+type zstdCompressor struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+func (compressor zstdCompressor) CompressBlock(block []byte) ([]byte, error) {
+	return compressor.encoder.EncodeAll(block, nil), nil
+}
+
+func (compressor zstdCompressor) DecompressBlock(block []byte) ([]byte, error) {
+	return compressor.decoder.DecodeAll(block, nil)
+}
+
+func init() {
+	encoder, _ := zstd.NewWriter(nil)
+	decoder, _ := zstd.NewReader(nil)
+	goparquet.RegisterBlockCompressor(
+		parquet.CompressionCodec_ZSTD,
+		zstdCompressor{encoder: encoder, decoder: decoder},
+	)
+}
@@ -0,0 +1,169 @@
+package timeseries
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	goparquet "github.com/fraugster/parquet-go"
+	"github.com/fraugster/parquet-go/parquet"
+	"github.com/fraugster/parquet-go/parquetschema"
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
+)
+
+func TestParquetSettings_Set(t *testing.T) {
+	tests := []struct {
+		name    string
+		setting string
+		value   string
+		want    ParquetSettings
+		wantErr bool
+	}{
+		{"Sets codec", "codec", "ZSTD", ParquetSettings{Codec: parquet.CompressionCodec_ZSTD}, false},
+		{"Sets no codec", "codec", "none", ParquetSettings{Codec: parquet.CompressionCodec_UNCOMPRESSED}, false},
+		{"Rejects unknown codec", "codec", "lzo", ParquetSettings{}, true},
+		{"Sets row group", "row-group", "64MB", ParquetSettings{RowGroupSize: 64 << 20}, false},
+		{"Sets max rows", "max-rows", "1000", ParquetSettings{MaxRows: 1000}, false},
+		{"Rejects negative rows", "max-rows", "-1", ParquetSettings{MaxRows: -1}, true},
+		{"Sets max bytes", "max-bytes", "1GiB", ParquetSettings{MaxBytes: 1 << 30}, false},
+		{"Sets dictionary", "dictionary", "on", ParquetSettings{Dictionary: true}, false},
+		{"Sets dictionary as bool", "dictionary", "true", ParquetSettings{Dictionary: true}, false},
+		{"Rejects bad dictionary", "dictionary", "maybe", ParquetSettings{}, true},
+		{"Rejects unknown setting", "page", "1", ParquetSettings{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var settings ParquetSettings
+			err := settings.Set(tt.setting, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParquetSettings.Set() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if settings != tt.want {
+				t.Errorf("ParquetSettings.Set() = %+v, want %+v", settings, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{"1234", 1234, false},
+		{"12B", 12, false},
+		{"2k", 2048, false},
+		{"3M", 3 << 20, false},
+		{"3MiB", 3 << 20, false},
+		{"1GB", 1 << 30, false},
+		{"1TB", 0, true},
+		{"-1K", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseByteSize(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseByteSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseByteSize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParquetTuning_Settings(t *testing.T) {
+	ccd := ParquetSettings{Codec: parquet.CompressionCodec_ZSTD, RowGroupSize: 1 << 20}
+	tuning := ParquetTuning{
+		Default: DefaultParquetSettings,
+		Streams: map[OutStream]ParquetSettings{CCD: ccd},
+	}
+	if got := tuning.Settings(CCD); got != ccd {
+		t.Errorf("ParquetTuning.Settings(CCD) = %+v, want %+v", got, ccd)
+	}
+	if got := tuning.Settings(HTR); got != DefaultParquetSettings {
+		t.Errorf("ParquetTuning.Settings(HTR) = %+v, want %+v", got, DefaultParquetSettings)
+	}
+}
+
+func TestNewParquet_settings(t *testing.T) {
+	defer SetParquetTuning(ActiveParquetTuning())
+	want, err := parquetschema.ParseSchemaDefinition(schema.ParquetSchema(HTR.Columns()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		settings  ParquetSettings
+		wantFiles []string
+	}{
+		{"Writes default", DefaultParquetSettings, []string{"my.parquet"}},
+		{
+			"Writes zstd without dictionary",
+			ParquetSettings{Codec: parquet.CompressionCodec_ZSTD},
+			[]string{"my.parquet"},
+		},
+		{
+			"Rolls over on rows",
+			ParquetSettings{Codec: parquet.CompressionCodec_GZIP, MaxRows: 2},
+			[]string{"my.parquet", "my.part1.parquet", "my.part2.parquet"},
+		},
+		{
+			"Rolls over on bytes",
+			ParquetSettings{Codec: parquet.CompressionCodec_UNCOMPRESSED, MaxBytes: 1, Dictionary: true},
+			[]string{"my.parquet", "my.part1.parquet", "my.part2.parquet", "my.part3.parquet", "my.part4.parquet"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			SetParquetTuning(ParquetTuning{Streams: map[OutStream]ParquetSettings{HTR: tt.settings}})
+			writeTestParquet(t, filepath.Join(dir, "my.parquet"), "my.rac", &aez.HTR{}, 1, 2, 3, 4, 5)
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var files []string
+			var seconds []int64
+			for _, entry := range entries {
+				files = append(files, entry.Name())
+			}
+			for part := range tt.wantFiles {
+				name := filepath.Join(dir, ParquetPartName("my.parquet", part))
+				file, err := os.Open(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				reader, err := goparquet.NewFileReader(file)
+				if err != nil {
+					t.Fatalf("could not read %v: %v", name, err)
+				}
+				if got := columnsDefinition(reader.GetSchemaDefinition()); got != columnsDefinition(want) {
+					t.Errorf("NewParquet() wrote schema\n%v\nwant\n%v", got, columnsDefinition(want))
+				}
+				if err := reader.PreLoad(); err != nil {
+					t.Fatalf("could not read %v: %v", name, err)
+				}
+				if got := reader.CurrentRowGroup().Columns[0].MetaData.Codec; got != tt.settings.Codec {
+					t.Errorf("NewParquet() wrote codec %v, want %v", got, tt.settings.Codec)
+				}
+				file.Close()
+				rows, _ := readTestParquet(t, name)
+				for _, row := range rows {
+					seconds = append(seconds, row["TMHeaderNanoseconds"].(int64)/1e9)
+				}
+			}
+			if !reflect.DeepEqual(files, tt.wantFiles) {
+				t.Errorf("NewParquet() wrote %v, want %v", files, tt.wantFiles)
+			}
+			if !reflect.DeepEqual(seconds, []int64{1, 2, 3, 4, 5}) {
+				t.Errorf("NewParquet() wrote seconds %v, want 1 to 5", seconds)
+			}
+		})
+	}
+}
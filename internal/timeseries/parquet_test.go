@@ -2,6 +2,7 @@ package timeseries
 
 import (
 	"log"
+	"math"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -11,8 +12,9 @@ import (
 	"github.com/innosat-mats/rac-extract-payload/internal/aez"
 	"github.com/innosat-mats/rac-extract-payload/internal/common"
 	"github.com/innosat-mats/rac-extract-payload/internal/innosat"
-	"github.com/innosat-mats/rac-extract-payload/internal/parquetrow"
+	"github.com/innosat-mats/rac-extract-payload/internal/limits"
 	"github.com/innosat-mats/rac-extract-payload/internal/ramses"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
 )
 
 func parseTime(timestamp string) time.Time {
@@ -39,6 +41,11 @@ func TestGetParquetRow(t *testing.T) {
 		TMHeader:       &innosat.TMHeader{CUCTimeSeconds: 42, CUCTimeFraction: 0xc000},
 		Buffer:         getTestImage(),
 	}
+	// Rates are NaN for a lone STAT so a previous one is needed
+	statTracker := aez.STATRateTracker{}
+	statTracker.Update(&aez.STAT{})
+	stat := aez.STAT{TS: 1}
+	statTracker.Update(&stat)
 	type args struct {
 		sid  aez.SID
 		data common.Exporter
@@ -46,7 +53,7 @@ func TestGetParquetRow(t *testing.T) {
 	tests := []struct {
 		name string
 		args args
-		want parquetrow.ParquetRow
+		want map[string]interface{}
 	}{
 		{
 			"Test CCDImage",
@@ -54,70 +61,98 @@ func TestGetParquetRow(t *testing.T) {
 				PackData:      &aez.CCDImagePackData{JPEGQ: 95},
 				ImageFileName: "HelloWorld.png",
 			}},
-			parquetrow.ParquetRow{
-				OriginFile:          "Sputnik",
-				ProcessingTime:      procDate,
-				RamsesTime:          data.RamsesHeader.Created(),
-				QualityIndicator:    0,
-				LossFlag:            1,
-				VCFrameCounter:      42,
-				SPSequenceCount:     3,
-				TMHeaderTime:        data.TMHeader.Time(aez.GpsTime),
-				TMHeaderNanoseconds: 42750000000,
-				SID:                 "",
-				RID:                 "",
-				EXPDate:             parseTime("1980-01-05 23:59:42 +0000 UTC"),
-				WDWMode:             "Manual",
-				WDWInputDataWindow:  "11..0",
-				JPEGQ:               95,
-				NCBINFPGAColumns:    1,
-				GAINMode:            "High",
-				GAINTiming:          "Faster",
-				ImageName:           "HelloWorld.png",
+			map[string]interface{}{
+				"OriginFile":          "Sputnik",
+				"ProcessingTime":      procDate,
+				"RamsesTime":          data.RamsesHeader.Created(),
+				"QualityIndicator":    int64(0),
+				"LossFlag":            int64(1),
+				"VCFrameCounter":      int64(42),
+				"SPSequenceCount":     int64(3),
+				"TMHeaderTime":        data.TMHeader.Time(aez.GpsTime),
+				"TMHeaderNanoseconds": int64(42750000000),
+				"SID":                 "",
+				"RID":                 "",
+				"EXPDate":             parseTime("1980-01-05 23:59:42 +0000 UTC"),
+				"WDWMode":             "Manual",
+				"WDWInputDataWindow":  "11..0",
+				"JPEGQ":               int64(95),
+				"NCBINFPGAColumns":    int64(1),
+				"GAINMode":            "High",
+				"GAINTiming":          "Faster",
+				"ImageName":           "HelloWorld.png",
 			},
 		},
 		{
 			"Test PMData",
-			args{0, &aez.PMData{}},
-			parquetrow.ParquetRow{
-				OriginFile:          "Sputnik",
-				ProcessingTime:      procDate,
-				RamsesTime:          data.RamsesHeader.Created(),
-				QualityIndicator:    0,
-				LossFlag:            1,
-				VCFrameCounter:      42,
-				SPSequenceCount:     3,
-				TMHeaderTime:        data.TMHeader.Time(aez.GpsTime),
-				TMHeaderNanoseconds: 42750000000,
-				SID:                 "",
-				RID:                 "",
-				PMTime:              parseTime("1980-01-05 23:59:42 +0000 UTC"),
+			args{0, &aez.PMData{
+				PM1ACNTR: 1,
+				PM1BCNTR: 1,
+				PM1SCNTR: 1,
+				PM2ACNTR: 1,
+				PM2BCNTR: 1,
+				PM2SCNTR: 1,
+			}},
+			map[string]interface{}{
+				"OriginFile":          "Sputnik",
+				"ProcessingTime":      procDate,
+				"RamsesTime":          data.RamsesHeader.Created(),
+				"QualityIndicator":    int64(0),
+				"LossFlag":            int64(1),
+				"VCFrameCounter":      int64(42),
+				"SPSequenceCount":     int64(3),
+				"TMHeaderTime":        data.TMHeader.Time(aez.GpsTime),
+				"TMHeaderNanoseconds": int64(42750000000),
+				"SID":                 "",
+				"RID":                 "",
+				"PMTime":              parseTime("1980-01-05 23:59:42 +0000 UTC"),
+				"PM1ACNTR":            int64(1),
+				"PM1AT":               nil,
+				"PM1BCNTR":            int64(1),
+				"PM1BT":               nil,
+				"PM1SCNTR":            int64(1),
+				"PM2ACNTR":            int64(1),
+				"PM2AT":               nil,
+				"PM2BCNTR":            int64(1),
+				"PM2BT":               nil,
+				"PM2SCNTR":            int64(1),
+				"PM1SAVG":             0.0,
+				"PM1SV":               nil,
+				"Warnings":            nil,
 			},
 		},
 		{
 			"Test HTR",
 			args{aez.SIDHTR, &aez.HTR{}},
-			parquetrow.ParquetRow{
-				OriginFile:          "Sputnik",
-				ProcessingTime:      procDate,
-				RamsesTime:          data.RamsesHeader.Created(),
-				QualityIndicator:    0,
-				LossFlag:            1,
-				VCFrameCounter:      42,
-				SPSequenceCount:     3,
-				TMHeaderTime:        data.TMHeader.Time(aez.GpsTime),
-				TMHeaderNanoseconds: 42750000000,
-				SID:                 "HTR",
-				RID:                 "",
-				HTR1A:               -55,
-				HTR1B:               -55,
-				HTR2A:               -55,
-				HTR2B:               -55,
-				HTR7A:               -55,
-				HTR7B:               -55,
-				HTR8A:               -55,
-				HTR8B:               -55,
-				Warnings: []string{
+			map[string]interface{}{
+				"OriginFile":          "Sputnik",
+				"ProcessingTime":      procDate,
+				"RamsesTime":          data.RamsesHeader.Created(),
+				"QualityIndicator":    int64(0),
+				"LossFlag":            int64(1),
+				"VCFrameCounter":      int64(42),
+				"SPSequenceCount":     int64(3),
+				"TMHeaderTime":        data.TMHeader.Time(aez.GpsTime),
+				"TMHeaderNanoseconds": int64(42750000000),
+				"SID":                 "HTR",
+				"RID":                 "",
+				"HTR1A":               -55.0,
+				"HTR1B":               -55.0,
+				"HTR2A":               -55.0,
+				"HTR2B":               -55.0,
+				"HTR7A":               -55.0,
+				"HTR7B":               -55.0,
+				"HTR8A":               -55.0,
+				"HTR8B":               -55.0,
+				"HTR1AUncertainty":    math.NaN(),
+				"HTR1BUncertainty":    math.NaN(),
+				"HTR2AUncertainty":    math.NaN(),
+				"HTR2BUncertainty":    math.NaN(),
+				"HTR7AUncertainty":    math.NaN(),
+				"HTR7BUncertainty":    math.NaN(),
+				"HTR8AUncertainty":    math.NaN(),
+				"HTR8BUncertainty":    math.NaN(),
+				"Warnings": []string{
 					"HTR1A: +Inf is too large for interpolator. Returning value for maximum.",
 					"HTR1B: +Inf is too large for interpolator. Returning value for maximum.",
 					"HTR2A: +Inf is too large for interpolator. Returning value for maximum.",
@@ -132,138 +167,168 @@ func TestGetParquetRow(t *testing.T) {
 		{
 			"Test PWR",
 			args{aez.SIDPWR, &aez.PWR{}},
-			parquetrow.ParquetRow{
-				OriginFile:          "Sputnik",
-				ProcessingTime:      procDate,
-				RamsesTime:          data.RamsesHeader.Created(),
-				QualityIndicator:    0,
-				LossFlag:            1,
-				VCFrameCounter:      42,
-				SPSequenceCount:     3,
-				TMHeaderTime:        data.TMHeader.Time(aez.GpsTime),
-				TMHeaderNanoseconds: 42750000000,
-				SID:                 "PWR",
-				RID:                 "",
-				PWRT:                -55,
-				Warnings:            []string{"PWRT: +Inf is too large for interpolator. Returning value for maximum."},
+			map[string]interface{}{
+				"OriginFile":          "Sputnik",
+				"ProcessingTime":      procDate,
+				"RamsesTime":          data.RamsesHeader.Created(),
+				"QualityIndicator":    int64(0),
+				"LossFlag":            int64(1),
+				"VCFrameCounter":      int64(42),
+				"SPSequenceCount":     int64(3),
+				"TMHeaderTime":        data.TMHeader.Time(aez.GpsTime),
+				"TMHeaderNanoseconds": int64(42750000000),
+				"SID":                 "PWR",
+				"RID":                 "",
+				"PWRT":                -55.0,
+				"PWRTUncertainty":     math.NaN(),
+				"Warnings":            []string{"PWRT: +Inf is too large for interpolator. Returning value for maximum."},
 			},
 		},
 		{
 			"Test CPRU",
 			args{aez.SIDCPRUA, &aez.CPRU{STAT: 8}},
-			parquetrow.ParquetRow{
-				OriginFile:          "Sputnik",
-				ProcessingTime:      procDate,
-				RamsesTime:          data.RamsesHeader.Created(),
-				QualityIndicator:    0,
-				LossFlag:            1,
-				VCFrameCounter:      42,
-				SPSequenceCount:     3,
-				TMHeaderTime:        data.TMHeader.Time(aez.GpsTime),
-				TMHeaderNanoseconds: 42750000000,
-				SID:                 "CPRUA",
-				RID:                 "",
-				Power0:              true,
+			map[string]interface{}{
+				"OriginFile":          "Sputnik",
+				"ProcessingTime":      procDate,
+				"RamsesTime":          data.RamsesHeader.Created(),
+				"QualityIndicator":    int64(0),
+				"LossFlag":            int64(1),
+				"VCFrameCounter":      int64(42),
+				"SPSequenceCount":     int64(3),
+				"TMHeaderTime":        data.TMHeader.Time(aez.GpsTime),
+				"TMHeaderNanoseconds": int64(42750000000),
+				"SID":                 "CPRUA",
+				"RID":                 "",
+				"Power0":              true,
 			},
 		},
 		{
 			"Test STAT",
-			args{aez.SIDSTAT, &aez.STAT{}},
-			parquetrow.ParquetRow{
-				OriginFile:          "Sputnik",
-				ProcessingTime:      procDate,
-				RamsesTime:          data.RamsesHeader.Created(),
-				QualityIndicator:    0,
-				LossFlag:            1,
-				VCFrameCounter:      42,
-				SPSequenceCount:     3,
-				TMHeaderTime:        data.TMHeader.Time(aez.GpsTime),
-				TMHeaderNanoseconds: 42750000000,
-				SID:                 "STAT",
-				RID:                 "",
-				STATTime:            parseTime("1980-01-05 23:59:42 +0000 UTC"),
+			args{aez.SIDSTAT, &stat},
+			map[string]interface{}{
+				"OriginFile":          "Sputnik",
+				"ProcessingTime":      procDate,
+				"RamsesTime":          data.RamsesHeader.Created(),
+				"QualityIndicator":    int64(0),
+				"LossFlag":            int64(1),
+				"VCFrameCounter":      int64(42),
+				"SPSequenceCount":     int64(3),
+				"TMHeaderTime":        data.TMHeader.Time(aez.GpsTime),
+				"TMHeaderNanoseconds": int64(42750000000),
+				"SID":                 "STAT",
+				"RID":                 "",
+				"STATTime":            parseTime("1980-01-05 23:59:43 +0000 UTC"),
+				"STATNanoseconds":     int64(1000000000),
 			},
 		},
 		{
 			"Test TCAcceptSuccessData",
 			args{0, &aez.TCAcceptSuccessData{TCPID: 1, PSC: 2}},
-			parquetrow.ParquetRow{
-				OriginFile:          "Sputnik",
-				ProcessingTime:      procDate,
-				RamsesTime:          data.RamsesHeader.Created(),
-				QualityIndicator:    0,
-				LossFlag:            1,
-				VCFrameCounter:      42,
-				SPSequenceCount:     3,
-				TMHeaderTime:        data.TMHeader.Time(aez.GpsTime),
-				TMHeaderNanoseconds: 42750000000,
-				SID:                 "",
-				RID:                 "",
-				TCV:                 "Accept",
-				TCPID:               1,
-				PSC:                 2,
+			map[string]interface{}{
+				"OriginFile":          "Sputnik",
+				"ProcessingTime":      procDate,
+				"RamsesTime":          data.RamsesHeader.Created(),
+				"QualityIndicator":    int64(0),
+				"LossFlag":            int64(1),
+				"VCFrameCounter":      int64(42),
+				"SPSequenceCount":     int64(3),
+				"TMHeaderTime":        data.TMHeader.Time(aez.GpsTime),
+				"TMHeaderNanoseconds": int64(42750000000),
+				"SID":                 "",
+				"RID":                 "",
+				"TCV":                 "Accept",
+				"TCPID":               int64(1),
+				"PSC":                 int64(2),
 			},
 		},
 		{
 			"Test TCAcceptFailureData",
 			args{0, &aez.TCAcceptFailureData{TCPID: 1, PSC: 2, ErrorCode: 3}},
-			parquetrow.ParquetRow{
-				OriginFile:          "Sputnik",
-				ProcessingTime:      procDate,
-				RamsesTime:          data.RamsesHeader.Created(),
-				QualityIndicator:    0,
-				LossFlag:            1,
-				VCFrameCounter:      42,
-				SPSequenceCount:     3,
-				TMHeaderTime:        data.TMHeader.Time(aez.GpsTime),
-				TMHeaderNanoseconds: 42750000000,
-				SID:                 "",
-				RID:                 "",
-				TCV:                 "Accept",
-				TCPID:               1,
-				PSC:                 2,
-				ErrorCode:           3,
+			map[string]interface{}{
+				"OriginFile":          "Sputnik",
+				"ProcessingTime":      procDate,
+				"RamsesTime":          data.RamsesHeader.Created(),
+				"QualityIndicator":    int64(0),
+				"LossFlag":            int64(1),
+				"VCFrameCounter":      int64(42),
+				"SPSequenceCount":     int64(3),
+				"TMHeaderTime":        data.TMHeader.Time(aez.GpsTime),
+				"TMHeaderNanoseconds": int64(42750000000),
+				"SID":                 "",
+				"RID":                 "",
+				"TCV":                 "Accept",
+				"TCPID":               int64(1),
+				"PSC":                 int64(2),
+				"ErrorCode":           int64(3),
 			},
 		},
 		{
 			"Test TCExecSuccessData",
 			args{0, &aez.TCExecSuccessData{TCPID: 1, PSC: 2}},
-			parquetrow.ParquetRow{
-				OriginFile:          "Sputnik",
-				ProcessingTime:      procDate,
-				RamsesTime:          data.RamsesHeader.Created(),
-				QualityIndicator:    0,
-				LossFlag:            1,
-				VCFrameCounter:      42,
-				SPSequenceCount:     3,
-				TMHeaderTime:        data.TMHeader.Time(aez.GpsTime),
-				TMHeaderNanoseconds: 42750000000,
-				SID:                 "",
-				RID:                 "",
-				TCV:                 "Exec",
-				TCPID:               1,
-				PSC:                 2,
+			map[string]interface{}{
+				"OriginFile":          "Sputnik",
+				"ProcessingTime":      procDate,
+				"RamsesTime":          data.RamsesHeader.Created(),
+				"QualityIndicator":    int64(0),
+				"LossFlag":            int64(1),
+				"VCFrameCounter":      int64(42),
+				"SPSequenceCount":     int64(3),
+				"TMHeaderTime":        data.TMHeader.Time(aez.GpsTime),
+				"TMHeaderNanoseconds": int64(42750000000),
+				"SID":                 "",
+				"RID":                 "",
+				"TCV":                 "Exec",
+				"TCPID":               int64(1),
+				"PSC":                 int64(2),
 			},
 		},
 		{
 			"Test TCExecFailureData",
 			args{0, &aez.TCExecFailureData{TCPID: 1, PSC: 2, ErrorCode: 3}},
-			parquetrow.ParquetRow{
-				OriginFile:          "Sputnik",
-				ProcessingTime:      procDate,
-				RamsesTime:          data.RamsesHeader.Created(),
-				QualityIndicator:    0,
-				LossFlag:            1,
-				VCFrameCounter:      42,
-				SPSequenceCount:     3,
-				TMHeaderTime:        data.TMHeader.Time(aez.GpsTime),
-				TMHeaderNanoseconds: 42750000000,
-				SID:                 "",
-				RID:                 "",
-				TCV:                 "Exec",
-				TCPID:               1,
-				PSC:                 2,
-				ErrorCode:           3,
+			map[string]interface{}{
+				"OriginFile":          "Sputnik",
+				"ProcessingTime":      procDate,
+				"RamsesTime":          data.RamsesHeader.Created(),
+				"QualityIndicator":    int64(0),
+				"LossFlag":            int64(1),
+				"VCFrameCounter":      int64(42),
+				"SPSequenceCount":     int64(3),
+				"TMHeaderTime":        data.TMHeader.Time(aez.GpsTime),
+				"TMHeaderNanoseconds": int64(42750000000),
+				"SID":                 "",
+				"RID":                 "",
+				"TCV":                 "Exec",
+				"TCPID":               int64(1),
+				"PSC":                 int64(2),
+				"ErrorCode":           int64(3),
+			},
+		},
+		{
+			"Test Alarm",
+			args{aez.SIDHTR, &limits.Alarm{
+				AlarmField:    "HTR1A",
+				AlarmValue:    -55,
+				AlarmSeverity: limits.Hard,
+				AlarmBound:    limits.Low,
+				AlarmLimit:    -40,
+			}},
+			map[string]interface{}{
+				"OriginFile":          "Sputnik",
+				"ProcessingTime":      procDate,
+				"RamsesTime":          data.RamsesHeader.Created(),
+				"QualityIndicator":    int64(0),
+				"LossFlag":            int64(1),
+				"VCFrameCounter":      int64(42),
+				"SPSequenceCount":     int64(3),
+				"TMHeaderTime":        data.TMHeader.Time(aez.GpsTime),
+				"TMHeaderNanoseconds": int64(42750000000),
+				"SID":                 "HTR",
+				"RID":                 "",
+				"AlarmField":          "HTR1A",
+				"AlarmValue":          -55.0,
+				"AlarmSeverity":       "HARD",
+				"AlarmBound":          "LOW",
+				"AlarmLimit":          -40.0,
 			},
 		},
 	}
@@ -271,9 +336,46 @@ func TestGetParquetRow(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			data.SID = tt.args.sid
 			data.Data = tt.args.data
-			if got := GetParquetRow(&data); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("GetParquetRow():\n%v\nwant:\n%v", got, tt.want)
+			got := GetParquetRow(&data)
+			if len(got.Values) != len(got.Columns) {
+				t.Fatalf("GetParquetRow() has %v values, want %v", len(got.Values), len(got.Columns))
+			}
+			for name, value := range tt.want {
+				idx := schema.Index(got.Columns, name)
+				if idx < 0 {
+					t.Errorf("GetParquetRow() lacks %v", name)
+					continue
+				}
+				if number, ok := value.(float64); ok && math.IsNaN(number) {
+					if gotNumber, ok := got.Values[idx].(float64); !ok || !math.IsNaN(gotNumber) {
+						t.Errorf("GetParquetRow() %v = %v, want NaN", name, got.Values[idx])
+					}
+					continue
+				}
+				if !reflect.DeepEqual(got.Values[idx], value) {
+					t.Errorf("GetParquetRow() %v = %v, want %v", name, got.Values[idx], value)
+				}
+			}
+			writer, err := NewParquet(filepath.Join(t.TempDir(), "test.parquet"), &data)
+			if err != nil {
+				t.Fatalf("NewParquet() error = %v", err)
+			}
+			defer writer.Close()
+			if err := writer.WriteData(got); err != nil {
+				t.Errorf("GetParquetRow() could not be written: %v", err)
 			}
 		})
 	}
 }
+
+func TestNewParquet_missingDirectory(t *testing.T) {
+	pkg := common.DataRecord{
+		Origin:   &common.OriginDescription{Name: "test1.rac"},
+		TMHeader: &innosat.TMHeader{},
+		Data:     &aez.HTR{},
+	}
+	name := filepath.Join(t.TempDir(), "missing", "test1.parquet")
+	if _, err := NewParquet(name, &pkg); err == nil {
+		t.Error("NewParquet() gave no error for a missing directory")
+	}
+}
@@ -0,0 +1,203 @@
+package timeseries
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+)
+
+// PartitionKey is the time that decides the partition of a record
+type PartitionKey int
+
+const (
+	// TMHeaderTime partitions on the time of the telemetry header
+	TMHeaderTime PartitionKey = iota
+	// ExposureTime partitions on the exposure time of images and photometer
+	// data and the measurement time of STAT, else on TMHeaderTime
+	ExposureTime
+	// RamsesTime partitions on the time the Ramses packet was created, if
+	// known, else on TMHeaderTime
+	RamsesTime
+)
+
+// PartitionKeys are the known partition keys
+var PartitionKeys = []PartitionKey{TMHeaderTime, ExposureTime, RamsesTime}
+
+func (key PartitionKey) String() string {
+	switch key {
+	case TMHeaderTime:
+		return "tm"
+	case ExposureTime:
+		return "exposure"
+	case RamsesTime:
+		return "ramses"
+	default:
+		return "unknown"
+	}
+}
+
+// PartitionKeyFromName returns the partition key with the name
+func PartitionKeyFromName(name string) (PartitionKey, error) {
+	for _, key := range PartitionKeys {
+		if strings.EqualFold(key.String(), name) {
+			return key, nil
+		}
+	}
+	return TMHeaderTime, fmt.Errorf("unknown partition key '%v'", name)
+}
+
+// Time returns the time of the record used for partitioning
+func (key PartitionKey) Time(pkg *common.DataRecord) time.Time {
+	switch key {
+	case ExposureTime:
+		switch data := pkg.Data.(type) {
+		case *aez.CCDImage:
+			if data.PackData != nil {
+				return data.PackData.Time(aez.GpsTime)
+			}
+		case *aez.PMData:
+			return data.Time(aez.GpsTime)
+		case *aez.STAT:
+			return data.Time(aez.GpsTime)
+		}
+	case RamsesTime:
+		if pkg.RamsesHeader != nil {
+			return pkg.RamsesHeader.Created()
+		}
+	}
+	return pkg.TMHeader.Time(time.Time{})
+}
+
+// Column returns the column of the stream holding the time used for
+// partitioning, for rows read back from outputs
+func (key PartitionKey) Column(stream OutStream) string {
+	switch key {
+	case ExposureTime:
+		switch stream {
+		case CCD:
+			return "EXPDate"
+		case PM:
+			return "PMTime"
+		case STAT:
+			return "STATTime"
+		}
+	case RamsesTime:
+		return "RamsesTime"
+	}
+	return "TMHeaderTime"
+}
+
+// Partitioning describes the directories that parquet and arrow files are
+// written to
+//
+// The templates are slash separated and may hold the placeholders {stream},
+// {year}, {month}, {day} and {hour}, the latter three zero padded, as well as
+// {m}, {d} and {h} that are not padded.
+type Partitioning struct {
+	Template      string
+	ImageTemplate string // Used for the CCD stream, if empty Template is
+	Key           PartitionKey
+}
+
+// LegacyPartitioning is the default partitioning, e.g. CCD/2023/1/5/14
+var LegacyPartitioning = Partitioning{
+	Template:      "{stream}/{year}/{m}/{d}",
+	ImageTemplate: "{stream}/{year}/{m}/{d}/{h}",
+}
+
+// HivePartitioning is partitioning as expected by Hive, Athena, Spark and
+// DuckDB, e.g. stream=CCD/year=2023/month=01/day=05/hour=14
+var HivePartitioning = Partitioning{
+	Template:      "stream={stream}/year={year}/month={month}/day={day}",
+	ImageTemplate: "stream={stream}/year={year}/month={month}/day={day}/hour={hour}",
+}
+
+var partitionPlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+var partitionValues = map[string]func(stream OutStream, t time.Time) string{
+	"stream": func(stream OutStream, t time.Time) string { return stream.String() },
+	"year":   func(stream OutStream, t time.Time) string { return fmt.Sprintf("%04d", t.Year()) },
+	"month":  func(stream OutStream, t time.Time) string { return fmt.Sprintf("%02d", int(t.Month())) },
+	"day":    func(stream OutStream, t time.Time) string { return fmt.Sprintf("%02d", t.Day()) },
+	"hour":   func(stream OutStream, t time.Time) string { return fmt.Sprintf("%02d", t.Hour()) },
+	"m":      func(stream OutStream, t time.Time) string { return fmt.Sprintf("%v", int(t.Month())) },
+	"d":      func(stream OutStream, t time.Time) string { return fmt.Sprintf("%v", t.Day()) },
+	"h":      func(stream OutStream, t time.Time) string { return fmt.Sprintf("%v", t.Hour()) },
+}
+
+// NewPartitioning returns the partitioning of the template, either "legacy",
+// "hive" or a template used for all streams
+func NewPartitioning(template string, key PartitionKey) (Partitioning, error) {
+	var partitioning Partitioning
+	switch strings.ToLower(template) {
+	case "", "legacy":
+		partitioning = LegacyPartitioning
+	case "hive":
+		partitioning = HivePartitioning
+	default:
+		partitioning = Partitioning{Template: template}
+	}
+	partitioning.Key = key
+	return partitioning, partitioning.Validate()
+}
+
+// Validate returns an error if the templates hold unknown placeholders or
+// are not relative paths
+func (partitioning Partitioning) Validate() error {
+	for _, template := range []string{partitioning.Template, partitioning.ImageTemplate} {
+		for _, match := range partitionPlaceholder.FindAllStringSubmatch(template, -1) {
+			if _, ok := partitionValues[match[1]]; !ok {
+				return fmt.Errorf("unknown placeholder {%v} in partition template '%v'", match[1], template)
+			}
+		}
+		if path.IsAbs(template) || strings.HasPrefix(path.Clean(template), "..") {
+			return fmt.Errorf("partition template '%v' must be a relative path", template)
+		}
+	}
+	return nil
+}
+
+// Prefix returns the directories of the record in the stream
+func (partitioning Partitioning) Prefix(pkg *common.DataRecord, stream OutStream) string {
+	return partitioning.PrefixAt(stream, partitioning.Key.Time(pkg))
+}
+
+// PrefixAt returns the directories of records in the stream at the time
+func (partitioning Partitioning) PrefixAt(stream OutStream, t time.Time) string {
+	template := partitioning.Template
+	if stream == CCD && partitioning.ImageTemplate != "" {
+		template = partitioning.ImageTemplate
+	}
+	prefix := partitionPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		value, ok := partitionValues[strings.Trim(placeholder, "{}")]
+		if !ok {
+			return placeholder
+		}
+		return value(stream, t)
+	})
+	return filepath.FromSlash(prefix)
+}
+
+var activePartitioning = LegacyPartitioning
+var activePartitioningLock sync.RWMutex
+
+// SetPartitioning sets the partitioning used by ParquetName and ArrowName
+func SetPartitioning(partitioning Partitioning) {
+	activePartitioningLock.Lock()
+	defer activePartitioningLock.Unlock()
+	activePartitioning = partitioning
+}
+
+// ActivePartitioning returns the partitioning used by ParquetName and ArrowName
+func ActivePartitioning() Partitioning {
+	activePartitioningLock.RLock()
+	defer activePartitioningLock.RUnlock()
+	return activePartitioning
+}
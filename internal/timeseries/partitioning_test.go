@@ -0,0 +1,166 @@
+package timeseries
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/innosat"
+	"github.com/innosat-mats/rac-extract-payload/internal/ramses"
+)
+
+func TestPartitionKeyFromName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    PartitionKey
+		wantErr bool
+	}{
+		{"tm", TMHeaderTime, false},
+		{"Exposure", ExposureTime, false},
+		{"RAMSES", RamsesTime, false},
+		{"satellite", TMHeaderTime, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PartitionKeyFromName(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PartitionKeyFromName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("PartitionKeyFromName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartitionKey_Time(t *testing.T) {
+	tmTime := time.Date(1980, 1, 5, 23, 59, 42, 0, time.UTC)
+	exposure := time.Date(1980, 1, 6, 23, 59, 42, 0, time.UTC)
+	ramsesTime := time.Date(2023, 1, 5, 14, 0, 0, 0, time.UTC)
+	image := &aez.CCDImage{PackData: &aez.CCDImagePackData{EXPTS: 86400}}
+	tests := []struct {
+		name string
+		key  PartitionKey
+		pkg  common.DataRecord
+		want time.Time
+	}{
+		{"TM header time", TMHeaderTime, common.DataRecord{Data: image}, tmTime},
+		{"Exposure time of image", ExposureTime, common.DataRecord{Data: image}, exposure},
+		{"Exposure time of PM", ExposureTime, common.DataRecord{Data: &aez.PMData{EXPTS: 86400}}, exposure},
+		{"Exposure time falls back", ExposureTime, common.DataRecord{Data: &aez.HTR{}}, tmTime},
+		{
+			"Ramses time",
+			RamsesTime,
+			common.DataRecord{RamsesHeader: &ramses.Ramses{Date: 8405, Time: 14 * 3600 * 1000}},
+			ramsesTime,
+		},
+		{"Ramses time falls back", RamsesTime, common.DataRecord{}, tmTime},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.pkg.TMHeader = &innosat.TMHeader{}
+			if got := tt.key.Time(&tt.pkg); !got.Equal(tt.want) {
+				t.Errorf("PartitionKey.Time() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartitionKey_Column(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    PartitionKey
+		stream OutStream
+		want   string
+	}{
+		{"TM header time", TMHeaderTime, CCD, "TMHeaderTime"},
+		{"Exposure time of image", ExposureTime, CCD, "EXPDate"},
+		{"Exposure time of PM", ExposureTime, PM, "PMTime"},
+		{"Measurement time of STAT", ExposureTime, STAT, "STATTime"},
+		{"Exposure time falls back", ExposureTime, HTR, "TMHeaderTime"},
+		{"Ramses time", RamsesTime, HTR, "RamsesTime"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.key.Column(tt.stream); got != tt.want {
+				t.Errorf("PartitionKey.Column() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPartitioning(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     Partitioning
+		wantErr  bool
+	}{
+		{"Defaults to legacy", "", LegacyPartitioning, false},
+		{"Legacy", "legacy", LegacyPartitioning, false},
+		{"Hive", "HIVE", HivePartitioning, false},
+		{"Template", "{stream}/{year}-{month}", Partitioning{Template: "{stream}/{year}-{month}"}, false},
+		{"Unknown placeholder", "{stream}/{week}", Partitioning{Template: "{stream}/{week}"}, true},
+		{"Absolute path", "/{stream}", Partitioning{Template: "/{stream}"}, true},
+		{"Leaving output", "../{stream}", Partitioning{Template: "../{stream}"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewPartitioning(tt.template, RamsesTime)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewPartitioning() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			tt.want.Key = RamsesTime
+			if got != tt.want {
+				t.Errorf("NewPartitioning() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartitioning_Prefix(t *testing.T) {
+	pkg := common.DataRecord{
+		RamsesHeader: &ramses.Ramses{Date: 8405, Time: 14 * 3600 * 1000},
+		TMHeader:     &innosat.TMHeader{},
+	}
+	hive := HivePartitioning
+	hive.Key = RamsesTime
+	tests := []struct {
+		name         string
+		partitioning Partitioning
+		stream       OutStream
+		want         string
+	}{
+		{"Legacy", LegacyPartitioning, STAT, "STAT/1980/1/5"},
+		{"Legacy CCD", LegacyPartitioning, CCD, "CCD/1980/1/5/23"},
+		{"Hive", hive, STAT, "stream=STAT/year=2023/month=01/day=05"},
+		{"Hive CCD", hive, CCD, "stream=CCD/year=2023/month=01/day=05/hour=14"},
+		{"Template for all", Partitioning{Template: "{year}{month}{day}/{stream}"}, CCD, "19800105/CCD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.partitioning.Prefix(&pkg, tt.stream); got != filepath.FromSlash(tt.want) {
+				t.Errorf("Partitioning.Prefix() = %v, want %v", got, filepath.FromSlash(tt.want))
+			}
+		})
+	}
+}
+
+func TestSetPartitioning(t *testing.T) {
+	defer SetPartitioning(ActivePartitioning())
+	SetPartitioning(HivePartitioning)
+	pkg := common.DataRecord{
+		Origin:   &common.OriginDescription{Name: filepath.FromSlash("some/dir/test1.rac")},
+		TMHeader: &innosat.TMHeader{},
+		Data:     &aez.CCDImage{},
+	}
+	want := filepath.FromSlash("stream=CCD/year=1980/month=01/day=05/hour=23/test1.parquet")
+	if got := ParquetName(&pkg, CCD); got != want {
+		t.Errorf("ParquetName() = %v, want %v", got, want)
+	}
+	if got := ParquetNameAt(pkg.Origin.Name, CCD, pkg.TMHeader.Time(time.Time{})); got != want {
+		t.Errorf("ParquetNameAt() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,323 @@
+package timeseries
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
+
+	// Registers the sqlite3 database driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLiteTimeFormat is the format of times in SQLite databases, being fixed
+// width it sorts and compares as text
+const SQLiteTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// SQLiteCommitRows is the number of rows written per transaction
+const SQLiteCommitRows = 10000
+
+// sqliteKey are the columns identifying a record, so that re-running the
+// same rac file updates rather than duplicates rows
+var sqliteKey = []string{"OriginFile", "TMHeaderNanoseconds", "SPSequenceCount"}
+
+const sqliteRunsTable = `CREATE TABLE IF NOT EXISTS "runs" (
+	"RunID" INTEGER PRIMARY KEY AUTOINCREMENT,
+	"Started" TEXT,
+	"Finished" TEXT,
+	"Version" TEXT,
+	"Head" TEXT,
+	"Records" INTEGER
+)`
+
+const sqliteOriginsTable = `CREATE TABLE IF NOT EXISTS "origins" (
+	"OriginFile" TEXT PRIMARY KEY,
+	"ProcessingTime" TEXT,
+	"RunID" INTEGER REFERENCES "runs" ("RunID")
+)`
+
+// SQLite writes each out stream to its own table of a SQLite database along
+// with the processed origin files and processing runs
+type SQLite struct {
+	db         *sql.DB
+	tx         *sql.Tx
+	withImages bool
+	runID      int64
+	statements map[OutStream]*sql.Stmt
+	origins    map[string]bool
+	pending    int
+	records    int
+}
+
+// NewSQLite opens or creates the database and registers a new processing run,
+// if withImages the images are stored as PNG in the ImageData column
+func NewSQLite(path string, withImages bool) (*SQLite, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	for _, statement := range []string{sqliteRunsTable, sqliteOriginsTable} {
+		if _, err := db.Exec(statement); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("could not prepare %v: %v", path, err)
+		}
+	}
+	result, err := db.Exec(
+		`INSERT INTO "runs" ("Started", "Version", "Head", "Records") VALUES (?, ?, ?, 0)`,
+		time.Now().UTC().Format(SQLiteTimeFormat),
+		common.Version,
+		common.Head,
+	)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not register run in %v: %v", path, err)
+	}
+	runID, err := result.LastInsertId()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLite{
+		db:         db,
+		withImages: withImages,
+		runID:      runID,
+		statements: make(map[OutStream]*sql.Stmt),
+		origins:    make(map[string]bool),
+	}, nil
+}
+
+// SQLiteType returns the SQLite column type used for the column type
+func SQLiteType(columnType schema.Type) string {
+	switch columnType {
+	case schema.Bool, schema.Int32, schema.Int64:
+		return "INTEGER"
+	case schema.Double:
+		return "REAL"
+	case schema.Bytes:
+		return "BLOB"
+	default:
+		return "TEXT"
+	}
+}
+
+// SQLiteValue returns the value as stored in SQLite
+//
+// Times are texts in SQLiteTimeFormat and lists are json arrays.
+func SQLiteValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		if math.IsNaN(v) {
+			return nil
+		}
+	case time.Time:
+		return v.UTC().Format(SQLiteTimeFormat)
+	case []int64, []string:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		return string(encoded)
+	}
+	return value
+}
+
+func quote(name string) string {
+	return fmt.Sprintf(`"%v"`, strings.ReplaceAll(name, `"`, `""`))
+}
+
+func sqliteKeyColumns(stream OutStream) []string {
+	if stream == ALARMS {
+		return append(append([]string{}, sqliteKey...), "AlarmField")
+	}
+	return sqliteKey
+}
+
+// prepare creates or extends the table of the stream and returns the upsert
+// statement
+func (db *SQLite) prepare(stream OutStream) (*sql.Stmt, error) {
+	table := quote(stream.String())
+	columns := stream.Columns()
+	var definitions []string
+	for _, column := range columns {
+		definitions = append(definitions, fmt.Sprintf("%v %v", quote(column.Name), SQLiteType(column.Type)))
+	}
+	var keys []string
+	for _, key := range sqliteKeyColumns(stream) {
+		keys = append(keys, quote(key))
+	}
+	_, err := db.tx.Exec(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %v (\n\t%v,\n\tUNIQUE (%v)\n)",
+		table,
+		strings.Join(definitions, ",\n\t"),
+		strings.Join(keys, ", "),
+	))
+	if err != nil {
+		return nil, err
+	}
+	if err = db.addMissingColumns(table, columns); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	updates := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = quote(column.Name)
+		placeholders[i] = "?"
+		updates[i] = fmt.Sprintf("%v = excluded.%v", names[i], names[i])
+	}
+	return db.tx.Prepare(fmt.Sprintf(
+		"INSERT INTO %v (%v) VALUES (%v) ON CONFLICT (%v) DO UPDATE SET %v",
+		table,
+		strings.Join(names, ", "),
+		strings.Join(placeholders, ", "),
+		strings.Join(keys, ", "),
+		strings.Join(updates, ", "),
+	))
+}
+
+// addMissingColumns adds columns introduced since the table was created
+func (db *SQLite) addMissingColumns(table string, columns []schema.Column) error {
+	rows, err := db.tx.Query(fmt.Sprintf("PRAGMA table_info(%v)", table))
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, primaryKey int
+		var name, columnType string
+		var defaultValue interface{}
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &primaryKey); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	for _, column := range columns {
+		if existing[column.Name] {
+			continue
+		}
+		_, err := db.tx.Exec(fmt.Sprintf(
+			"ALTER TABLE %v ADD COLUMN %v %v",
+			table,
+			quote(column.Name),
+			SQLiteType(column.Type),
+		))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *SQLite) begin() error {
+	if db.tx != nil {
+		return nil
+	}
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	db.tx = tx
+	return nil
+}
+
+// commit ends the current transaction, prepared statements belong to it
+func (db *SQLite) commit() error {
+	if db.tx == nil {
+		return nil
+	}
+	err := db.tx.Commit()
+	db.tx = nil
+	db.statements = make(map[OutStream]*sql.Stmt)
+	db.pending = 0
+	return err
+}
+
+func (db *SQLite) registerOrigin(pkg *common.DataRecord) error {
+	if pkg.Origin == nil || db.origins[pkg.Origin.Name] {
+		return nil
+	}
+	columns := pkg.Origin.Columns()
+	values := pkg.Origin.Values()
+	_, err := db.tx.Exec(
+		`INSERT INTO "origins" ("OriginFile", "ProcessingTime", "RunID") VALUES (?, ?, ?)
+		ON CONFLICT ("OriginFile") DO UPDATE SET
+			"ProcessingTime" = excluded."ProcessingTime", "RunID" = excluded."RunID"`,
+		SQLiteValue(values[schema.Index(columns, "OriginFile")]),
+		SQLiteValue(values[schema.Index(columns, "ProcessingTime")]),
+		db.runID,
+	)
+	if err == nil {
+		db.origins[pkg.Origin.Name] = true
+	}
+	return err
+}
+
+// Write upserts the record into the table of its out stream
+func (db *SQLite) Write(pkg *common.DataRecord) error {
+	stream := OutStreamFromDataRecord(pkg)
+	if stream == Unknown {
+		log.Printf("Unknown timeseries stream RID %v, SID %v", pkg.RID, pkg.SID)
+		return nil
+	}
+	if err := db.begin(); err != nil {
+		return err
+	}
+	if err := db.registerOrigin(pkg); err != nil {
+		return err
+	}
+	statement, ok := db.statements[stream]
+	if !ok {
+		var err error
+		statement, err = db.prepare(stream)
+		if err != nil {
+			return fmt.Errorf("could not prepare table %v: %v", stream, err)
+		}
+		db.statements[stream] = statement
+	}
+	var values []interface{}
+	if db.withImages {
+		values = GetParquetRow(pkg).Values
+	} else {
+		values = pkg.Values()
+	}
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		args[i] = SQLiteValue(value)
+	}
+	if _, err := statement.Exec(args...); err != nil {
+		return err
+	}
+	db.records++
+	db.pending++
+	if db.pending >= SQLiteCommitRows {
+		return db.commit()
+	}
+	return nil
+}
+
+// Close commits remaining records, completes the run and closes the database
+func (db *SQLite) Close() error {
+	err := db.commit()
+	if err == nil {
+		_, err = db.db.Exec(
+			`UPDATE "runs" SET "Finished" = ?, "Records" = ? WHERE "RunID" = ?`,
+			time.Now().UTC().Format(SQLiteTimeFormat),
+			db.records,
+			db.runID,
+		)
+	}
+	closeErr := db.db.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
@@ -0,0 +1,199 @@
+package timeseries
+
+import (
+	"database/sql"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/innosat-mats/rac-extract-payload/internal/aez"
+	"github.com/innosat-mats/rac-extract-payload/internal/common"
+	"github.com/innosat-mats/rac-extract-payload/internal/innosat"
+	"github.com/innosat-mats/rac-extract-payload/internal/limits"
+	"github.com/innosat-mats/rac-extract-payload/internal/schema"
+)
+
+func TestSQLiteType(t *testing.T) {
+	tests := []struct {
+		columnType schema.Type
+		want       string
+	}{
+		{schema.Bool, "INTEGER"},
+		{schema.Int32, "INTEGER"},
+		{schema.Int64, "INTEGER"},
+		{schema.Double, "REAL"},
+		{schema.Bytes, "BLOB"},
+		{schema.String, "TEXT"},
+		{schema.Timestamp, "TEXT"},
+		{schema.IntList, "TEXT"},
+		{schema.StringList, "TEXT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := SQLiteType(tt.columnType); got != tt.want {
+				t.Errorf("SQLiteType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLiteValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+	}{
+		{"Keeps integers", int64(42), int64(42)},
+		{"Keeps floats", 4.2, 4.2},
+		{"NaN is null", math.NaN(), nil},
+		{"Keeps null", nil, nil},
+		{
+			"Time as fixed width text",
+			time.Date(2022, 11, 1, 12, 0, 0, 5, time.FixedZone("CET", 3600)),
+			"2022-11-01T11:00:00.000000005Z",
+		},
+		{"Int list as json", []int64{1, 2}, "[1,2]"},
+		{"String list as json", []string{"a"}, `["a"]`},
+		{"Empty list as json", []string{}, "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SQLiteValue(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SQLiteValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func countRows(t *testing.T, db *sql.DB, table string) int {
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM "` + table + `"`).Scan(&count); err != nil {
+		t.Fatalf("could not count %v: %v", table, err)
+	}
+	return count
+}
+
+func TestSQLite_Write(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rac.db")
+	records := []common.DataRecord{
+		{
+			Origin:       &common.OriginDescription{Name: "File1.rac"},
+			SourceHeader: &innosat.SourcePacketHeader{PacketSequenceControl: 1},
+			TMHeader:     &innosat.TMHeader{},
+			Data:         &aez.STAT{MODE: 1},
+		},
+		{
+			Origin:       &common.OriginDescription{Name: "File1.rac"},
+			SourceHeader: &innosat.SourcePacketHeader{PacketSequenceControl: 2},
+			TMHeader:     &innosat.TMHeader{},
+			Data:         &aez.STAT{MODE: 2},
+		},
+		{
+			Origin:       &common.OriginDescription{Name: "File1.rac"},
+			SourceHeader: &innosat.SourcePacketHeader{PacketSequenceControl: 2},
+			TMHeader:     &innosat.TMHeader{},
+			Data:         &limits.Alarm{AlarmField: "HTR1A"},
+		},
+		{
+			Origin:       &common.OriginDescription{Name: "File1.rac"},
+			SourceHeader: &innosat.SourcePacketHeader{PacketSequenceControl: 2},
+			TMHeader:     &innosat.TMHeader{},
+			Data:         &limits.Alarm{AlarmField: "HTR1B"},
+		},
+	}
+	// Processing the same file twice should replace rather than add rows
+	for run := 0; run < 2; run++ {
+		db, err := NewSQLite(path, false)
+		if err != nil {
+			t.Fatalf("NewSQLite() error = %v", err)
+		}
+		for i := range records {
+			if err := db.Write(&records[i]); err != nil {
+				t.Errorf("SQLite.Write() error = %v", err)
+			}
+		}
+		if err := db.Close(); err != nil {
+			t.Errorf("SQLite.Close() error = %v", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tests := []struct {
+		table string
+		want  int
+	}{
+		{"STAT", 2},
+		{"ALARMS", 2},
+		{"origins", 1},
+		{"runs", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.table, func(t *testing.T) {
+			if got := countRows(t, db, tt.table); got != tt.want {
+				t.Errorf("SQLite wrote %v rows to %v, want %v", got, tt.table, tt.want)
+			}
+		})
+	}
+
+	var mode int
+	var tmHeaderTime string
+	err = db.QueryRow(`SELECT "MODE", "TMHeaderTime" FROM "STAT" WHERE "SPSequenceCount" = 2`).Scan(&mode, &tmHeaderTime)
+	if err != nil {
+		t.Fatalf("could not read STAT: %v", err)
+	}
+	if mode != 2 || tmHeaderTime != "1980-01-05T23:59:42.000000000Z" {
+		t.Errorf("SQLite wrote STAT MODE %v at %v", mode, tmHeaderTime)
+	}
+	var runRecords int
+	err = db.QueryRow(`SELECT "Records" FROM "runs" WHERE "Finished" IS NOT NULL ORDER BY "RunID" DESC`).Scan(&runRecords)
+	if err != nil || runRecords != len(records) {
+		t.Errorf("SQLite registered run with %v records (%v), want %v", runRecords, err, len(records))
+	}
+}
+
+func TestSQLite_Write_AddsMissingColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rac.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`CREATE TABLE "HTR" ("OriginFile" TEXT, "TMHeaderNanoseconds" INTEGER, "SPSequenceCount" INTEGER,
+		UNIQUE ("OriginFile", "TMHeaderNanoseconds", "SPSequenceCount"))`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlite, err := NewSQLite(path, false)
+	if err != nil {
+		t.Fatalf("NewSQLite() error = %v", err)
+	}
+	pkg := common.DataRecord{
+		Origin:       &common.OriginDescription{Name: "File1.rac"},
+		SourceHeader: &innosat.SourcePacketHeader{},
+		TMHeader:     &innosat.TMHeader{},
+		Data:         &aez.HTR{},
+	}
+	if err := sqlite.Write(&pkg); err != nil {
+		t.Errorf("SQLite.Write() error = %v", err)
+	}
+	if err := sqlite.Close(); err != nil {
+		t.Errorf("SQLite.Close() error = %v", err)
+	}
+
+	db, err = sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var htr1a sql.NullFloat64
+	if err := db.QueryRow(`SELECT "HTR1A" FROM "HTR"`).Scan(&htr1a); err != nil {
+		t.Errorf("SQLite didn't add HTR1A column: %v", err)
+	}
+}